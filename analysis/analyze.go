@@ -0,0 +1,55 @@
+package analysis
+
+import (
+	"context"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// Options configures Analyze and Summarize.
+type Options struct {
+	// Exclude skips files and directories whose name, or slash-separated
+	// path below the analyzed directory, matches one of these path.Match
+	// patterns, such as "vendor" or "*_gen.go".
+	Exclude []string
+
+	// EntryPoints are the functions dead code detection starts from, in
+	// addition to exported functions. DefaultEntryPoints are used if empty.
+	EntryPoints []string
+
+	// Thresholds classifies functions in summaries. Fields left zero use
+	// their DefaultComplexityThresholds value.
+	Thresholds ComplexityThresholds
+
+	IncludeClosures   bool // report function literals as separate functions
+	Locals            bool // report unused and shadowed local variables
+	SkipNestedModules bool // skip nested modules
+	Strict            bool // fail on the first file that cannot be parsed
+}
+
+// Analyze analyzes the Go files under dir and returns the report. It is the
+// entry point for embedding surrealcode as a library: it neither connects
+// to a database nor writes any output.
+func Analyze(ctx context.Context, dir string, opts Options) (surrealtypes.AnalysisReport, error) {
+	return newLibraryAnalyzer(opts).GetAnalysis(ctx, dir)
+}
+
+// Summarize summarizes a report returned by Analyze using the thresholds in
+// opts.
+func Summarize(report surrealtypes.AnalysisReport, opts Options) surrealtypes.CodeSummary {
+	return newLibraryAnalyzer(opts).GenerateCodeSummary(report)
+}
+
+// newLibraryAnalyzer returns an analyzer without a database or progress
+// handler, configured by opts.
+func newLibraryAnalyzer(opts Options) *Analyzer {
+	a := NewAnalyzerWithoutDB()
+	a.Exclude = opts.Exclude
+	a.EntryPoints = opts.EntryPoints
+	a.IncludeClosures = opts.IncludeClosures
+	a.Locals = opts.Locals
+	a.SkipNestedModules = opts.SkipNestedModules
+	a.Strict = opts.Strict
+	a.Metrics.Thresholds = opts.Thresholds.withDefaults()
+	return a
+}
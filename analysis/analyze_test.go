@@ -0,0 +1,73 @@
+package analysis_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyze(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+		func run() { helper() }
+		func helper() {}
+		func orphan() {}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "zz_gen.go"), []byte(`package main
+		func generated() {}`), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "vendor"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "vendor", "dep.go"), []byte(`package dep
+		func vendored() {}`), 0644))
+
+	opts := analysis.Options{
+		Exclude:     []string{"vendor", "*_gen.go"},
+		EntryPoints: []string{"run"},
+	}
+
+	// Capture stdout and the standard logger to check that nothing is written.
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+
+	report, err := analysis.Analyze(context.Background(), dir, opts)
+	runtime.GC() // run cleanups registered by the analysis
+
+	os.Stdout = stdout
+	log.SetOutput(os.Stderr)
+	require.NoError(t, w.Close())
+	printed, _ := io.ReadAll(r)
+	require.NoError(t, err)
+
+	assert.Empty(t, string(printed))
+	assert.Empty(t, logged.String())
+
+	unused := map[string]bool{}
+	for _, fn := range report.Functions {
+		unused[fn.Caller] = fn.Metrics.IsUnused
+	}
+	assert.Equal(t, map[string]bool{"run": false, "helper": false, "orphan": true}, unused)
+
+	// Thresholds left zero keep their defaults.
+	summary := analysis.Summarize(report, analysis.Options{
+		Thresholds: analysis.ComplexityThresholds{MaxComplexity: 10},
+	})
+	assert.Equal(t, map[string]int{"Low": 3}, summary.ComplexityDistribution)
+	assert.Empty(t, summary.Hotspots)
+	assert.Equal(t, 0, summary.MaxCallDepth) // main and complex are not declared
+	assert.Equal(t, map[string]int{"Low": 3}, analysis.Summarize(report, analysis.Options{}).ComplexityDistribution)
+
+	summary = analysis.Summarize(report, opts)
+	assert.Equal(t, 2, summary.MaxCallDepth)
+	assert.Equal(t, []string{"main.run", "main.helper"}, summary.DeepestCallPath)
+}
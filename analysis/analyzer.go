@@ -1,21 +1,30 @@
 package analysis
 
 import (
+	"cmp"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"go/ast"
-	"go/importer"
 	"go/parser"
+	"go/printer"
 	"go/token"
 	"go/types"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
 	"math"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
 	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 	"unicode"
 
 	"github.com/TFMV/surrealcode/db"
@@ -33,16 +42,157 @@ type Analyzer struct {
 	ExprCache *expr.ExprCache
 	Metrics   *MetricsAnalyzer
 	Report    surrealtypes.AnalysisReport
+
+	// IncludeClosures reports function literals as separate functions.
+	IncludeClosures bool
+
+	// Exclude skips files and directories whose name, or slash-separated
+	// path below the analyzed directory, matches one of these path.Match
+	// patterns.
+	Exclude []string
+
+	// EntryPoints are the functions dead code detection starts from, in
+	// addition to exported functions. DefaultEntryPoints are used if empty.
+	EntryPoints []string
+
+	// Progress, if set, is called as the analysis proceeds.
+	Progress func(event ProgressEvent)
+
+	// Logger, if set, logs the progress of the analysis at Info level and
+	// files that were skipped or not type checked at Warn level. Nothing
+	// is logged by default.
+	Logger *slog.Logger
+
+	// SkipNestedModules skips nested modules, i.e. subdirectories with their
+	// own go.mod, which are analyzed by default.
+	SkipNestedModules bool
+
+	// SeparateTests reports the functions of _test.go files in their own
+	// section of code summaries instead of mixing them with production code.
+	SeparateTests bool
+
+	// Locals reports unused and shadowed local variables in the report's
+	// Findings. It needs type information, so files that fail to type check
+	// have no findings.
+	Locals bool
+
+	// IncludeExternalCalls records the calls of each function to functions
+	// and methods of other packages in its ExternalCalls. Otherwise they
+	// are left out of the call graph.
+	IncludeExternalCalls bool
+
+	// Codeowners, if set, attaches the owners of each function's file to
+	// its Owners.
+	Codeowners *Codeowners
+
+	// Rules, if set, labels the functions matching them.
+	Rules *Rules
+
+	// Strict fails the analysis on the first file that cannot be parsed
+	// instead of recording it in the report's FileErrors and moving on.
+	Strict bool
+
+	// Partial makes an analysis whose context is done before every file
+	// was analyzed return the report of the files analyzed so far, with the
+	// others in its FileErrors, along with the context's error. The report
+	// is also kept in Report. Otherwise only the error is returned.
+	Partial bool
+
+	// timings, if set, accumulates the time spent parsing and type checking
+	// files during TimedAnalysis.
+	timings *Timings
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // MetricsAnalyzer handles all metrics computation.
 type MetricsAnalyzer struct {
+	// Thresholds classifies functions in code summaries and flags hotspots.
+	Thresholds ComplexityThresholds
+
+	// HotspotOrder ranks the hotspots of code summaries, by complexity
+	// unless set, and TopHotspots keeps only the first so many of them, or
+	// all if zero.
+	HotspotOrder string
+	TopHotspots  int
+
 	duplicationDetector *CodeDuplicationDetector
 }
 
+// Hotspot rankings for MetricsAnalyzer.HotspotOrder.
+const (
+	HotspotsByComplexity      = "complexity"      // highest cyclomatic complexity first
+	HotspotsByMaintainability = "maintainability" // lowest maintainability index first
+	HotspotsByCognitive       = "cognitive"       // highest cognitive complexity first
+	HotspotsByLines           = "loc"             // most lines of code first
+)
+
+// ComplexityThresholds sets the complexity bands and the limits beyond
+// which a function is a hotspot, in code summaries and the health score.
+type ComplexityThresholds struct {
+	LowComplexity    int // highest cyclomatic complexity in the Low band
+	MediumComplexity int // highest cyclomatic complexity in the Medium band
+
+	MaxComplexity      int     // cyclomatic complexity
+	MaxNestingDepth    int     // nesting depth
+	MinMaintainability float64 // maintainability index
+	MaxCognitive       int     // cognitive complexity
+	MaxParams          int     // parameters
+	MaxStatements      int     // statements
+}
+
+// DefaultComplexityThresholds returns the thresholds used unless configured
+// otherwise.
+func DefaultComplexityThresholds() ComplexityThresholds {
+	return ComplexityThresholds{
+		LowComplexity:      5,
+		MediumComplexity:   10,
+		MaxComplexity:      10,
+		MaxNestingDepth:    4,
+		MinMaintainability: 50,
+		MaxCognitive:       15,
+		MaxParams:          5,
+		MaxStatements:      40,
+	}
+}
+
+// withDefaults returns t with its zero fields set to their default, so
+// callers may set only the thresholds they care about.
+func (t ComplexityThresholds) withDefaults() ComplexityThresholds {
+	d := DefaultComplexityThresholds()
+	return ComplexityThresholds{
+		LowComplexity:      cmp.Or(t.LowComplexity, d.LowComplexity),
+		MediumComplexity:   cmp.Or(t.MediumComplexity, d.MediumComplexity),
+		MaxComplexity:      cmp.Or(t.MaxComplexity, d.MaxComplexity),
+		MaxNestingDepth:    cmp.Or(t.MaxNestingDepth, d.MaxNestingDepth),
+		MinMaintainability: cmp.Or(t.MinMaintainability, d.MinMaintainability),
+		MaxCognitive:       cmp.Or(t.MaxCognitive, d.MaxCognitive),
+		MaxParams:          cmp.Or(t.MaxParams, d.MaxParams),
+		MaxStatements:      cmp.Or(t.MaxStatements, d.MaxStatements),
+	}
+}
+
+// band returns the complexity band, Low, Medium or High, of complexity.
+func (t ComplexityThresholds) band(complexity int) string {
+	switch {
+	case complexity <= t.LowComplexity:
+		return "Low"
+	case complexity <= t.MediumComplexity:
+		return "Medium"
+	default:
+		return "High"
+	}
+}
+
+// DefaultEntryPoints are the entry points of dead code detection unless
+// configured otherwise.
+var DefaultEntryPoints = []string{"main", "complex"}
+
 // NewMetricsAnalyzer creates a new metrics analyzer.
 func NewMetricsAnalyzer() *MetricsAnalyzer {
 	return &MetricsAnalyzer{
+		Thresholds:          DefaultComplexityThresholds(),
 		duplicationDetector: NewCodeDuplicationDetector(),
 	}
 }
@@ -60,9 +210,10 @@ func NewAnalyzer(config db.Config) (*Analyzer, error) {
 		Metrics:   NewMetricsAnalyzer(),
 	}
 
-	// Add cleanup for database connection
+	// Add cleanup for database connection as a safety net; callers should
+	// still Close the analyzer explicitly.
 	runtime.AddCleanup(analyzer, func(db db.DB) {
-		if closer, ok := db.(interface{ Close() error }); ok {
+		if closer, ok := db.(io.Closer); ok {
 			closer.Close()
 		}
 	}, analyzer.DB)
@@ -70,6 +221,13 @@ func NewAnalyzer(config db.Config) (*Analyzer, error) {
 	return analyzer, nil
 }
 
+// NewAnalyzerWithDB creates an analyzer that stores results in the given backend.
+func NewAnalyzerWithDB(store db.DB) *Analyzer {
+	analyzer := NewAnalyzerWithoutDB()
+	analyzer.DB = store
+	return analyzer
+}
+
 // NewAnalyzerWithoutDB creates an analyzer without a database connection.
 func NewAnalyzerWithoutDB() *Analyzer {
 	cache := expr.NewExprCache(10000)
@@ -84,6 +242,17 @@ func (a *Analyzer) Initialize(ctx context.Context) error {
 	return a.DB.Initialize(ctx)
 }
 
+// Close releases the analyzer's database connection, if any. It is safe to
+// call more than once.
+func (a *Analyzer) Close() error {
+	a.closeOnce.Do(func() {
+		if closer, ok := a.DB.(io.Closer); ok {
+			a.closeErr = closer.Close()
+		}
+	})
+	return a.closeErr
+}
+
 // -----------------------------------------------------------------------------
 // File Analysis (Formerly in parser.go)
 // -----------------------------------------------------------------------------
@@ -93,9 +262,29 @@ type FileAnalysis struct {
 	Functions  []surrealtypes.FunctionCall
 	Structs    []surrealtypes.StructDefinition
 	Interfaces []surrealtypes.InterfaceDefinition
+	Types      []surrealtypes.TypeDefinition
 	Globals    []surrealtypes.GlobalVariable
 	Imports    []surrealtypes.ImportDefinition
 	Implements []surrealtypes.InterfaceImplementation
+	Findings   []surrealtypes.Finding
+	// LeakSuspects lists the resources opened and never closed, in source
+	// order.
+	LeakSuspects []surrealtypes.LeakSuspect
+	// InitActions lists the variable initializers calling functions, then
+	// the init functions, in source order.
+	InitActions []surrealtypes.InitAction
+
+	// unresolved holds, per function, identifiers not declared in the file.
+	unresolved map[string][]string
+	// calledMethods holds the names of the methods called in the file.
+	calledMethods map[string]bool
+	// anonymousTypes holds each use of an anonymous type in the file.
+	anonymousTypes []surrealtypes.AnonymousType
+	// discards holds, per function, the calls discarding results.
+	discards map[string][]discardedCall
+	// uses holds the imports and package-level names referenced anywhere in
+	// the file, keyed as DetectUnusedDeclarations expects.
+	uses map[string]bool
 }
 
 type HalsteadMetrics struct {
@@ -111,22 +300,193 @@ type HalsteadMetrics struct {
 // AnalyzeFile parses and analyzes a single file.
 // This method merges the logic formerly in your SurrealParser.
 func (a *Analyzer) AnalyzeFile(path string) (FileAnalysis, error) {
+	return a.analyzeFile(path, nil)
+}
+
+// AnalyzeSource analyzes src as the Go file filename, which need not exist,
+// without walking directories or storing anything.
+func (a *Analyzer) AnalyzeSource(filename string, src []byte) (FileAnalysis, error) {
+	if src == nil {
+		src = []byte{}
+	}
+	return a.analyzeFile(filename, src)
+}
+
+// fieldCount returns the number of fields in fields, counting each name of
+// a field list such as "x, y int" and each embedded field.
+func fieldCount(fields *ast.FieldList) int {
+	n := 0
+	for _, field := range fields.List {
+		n += max(len(field.Names), 1)
+	}
+	return n
+}
+
+// sourceFile is a Go file to analyze: name is its path within fsys and path
+// the path it is reported under.
+type sourceFile struct {
+	fsys fs.FS
+	name string
+	path string
+
+	pkgPath string // import path of the file's package, "" outside a module
+}
+
+// analyzeSource analyzes f, type checked with the other files of its
+// package in cache.
+func (a *Analyzer) analyzeSource(f sourceFile, cache *packageCache) (FileAnalysis, error) {
+	loaded := a.loadFile(f, cache)
+	if loaded.err != nil {
+		return FileAnalysis{}, loaded.err
+	}
+	return a.analyzeChecked(f.path, loaded.file, loaded.pkg), nil
+}
+
+// analyzeFile analyzes the file at path on its own, reading it from disk if
+// src is nil. Symbols of other files of its package stay unresolved.
+func (a *Analyzer) analyzeFile(path string, src []byte) (FileAnalysis, error) {
 	fset := token.NewFileSet()
 
-	// Parse file using go/parser.
-	file, err := parser.ParseFile(fset, path, nil, parser.AllErrors)
+	// Parse file using go/parser. A nil []byte is not a nil source, so only
+	// pass src along when it is set.
+	var source any
+	if src != nil {
+		source = src
+	}
+	start := time.Now()
+	file, err := parser.ParseFile(fset, path, source, parser.AllErrors|parser.ParseComments)
+	if a.timings != nil {
+		a.timings.Parse += time.Since(start)
+	}
 	if err != nil {
 		return FileAnalysis{}, fmt.Errorf("failed to parse %s: %w", path, err)
 	}
+	pkg := &checkedPackage{name: file.Name.Name, fset: fset, paths: []string{path}, files: []*ast.File{file}}
+	a.checkPackage(pkg, newImportCache())
+	return a.analyzeChecked(path, file, pkg), nil
+}
+
+// loadFile returns f parsed, loading the files of its directory into cache
+// first if they are not loaded yet, and type checks its package unless
+// another of its files did.
+func (a *Analyzer) loadFile(f sourceFile, cache *packageCache) loadedFile {
+	loaded, ok := cache.files[f.path]
+	if !ok {
+		a.loadDir(cache, filepath.Dir(f.path))
+		loaded = cache.files[f.path]
+	}
+	delete(cache.files, f.path)
+	if loaded.err != nil {
+		return loaded
+	}
+	if loaded.pkg.checked {
+		cache.hits++
+	} else {
+		cache.misses++
+		a.checkPackage(loaded.pkg, cache.imports)
+	}
+	return loaded
+}
+
+// loadDir reads and parses the files of cache in dir, grouping those the
+// default build context compiles into packages by package name.
+func (a *Analyzer) loadDir(cache *packageCache, dir string) {
+	fset := token.NewFileSet()
+	packages := make(map[string]*checkedPackage)
+	for _, f := range cache.dirs[dir] {
+		start := time.Now()
+		var file *ast.File
+		src, err := fs.ReadFile(f.fsys, f.name)
+		if err != nil {
+			err = fmt.Errorf("failed to read %s: %w", f.path, err)
+		} else if file, err = parser.ParseFile(fset, f.path, src, parser.AllErrors|parser.ParseComments); err != nil {
+			err = fmt.Errorf("failed to parse %s: %w", f.path, err)
+		}
+		if a.timings != nil {
+			a.timings.Parse += time.Since(start)
+		}
+		if err != nil {
+			cache.files[f.path] = loadedFile{err: err}
+			continue
+		}
+		pkg := &checkedPackage{name: file.Name.Name, fset: fset}
+		if buildable(f.path, src) {
+			if shared, ok := packages[pkg.name]; ok {
+				pkg = shared
+			} else {
+				packages[pkg.name] = pkg
+			}
+		}
+		pkg.paths = append(pkg.paths, f.path)
+		pkg.files = append(pkg.files, file)
+		cache.files[f.path] = loadedFile{file: file, pkg: pkg}
+	}
+	delete(cache.dirs, dir)
+}
+
+// checkPackage type checks the files of pkg together, importing packages
+// through imports. Even when it fails, the imports it managed to load are
+// recorded in info.Implicits. Soft errors, such as unused variables, leave
+// the type information complete, so only hard errors are kept, each for the
+// file it occurs in.
+func (a *Analyzer) checkPackage(pkg *checkedPackage, imports *importCache) {
+	pkg.checked = true
+	pkg.errs = make(map[string]error)
+	conf := types.Config{
+		Importer: imports,
+		Error: func(err error) {
+			terr, ok := err.(types.Error)
+			if ok && terr.Soft {
+				return
+			}
+			paths := pkg.paths
+			if ok && terr.Pos.IsValid() {
+				paths = []string{terr.Fset.Position(terr.Pos).Filename}
+			}
+			for _, path := range paths {
+				if pkg.errs[path] == nil {
+					pkg.errs[path] = err
+				}
+			}
+		},
+	}
+	pkg.info = &types.Info{
+		Types:     make(map[ast.Expr]types.TypeAndValue),
+		Defs:      make(map[*ast.Ident]types.Object),
+		Uses:      make(map[*ast.Ident]types.Object),
+		Implicits: make(map[ast.Node]types.Object),
+	}
+	start := time.Now()
+	pkg.pkg, _ = conf.Check(pkg.name, pkg.fset, pkg.files, pkg.info)
+	if a.timings != nil {
+		a.timings.TypeCheck += time.Since(start)
+	}
+}
+
+// analyzeChecked analyzes file, which is at path, using the type
+// information of its package.
+func (a *Analyzer) analyzeChecked(path string, file *ast.File, pkg *checkedPackage) FileAnalysis {
+	fset := pkg.fset
 	pkgName := file.Name.Name
 
 	var functions []surrealtypes.FunctionCall
 	var structs []surrealtypes.StructDefinition
 	var interfaces []surrealtypes.InterfaceDefinition
+	var namedTypes []surrealtypes.TypeDefinition
 	var globals []surrealtypes.GlobalVariable
+	var anonymous []surrealtypes.AnonymousType
 	var imports []surrealtypes.ImportDefinition
 	var implements []surrealtypes.InterfaceImplementation
 
+	// Function declarations, parallel to functions.
+	var funcDecls []*ast.FuncDecl
+	// Import specs, parallel to imports.
+	var importSpecs []*ast.ImportSpec
+	// Package-level variable specs with values, checked for calls once
+	// type information is available.
+	var varSpecs []*ast.ValueSpec
+	var initActions []surrealtypes.InitAction
+
 	// Maps for type checking.
 	structIdents := make(map[string]*ast.Ident)
 	ifaceIdents := make(map[string]*ast.Ident)
@@ -138,86 +498,97 @@ func (a *Analyzer) AnalyzeFile(path string) (FileAnalysis, error) {
 			// Build full function/method name.
 			methodName := d.Name.Name
 			if d.Recv != nil && len(d.Recv.List) > 0 {
-				recvType := simpleTypeString(d.Recv.List[0].Type)
+				recvType, pointer := receiverType(d.Recv.List[0].Type)
+				if pointer {
+					recvType = "*" + recvType
+				}
 				methodName = fmt.Sprintf("%s.%s", recvType, d.Name.Name)
+			} else if methodName == "init" {
+				// A package may have any number of init functions, so
+				// they are named by file and position in the file.
+				methodName = initFunctionName(path, len(initActions)+1)
+				initActions = append(initActions, surrealtypes.InitAction{
+					Name:    methodName,
+					Package: pkgName,
+					File:    path,
+					Line:    fset.Position(d.Pos()).Line,
+					Kind:    surrealtypes.InitFunction,
+				})
 			}
 			fn := surrealtypes.FunctionCall{
 				Caller:            methodName,
 				File:              path,
 				Package:           pkgName,
+				IsTest:            isTestFile(path),
 				Params:            []string{},
 				Returns:           []string{},
 				Callees:           []string{},
 				ReferencedGlobals: []string{},
 				Dependencies:      []string{},
+				Doc:               d.Doc.Text(),
 			}
-			// Extract parameter types.
-			if d.Type.Params != nil {
-				for _, param := range d.Type.Params.List {
-					fn.Params = append(fn.Params, simpleTypeString(param.Type))
-				}
-			}
-			// Extract return types.
-			if d.Type.Results != nil {
-				for _, ret := range d.Type.Results.List {
-					fn.Returns = append(fn.Returns, simpleTypeString(ret.Type))
-				}
-			}
+			fn.Ignored, fn.IgnoredChecks = ignoreDirective(d.Doc)
+			// Extract parameter and return types.
+			fn.Params = append(fn.Params, fieldTypes(d.Type.Params)...)
+			fn.Returns = append(fn.Returns, fieldTypes(d.Type.Results)...)
+			fn.ParamCount, fn.ReturnCount = len(fn.Params), len(fn.Returns)
+			fn.IsVariadic = isVariadic(d.Type)
+			fn.ReturnNames = fieldNames(d.Type.Results)
+			// Calls of closures stored in local variables are not callees,
+			// so recursion through them is detected here.
+			fn.IsRecursive = len(recursiveClosures(d.Body)) > 0
+			signatureTypes := signatureAnonymousTypes(d.Type)
+			fn.UsesAnonymousTypes = len(signatureTypes) > 0
+			anonymous = append(anonymous, signatureTypes...)
 			if d.Recv != nil {
 				fn.IsMethod = true
 				if len(d.Recv.List) > 0 {
-					fn.Struct = simpleTypeString(d.Recv.List[0].Type)
+					fn.Struct, fn.PointerReceiver = receiverType(d.Recv.List[0].Type)
 				}
 			}
-			// Track globals and dependencies via a simple AST inspection.
-			ast.Inspect(d.Body, func(n ast.Node) bool {
-				switch node := n.(type) {
-				case *ast.SelectorExpr:
-					if ident, ok := node.X.(*ast.Ident); ok {
-						for _, imp := range imports {
-							if strings.HasSuffix(imp.Path, ident.Name) {
-								fn.Dependencies = append(fn.Dependencies, imp.Path)
-							}
-						}
-					}
-				case *ast.Ident:
-					// If the identifier matches a global name, record it.
-					for _, global := range globals {
-						if node.Name == global.Name {
-							fn.ReferencedGlobals = append(fn.ReferencedGlobals, global.Name)
-						}
-					}
-				}
-				return true
-			})
 			functions = append(functions, fn)
+			funcDecls = append(funcDecls, d)
 
 		case *ast.GenDecl:
 			switch d.Tok {
 			case token.IMPORT:
 				for _, spec := range d.Specs {
 					if impSpec, ok := spec.(*ast.ImportSpec); ok {
-						imports = append(imports, surrealtypes.ImportDefinition{
-							Path:    strings.Trim(impSpec.Path.Value, `"`),
-							File:    path,
-							Package: pkgName,
-						})
+						imp := surrealtypes.ImportDefinition{
+							Path:       strings.Trim(impSpec.Path.Value, `"`),
+							File:       path,
+							Package:    pkgName,
+							ImportKind: importKind(impSpec),
+						}
+						imports = append(imports, imp)
+						importSpecs = append(importSpecs, impSpec)
 					}
 				}
 			case token.VAR, token.CONST:
+				// A constant spec without values repeats the type and values
+				// of the previous spec, such as iota in an enumeration.
+				var typ ast.Expr
+				var values []ast.Expr
 				for _, spec := range d.Specs {
 					if vs, ok := spec.(*ast.ValueSpec); ok {
+						if d.Tok == token.VAR || len(vs.Values) > 0 {
+							typ, values = vs.Type, vs.Values
+						}
+						if d.Tok == token.VAR && len(vs.Values) > 0 {
+							varSpecs = append(varSpecs, vs)
+						}
 						for i, name := range vs.Names {
 							var valueStr string
-							if i < len(vs.Values) {
-								valueStr = a.ExprCache.ToString(vs.Values[i])
+							if i < len(values) {
+								valueStr = a.ExprCache.ToString(values[i])
 							}
 							globals = append(globals, surrealtypes.GlobalVariable{
 								Name:    name.Name,
-								Type:    a.ExprCache.ToString(vs.Type),
+								Type:    a.ExprCache.ToString(typ),
 								Value:   valueStr,
 								File:    path,
 								Package: pkgName,
+								IsConst: d.Tok == token.CONST,
 							})
 						}
 					}
@@ -225,13 +596,23 @@ func (a *Analyzer) AnalyzeFile(path string) (FileAnalysis, error) {
 			case token.TYPE:
 				for _, spec := range d.Specs {
 					if ts, ok := spec.(*ast.TypeSpec); ok {
+						// An ungrouped declaration's doc comment belongs to its spec.
+						doc := ts.Doc
+						if doc == nil && !d.Lparen.IsValid() {
+							doc = d.Doc
+						}
 						switch t := ts.Type.(type) {
 						case *ast.StructType:
 							structs = append(structs, surrealtypes.StructDefinition{
-								Name:    ts.Name.Name,
-								File:    path,
-								Package: pkgName,
+								Name:       ts.Name.Name,
+								File:       path,
+								Package:    pkgName,
+								Doc:        doc.Text(),
+								FieldCount: fieldCount(t.Fields),
 							})
+							for _, field := range t.Fields.List {
+								anonymous = append(anonymous, anonymousTypes(field.Type)...)
+							}
 							structIdents[ts.Name.Name] = ts.Name
 						case *ast.InterfaceType:
 							var methods []string
@@ -247,8 +628,17 @@ func (a *Analyzer) AnalyzeFile(path string) (FileAnalysis, error) {
 								File:    path,
 								Package: pkgName,
 								Methods: methods,
+								Doc:     doc.Text(),
 							})
 							ifaceIdents[ts.Name.Name] = ts.Name
+						default:
+							namedTypes = append(namedTypes, surrealtypes.TypeDefinition{
+								Name:       ts.Name.Name,
+								Underlying: types.ExprString(ts.Type),
+								IsAlias:    ts.Assign.IsValid(),
+								File:       path,
+								Package:    pkgName,
+							})
 						}
 					}
 				}
@@ -256,18 +646,124 @@ func (a *Analyzer) AnalyzeFile(path string) (FileAnalysis, error) {
 		}
 	}
 
-	// Perform type checking using Go's type checker.
-	conf := types.Config{
-		Importer: importer.ForCompiler(fset, "source", nil),
-		Error:    func(err error) {}, // ignore errors
+	// The type information of the package is complete for this file unless
+	// it has a hard type error.
+	info, checked, hardErr := pkg.info, pkg.pkg, pkg.errs[path]
+
+	// Resolve the name each import is referenced by in this file. Blank
+	// imports are never referenced, and the symbols of dot imports are
+	// referenced without a name, so only type information can attribute them.
+	importNames := make(map[string]string, len(imports))
+	dotImports := make(map[string]bool)
+	for i, spec := range importSpecs {
+		imports[i].Name = importLocalName(spec, info)
+		switch imports[i].ImportKind {
+		case surrealtypes.ImportDot:
+			dotImports[imports[i].Path] = true
+		case surrealtypes.ImportNormal, surrealtypes.ImportAlias:
+			importNames[imports[i].Name] = imports[i].Path
+		}
+	}
+
+	// Optionally report closures as functions of their own, after the
+	// declared functions.
+	declared := len(funcDecls)
+	if a.IncludeClosures {
+		for i, n := 0, len(functions); i < n; i++ {
+			closures, decls := extractClosures(functions[i], funcDecls[i])
+			functions = append(functions, closures...)
+			funcDecls = append(funcDecls, decls...)
+		}
 	}
-	info := &types.Info{
-		Defs: make(map[*ast.Ident]types.Object),
+
+	// Track calls, globals and dependencies now that every import and global
+	// in the file is known. Identifiers the parser could not resolve within this
+	// file are kept so GetAnalysis can match globals declared in sibling files.
+	globalNames := make(map[string]bool, len(globals))
+	for _, global := range globals {
+		globalNames[global.Name] = true
 	}
-	pkgInfo := types.NewPackage(pkgName, "")
-	_, err = conf.Check(pkgInfo.Path(), fset, []*ast.File{file}, info)
-	if err != nil {
-		fmt.Printf("Type checking skipped for %s: %v\n", path, err)
+	unresolved := make(map[string][]string)
+	calledMethods := make(map[string]bool)
+	discards := make(map[string][]discardedCall)
+	for i, d := range funcDecls {
+		fn := &functions[i]
+		if d.Body == nil {
+			continue
+		}
+		selected := make(map[*ast.Ident]bool)
+		ast.Inspect(d.Body, func(n ast.Node) bool {
+			switch node := n.(type) {
+			case *ast.CallExpr:
+				if sel, ok := ast.Unparen(node.Fun).(*ast.SelectorExpr); ok {
+					calledMethods[sel.Sel.Name] = true
+					if ident, ok := sel.X.(*ast.Ident); ok && ident.Obj == nil {
+						if impPath, ok := importNames[ident.Name]; ok {
+							dep := surrealtypes.PackageDependency{Caller: fn.Caller, ImportPath: impPath, Symbol: sel.Sel.Name}
+							if !slices.Contains(fn.PackageDependencies, dep) {
+								fn.PackageDependencies = append(fn.PackageDependencies, dep)
+							}
+						}
+					}
+				}
+				if ident, ok := ast.Unparen(node.Fun).(*ast.Ident); ok {
+					if impPath := dotImported(ident, info, dotImports); impPath != "" {
+						dep := surrealtypes.PackageDependency{Caller: fn.Caller, ImportPath: impPath, Symbol: ident.Name}
+						if !slices.Contains(fn.PackageDependencies, dep) {
+							fn.PackageDependencies = append(fn.PackageDependencies, dep)
+						}
+					}
+				}
+				if callee, ident := calleeName(node.Fun, info, checked); callee != "" {
+					pos := fset.Position(ident.Pos())
+					fn.CallSites = append(fn.CallSites, surrealtypes.CallSite{Callee: callee, Line: pos.Line, Col: pos.Column})
+					if !slices.Contains(fn.Callees, callee) {
+						fn.Callees = append(fn.Callees, callee)
+					}
+				} else if a.IncludeExternalCalls {
+					if call, ok := externalCall(node.Fun, info, checked, importNames); ok && !slices.Contains(fn.ExternalCalls, call) {
+						fn.ExternalCalls = append(fn.ExternalCalls, call)
+					}
+				}
+			case *ast.GoStmt:
+				fn.HasGoroutines = true
+			case *ast.SelectorExpr:
+				selected[node.Sel] = true
+				if ident, ok := node.X.(*ast.Ident); ok && ident.Obj == nil {
+					if impPath, ok := importNames[ident.Name]; ok && !slices.Contains(fn.Dependencies, impPath) {
+						fn.Dependencies = append(fn.Dependencies, impPath)
+					}
+				}
+			case *ast.Ident:
+				switch impPath := dotImported(node, info, dotImports); {
+				case selected[node]:
+				case impPath != "":
+					if !slices.Contains(fn.Dependencies, impPath) {
+						fn.Dependencies = append(fn.Dependencies, impPath)
+					}
+				case globalNames[node.Name]:
+					if !slices.Contains(fn.ReferencedGlobals, node.Name) {
+						fn.ReferencedGlobals = append(fn.ReferencedGlobals, node.Name)
+					}
+				case node.Obj == nil && !slices.Contains(unresolved[fn.Caller], node.Name):
+					unresolved[fn.Caller] = append(unresolved[fn.Caller], node.Name)
+				}
+			}
+			return true
+		})
+		discards[fn.Caller] = discardedCalls(d.Body, info, checked)
+		// Writes to identifiers of other files are kept until
+		// resolvePackages knows the globals of the package.
+		for _, ident := range unguardedWrites(d.Body) {
+			if (ident.Obj == nil || file.Scope.Objects[ident.Name] == ident.Obj) && !slices.Contains(fn.UnguardedGlobalWrites, ident.Name) {
+				fn.UnguardedGlobalWrites = append(fn.UnguardedGlobalWrites, ident.Name)
+			}
+		}
+	}
+
+	// Interface implementations need a successful type check.
+	if hardErr != nil {
+		a.progress(ProgressEvent{Stage: ProgressTypeCheckSkipped, Path: path, Err: hardErr})
 		// Continue with AST-based analysis
 	} else {
 		// For each struct and interface, check for implementations.
@@ -280,7 +776,7 @@ func (a *Analyzer) AnalyzeFile(path string) (FileAnalysis, error) {
 			if obj == nil {
 				continue
 			}
-			structType := obj.Type()
+			structType := instantiateOwn(obj.Type())
 			for _, iface := range interfaces {
 				iident, ok := ifaceIdents[iface.Name]
 				if !ok {
@@ -290,37 +786,93 @@ func (a *Analyzer) AnalyzeFile(path string) (FileAnalysis, error) {
 				if iobj == nil {
 					continue
 				}
-				ifaceType := iobj.Type()
+				ifaceType := genericInterfaceFor(iobj.Type(), structType)
 				if ifaceType == nil {
 					continue
 				}
+				// Constraints such as ~int | ~string are satisfied, not
+				// implemented.
 				ifaceUnderlying, ok := ifaceType.Underlying().(*types.Interface)
-				if !ok {
+				if !ok || !ifaceUnderlying.IsMethodSet() {
 					continue
 				}
 				if types.Implements(structType, ifaceUnderlying) || types.Implements(types.NewPointer(structType), ifaceUnderlying) {
 					implements = append(implements, surrealtypes.InterfaceImplementation{
 						Struct:    st.Name,
 						Interface: iface.Name,
+						Package:   pkgName,
+						File:      path,
 					})
 				}
 			}
 		}
+
+		// Generic structs have no size until instantiated.
+		sizes := types.SizesFor("gc", runtime.GOARCH)
+		for i, st := range structs {
+			if obj := info.Defs[structIdents[st.Name]]; obj != nil {
+				if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() == 0 {
+					structs[i].SizeBytes = sizes.Sizeof(named)
+				}
+			}
+		}
+	}
+
+	// Variable initializers run before init functions.
+	var varActions []surrealtypes.InitAction
+	for _, vs := range varSpecs {
+		if !slices.ContainsFunc(vs.Values, func(value ast.Expr) bool { return callsFunction(value, info) }) {
+			continue
+		}
+		names := make([]string, len(vs.Names))
+		for i, name := range vs.Names {
+			names[i] = name.Name
+		}
+		varActions = append(varActions, surrealtypes.InitAction{
+			Name:    strings.Join(names, ", "),
+			Package: pkgName,
+			File:    path,
+			Line:    fset.Position(vs.Pos()).Line,
+			Kind:    surrealtypes.InitVariable,
+		})
+	}
+	initActions = append(varActions, initActions...)
+
+	// Local variable findings need complete type information.
+	var findings []surrealtypes.Finding
+	if a.Locals && hardErr == nil {
+		used := usedObjects(file, info)
+		for i, d := range funcDecls[:declared] {
+			findings = append(findings, localFindings(d, functions[i].Caller, path, fset, info, used)...)
+		}
+	}
+
+	// Leak detection recognizes resources by their type information.
+	var leaks []surrealtypes.LeakSuspect
+	resources := a.resources()
+	for i, d := range funcDecls[:declared] {
+		leaks = append(leaks, leakSuspects(d, functions[i].Caller, path, fset, info, resources)...)
 	}
 
 	// Process functions further to calculate metrics.
-	// (We loop again over our functions slice and try to find their AST node.)
+	// (We loop again over our functions slice and its parallel AST nodes.)
 	detector := NewCodeDuplicationDetector()
 	for i := range functions {
-		funcDecl := findFunctionDecl(file, functions[i].Caller)
+		funcDecl := funcDecls[i]
 		if funcDecl != nil {
 			// Check for duplication before the first function
 			if detector.DetectDuplication(funcDecl) {
 				functions[i].IsDuplicate = true
 			}
+			functions[i].Line = fset.Position(funcDecl.Pos()).Line
+			if funcDecl.Body != nil {
+				functions[i].BodyHash = fmt.Sprintf("%x", sha256.Sum256([]byte(extractFunctionBody(funcDecl))))
+			}
 			// Calculate metrics after duplication check
 			complexity := ComputeComplexity(funcDecl)
 			loc := ComputeLOC(fset, funcDecl.Body)
+			sloc := ComputeSLOC(fset, funcDecl)
+			statements := ComputeStatementCount(funcDecl)
 			readability := ComputeReadabilityMetrics(funcDecl, fset)
 			halstead := ComputeHalsteadMetrics(funcDecl)
 			cognitive := ComputeCognitiveComplexity(funcDecl)
@@ -328,15 +880,24 @@ func (a *Analyzer) AnalyzeFile(path string) (FileAnalysis, error) {
 			functions[i].Metrics = surrealtypes.FunctionMetrics{
 				CyclomaticComplexity: complexity,
 				LinesOfCode:          loc,
+				SLOC:                 sloc,
+				StatementCount:       statements,
 				HalsteadMetrics:      halstead,
 				CognitiveComplexity:  cognitive,
 				Readability: surrealtypes.ReadabilityMetrics{
 					NestingDepth:   readability.NestingDepth,
 					CommentDensity: readability.CommentDensity,
 					BranchDensity:  readability.BranchDensity,
+					NumLoops:       readability.NumLoops,
+					NumSwitches:    readability.NumSwitches,
+					NumSelects:     readability.NumSelects,
 				},
-				Maintainability: calculateMaintainability(readability, complexity),
 			}
+			functions[i].Metrics.Maintainability, functions[i].Metrics.MaintainabilityRaw =
+				calculateMaintainability(halstead.Volume, complexity, sloc)
+			functions[i].HasNakedReturn = len(functions[i].ReturnNames) > 0 && hasNakedReturn(funcDecl)
+			functions[i].IgnoresErrors = ignoresErrors(funcDecl, info)
+			functions[i].IsEmpty = isStub(funcDecl.Body)
 		}
 	}
 
@@ -344,112 +905,1521 @@ func (a *Analyzer) AnalyzeFile(path string) (FileAnalysis, error) {
 		Functions:  functions,
 		Structs:    structs,
 		Interfaces: interfaces,
+		Types:      namedTypes,
 		Globals:    globals,
 		Imports:    imports,
 		Implements: implements,
-	}, nil
+		Findings:   findings,
+
+		InitActions:  initActions,
+		LeakSuspects: leaks,
+
+		unresolved:    unresolved,
+		calledMethods: calledMethods,
+
+		anonymousTypes: anonymous,
+		discards:       discards,
+		uses:           fileUses(file, path, info, checked, importNames, dotImports),
+	}
 }
 
-// simpleTypeString converts an AST expression representing a type into a string.
-func simpleTypeString(expr ast.Expr) string {
-	switch t := expr.(type) {
-	case *ast.Ident:
-		return t.Name
-	case *ast.StarExpr:
-		return "*" + simpleTypeString(t.X)
-	case *ast.SelectorExpr:
-		return simpleTypeString(t.X) + "." + t.Sel.Name
-	case *ast.ArrayType:
-		return "[]" + simpleTypeString(t.Elt)
-	default:
-		return fmt.Sprintf("%T", expr)
+// usedObjects returns the objects used anywhere in file. Plain assignments
+// do not use a variable.
+func usedObjects(file *ast.File, info *types.Info) map[types.Object]bool {
+	assigned := make(map[*ast.Ident]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if node, ok := n.(*ast.AssignStmt); ok && node.Tok == token.ASSIGN {
+			for _, lhs := range node.Lhs {
+				if ident, ok := lhs.(*ast.Ident); ok {
+					assigned[ident] = true
+				}
+			}
+		}
+		return true
+	})
+	used := make(map[types.Object]bool)
+	for ident, obj := range info.Uses {
+		if !assigned[ident] {
+			used[obj] = true
+		}
 	}
+	return used
 }
 
-// -----------------------------------------------------------------------------
-// Analyzer Workflow
-// -----------------------------------------------------------------------------
+// localFindings returns the unused local variables of fn, and those that
+// shadow a variable declared earlier in fn, in source order. Closures are
+// part of the function they are declared in. used holds the objects used in
+// fn's file, as returned by usedObjects.
+func localFindings(fn *ast.FuncDecl, caller, path string, fset *token.FileSet, info *types.Info, used map[types.Object]bool) []surrealtypes.Finding {
+	if fn.Body == nil {
+		return nil
+	}
+	// Parameters and results may go unused.
+	ignored := make(map[*ast.Ident]bool)
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if node, ok := n.(*ast.FuncType); ok {
+			for _, fields := range []*ast.FieldList{node.TypeParams, node.Params, node.Results} {
+				if fields == nil {
+					continue
+				}
+				for _, field := range fields.List {
+					for _, name := range field.Names {
+						ignored[name] = true
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	var findings []surrealtypes.Finding
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok || ident.Name == "_" || ignored[ident] {
+			return true
+		}
+		v, ok := info.Defs[ident].(*types.Var)
+		if !ok || v.IsField() || v.Parent() == nil {
+			return true
+		}
+		finding := surrealtypes.Finding{Func: caller, File: path, Var: ident.Name, Line: fset.Position(ident.Pos()).Line}
+		if !used[v] {
+			finding.Kind = surrealtypes.FindingUnused
+			findings = append(findings, finding)
+		}
+		if _, outer := v.Parent().Parent().LookupParent(ident.Name, ident.Pos()); outer != nil {
+			if ov, ok := outer.(*types.Var); ok && ov.Pos() >= fn.Pos() && ov.Pos() < fn.End() {
+				finding.Kind = surrealtypes.FindingShadowed
+				findings = append(findings, finding)
+			}
+		}
+		return true
+	})
+	return findings
+}
 
-// AnalyzeDirectory scans a directory tree and stores analysis results.
-func (a *Analyzer) AnalyzeDirectory(ctx context.Context, dir string) error {
-	fmt.Println("Starting analysis...")
-	report, err := a.GetAnalysis(ctx, dir)
+// instantiateOwn instantiates a generic named type with its own type
+// parameters, such as Box[T] for Box, since the method set of an
+// uninstantiated type is unspecified. Other types are returned unchanged.
+func instantiateOwn(t types.Type) types.Type {
+	named, ok := t.(*types.Named)
+	if !ok || named.TypeParams().Len() == 0 {
+		return t
+	}
+	inst, err := types.Instantiate(nil, named, typeParamArgs(named), false)
 	if err != nil {
-		return fmt.Errorf("failed to analyze directory: %w", err)
+		return t
 	}
-	fmt.Println("Analysis complete, storing results...")
-	if err := a.DB.StoreAnalysis(ctx, report); err != nil {
-		return fmt.Errorf("failed to store analysis results: %w", err)
+	return inst
+}
+
+// genericInterfaceFor returns iface, instantiating it if it is generic with
+// the type arguments of the instantiated generic type impl, so Box[T] is
+// checked against Getter[T]. It returns nil if a generic interface cannot
+// be instantiated that way.
+func genericInterfaceFor(iface, impl types.Type) types.Type {
+	named, ok := iface.(*types.Named)
+	if !ok || named.TypeParams().Len() == 0 {
+		return iface
 	}
-	fmt.Println("Results stored successfully")
-	return nil
+	implNamed, ok := impl.(*types.Named)
+	if !ok || implNamed.TypeArgs().Len() != named.TypeParams().Len() {
+		return nil
+	}
+	args := make([]types.Type, implNamed.TypeArgs().Len())
+	for i := range args {
+		args[i] = implNamed.TypeArgs().At(i)
+	}
+	inst, err := types.Instantiate(nil, named, args, false)
+	if err != nil {
+		return nil
+	}
+	return inst
 }
 
-// GetAnalysis performs code analysis without storing results.
-func (a *Analyzer) GetAnalysis(ctx context.Context, dir string) (surrealtypes.AnalysisReport, error) {
-	fmt.Println("Scanning directory:", dir)
-	var filePaths []string
-	if err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
-		if !d.IsDir() && strings.HasSuffix(path, ".go") {
-			filePaths = append(filePaths, path)
-		}
-		return err
-	}); err != nil {
-		return surrealtypes.AnalysisReport{}, err
+// typeParamArgs returns the type parameters of named as type arguments.
+func typeParamArgs(named *types.Named) []types.Type {
+	args := make([]types.Type, named.TypeParams().Len())
+	for i := range args {
+		args[i] = named.TypeParams().At(i)
 	}
-	fmt.Printf("Found %d Go files\n", len(filePaths))
-	var report surrealtypes.AnalysisReport
-	functionMap := make(map[string]surrealtypes.FunctionCall)
+	return args
+}
 
-	// Process each file.
-	for _, path := range filePaths {
-		fmt.Printf("Processing file: %s\n", path)
-		analysis, err := a.AnalyzeFile(path)
-		if err != nil {
-			return surrealtypes.AnalysisReport{}, err
+// hasNakedReturn reports whether fn has a return statement without
+// expressions. Returns inside function literals belong to the literal.
+func hasNakedReturn(fn *ast.FuncDecl) bool {
+	found := false
+	ast.Inspect(fn, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			if len(n.Results) == 0 {
+				found = true
+			}
 		}
-		// Merge functions from this file.
-		for _, fn := range analysis.Functions {
-			functionMap[fn.Caller] = fn
+		return !found
+	})
+	return found
+}
+
+// stubMessages are the panic messages marking a function as unimplemented.
+var stubMessages = map[string]bool{
+	`"not implemented"`: true,
+	`"TODO"`:            true,
+}
+
+// isStub reports whether body is missing, as for functions implemented in
+// assembly, or holds nothing but an empty block or a panic("not
+// implemented") or panic("TODO"). Comments are not statements, so a body
+// with only comments is empty.
+func isStub(body *ast.BlockStmt) bool {
+	if body == nil || len(body.List) == 0 {
+		return true
+	}
+	if len(body.List) > 1 {
+		return false
+	}
+	switch stmt := body.List[0].(type) {
+	case *ast.BlockStmt:
+		return len(stmt.List) == 0
+	case *ast.EmptyStmt:
+		return true
+	case *ast.ExprStmt:
+		call, ok := stmt.X.(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 {
+			return false
+		}
+		if fun, ok := call.Fun.(*ast.Ident); !ok || fun.Name != "panic" {
+			return false
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		return ok && lit.Kind == token.STRING && stubMessages[lit.Value]
+	}
+	return false
+}
+
+// ignoresErrors reports whether fn, including its function literals,
+// assigns an error result to the blank identifier or calls a function
+// returning an error without using the results. It relies on the types
+// recorded by the type checker, so it is best-effort when type checking
+// fails. Like most linters, it allows dropping the errors of fmt.Print*.
+func ignoresErrors(fn *ast.FuncDecl, info *types.Info) bool {
+	errorType := types.Universe.Lookup("error").Type()
+	isError := func(t types.Type) bool {
+		return t != nil && types.Identical(t, errorType)
+	}
+	isBlank := func(e ast.Expr) bool {
+		ident, ok := e.(*ast.Ident)
+		return ok && ident.Name == "_"
+	}
+
+	found := false
+	ast.Inspect(fn, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if len(n.Rhs) == 1 && len(n.Lhs) > 1 {
+				tuple, ok := info.Types[n.Rhs[0]].Type.(*types.Tuple)
+				for i, lhs := range n.Lhs {
+					if ok && i < tuple.Len() && isBlank(lhs) && isError(tuple.At(i).Type()) {
+						found = true
+					}
+				}
+			} else if len(n.Lhs) == len(n.Rhs) {
+				for i, lhs := range n.Lhs {
+					if isBlank(lhs) && isError(info.Types[n.Rhs[i]].Type) {
+						found = true
+					}
+				}
+			}
+		case *ast.ExprStmt:
+			call, ok := n.X.(*ast.CallExpr)
+			if !ok || isFmtPrint(call, info) {
+				break
+			}
+			switch t := info.Types[call].Type.(type) {
+			case *types.Tuple:
+				for i := 0; i < t.Len(); i++ {
+					if isError(t.At(i).Type()) {
+						found = true
+					}
+				}
+			default:
+				if isError(t) {
+					found = true
+				}
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// discardedCall is a call of a function of the analyzed package that
+// discards its results: all of them if results is nil, or those at the
+// given indices.
+type discardedCall struct {
+	callee  string
+	results []int
+}
+
+// discardedCalls returns the calls in body, including its function
+// literals, that discard results, as expression statements or by
+// assigning them to _. Like calleeName, it only knows functions of pkg.
+func discardedCalls(body *ast.BlockStmt, info *types.Info, pkg *types.Package) []discardedCall {
+	if body == nil {
+		return nil
+	}
+	var calls []discardedCall
+	add := func(expr ast.Expr, results []int) {
+		if call, ok := ast.Unparen(expr).(*ast.CallExpr); ok {
+			if callee, _ := calleeName(call.Fun, info, pkg); callee != "" {
+				calls = append(calls, discardedCall{callee: callee, results: results})
+			}
+		}
+	}
+	isBlank := func(e ast.Expr) bool {
+		ident, ok := e.(*ast.Ident)
+		return ok && ident.Name == "_"
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ExprStmt:
+			add(n.X, nil)
+		case *ast.AssignStmt:
+			if len(n.Rhs) == 1 && len(n.Lhs) > 1 {
+				var blank []int
+				for i, lhs := range n.Lhs {
+					if isBlank(lhs) {
+						blank = append(blank, i)
+					}
+				}
+				if len(blank) > 0 {
+					add(n.Rhs[0], blank)
+				}
+			} else if len(n.Lhs) == len(n.Rhs) {
+				for i, lhs := range n.Lhs {
+					if isBlank(lhs) {
+						add(n.Rhs[i], []int{0})
+					}
+				}
+			}
+		}
+		return true
+	})
+	return calls
+}
+
+// markIgnoredErrors sets the ErrorsIgnoredBy of each function with an error
+// result to the callers of its package that discard that result. discards
+// holds the calls discarding results, keyed by package key and caller.
+func markIgnoredErrors(functions []surrealtypes.FunctionCall, discards map[string][]discardedCall) {
+	index := make(map[string]int, len(functions))
+	for i, fn := range functions {
+		index[packageKey(fn.File, fn.Package)+"."+fn.Caller] = i
+	}
+	for key, calls := range discards {
+		i, ok := index[key]
+		if !ok {
+			continue
+		}
+		caller := functions[i]
+		for _, call := range calls {
+			j, ok := index[packageKey(caller.File, caller.Package)+"."+call.callee]
+			if !ok {
+				continue
+			}
+			callee := &functions[j]
+			if dropsError(callee.Returns, call.results) && !slices.Contains(callee.ErrorsIgnoredBy, caller.Caller) {
+				callee.ErrorsIgnoredBy = append(callee.ErrorsIgnoredBy, caller.Caller)
+			}
+		}
+	}
+	for i := range functions {
+		slices.Sort(functions[i].ErrorsIgnoredBy)
+	}
+}
+
+// dropsError reports whether discarding results, or all results if nil, of
+// a function returning returns drops an error.
+func dropsError(returns []string, results []int) bool {
+	for i, typ := range returns {
+		if typ == "error" && (results == nil || slices.Contains(results, i)) {
+			return true
+		}
+	}
+	return false
+}
+
+// calleeName returns the name of the function of pkg that fun calls, as it
+// appears in the callee's Caller, along with the identifier naming it. It
+// returns "" for builtins, conversions, function values, calls into other
+// packages and, when type information is missing, method calls. A file
+// analyzed on its own does not know the functions of its sibling files, so
+// an unresolved identifier is assumed to name one.
+func calleeName(fun ast.Expr, info *types.Info, pkg *types.Package) (string, *ast.Ident) {
+	switch f := ast.Unparen(fun).(type) {
+	case *ast.IndexExpr:
+		return calleeName(f.X, info, pkg)
+	case *ast.IndexListExpr:
+		return calleeName(f.X, info, pkg)
+	case *ast.Ident:
+		if obj, ok := info.Uses[f]; ok {
+			if fn, ok := obj.(*types.Func); ok && fn.Pkg() == pkg {
+				return f.Name, f
+			}
+			return "", nil
+		}
+		if f.Obj != nil {
+			if f.Obj.Kind == ast.Fun {
+				return f.Name, f
+			}
+			return "", nil
+		}
+		if types.Universe.Lookup(f.Name) != nil {
+			return "", nil
+		}
+		return f.Name, f
+	case *ast.SelectorExpr:
+		method, ok := info.Uses[f.Sel].(*types.Func)
+		if !ok || method.Pkg() != pkg {
+			return "", nil
+		}
+		recv := method.Type().(*types.Signature).Recv()
+		if recv == nil {
+			return "", nil
+		}
+		recvType, prefix := recv.Type(), ""
+		if ptr, ok := recvType.(*types.Pointer); ok {
+			recvType, prefix = ptr.Elem(), "*"
+		}
+		named, ok := types.Unalias(recvType).(*types.Named)
+		if !ok || types.IsInterface(named) {
+			return "", nil
+		}
+		return prefix + named.Obj().Name() + "." + f.Sel.Name, f.Sel
+	}
+	return "", nil
+}
+
+// externalCall returns the function or method of another package than pkg
+// that fun calls. Without type information, only calls through an import
+// name, as in fmt.Println, are found, and may be conversions.
+func externalCall(fun ast.Expr, info *types.Info, pkg *types.Package, importNames map[string]string) (surrealtypes.ExternalCall, bool) {
+	var ident *ast.Ident
+	switch f := ast.Unparen(fun).(type) {
+	case *ast.IndexExpr:
+		return externalCall(f.X, info, pkg, importNames)
+	case *ast.IndexListExpr:
+		return externalCall(f.X, info, pkg, importNames)
+	case *ast.Ident:
+		ident = f
+	case *ast.SelectorExpr:
+		ident = f.Sel
+		if x, ok := f.X.(*ast.Ident); ok && x.Obj == nil && info.Uses[f.Sel] == nil {
+			if path, ok := importNames[x.Name]; ok {
+				return surrealtypes.ExternalCall{Package: path, Function: f.Sel.Name}, true
+			}
+		}
+	default:
+		return surrealtypes.ExternalCall{}, false
+	}
+	fn, ok := info.Uses[ident].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg() == pkg {
+		return surrealtypes.ExternalCall{}, false
+	}
+	name := fn.Name()
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		recvType, prefix := recv.Type(), ""
+		if ptr, ok := recvType.(*types.Pointer); ok {
+			recvType, prefix = ptr.Elem(), "*"
+		}
+		named, ok := types.Unalias(recvType).(*types.Named)
+		if !ok {
+			return surrealtypes.ExternalCall{}, false
+		}
+		name = prefix + named.Obj().Name() + "." + name
+	}
+	return surrealtypes.ExternalCall{Package: fn.Pkg().Path(), Function: name}, true
+}
+
+// linkAnalyzedCalls sets the Dir and Name of the external calls of
+// functions to packages that were analyzed too. dirPaths maps the directory
+// of each analyzed file to the import path of its package, or "" if it is
+// not in a module.
+func linkAnalyzedCalls(functions []surrealtypes.FunctionCall, dirPaths map[string]string) {
+	pathDirs := make(map[string]string)
+	for dir, path := range dirPaths {
+		if path != "" {
+			pathDirs[path] = dir
+		}
+	}
+	// External test packages share the directory of the package they test.
+	dirNames := make(map[string]string)
+	for _, fn := range functions {
+		if !strings.HasSuffix(fn.Package, "_test") {
+			dirNames[filepath.Dir(fn.File)] = fn.Package
+		}
+	}
+	for _, fn := range functions {
+		for i, call := range fn.ExternalCalls {
+			if dir, ok := pathDirs[call.Package]; ok && dirNames[dir] != "" {
+				fn.ExternalCalls[i].Dir = dir
+				fn.ExternalCalls[i].Name = dirNames[dir]
+			}
+		}
+	}
+}
+
+// isFmtPrint reports whether call calls one of fmt's Print functions.
+func isFmtPrint(call *ast.CallExpr, info *types.Info) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	fn, ok := info.Uses[sel.Sel].(*types.Func)
+	return ok && fn.Pkg() != nil && fn.Pkg().Path() == "fmt" && strings.HasPrefix(fn.Name(), "Print")
+}
+
+// goFiles returns the Go files under root in fsys. Nested modules, i.e.
+// directories with their own go.mod, are skipped if SkipNestedModules is
+// set.
+func (a *Analyzer) goFiles(fsys fs.FS, root string) ([]string, error) {
+	var names []string
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != root && a.excluded(name, root) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if name != root && a.SkipNestedModules {
+				if _, err := fs.Stat(fsys, path.Join(name, "go.mod")); err == nil {
+					return fs.SkipDir
+				}
+			}
+			return nil
+		}
+		if strings.HasSuffix(name, ".go") {
+			names = append(names, name)
+		}
+		return nil
+	})
+	return names, err
+}
+
+// excluded reports whether name, found while walking root, matches one of
+// the Exclude patterns.
+func (a *Analyzer) excluded(name, root string) bool {
+	rel := name
+	if root != "." {
+		rel = strings.TrimPrefix(strings.TrimPrefix(name, root), "/")
+	}
+	for _, pattern := range a.Exclude {
+		if ok, _ := path.Match(pattern, path.Base(name)); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// entryPoints returns the entry points of dead code detection.
+func (a *Analyzer) entryPoints() []string {
+	if len(a.EntryPoints) > 0 {
+		return a.EntryPoints
+	}
+	return DefaultEntryPoints
+}
+
+// unguardedWrites returns the identifiers assigned, incremented or
+// decremented in body while no mutex is locked, including the variables
+// whose fields or elements are assigned. A mutex is locked by a statement
+// calling a Lock method, until a statement calling Unlock, and a lock
+// guards the blocks nested in its statement list. Function literals may
+// run later, so their bodies start unlocked.
+func unguardedWrites(body *ast.BlockStmt) []*ast.Ident {
+	if body == nil {
+		return nil
+	}
+	var writes []*ast.Ident
+	write := func(expr ast.Expr) {
+		for {
+			switch x := ast.Unparen(expr).(type) {
+			case *ast.SelectorExpr:
+				expr = x.X
+				continue
+			case *ast.IndexExpr:
+				expr = x.X
+				continue
+			case *ast.StarExpr:
+				expr = x.X
+				continue
+			case *ast.Ident:
+				if x.Name != "_" {
+					writes = append(writes, x)
+				}
+			}
+			return
+		}
+	}
+	var walk func(stmts []ast.Stmt, locked bool)
+	walk = func(stmts []ast.Stmt, locked bool) {
+		for _, stmt := range stmts {
+			if expr, ok := stmt.(*ast.ExprStmt); ok {
+				if call, ok := expr.X.(*ast.CallExpr); ok {
+					if sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr); ok {
+						switch sel.Sel.Name {
+						case "Lock":
+							locked = true
+						case "Unlock":
+							locked = false
+						}
+					}
+				}
+			}
+			ast.Inspect(stmt, func(n ast.Node) bool {
+				switch node := n.(type) {
+				case *ast.BlockStmt:
+					walk(node.List, locked)
+					return false
+				case *ast.CaseClause:
+					walk(node.Body, locked)
+					return false
+				case *ast.CommClause:
+					walk(node.Body, locked)
+					return false
+				case *ast.FuncLit:
+					walk(node.Body.List, false)
+					return false
+				case *ast.AssignStmt:
+					if node.Tok != token.DEFINE && !locked {
+						for _, lhs := range node.Lhs {
+							write(lhs)
+						}
+					}
+				case *ast.IncDecStmt:
+					if !locked {
+						write(node.X)
+					}
+				}
+				return true
+			})
+		}
+	}
+	walk(body.List, false)
+	return writes
+}
+
+// initFunctionName returns the name of the nth init function of file path.
+func initFunctionName(path string, n int) string {
+	return fmt.Sprintf("init@%s#%d", filepath.Base(path), n)
+}
+
+// isInitFunction reports whether name, as given by initFunctionName, names
+// an init function.
+func isInitFunction(name string) bool {
+	return strings.HasPrefix(name, "init@") && !strings.Contains(name, "$")
+}
+
+// initKindOrder orders init actions by when they run.
+func initKindOrder(kind string) int {
+	if kind == surrealtypes.InitVariable {
+		return 0
+	}
+	return 1
+}
+
+// callsFunction reports whether evaluating expr calls a function, other
+// than builtins and conversions. Function literals are not evaluated.
+func callsFunction(expr ast.Expr, info *types.Info) bool {
+	found := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.CallExpr:
+			if tv, ok := info.Types[n.Fun]; ok && (tv.IsType() || tv.IsBuiltin()) {
+				break
+			}
+			if ident, ok := ast.Unparen(n.Fun).(*ast.Ident); ok && ident.Obj == nil && types.Universe.Lookup(ident.Name) != nil {
+				break
+			}
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// ignoreDirective parses a //surrealcode:ignore directive in doc, returning
+// whether there is one and the checks it lists, if any.
+func ignoreDirective(doc *ast.CommentGroup) (bool, []string) {
+	if doc == nil {
+		return false, nil
+	}
+	for _, c := range doc.List {
+		directive, _, _ := strings.Cut(c.Text, " ")
+		rest, ok := strings.CutPrefix(directive, "//surrealcode:ignore")
+		if !ok {
+			continue
+		}
+		if rest == "" {
+			return true, nil
+		}
+		if list, ok := strings.CutPrefix(rest, "="); ok {
+			var checks []string
+			for _, check := range strings.Split(list, ",") {
+				if check = strings.TrimSpace(check); check != "" {
+					checks = append(checks, check)
+				}
+			}
+			return true, checks
+		}
+	}
+	return false, nil
+}
+
+// recursiveClosures returns the function literals in body that call
+// themselves through the local variable they are assigned to, as in
+//
+//	var visit func(n *Node)
+//	visit = func(n *Node) { visit(n.Next) }
+func recursiveClosures(body *ast.BlockStmt) map[*ast.FuncLit]bool {
+	recursive := make(map[*ast.FuncLit]bool)
+	if body == nil {
+		return recursive
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		assign, ok := n.(*ast.AssignStmt)
+		if !ok || len(assign.Lhs) != len(assign.Rhs) {
+			return true
+		}
+		for i, rhs := range assign.Rhs {
+			lit, ok := rhs.(*ast.FuncLit)
+			if !ok {
+				continue
+			}
+			variable, ok := assign.Lhs[i].(*ast.Ident)
+			if !ok || variable.Obj == nil {
+				continue
+			}
+			ast.Inspect(lit.Body, func(n ast.Node) bool {
+				if call, ok := n.(*ast.CallExpr); ok {
+					if ident, ok := ast.Unparen(call.Fun).(*ast.Ident); ok && ident.Obj == variable.Obj {
+						recursive[lit] = true
+					}
+				}
+				return !recursive[lit]
+			})
+		}
+		return true
+	})
+	return recursive
+}
+
+// extractClosures returns a synthetic function, named Parent$N in source
+// order, for every function literal inside decl, along with a declaration
+// wrapping the literal so the usual metrics can be computed for it. The
+// enclosing function's own metrics still include its closures.
+func extractClosures(parent surrealtypes.FunctionCall, decl *ast.FuncDecl) ([]surrealtypes.FunctionCall, []*ast.FuncDecl) {
+	if decl.Body == nil {
+		return nil, nil
+	}
+	recursive := recursiveClosures(decl.Body)
+	var closures []surrealtypes.FunctionCall
+	var decls []*ast.FuncDecl
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		lit, ok := n.(*ast.FuncLit)
+		if !ok {
+			return true
+		}
+		name := fmt.Sprintf("%s$%d", parent.Caller, len(closures)+1)
+		fn := surrealtypes.FunctionCall{
+			Caller:            name,
+			File:              parent.File,
+			Package:           parent.Package,
+			Params:            []string{},
+			Returns:           []string{},
+			Callees:           []string{},
+			ReferencedGlobals: []string{},
+			Dependencies:      []string{},
+			IsClosure:         true,
+			IsRecursive:       recursive[lit],
+			IsTest:            parent.IsTest,
+			Captures:          closureCaptures(lit, decl),
+		}
+		fn.Params = append(fn.Params, fieldTypes(lit.Type.Params)...)
+		fn.Returns = append(fn.Returns, fieldTypes(lit.Type.Results)...)
+		fn.ParamCount, fn.ReturnCount = len(fn.Params), len(fn.Returns)
+		fn.IsVariadic = isVariadic(lit.Type)
+		fn.ReturnNames = fieldNames(lit.Type.Results)
+		fn.UsesAnonymousTypes = len(signatureAnonymousTypes(lit.Type)) > 0
+		closures = append(closures, fn)
+		decls = append(decls, &ast.FuncDecl{Name: ast.NewIdent(name), Type: lit.Type, Body: lit.Body})
+		return true
+	})
+	return closures, decls
+}
+
+// closureCaptures returns the free variables of lit: identifiers it uses
+// that are declared in the enclosing function but outside the literal.
+func closureCaptures(lit *ast.FuncLit, parent *ast.FuncDecl) []string {
+	captures := []string{}
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok || ident.Obj == nil || ident.Obj.Kind != ast.Var {
+			return true
+		}
+		declNode, ok := ident.Obj.Decl.(ast.Node)
+		if !ok {
+			return true
+		}
+		pos := declNode.Pos()
+		inParent := pos >= parent.Pos() && pos < parent.End()
+		inLit := pos >= lit.Pos() && pos < lit.End()
+		if inParent && !inLit && !slices.Contains(captures, ident.Name) {
+			captures = append(captures, ident.Name)
+		}
+		return true
+	})
+	return captures
+}
+
+// importLocalName returns the name an import is referenced by: its alias if
+// present, otherwise the imported package's declared name when the type
+// checker could load it, falling back to a guess from the import path.
+func importLocalName(spec *ast.ImportSpec, info *types.Info) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	if pkgName, ok := info.Implicits[spec].(*types.PkgName); ok {
+		return pkgName.Imported().Name()
+	}
+	return importPathName(strings.Trim(spec.Path.Value, `"`))
+}
+
+// importKind returns how spec imports its package: as is, into the file's
+// scope, for side effects only, or under an alias.
+func importKind(spec *ast.ImportSpec) string {
+	if spec.Name == nil {
+		return surrealtypes.ImportNormal
+	}
+	switch spec.Name.Name {
+	case ".":
+		return surrealtypes.ImportDot
+	case "_":
+		return surrealtypes.ImportBlank
+	}
+	return surrealtypes.ImportAlias
+}
+
+// dotImported returns the import path of the dot import ident refers to a
+// symbol of, if any. Without type information for the import, its symbols
+// cannot be told from the package's own.
+func dotImported(ident *ast.Ident, info *types.Info, dotImports map[string]bool) string {
+	if len(dotImports) == 0 {
+		return ""
+	}
+	obj := info.Uses[ident]
+	if obj == nil || obj.Pkg() == nil || !dotImports[obj.Pkg().Path()] {
+		return ""
+	}
+	return obj.Pkg().Path()
+}
+
+// importPathName guesses the package name of an import path from its last
+// element, skipping a major version suffix such as "/v2".
+func importPathName(importPath string) string {
+	parts := strings.Split(importPath, "/")
+	name := parts[len(parts)-1]
+	if len(parts) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = parts[len(parts)-2]
+	}
+	return name
+}
+
+// fieldTypes returns the type of every field in fields, repeating the type
+// of comma-grouped names such as a and b in func(a, b int).
+func fieldTypes(fields *ast.FieldList) []string {
+	if fields == nil {
+		return nil
+	}
+	var out []string
+	for _, field := range fields.List {
+		typ := simpleTypeString(field.Type)
+		for range max(len(field.Names), 1) {
+			out = append(out, typ)
+		}
+	}
+	return out
+}
+
+// fieldNames returns the names of the fields in fields, or nil if they are
+// unnamed.
+func fieldNames(fields *ast.FieldList) []string {
+	if fields == nil {
+		return nil
+	}
+	var names []string
+	for _, field := range fields.List {
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+	}
+	return names
+}
+
+// isVariadic reports whether the last parameter of fn is variadic.
+func isVariadic(fn *ast.FuncType) bool {
+	if fn.Params == nil || len(fn.Params.List) == 0 {
+		return false
+	}
+	_, ok := fn.Params.List[len(fn.Params.List)-1].Type.(*ast.Ellipsis)
+	return ok
+}
+
+// simpleTypeString converts an AST expression representing a type into a string.
+func simpleTypeString(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return "*" + simpleTypeString(t.X)
+	case *ast.SelectorExpr:
+		return simpleTypeString(t.X) + "." + t.Sel.Name
+	case *ast.ArrayType:
+		return "[]" + simpleTypeString(t.Elt)
+	case *ast.Ellipsis:
+		return "..." + simpleTypeString(t.Elt)
+	case *ast.StructType, *ast.InterfaceType:
+		return types.ExprString(t)
+	default:
+		return fmt.Sprintf("%T", expr)
+	}
+}
+
+// signatureAnonymousTypes returns a use of each anonymous type in the
+// parameters and results of ft.
+func signatureAnonymousTypes(ft *ast.FuncType) []surrealtypes.AnonymousType {
+	var uses []surrealtypes.AnonymousType
+	for _, fields := range []*ast.FieldList{ft.Params, ft.Results} {
+		if fields == nil {
+			continue
+		}
+		for _, field := range fields.List {
+			for range max(len(field.Names), 1) {
+				uses = append(uses, anonymousTypes(field.Type)...)
+			}
+		}
+	}
+	return uses
+}
+
+// anonymousTypes returns a use of each anonymous struct and non-empty
+// interface type in the type expression expr, such as the struct of
+// []struct{ X int }. Types nested in an anonymous type are part of it. The
+// empty interface is not reported, as it is any.
+func anonymousTypes(expr ast.Expr) []surrealtypes.AnonymousType {
+	var uses []surrealtypes.AnonymousType
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch t := n.(type) {
+		case *ast.StructType:
+			uses = append(uses, surrealtypes.AnonymousType{Type: types.ExprString(t), Kind: "struct", Uses: 1})
+			return false
+		case *ast.InterfaceType:
+			if len(t.Methods.List) == 0 {
+				return false
+			}
+			use := surrealtypes.AnonymousType{Type: types.ExprString(t), Kind: "interface", Uses: 1}
+			for _, m := range t.Methods.List {
+				for _, name := range m.Names {
+					use.Methods = append(use.Methods, name.Name)
+				}
+			}
+			uses = append(uses, use)
+			return false
+		}
+		return true
+	})
+	return uses
+}
+
+// countAnonymousTypes merges uses of the same anonymous type, most used
+// first.
+func countAnonymousTypes(uses []surrealtypes.AnonymousType) []surrealtypes.AnonymousType {
+	var counted []surrealtypes.AnonymousType
+	index := make(map[string]int)
+	for _, use := range uses {
+		if i, ok := index[use.Type]; ok {
+			counted[i].Uses += use.Uses
+			continue
+		}
+		index[use.Type] = len(counted)
+		counted = append(counted, use)
+	}
+	slices.SortStableFunc(counted, func(a, b surrealtypes.AnonymousType) int {
+		return cmp.Or(cmp.Compare(b.Uses, a.Uses), cmp.Compare(a.Type, b.Type))
+	})
+	return counted
+}
+
+// receiverType returns the name of the type a method with receiver type
+// expr is declared on, without any pointer or type parameters, and whether
+// the receiver is a pointer. Value and pointer methods of T thus share the
+// name T.
+func receiverType(expr ast.Expr) (name string, pointer bool) {
+	switch t := expr.(type) {
+	case *ast.ParenExpr:
+		return receiverType(t.X)
+	case *ast.StarExpr:
+		name, _ = receiverType(t.X)
+		return name, true
+	case *ast.IndexExpr:
+		return receiverType(t.X)
+	case *ast.IndexListExpr:
+		return receiverType(t.X)
+	default:
+		return simpleTypeString(expr), false
+	}
+}
+
+// methodNames returns the sorted names of the methods declared on the type
+// called name in package pkg.
+func methodNames(functions []surrealtypes.FunctionCall, pkg, name string) []string {
+	names := []string{}
+	for _, fn := range functions {
+		if !fn.IsMethod || fn.Package != pkg || fn.Struct != name {
+			continue
+		}
+		names = append(names, fn.Caller[strings.LastIndex(fn.Caller, ".")+1:])
+	}
+	sort.Strings(names)
+	return names
+}
+
+// -----------------------------------------------------------------------------
+// Analyzer Workflow
+// -----------------------------------------------------------------------------
+
+// AnalyzeDirectory scans one or more directory trees and stores the merged
+// analysis results.
+func (a *Analyzer) AnalyzeDirectory(ctx context.Context, dirs ...string) error {
+	report, err := a.GetAnalysis(ctx, dirs...)
+	if err != nil {
+		return fmt.Errorf("failed to analyze directory: %w", err)
+	}
+	return a.store(ctx, report)
+}
+
+// store stores report in the database, reporting progress.
+func (a *Analyzer) store(ctx context.Context, report surrealtypes.AnalysisReport) error {
+	a.progress(ProgressEvent{Stage: ProgressStoreStart})
+	if err := a.DB.StoreAnalysis(ctx, report); err != nil {
+		return fmt.Errorf("failed to store analysis results: %w", err)
+	}
+	a.progress(ProgressEvent{Stage: ProgressDone})
+	return nil
+}
+
+// Prune deletes the stored analysis of files under the given directories
+// that no longer exist. The analysis of other directories is kept. The
+// database must implement db.Pruner.
+func (a *Analyzer) Prune(ctx context.Context, dirs ...string) error {
+	pruner, ok := a.DB.(db.Pruner)
+	if !ok {
+		return fmt.Errorf("database %T does not support pruning", a.DB)
+	}
+	files, err := a.collectFiles(dirs)
+	if err != nil {
+		return err
+	}
+	filePaths := make([]string, len(files))
+	for i, f := range files {
+		filePaths[i] = f.path
+	}
+	if err := pruner.Prune(ctx, dirs, filePaths); err != nil {
+		return fmt.Errorf("failed to prune stored analysis: %w", err)
+	}
+	return nil
+}
+
+// GetAnalysis analyzes the Go files under the given directories and merges
+// them into a single report. Functions are grouped by package directory, so
+// packages with the same name in different roots or modules do not collide.
+func (a *Analyzer) GetAnalysis(ctx context.Context, dirs ...string) (surrealtypes.AnalysisReport, error) {
+	files, err := a.collectFiles(dirs)
+	if err != nil {
+		return surrealtypes.AnalysisReport{}, err
+	}
+	return a.analyzeFiles(ctx, dirs, files)
+}
+
+// AnalyzeFS analyzes the Go files under root in fsys, such as an embedded
+// file system or a git tree, without extracting them to disk. Files are
+// reported under their path in fsys.
+func (a *Analyzer) AnalyzeFS(ctx context.Context, fsys fs.FS, root string) (surrealtypes.AnalysisReport, error) {
+	a.progress(ProgressEvent{Stage: ProgressScanStart, Path: root})
+	names, err := a.goFiles(fsys, root)
+	if err != nil {
+		return surrealtypes.AnalysisReport{}, err
+	}
+	files := make([]sourceFile, len(names))
+	pkgPaths := make(map[string]string)
+	for i, name := range names {
+		dir := path.Dir(name)
+		if _, ok := pkgPaths[dir]; !ok {
+			pkgPaths[dir] = moduleImportPath(fsys, dir)
+		}
+		files[i] = sourceFile{fsys: fsys, name: name, path: name, pkgPath: pkgPaths[dir]}
+	}
+	return a.analyzeFiles(ctx, []string{root}, files)
+}
+
+// analyzeFiles analyzes files, every Go file under roots, and merges them
+// into a single report. It stops once ctx is done, returning a partial
+// report, which covers no root, if a.Partial is set.
+func (a *Analyzer) analyzeFiles(ctx context.Context, roots []string, files []sourceFile) (surrealtypes.AnalysisReport, error) {
+	var report surrealtypes.AnalysisReport
+	functionMap := make(map[string]surrealtypes.FunctionCall)
+	unresolved := make(map[string][]string)
+	calledMethods := make(map[string]bool)
+	discards := make(map[string][]discardedCall)
+	dirPaths := make(map[string]string)
+	uses := make(map[string]bool)
+	var anonymous []surrealtypes.AnonymousType
+
+	// Process each file, type checking each package and each package it
+	// imports once.
+	cache := newPackageCache(files)
+	var fileErrors []surrealtypes.FileError
+	var ctxErr error
+	for i, f := range files {
+		if ctxErr = ctx.Err(); ctxErr != nil {
+			if !a.Partial {
+				return surrealtypes.AnalysisReport{}, ctxErr
+			}
+			for _, rest := range files[i:] {
+				fileErrors = append(fileErrors, surrealtypes.FileError{Path: rest.path, Error: ctxErr.Error()})
+			}
+			break
+		}
+		dirPaths[filepath.Dir(f.path)] = f.pkgPath
+		analysis, err := a.analyzeSource(f, cache)
+		if err != nil {
+			if a.Strict {
+				return surrealtypes.AnalysisReport{}, err
+			}
+			a.progress(ProgressEvent{Stage: ProgressFileParsed, Path: f.path, Index: i + 1, Total: len(files), Err: err})
+			fileErrors = append(fileErrors, surrealtypes.FileError{Path: f.path, Error: err.Error()})
+			continue
+		}
+		a.progress(ProgressEvent{Stage: ProgressFileParsed, Path: f.path, Index: i + 1, Total: len(files)})
+		// Merge functions from this file.
+		for _, fn := range analysis.Functions {
+			key := packageKey(fn.File, fn.Package) + "." + fn.Caller
+			functionMap[key] = fn
+			unresolved[key] = analysis.unresolved[fn.Caller]
+			discards[key] = analysis.discards[fn.Caller]
+		}
+		// Merge other collected types.
+		report.Structs = append(report.Structs, analysis.Structs...)
+		report.Interfaces = append(report.Interfaces, analysis.Interfaces...)
+		report.Types = append(report.Types, analysis.Types...)
+		report.Globals = append(report.Globals, analysis.Globals...)
+		report.Imports = append(report.Imports, analysis.Imports...)
+		report.Implements = append(report.Implements, analysis.Implements...)
+		report.Findings = append(report.Findings, analysis.Findings...)
+		report.LeakSuspects = append(report.LeakSuspects, analysis.LeakSuspects...)
+		report.InitActions = append(report.InitActions, analysis.InitActions...)
+		maps.Copy(calledMethods, analysis.calledMethods)
+		maps.Copy(uses, analysis.uses)
+		anonymous = append(anonymous, analysis.anonymousTypes...)
+	}
+	if ctxErr == nil && len(files) > 0 && len(fileErrors) == len(files) {
+		return surrealtypes.AnalysisReport{}, fmt.Errorf("no file could be analyzed: %s", fileErrors[0].Error)
+	}
+
+	// A declaration is kept once per package directory, so types of the
+	// same name in different packages stay distinct.
+	report.Structs = dedupe(report.Structs, func(st surrealtypes.StructDefinition) string {
+		return packageKey(st.File, st.Package) + "." + st.Name
+	})
+	report.Interfaces = dedupe(report.Interfaces, func(iface surrealtypes.InterfaceDefinition) string {
+		return packageKey(iface.File, iface.Package) + "." + iface.Name
+	})
+	report.Types = dedupe(report.Types, func(typ surrealtypes.TypeDefinition) string {
+		return packageKey(typ.File, typ.Package) + "." + typ.Name
+	})
+	report.Globals = dedupe(report.Globals, func(global surrealtypes.GlobalVariable) string {
+		return packageKey(global.File, global.Package) + "." + global.Name
+	})
+	report.Implements = dedupe(report.Implements, func(impl surrealtypes.InterfaceImplementation) string {
+		return packageKey(impl.File, impl.Package) + "." + impl.Struct + ":" + impl.Interface
+	})
+
+	// Build the final report
+	functions, groups, deadCode := resolvePackages(functionMap, unresolved, report.Globals, a.entryPoints())
+	report = surrealtypes.AnalysisReport{
+		Functions:       functions,
+		Structs:         report.Structs,
+		Interfaces:      report.Interfaces,
+		Types:           report.Types,
+		Globals:         report.Globals,
+		Imports:         report.Imports,
+		Implements:      report.Implements,
+		RecursionGroups: groups,
+		FileErrors:      fileErrors,
+		Findings:        report.Findings,
+		LeakSuspects:    report.LeakSuspects,
+		InitActions:     report.InitActions,
+	}
+	if ctxErr == nil {
+		report.Roots = slices.Clone(roots)
+	}
+	report.UnusedInterfaceMethods = unusedInterfaceMethods(report.Interfaces, calledMethods)
+	report.AnonymousTypes = countAnonymousTypes(anonymous)
+	report.DataRaceSuspects = DetectDataRaceSuspects(report.Functions)
+	report.ReceiverInconsistencies = DetectReceiverInconsistencies(report.Functions, report.Structs)
+	markIgnoredErrors(report.Functions, discards)
+	if a.Codeowners != nil {
+		assignOwners(report.Functions, a.Codeowners)
+	}
+	if a.Rules != nil {
+		a.Rules.Apply(report.Functions)
+	}
+	markHotspots(report.Functions, a.Metrics.Thresholds.withDefaults())
+	report.ImportCycles = importCycles(report.Imports, dirPaths)
+	linkAnalyzedCalls(report.Functions, dirPaths)
+
+	// Attach method sets to their types.
+	for i, st := range report.Structs {
+		report.Structs[i].Methods = methodNames(report.Functions, st.Package, st.Name)
+	}
+	for i, typ := range report.Types {
+		report.Types[i].Methods = methodNames(report.Functions, typ.Package, typ.Name)
+	}
+
+	DetectUnusedDeclarations(&report, &deadCode, uses)
+	sortReport(&report)
+	a.progress(ProgressEvent{Stage: ProgressAnalyzed, Total: len(files), PackageHits: cache.hits, PackageMisses: cache.misses, ImportHits: cache.imports.hits, ImportMisses: cache.imports.misses})
+	a.Report = report
+	return report, ctxErr
+}
+
+// dedupe returns items without those whose key was seen before.
+func dedupe[T any](items []T, key func(T) string) []T {
+	seen := make(map[string]bool, len(items))
+	return slices.DeleteFunc(items, func(item T) bool {
+		k := key(item)
+		if seen[k] {
+			return true
+		}
+		seen[k] = true
+		return false
+	})
+}
+
+// collectFiles returns the Go files under dirs on disk, without duplicates.
+// A path naming a file rather than a directory yields just that file.
+func (a *Analyzer) collectFiles(dirs []string) ([]sourceFile, error) {
+	var files []sourceFile
+	seen := make(map[string]bool)
+	pkgPaths := make(map[string]string)
+	for _, dir := range dirs {
+		a.progress(ProgressEvent{Stage: ProgressScanStart, Path: dir})
+		base, root := dir, "."
+		if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+			base, root = filepath.Dir(dir), filepath.Base(dir)
+		}
+		fsys := os.DirFS(base)
+		names, err := a.goFiles(fsys, root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+		}
+		for _, name := range names {
+			path := filepath.Join(base, filepath.FromSlash(name))
+			// The same file may be reached through relative and absolute paths.
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				abs = path
+			}
+			if !seen[abs] {
+				seen[abs] = true
+				pkgDir := filepath.Dir(abs)
+				if _, ok := pkgPaths[pkgDir]; !ok {
+					pkgPaths[pkgDir] = diskImportPath(pkgDir)
+				}
+				files = append(files, sourceFile{fsys: fsys, name: name, path: path, pkgPath: pkgPaths[pkgDir]})
+			}
+		}
+	}
+	return files, nil
+}
+
+// sortReport sorts every list in report by package and name, breaking ties
+// by file, so analyzing the same code always yields the same report.
+func sortReport(report *surrealtypes.AnalysisReport) {
+	slices.SortFunc(report.Functions, func(a, b surrealtypes.FunctionCall) int {
+		return cmp.Or(cmp.Compare(a.Package, b.Package), cmp.Compare(a.Caller, b.Caller), cmp.Compare(a.File, b.File))
+	})
+	slices.SortFunc(report.Structs, func(a, b surrealtypes.StructDefinition) int {
+		return cmp.Or(cmp.Compare(a.Package, b.Package), cmp.Compare(a.Name, b.Name), cmp.Compare(a.File, b.File))
+	})
+	slices.SortFunc(report.Interfaces, func(a, b surrealtypes.InterfaceDefinition) int {
+		return cmp.Or(cmp.Compare(a.Package, b.Package), cmp.Compare(a.Name, b.Name), cmp.Compare(a.File, b.File))
+	})
+	slices.SortFunc(report.Types, func(a, b surrealtypes.TypeDefinition) int {
+		return cmp.Or(cmp.Compare(a.Package, b.Package), cmp.Compare(a.Name, b.Name), cmp.Compare(a.File, b.File))
+	})
+	slices.SortFunc(report.Globals, func(a, b surrealtypes.GlobalVariable) int {
+		return cmp.Or(cmp.Compare(a.Package, b.Package), cmp.Compare(a.Name, b.Name), cmp.Compare(a.File, b.File))
+	})
+	slices.SortFunc(report.Imports, func(a, b surrealtypes.ImportDefinition) int {
+		return cmp.Or(cmp.Compare(a.Package, b.Package), cmp.Compare(a.Path, b.Path), cmp.Compare(a.File, b.File))
+	})
+	slices.SortFunc(report.Implements, func(a, b surrealtypes.InterfaceImplementation) int {
+		return cmp.Or(cmp.Compare(a.Package, b.Package), cmp.Compare(a.Struct, b.Struct), cmp.Compare(a.Interface, b.Interface), cmp.Compare(a.File, b.File))
+	})
+	// Within a package, variables are initialized before init functions
+	// run, each in file order as the go command passes files.
+	slices.SortFunc(report.InitActions, func(a, b surrealtypes.InitAction) int {
+		return cmp.Or(
+			cmp.Compare(packageKey(a.File, a.Package), packageKey(b.File, b.Package)),
+			cmp.Compare(initKindOrder(a.Kind), initKindOrder(b.Kind)),
+			cmp.Compare(a.File, b.File),
+			cmp.Compare(a.Line, b.Line),
+		)
+	})
+	slices.SortStableFunc(report.Findings, func(a, b surrealtypes.Finding) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line))
+	})
+	slices.SortStableFunc(report.LeakSuspects, func(a, b surrealtypes.LeakSuspect) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line))
+	})
+}
+
+// resolvePackages performs the analysis that needs every file of a package:
+// it links references to globals declared in other files of the same
+// package and detects recursion and dead code. functionMap and unresolved
+// are keyed by package key and caller. Recursion groups are returned with
+// members named package.Caller, numbered from one in the order returned.
+// Centrality is computed over the call graph of every package.
+func resolvePackages(functionMap map[string]surrealtypes.FunctionCall, unresolved map[string][]string, globals []surrealtypes.GlobalVariable, entryPoints []string) ([]surrealtypes.FunctionCall, [][]string, DeadCodeInfo) {
+	// Link references to globals declared in other files of the same package.
+	packageGlobals := make(map[string]bool)
+	for _, global := range globals {
+		packageGlobals[packageKey(global.File, global.Package)+"."+global.Name] = true
+	}
+	for key, names := range unresolved {
+		fn := functionMap[key]
+		for _, name := range names {
+			if packageGlobals[packageKey(fn.File, fn.Package)+"."+name] && !slices.Contains(fn.ReferencedGlobals, name) {
+				fn.ReferencedGlobals = append(fn.ReferencedGlobals, name)
+			}
+		}
+		functionMap[key] = fn
+	}
+	for key, fn := range functionMap {
+		if len(fn.UnguardedGlobalWrites) > 0 {
+			fn.UnguardedGlobalWrites = slices.DeleteFunc(fn.UnguardedGlobalWrites, func(name string) bool {
+				return !packageGlobals[packageKey(fn.File, fn.Package)+"."+name]
+			})
+			functionMap[key] = fn
+		}
+	}
+
+	// Callees are unqualified, so recursion and dead code are detected per
+	// package.
+	packages := make(map[string]map[string]surrealtypes.FunctionCall)
+	for _, fn := range functionMap {
+		pkg := packageKey(fn.File, fn.Package)
+		if packages[pkg] == nil {
+			packages[pkg] = make(map[string]surrealtypes.FunctionCall)
+		}
+		packages[pkg][fn.Caller] = fn
+	}
+
+	// Calls to names that are not functions of the package, such as
+	// conversions to types of other files when type checking failed, are
+	// dropped so no call edge dangles.
+	for pkg, functions := range packages {
+		for name, fn := range functions {
+			undeclared := func(callee string) bool {
+				_, ok := functions[callee]
+				return !ok
+			}
+			if !slices.ContainsFunc(fn.Callees, undeclared) {
+				continue
+			}
+			fn.Callees = slices.DeleteFunc(slices.Clone(fn.Callees), undeclared)
+			fn.CallSites = slices.DeleteFunc(slices.Clone(fn.CallSites), func(site surrealtypes.CallSite) bool {
+				return undeclared(site.Callee)
+			})
+			functions[name] = fn
+			functionMap[pkg+"."+name] = fn
+		}
+	}
+
+	type recursionGroup struct {
+		names []string // package.Caller
+		keys  []string // package key and caller
+	}
+	var groups []recursionGroup
+	resolved := make([]surrealtypes.FunctionCall, 0, len(functionMap))
+	var deadCode DeadCodeInfo
+	for pkg, functions := range packages {
+		functions, pkgGroups := recursionGroups(functions)
+		for _, members := range pkgGroups {
+			var group recursionGroup
+			for _, caller := range members {
+				group.names = append(group.names, functions[caller].Package+"."+caller)
+				group.keys = append(group.keys, pkg+"."+caller)
+			}
+			groups = append(groups, group)
+		}
+		// Functions ignoring dead code detection are roots, so what they
+		// call is used too.
+		roots := slices.Clone(entryPoints)
+		for name, fn := range functions {
+			if fn.IgnoresCheck(surrealtypes.CheckDeadCode) {
+				roots = append(roots, name)
+			}
+		}
+		unused := deadFunctions(functions, roots)
+		for _, fn := range functions {
+			fn.Metrics.IsUnused = slices.Contains(unused, fn.Caller)
+			resolved = append(resolved, fn)
+		}
+		deadCode.UnusedFunctions = append(deadCode.UnusedFunctions, unused...)
+	}
+
+	// Number the groups in a stable order.
+	slices.SortFunc(groups, func(a, b recursionGroup) int {
+		return cmp.Or(slices.Compare(a.names, b.names), slices.Compare(a.keys, b.keys))
+	})
+	groupIDs := make(map[string]int)
+	names := make([][]string, len(groups))
+	for i, group := range groups {
+		names[i] = group.names
+		for _, key := range group.keys {
+			groupIDs[key] = i + 1
+		}
+	}
+	for i, fn := range resolved {
+		resolved[i].RecursionGroupID = groupIDs[packageKey(fn.File, fn.Package)+"."+fn.Caller]
+	}
+
+	// Qualify callees like functionMap to rank the whole call graph.
+	graph := make(map[string]surrealtypes.FunctionCall, len(functionMap))
+	for key, fn := range functionMap {
+		pkg := packageKey(fn.File, fn.Package)
+		var callees []string
+		for _, callee := range fn.Callees {
+			if _, ok := functionMap[pkg+"."+callee]; ok {
+				callees = append(callees, pkg+"."+callee)
+			}
+		}
+		graph[key] = surrealtypes.FunctionCall{Caller: key, Callees: callees}
+	}
+	ranks := ComputePageRank(graph)
+	for i, fn := range resolved {
+		resolved[i].Metrics.Centrality = ranks[packageKey(fn.File, fn.Package)+"."+fn.Caller]
+	}
+	return resolved, names, deadCode
+}
+
+// deadFunctions returns the unused functions of a package. Production code
+// must be reachable from production code, so functions only called from
+// tests are unused. Test functions, with the exported Test, Benchmark, Fuzz
+// and Example functions as roots, may reach any function of the package.
+func deadFunctions(functions map[string]surrealtypes.FunctionCall, entryPoints []string) []string {
+	production := make(map[string]surrealtypes.FunctionCall, len(functions))
+	for name, fn := range functions {
+		if !fn.IsTest {
+			production[name] = fn
+		}
+	}
+	unused := DetectDeadCode(production, entryPoints).UnusedFunctions
+	if len(production) == len(functions) {
+		return unused
+	}
+	for _, name := range DetectDeadCode(functions, entryPoints).UnusedFunctions {
+		if functions[name].IsTest {
+			unused = append(unused, name)
 		}
-		// Merge other collected types.
-		report.Structs = append(report.Structs, analysis.Structs...)
-		report.Interfaces = append(report.Interfaces, analysis.Interfaces...)
-		report.Globals = append(report.Globals, analysis.Globals...)
-		report.Imports = append(report.Imports, analysis.Imports...)
-		report.Implements = append(report.Implements, analysis.Implements...)
 	}
+	return unused
+}
 
-	// Add recursion detection
-	functionMap = detectRecursion(functionMap)
+// isTestFile reports whether path is a Go test file.
+func isTestFile(path string) bool {
+	return strings.HasSuffix(path, "_test.go")
+}
 
-	// Build the final report
-	report = surrealtypes.AnalysisReport{
-		Functions:  make([]surrealtypes.FunctionCall, 0, len(functionMap)),
-		Structs:    report.Structs,
-		Interfaces: report.Interfaces,
-		Globals:    report.Globals,
-		Imports:    report.Imports,
-		Implements: report.Implements,
+// GenerateCodeSummary creates a summary report from analysis results. With
+// SeparateTests set, it summarizes production code and reports test code in
+// the summary's Tests section.
+func (a *Analyzer) GenerateCodeSummary(report surrealtypes.AnalysisReport) surrealtypes.CodeSummary {
+	metrics := a.Metrics
+	if metrics == nil {
+		metrics = NewMetricsAnalyzer()
 	}
-
-	// Convert map to slice
-	for _, fn := range functionMap {
-		report.Functions = append(report.Functions, fn)
+	summarize := func(report surrealtypes.AnalysisReport) surrealtypes.CodeSummary {
+		summary := codeSummary(report, metrics.Thresholds, a.entryPoints())
+		summary.Hotspots = rankHotspots(summary.Hotspots, metrics.HotspotOrder, metrics.TopHotspots)
+		return summary
+	}
+	if !a.SeparateTests {
+		return summarize(report)
 	}
+	production, tests := report.SplitTests()
+	summary := summarize(production)
+	testSummary := summarize(tests)
+	summary.Tests = &testSummary
+	return summary
+}
 
-	// Post-process: detect dead code.
-	deadCode := DetectDeadCode(functionMap, []string{"main", "complex"})
-	for i := range report.Functions {
-		report.Functions[i].Metrics.IsUnused = slices.Contains(deadCode.UnusedFunctions, report.Functions[i].Caller)
+// rankHotspots orders hotspots by the named ranking, by complexity if
+// unknown, then by file and name, and keeps the first top of them, or all
+// if top is zero.
+func rankHotspots(hotspots []surrealtypes.HotspotFunction, order string, top int) []surrealtypes.HotspotFunction {
+	key := func(a, b surrealtypes.HotspotFunction) int {
+		return cmp.Compare(b.Complexity, a.Complexity)
 	}
-	fmt.Println("Post-processing results...")
-	a.Report = report
-	return report, nil
+	switch order {
+	case HotspotsByMaintainability:
+		key = func(a, b surrealtypes.HotspotFunction) int {
+			return cmp.Compare(a.Maintainability, b.Maintainability)
+		}
+	case HotspotsByCognitive:
+		key = func(a, b surrealtypes.HotspotFunction) int {
+			return cmp.Compare(b.CognitiveComplexity, a.CognitiveComplexity)
+		}
+	case HotspotsByLines:
+		key = func(a, b surrealtypes.HotspotFunction) int {
+			return cmp.Compare(b.LinesOfCode, a.LinesOfCode)
+		}
+	}
+	slices.SortFunc(hotspots, func(a, b surrealtypes.HotspotFunction) int {
+		return cmp.Or(key(a, b), cmp.Compare(a.File, b.File), cmp.Compare(a.Name, b.Name))
+	})
+	if top > 0 && len(hotspots) > top {
+		hotspots = hotspots[:top]
+	}
+	return hotspots
 }
 
-// GenerateCodeSummary creates a summary report from analysis results.
-func (a *Analyzer) GenerateCodeSummary(report surrealtypes.AnalysisReport) surrealtypes.CodeSummary {
+// codeSummary summarizes report using thresholds. The deepest call chain is
+// searched from each of entryPoints in every package.
+func codeSummary(report surrealtypes.AnalysisReport, thresholds ComplexityThresholds, entryPoints []string) surrealtypes.CodeSummary {
 	summary := surrealtypes.CodeSummary{
 		ComplexityDistribution: make(map[string]int),
 	}
@@ -466,25 +2436,25 @@ func (a *Analyzer) GenerateCodeSummary(report surrealtypes.AnalysisReport) surre
 		if fn.IsDuplicate {
 			summary.DuplicateCode++
 		}
+		if fn.IsEmpty {
+			summary.StubFunctions++
+		}
+		if len(fn.ErrorsIgnoredBy) > 0 {
+			summary.UncheckedErrors++
+		}
 		totalComplexity += float64(fn.Metrics.CyclomaticComplexity)
 		totalMaintainability += fn.Metrics.Maintainability
 		totalNesting += float64(fn.Metrics.Readability.NestingDepth)
-		switch {
-		case fn.Metrics.CyclomaticComplexity <= 5:
-			summary.ComplexityDistribution["Low"]++
-		case fn.Metrics.CyclomaticComplexity <= 10:
-			summary.ComplexityDistribution["Medium"]++
-		default:
-			summary.ComplexityDistribution["High"]++
-		}
-		if isHotspot(fn.Metrics) {
-			issues := identifyIssues(fn.Metrics)
+		summary.ComplexityDistribution[thresholds.band(fn.Metrics.CyclomaticComplexity)]++
+		if issues := thresholds.hotspotIssues(fn); len(issues) > 0 {
 			hotspot := surrealtypes.HotspotFunction{
-				Name:            fn.Caller,
-				File:            fn.File,
-				Complexity:      fn.Metrics.CyclomaticComplexity,
-				Maintainability: fn.Metrics.Maintainability,
-				Issues:          issues,
+				Name:                fn.Caller,
+				File:                fn.File,
+				Complexity:          fn.Metrics.CyclomaticComplexity,
+				CognitiveComplexity: fn.Metrics.CognitiveComplexity.Score,
+				LinesOfCode:         fn.Metrics.LinesOfCode,
+				Maintainability:     fn.Metrics.Maintainability,
+				Issues:              issues,
 			}
 			summary.Hotspots = append(summary.Hotspots, hotspot)
 		}
@@ -495,12 +2465,82 @@ func (a *Analyzer) GenerateCodeSummary(report surrealtypes.AnalysisReport) surre
 		summary.AvgMaintainability = totalMaintainability / sf
 		summary.AvgNestingDepth = totalNesting / sf
 	}
-	sort.Slice(summary.Hotspots, func(i, j int) bool {
-		return summary.Hotspots[i].Complexity > summary.Hotspots[j].Complexity
-	})
+	summary.Files = report.FileMetrics()
+	summary.MixedReceivers = len(report.ReceiverInconsistencies)
+	summary.LeakSuspects = len(report.LeakSuspects)
+	summary.MaxCallDepth, summary.DeepestCallPath = deepestCallChain(report.Functions, entryPoints)
+	summary.MostCalled, summary.NeverCalled = fanIn(report.Functions, entryPoints)
 	return summary
 }
 
+// mostCalledCount is the number of functions reported as most called.
+const mostCalledCount = 10
+
+// fanIn returns the mostCalledCount functions with the most call sites in
+// functions, most called first, and the functions without any call site that
+// are not exported, entry points, init functions or closures, by location.
+// Recursive calls of a function to itself are not counted.
+func fanIn(functions []surrealtypes.FunctionCall, entryPoints []string) (mostCalled, neverCalled []surrealtypes.FunctionRef) {
+	calls := make(map[string]int)
+	for _, fn := range functions {
+		for _, site := range fn.CallSites {
+			if site.Callee != fn.Caller {
+				calls[packageKey(fn.File, fn.Package)+"."+site.Callee]++
+			}
+		}
+	}
+	for _, fn := range functions {
+		n := calls[packageKey(fn.File, fn.Package)+"."+fn.Caller]
+		ref := surrealtypes.FunctionRef{Function: fn.Caller, File: fn.File, Calls: n}
+		switch {
+		case n > 0:
+			mostCalled = append(mostCalled, ref)
+		case fn.IsClosure || strings.HasPrefix(fn.Caller, "init@") || slices.Contains(entryPoints, fn.Caller):
+		case !isExported(fn.Caller[strings.LastIndex(fn.Caller, ".")+1:]):
+			neverCalled = append(neverCalled, ref)
+		}
+	}
+	slices.SortFunc(mostCalled, func(a, b surrealtypes.FunctionRef) int {
+		return cmp.Or(cmp.Compare(b.Calls, a.Calls), cmp.Compare(a.File, b.File), cmp.Compare(a.Function, b.Function))
+	})
+	if len(mostCalled) > mostCalledCount {
+		mostCalled = mostCalled[:mostCalledCount]
+	}
+	slices.SortFunc(neverCalled, func(a, b surrealtypes.FunctionRef) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Function, b.Function))
+	})
+	return mostCalled, neverCalled
+}
+
+// deepestCallChain returns the longest call chain from any of entryPoints
+// in any package, with its functions named package.Caller. Of chains of
+// equal length, the first found in package key order is returned.
+func deepestCallChain(functions []surrealtypes.FunctionCall, entryPoints []string) (int, []string) {
+	packages := make(map[string]map[string]surrealtypes.FunctionCall)
+	for _, fn := range functions {
+		pkg := packageKey(fn.File, fn.Package)
+		if packages[pkg] == nil {
+			packages[pkg] = make(map[string]surrealtypes.FunctionCall)
+		}
+		packages[pkg][fn.Caller] = fn
+	}
+	var maxDepth int
+	var deepest []string
+	for _, pkg := range slices.Sorted(maps.Keys(packages)) {
+		for _, entry := range entryPoints {
+			depth, path := MaxCallDepth(packages[pkg], entry)
+			if depth <= maxDepth {
+				continue
+			}
+			maxDepth, deepest = depth, make([]string, len(path))
+			for i, name := range path {
+				deepest[i] = packages[pkg][name].Package + "." + name
+			}
+		}
+	}
+	return maxDepth, deepest
+}
+
 // -----------------------------------------------------------------------------
 // Helper Functions and Metrics Computation
 // -----------------------------------------------------------------------------
@@ -508,39 +2548,244 @@ func (a *Analyzer) GenerateCodeSummary(report surrealtypes.AnalysisReport) surre
 type DeadCodeInfo struct {
 	Reachable       map[string]bool
 	UnusedFunctions []string
+	UnusedImports   []string
+	UnusedGlobals   []string
 }
 
 func DetectDeadCode(functions map[string]surrealtypes.FunctionCall, entryPoints []string) DeadCodeInfo {
 	var info DeadCodeInfo
 	info.Reachable = make(map[string]bool)
+	graph := surrealtypes.NewCallGraph(functions)
+	var markReachable func(fname string)
+	markReachable = func(fname string) {
+		if info.Reachable[fname] {
+			return
+		}
+		info.Reachable[fname] = true
+		for _, callee := range graph.Successors(fname) {
+			markReachable(callee)
+		}
+	}
 	for _, entry := range entryPoints {
-		markReachable(entry, functions, info.Reachable)
+		markReachable(entry)
+	}
+	for fname := range functions {
+		if isExported(fname) || isInitFunction(fname) {
+			markReachable(fname)
+		}
 	}
+	// Closures are reachable whenever their enclosing function is.
 	for fname := range functions {
-		if isExported(fname) {
-			markReachable(fname, functions, info.Reachable)
+		if parent, _, ok := strings.Cut(fname, "$"); ok && (info.Reachable[parent] || isExported(parent) || slices.Contains(entryPoints, parent)) {
+			info.Reachable[fname] = true
 		}
 	}
 	for fname := range functions {
-		if !info.Reachable[fname] && !isExported(fname) && !slices.Contains(entryPoints, fname) {
+		if !info.Reachable[fname] && !isExported(fname) && !isInitFunction(fname) && !slices.Contains(entryPoints, fname) {
 			info.UnusedFunctions = append(info.UnusedFunctions, fname)
 		}
 	}
 	return info
 }
 
-func markReachable(fname string, functions map[string]surrealtypes.FunctionCall, reachable map[string]bool) {
-	if reachable[fname] {
-		return
+// MaxCallDepth returns the length, in functions, of the longest call chain
+// starting at entry and one such chain. functions are keyed by caller, and
+// callees that are not in functions are ignored. A chain never visits a
+// function twice, so recursion ends it. The depth is zero if entry is not
+// in functions.
+func MaxCallDepth(functions map[string]surrealtypes.FunctionCall, entry string) (int, []string) {
+	if _, ok := functions[entry]; !ok {
+		return 0, nil
 	}
-	reachable[fname] = true
-	if fn, exists := functions[fname]; exists {
-		for _, callee := range fn.Callees {
-			if !strings.Contains(callee, ".") {
-				markReachable(callee, functions, reachable)
+	// Chains are memoized unless they were cut short by a function on the
+	// current chain, since they then depend on how the function was reached.
+	memo := make(map[string][]string)
+	onPath := make(map[string]bool)
+	var longest func(name string) (path []string, cut bool)
+	longest = func(name string) ([]string, bool) {
+		if path, ok := memo[name]; ok {
+			return path, false
+		}
+		onPath[name] = true
+		var best []string
+		cut := false
+		for _, callee := range functions[name].Callees {
+			if _, ok := functions[callee]; !ok {
+				continue
+			}
+			if onPath[callee] {
+				cut = true
+				continue
+			}
+			path, calleeCut := longest(callee)
+			cut = cut || calleeCut
+			if len(path) > len(best) {
+				best = path
+			}
+		}
+		onPath[name] = false
+		path := append([]string{name}, best...)
+		if !cut {
+			memo[name] = path
+		}
+		return path, cut
+	}
+	path, _ := longest(entry)
+	return len(path), path
+}
+
+// PageRank parameters.
+const (
+	pageRankDamping       = 0.85
+	pageRankTolerance     = 1e-9 // total change in rank at which iteration stops
+	pageRankMaxIterations = 100
+)
+
+// ComputePageRank returns the PageRank of every function in the call graph
+// whose edges are the calls from each function to its Callees, keyed like
+// functions. Callees that are not in functions are ignored, and functions
+// without calls link to every function. The ranks sum to one.
+func ComputePageRank(functions map[string]surrealtypes.FunctionCall) map[string]float64 {
+	n := len(functions)
+	if n == 0 {
+		return map[string]float64{}
+	}
+	names := slices.Sorted(maps.Keys(functions))
+	out := make(map[string][]string, n)
+	for _, name := range names {
+		for _, callee := range functions[name].Callees {
+			if _, ok := functions[callee]; ok && !slices.Contains(out[name], callee) {
+				out[name] = append(out[name], callee)
+			}
+		}
+	}
+
+	rank := make(map[string]float64, n)
+	for _, name := range names {
+		rank[name] = 1 / float64(n)
+	}
+	for range pageRankMaxIterations {
+		dangling := 0.0
+		for _, name := range names {
+			if len(out[name]) == 0 {
+				dangling += rank[name]
+			}
+		}
+		next := make(map[string]float64, n)
+		base := (1-pageRankDamping)/float64(n) + pageRankDamping*dangling/float64(n)
+		for _, name := range names {
+			next[name] += base
+			for _, callee := range out[name] {
+				next[callee] += pageRankDamping * rank[name] / float64(len(out[name]))
+			}
+		}
+		delta := 0.0
+		for _, name := range names {
+			delta += math.Abs(next[name] - rank[name])
+		}
+		rank = next
+		if delta < pageRankTolerance {
+			break
+		}
+	}
+	return rank
+}
+
+// DetectUnusedDeclarations flags imports that nothing in the same file
+// references and unexported globals that nothing in the same package
+// references. Besides the dependencies of functions, uses holds the
+// references made anywhere in a file, such as in signatures, struct fields
+// and global initializers: imports keyed file:path and globals keyed by
+// package key and name. It sets IsUnused on the report entries and records
+// them in info.
+func DetectUnusedDeclarations(report *surrealtypes.AnalysisReport, info *DeadCodeInfo, uses map[string]bool) {
+	usedImports := make(map[string]bool)
+	usedGlobals := make(map[string]bool)
+	for _, fn := range report.Functions {
+		for _, dep := range fn.Dependencies {
+			usedImports[fn.File+":"+dep] = true
+		}
+		for _, global := range fn.ReferencedGlobals {
+			usedGlobals[packageKey(fn.File, fn.Package)+"."+global] = true
+		}
+	}
+	for i, imp := range report.Imports {
+		key := imp.File + ":" + imp.Path
+		if imp.ImportKind != surrealtypes.ImportBlank && !usedImports[key] && !uses[key] {
+			report.Imports[i].IsUnused = true
+			info.UnusedImports = append(info.UnusedImports, imp.Path)
+		}
+	}
+	for i, global := range report.Globals {
+		key := packageKey(global.File, global.Package) + "." + global.Name
+		if !isExported(global.Name) && global.Name != "_" && !usedGlobals[key] && !uses[key] {
+			report.Globals[i].IsUnused = true
+			info.UnusedGlobals = append(info.UnusedGlobals, global.Name)
+		}
+	}
+}
+
+// fileUses returns the imports and package-level names referenced anywhere
+// in file, keyed as DetectUnusedDeclarations expects. The file is type
+// checked on its own, so a name the checker could not resolve is counted
+// as a reference to a global of a sibling file.
+func fileUses(file *ast.File, path string, info *types.Info, checked *types.Package, importNames map[string]string, dotImports map[string]bool) map[string]bool {
+	uses := make(map[string]bool)
+	pkgKey := packageKey(path, file.Name.Name)
+	selected := make(map[*ast.Ident]bool)
+	for _, decl := range file.Decls {
+		ast.Inspect(decl, func(n ast.Node) bool {
+			switch node := n.(type) {
+			case *ast.SelectorExpr:
+				selected[node.Sel] = true
+			case *ast.Ident:
+				if _, ok := info.Defs[node]; ok || selected[node] {
+					return true
+				}
+				switch obj := info.Uses[node].(type) {
+				case nil:
+					if impPath, ok := importNames[node.Name]; ok {
+						uses[path+":"+impPath] = true
+					} else {
+						uses[pkgKey+"."+node.Name] = true
+					}
+				case *types.PkgName:
+					uses[path+":"+obj.Imported().Path()] = true
+				default:
+					if impPath := dotImported(node, info, dotImports); impPath != "" {
+						uses[path+":"+impPath] = true
+					} else if checked != nil && obj.Parent() == checked.Scope() {
+						uses[pkgKey+"."+node.Name] = true
+					}
+				}
+			}
+			return true
+		})
+	}
+	return uses
+}
+
+// unusedInterfaceMethods returns the methods of interfaces, named
+// package.Interface.Method, whose name is never called. Without type
+// information for every package, a call to any method of the same name
+// counts as a call to the interface method.
+func unusedInterfaceMethods(interfaces []surrealtypes.InterfaceDefinition, calledMethods map[string]bool) []string {
+	var unused []string
+	for _, iface := range interfaces {
+		for _, method := range iface.Methods {
+			if !calledMethods[method] {
+				unused = append(unused, iface.Package+"."+iface.Name+"."+method)
 			}
 		}
 	}
+	slices.Sort(unused)
+	return slices.Compact(unused)
+}
+
+// packageKey identifies the package a file belongs to by its directory and
+// package name, keeping same-named packages in different directories apart.
+func packageKey(file, pkg string) string {
+	return filepath.Dir(file) + ":" + pkg
 }
 
 func isExported(fname string) bool {
@@ -550,38 +2795,97 @@ func isExported(fname string) bool {
 	return unicode.IsUpper(rune(fname[0]))
 }
 
-func isHotspot(metrics surrealtypes.FunctionMetrics) bool {
-	return metrics.CyclomaticComplexity > 10 ||
-		metrics.Readability.NestingDepth > 4 ||
-		metrics.Maintainability < 50
+func (t ComplexityThresholds) isHotspot(fn surrealtypes.FunctionCall) bool {
+	metrics := fn.Metrics
+	return metrics.CyclomaticComplexity > t.MaxComplexity ||
+		metrics.Readability.NestingDepth > t.MaxNestingDepth ||
+		metrics.Maintainability < t.MinMaintainability ||
+		fn.ParamCount > t.MaxParams ||
+		metrics.StatementCount > t.MaxStatements ||
+		isGodFunction(fn)
+}
+
+// hotspotIssues returns the issues making fn a hotspot that it does not
+// ignore, or nil if fn is no hotspot. Issues without a check of their own
+// are only ignored by a directive ignoring every check.
+func (t ComplexityThresholds) hotspotIssues(fn surrealtypes.FunctionCall) []string {
+	if !t.isHotspot(fn) {
+		return nil
+	}
+	return slices.DeleteFunc(t.identifyIssues(fn), func(issue string) bool {
+		return fn.IgnoresCheck(issueChecks[issue])
+	})
+}
+
+// markHotspots sets the IsHotspot metric of each function with unignored
+// hotspot issues under t.
+func markHotspots(functions []surrealtypes.FunctionCall, t ComplexityThresholds) {
+	for i, fn := range functions {
+		functions[i].Metrics.IsHotspot = len(t.hotspotIssues(fn)) > 0
+	}
+}
+
+func isGodFunction(fn surrealtypes.FunctionCall) bool {
+	_, ok := godFunction(fn, DefaultSmellConfig())
+	return ok
 }
 
-func identifyIssues(metrics surrealtypes.FunctionMetrics) []string {
+// issueChecks maps hotspot issues to the check ignoring them.
+var issueChecks = map[string]string{
+	"High cyclomatic complexity": surrealtypes.CheckComplexity,
+	"Deep nesting":               surrealtypes.CheckComplexity,
+	"High cognitive complexity":  surrealtypes.CheckComplexity,
+	"Low maintainability":        surrealtypes.CheckMaintainability,
+}
+
+func (t ComplexityThresholds) identifyIssues(fn surrealtypes.FunctionCall) []string {
+	metrics := fn.Metrics
 	var issues []string
-	if metrics.CyclomaticComplexity > 10 {
+	if metrics.CyclomaticComplexity > t.MaxComplexity {
 		issues = append(issues, "High cyclomatic complexity")
 	}
-	if metrics.Readability.NestingDepth > 4 {
+	if metrics.Readability.NestingDepth > t.MaxNestingDepth {
 		issues = append(issues, "Deep nesting")
 	}
-	if metrics.Maintainability < 50 {
+	if metrics.Maintainability < t.MinMaintainability {
 		issues = append(issues, "Low maintainability")
 	}
-	if metrics.CognitiveComplexity.Score > 15 {
+	if metrics.CognitiveComplexity.Score > t.MaxCognitive {
 		issues = append(issues, "High cognitive complexity")
 	}
+	if fn.ParamCount > t.MaxParams {
+		issues = append(issues, "Long parameter list")
+	}
+	if metrics.StatementCount > t.MaxStatements {
+		issues = append(issues, "Long function")
+	}
+	if isGodFunction(fn) {
+		issues = append(issues, "God function")
+	}
 	return issues
 }
 
+// ComputeComplexity returns McCabe's cyclomatic complexity of node: one plus
+// a point for every if, for, range, non-default case (in expression switches,
+// type switches and selects alike), && and ||. The switch and select
+// statements themselves add nothing, and neither do break, continue or goto,
+// labeled or not.
 func ComputeComplexity(node ast.Node) int {
 	complexity := 1
 	ast.Inspect(node, func(n ast.Node) bool {
-		switch n.(type) {
-		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt, *ast.CaseClause, *ast.CommClause, *ast.SelectStmt:
+		switch n := n.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
 			complexity++
+		case *ast.CaseClause:
+			if n.List != nil {
+				complexity++
+			}
+		case *ast.CommClause:
+			if n.Comm != nil {
+				complexity++
+			}
 		case *ast.BinaryExpr:
-			bin := n.(*ast.BinaryExpr)
-			if bin.Op == token.LAND || bin.Op == token.LOR {
+			if n.Op == token.LAND || n.Op == token.LOR {
 				complexity++
 			}
 		}
@@ -597,6 +2901,58 @@ func ComputeLOC(fset *token.FileSet, node *ast.BlockStmt) int {
 	return fset.Position(node.End()).Line - fset.Position(node.Pos()).Line + 1
 }
 
+// ComputeSLOC returns the source lines of code of fn: the lines in its token
+// range holding at least one token. Blank lines and comment-only lines are
+// not counted, while lines with just a brace are.
+func ComputeSLOC(fset *token.FileSet, fn *ast.FuncDecl) int {
+	if fset == nil || fn == nil {
+		return 0
+	}
+	lines := make(map[int]bool)
+	mark := func(pos token.Pos) {
+		if pos.IsValid() {
+			lines[fset.Position(pos).Line] = true
+		}
+	}
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if _, ok := n.(*ast.CommentGroup); ok || n == nil {
+			return false
+		}
+		mark(n.Pos())
+		mark(n.End() - 1)
+		// Every line of a multi-line raw string is code.
+		if lit, ok := n.(*ast.BasicLit); ok && lit.ValuePos.IsValid() {
+			start, end := fset.Position(lit.Pos()).Line, fset.Position(lit.End()).Line
+			for line := start; line <= end; line++ {
+				lines[line] = true
+			}
+		}
+		return true
+	})
+	return len(lines)
+}
+
+// ComputeStatementCount returns the statements of fn at every nesting level,
+// including those of its closures, as a measure of length that does not
+// depend on formatting. Blocks are not statements of their own, and neither
+// are the empty statements of stray semicolons, but the case clauses of
+// switches and selects are.
+func ComputeStatementCount(fn *ast.FuncDecl) int {
+	if fn == nil || fn.Body == nil {
+		return 0
+	}
+	count := 0
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.BlockStmt, *ast.EmptyStmt:
+		case ast.Stmt:
+			count++
+		}
+		return true
+	})
+	return count
+}
+
 func CountLines(fn *ast.FuncDecl, fset *token.FileSet) int {
 	if fset == nil {
 		return 0
@@ -612,6 +2968,9 @@ type CodeReadabilityMetrics struct {
 	CommentDensity   float64
 	CyclomaticPoints int
 	BranchDensity    float64
+	NumLoops         int // for and range statements
+	NumSwitches      int // expression and type switches
+	NumSelects       int
 }
 
 func ComputeReadabilityMetrics(fn *ast.FuncDecl, fset *token.FileSet) CodeReadabilityMetrics {
@@ -620,51 +2979,83 @@ func ComputeReadabilityMetrics(fn *ast.FuncDecl, fset *token.FileSet) CodeReadab
 		branchCount  int
 		commentCount int
 		maxNesting   int
+		loops        int
+		switches     int
+		selects      int
 	}{}
-	var recReadability func(n ast.Node, currentNesting int)
-	recReadability = func(n ast.Node, currentNesting int) {
+	// A single pass keeps the enclosing nodes on a stack. The bodies of if,
+	// for, range, switch, type switch and select statements, and else
+	// branches, are nested one level deeper than their statement; the init
+	// statements of if and switch statements are not counted.
+	type frame struct {
+		node    ast.Node
+		nesting int
+	}
+	var stack []frame
+	ast.Inspect(fn, func(n ast.Node) bool {
 		if n == nil {
-			return
-		}
-		if currentNesting > acc.maxNesting {
-			acc.maxNesting = currentNesting
+			stack = stack[:len(stack)-1]
+			return true
 		}
-		switch node := n.(type) {
-		case *ast.IfStmt, *ast.ForStmt, *ast.SwitchStmt, *ast.SelectStmt:
-			acc.branchCount++
-			if ifNode, ok := node.(*ast.IfStmt); ok {
-				recReadability(ifNode.Cond, currentNesting)
-				recReadability(ifNode.Body, currentNesting+1)
-				if ifNode.Else != nil {
-					recReadability(ifNode.Else, currentNesting+1)
+		nesting := 0
+		if len(stack) > 0 {
+			parent := stack[len(stack)-1]
+			nesting = parent.nesting
+			switch p := parent.node.(type) {
+			case *ast.IfStmt:
+				if n == p.Init {
+					return false
+				}
+				if n == p.Body || n == p.Else {
+					nesting++
+				}
+			case *ast.ForStmt:
+				if n == p.Body {
+					nesting++
+				}
+			case *ast.RangeStmt:
+				if n == p.Body {
+					nesting++
+				}
+			case *ast.SwitchStmt:
+				if n == p.Init {
+					return false
+				}
+				if n == p.Body {
+					nesting++
+				}
+			case *ast.TypeSwitchStmt:
+				if n == p.Init {
+					return false
+				}
+				if n == p.Body {
+					nesting++
+				}
+			case *ast.SelectStmt:
+				if n == p.Body {
+					nesting++
 				}
-				return
-			}
-			if forNode, ok := node.(*ast.ForStmt); ok {
-				recReadability(forNode.Init, currentNesting)
-				recReadability(forNode.Cond, currentNesting)
-				recReadability(forNode.Post, currentNesting)
-				recReadability(forNode.Body, currentNesting+1)
-				return
-			}
-			if rangeNode, ok := node.(*ast.RangeStmt); ok {
-				recReadability(rangeNode.X, currentNesting)
-				recReadability(rangeNode.Body, currentNesting+1)
-				return
-			}
-			if switchNode, ok := node.(*ast.SwitchStmt); ok {
-				recReadability(switchNode.Tag, currentNesting)
-				recReadability(switchNode.Body, currentNesting+1)
-				return
 			}
+		}
+		acc.maxNesting = max(acc.maxNesting, nesting)
+		switch n.(type) {
+		case *ast.IfStmt:
+			acc.branchCount++
+		case *ast.ForStmt, *ast.RangeStmt:
+			acc.branchCount++
+			acc.loops++
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt:
+			acc.branchCount++
+			acc.switches++
+		case *ast.SelectStmt:
+			acc.branchCount++
+			acc.selects++
 		case *ast.Comment:
 			acc.commentCount++
 		}
-		for _, child := range children(n) {
-			recReadability(child, currentNesting)
-		}
-	}
-	recReadability(fn, 0)
+		stack = append(stack, frame{n, nesting})
+		return true
+	})
 	commentDensity, branchDensity := 0.0, 0.0
 	if loc > 0 {
 		commentDensity = float64(acc.commentCount) / float64(loc)
@@ -676,6 +3067,9 @@ func ComputeReadabilityMetrics(fn *ast.FuncDecl, fset *token.FileSet) CodeReadab
 		CommentDensity:   commentDensity,
 		CyclomaticPoints: acc.branchCount + 1,
 		BranchDensity:    branchDensity,
+		NumLoops:         acc.loops,
+		NumSwitches:      acc.switches,
+		NumSelects:       acc.selects,
 	}
 }
 
@@ -691,8 +3085,17 @@ func children(n ast.Node) []ast.Node {
 	return out
 }
 
-func calculateMaintainability(r CodeReadabilityMetrics, complexity int) float64 {
-	return 171 - 5.2*math.Log(float64(complexity)) - 0.23*float64(r.NestingDepth)
+// calculateMaintainability returns the maintainability index normalized to
+// [0, 100] along with the raw index, computed from the Halstead volume, the
+// cyclomatic complexity and the source lines of code:
+//
+//	MI = 171 - 5.2*ln(V) - 0.23*CC - 16.2*ln(SLOC)
+//
+// Volume and SLOC are floored at 1 so trivial functions score the maximum.
+func calculateMaintainability(volume float64, complexity, sloc int) (normalized, raw float64) {
+	raw = 171 - 5.2*math.Log(math.Max(volume, 1)) - 0.23*float64(complexity) - 16.2*math.Log(math.Max(float64(sloc), 1))
+	normalized = math.Max(0, math.Min(100, raw*100/171))
+	return normalized, raw
 }
 
 // CodeDuplicationDetector with thread safety.
@@ -707,9 +3110,16 @@ func NewCodeDuplicationDetector() *CodeDuplicationDetector {
 	}
 }
 
+// DetectDuplication reports whether the normalized body of fn was seen
+// before.
 func (c *CodeDuplicationDetector) DetectDuplication(fn *ast.FuncDecl) bool {
 	body := extractFunctionBody(fn)
-	hash := rabinKarpHash(body)
+	hash := fnv.New64a()
+	hash.Write([]byte(body))
+	return c.seenBefore(hash.Sum64(), body)
+}
+
+func (c *CodeDuplicationDetector) seenBefore(hash uint64, body string) bool {
 	c.mu.RLock()
 	_, exists := c.seen[hash]
 	c.mu.RUnlock()
@@ -722,53 +3132,99 @@ func (c *CodeDuplicationDetector) DetectDuplication(fn *ast.FuncDecl) bool {
 	return false
 }
 
+// extractFunctionBody returns the body of fn as gofmt prints it, without
+// comments, or "" if fn has no body. The body is printed without its
+// positions, so the layout of the source does not matter either.
 func extractFunctionBody(fn *ast.FuncDecl) string {
 	if fn.Body == nil {
 		return ""
 	}
-	// Remove comments and normalize whitespace
 	var buf strings.Builder
-	ast.Inspect(fn.Body, func(n ast.Node) bool {
-		switch node := n.(type) {
-		case *ast.Ident:
-			buf.WriteString(node.Name)
-			buf.WriteString(" ")
-		case *ast.BasicLit:
-			buf.WriteString(node.Value)
-			buf.WriteString(" ")
-		case *ast.BinaryExpr:
-			buf.WriteString(node.Op.String())
-			buf.WriteString(" ")
-		case *ast.ReturnStmt:
-			buf.WriteString("return ")
-		}
-		return true
-	})
-	return strings.TrimSpace(buf.String())
-}
-
-func rabinKarpHash(s string) uint64 {
-	// Placeholder hash function (replace with a proper implementation as needed)
-	var hash uint64
-	for i := 0; i < len(s); i++ {
-		hash = hash*31 + uint64(s[i])
+	if err := printer.Fprint(&buf, token.NewFileSet(), fn.Body); err != nil {
+		return ""
 	}
-	return hash
+	return buf.String()
 }
 
-// ComputeHalsteadMetrics is a stub for Halstead metric calculation.
+// ComputeHalsteadMetrics computes Halstead metrics for a function.
+// Operators are operator tokens, assignments, calls (keyed by callee),
+// indexing, selectors, parentheses and control keywords; operands are the
+// remaining identifiers and literals. Identifiers that name the declared or
+// called function are counted as part of their operator, not as operands.
 func ComputeHalsteadMetrics(fn *ast.FuncDecl) surrealtypes.HalsteadMetrics {
 	operators := make(map[string]int)
 	operands := make(map[string]int)
+	consumed := make(map[*ast.Ident]bool)
 
 	ast.Inspect(fn, func(n ast.Node) bool {
 		switch node := n.(type) {
+		case *ast.FuncDecl:
+			operators["func"]++
+			consumed[node.Name] = true
+		case *ast.FuncLit:
+			operators["func"]++
 		case *ast.BinaryExpr:
 			operators[node.Op.String()]++
 		case *ast.UnaryExpr:
 			operators[node.Op.String()]++
+		case *ast.StarExpr:
+			operators["*"]++
+		case *ast.AssignStmt:
+			operators[node.Tok.String()]++
+		case *ast.IncDecStmt:
+			operators[node.Tok.String()]++
+		case *ast.SendStmt:
+			operators["<-"]++
+		case *ast.CallExpr:
+			switch fun := node.Fun.(type) {
+			case *ast.Ident:
+				operators[fun.Name+"()"]++
+				consumed[fun] = true
+			case *ast.SelectorExpr:
+				operators[fun.Sel.Name+"()"]++
+				consumed[fun.Sel] = true
+			default:
+				operators["()"]++
+			}
+		case *ast.IndexExpr, *ast.IndexListExpr:
+			operators["[]"]++
+		case *ast.SliceExpr:
+			operators["[:]"]++
+		case *ast.SelectorExpr:
+			operators["."]++
+		case *ast.ParenExpr:
+			operators["()"]++
+		case *ast.KeyValueExpr:
+			operators[":"]++
+		case *ast.CompositeLit:
+			operators["{}"]++
+		case *ast.IfStmt:
+			operators["if"]++
+			if node.Else != nil {
+				operators["else"]++
+			}
+		case *ast.ForStmt:
+			operators["for"]++
+		case *ast.RangeStmt:
+			operators["range"]++
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt:
+			operators["switch"]++
+		case *ast.SelectStmt:
+			operators["select"]++
+		case *ast.CaseClause, *ast.CommClause:
+			operators["case"]++
+		case *ast.ReturnStmt:
+			operators["return"]++
+		case *ast.BranchStmt:
+			operators[node.Tok.String()]++
+		case *ast.GoStmt:
+			operators["go"]++
+		case *ast.DeferStmt:
+			operators["defer"]++
 		case *ast.Ident:
-			operands[node.Name]++
+			if !consumed[node] {
+				operands[node.Name]++
+			}
 		case *ast.BasicLit:
 			operands[node.Value]++
 		}
@@ -788,17 +3244,26 @@ func ComputeHalsteadMetrics(fn *ast.FuncDecl) surrealtypes.HalsteadMetrics {
 	}
 
 	volume := float64(N1+N2) * math.Log2(float64(n1+n2))
-	difficulty := float64(n1) * float64(N2) / (2.0 * float64(n2))
+	// A function without operands (e.g. an empty body) has no difficulty;
+	// avoid the NaN that 0/0 would produce and JSON cannot encode.
+	difficulty := 0.0
+	if n2 > 0 {
+		difficulty = float64(n1) * float64(N2) / (2.0 * float64(n2))
+	}
 	effort := difficulty * volume
 
 	return surrealtypes.HalsteadMetrics{
-		Volume:     volume,
-		Difficulty: difficulty,
-		Effort:     effort,
+		Operators:       N1,
+		Operands:        N2,
+		UniqueOperators: n1,
+		UniqueOperands:  n2,
+		Volume:          volume,
+		Difficulty:      difficulty,
+		Effort:          effort,
 	}
 }
 
-// ComputeCognitiveComplexity computes cognitive complexity via a recursive AST traversal.
+// CognitiveComplexity accumulates the cognitive complexity of a function.
 type CognitiveComplexity struct {
 	Score          int
 	NestedDepth    int
@@ -806,63 +3271,127 @@ type CognitiveComplexity struct {
 	BranchingScore int
 }
 
+// ComputeCognitiveComplexity computes the SonarSource cognitive complexity of
+// fn:
+//
+//   - if, for, range, switch, type switch and select cost 1 plus the current
+//     nesting level, and nest their bodies one level deeper;
+//   - else and else if cost 1 regardless of nesting;
+//   - each sequence of like boolean operators costs 1, so a && b && c costs 1
+//     and a && b || c costs 2;
+//   - break, continue and goto to a label cost 1;
+//   - function literals nest their bodies without costing anything.
+//
+// For example:
+//
+//	if x > 0 && y > 0 {           // +1 if, +1 &&
+//		return "a"
+//	} else if x < 0 || y < 0 {    // +1 else if, +1 ||
+//		return "b"
+//	} else {                      // +1 else
+//		for i := 0; i < x; i++ {  // +2 for at nesting 1
+//			if i > y {            // +3 if at nesting 2
+//				break
+//			}
+//		}
+//	}
+//
+// scores 10. NestedDepth is the deepest nesting level reached, LogicalOps the
+// number of && and || operators and BranchingScore the number of if, else,
+// loop, switch and select statements.
 func ComputeCognitiveComplexity(fn *ast.FuncDecl) surrealtypes.CognitiveComplexityMetrics {
 	cc := CognitiveComplexity{}
 	maxDepth := 0
-	var recursiveVisit func(n ast.Node, depth int)
-	recursiveVisit = func(n ast.Node, depth int) {
+	structural := func(nesting int) {
+		cc.BranchingScore++
+		cc.Score += 1 + nesting
+	}
+	var visit func(n ast.Node, nesting int)
+	var visitIf func(node *ast.IfStmt, nesting int)
+	visit = func(n ast.Node, nesting int) {
 		if n == nil {
 			return
 		}
-		if depth > maxDepth {
-			maxDepth = depth
+		if nesting > maxDepth {
+			maxDepth = nesting
 		}
 		switch node := n.(type) {
 		case *ast.IfStmt:
-			recursiveVisit(node.Cond, depth)
-			cc.BranchingScore++
-			cc.Score++
-			recursiveVisit(node.Body, depth+1)
-			if node.Else != nil {
-				recursiveVisit(node.Else, depth+1)
-			}
+			structural(nesting)
+			visitIf(node, nesting)
 			return
 		case *ast.ForStmt:
-			recursiveVisit(node.Init, depth)
-			recursiveVisit(node.Cond, depth)
-			recursiveVisit(node.Post, depth)
-			cc.BranchingScore++
-			cc.Score++
-			recursiveVisit(node.Body, depth+1)
+			visit(node.Init, nesting)
+			visit(node.Cond, nesting)
+			visit(node.Post, nesting)
+			structural(nesting)
+			visit(node.Body, nesting+1)
 			return
 		case *ast.RangeStmt:
-			recursiveVisit(node.X, depth)
-			cc.BranchingScore++
-			cc.Score++
-			recursiveVisit(node.Body, depth+1)
+			visit(node.X, nesting)
+			structural(nesting)
+			visit(node.Body, nesting+1)
 			return
 		case *ast.SwitchStmt:
-			recursiveVisit(node.Tag, depth)
-			cc.BranchingScore++
-			cc.Score++
-			recursiveVisit(node.Body, depth+1)
+			visit(node.Init, nesting)
+			visit(node.Tag, nesting)
+			structural(nesting)
+			visit(node.Body, nesting+1)
 			return
-		case *ast.BinaryExpr:
-			if node.Op == token.LAND || node.Op == token.LOR {
-				cc.LogicalOps++
+		case *ast.TypeSwitchStmt:
+			visit(node.Init, nesting)
+			visit(node.Assign, nesting)
+			structural(nesting)
+			visit(node.Body, nesting+1)
+			return
+		case *ast.SelectStmt:
+			structural(nesting)
+			visit(node.Body, nesting+1)
+			return
+		case *ast.FuncLit:
+			visit(node.Body, nesting+1)
+			return
+		case *ast.BranchStmt:
+			if node.Label != nil {
 				cc.Score++
 			}
-			recursiveVisit(node.X, depth)
-			recursiveVisit(node.Y, depth)
 			return
+		case *ast.BinaryExpr:
+			if node.Op == token.LAND || node.Op == token.LOR {
+				ops, operands := logicalSequence(node)
+				cc.LogicalOps += len(ops)
+				for i, op := range ops {
+					if i == 0 || op != ops[i-1] {
+						cc.Score++
+					}
+				}
+				for _, operand := range operands {
+					visit(operand, nesting)
+				}
+				return
+			}
 		}
 		for _, child := range children(n) {
-			recursiveVisit(child, depth)
+			visit(child, nesting)
 		}
 	}
-	recursiveVisit(fn.Body, 0)
-	if cc.BranchingScore > 0 {
-		cc.Score++
+	visitIf = func(node *ast.IfStmt, nesting int) {
+		visit(node.Init, nesting)
+		visit(node.Cond, nesting)
+		visit(node.Body, nesting+1)
+		switch els := node.Else.(type) {
+		case *ast.IfStmt:
+			cc.BranchingScore++
+			cc.Score++
+			visitIf(els, nesting)
+		case *ast.BlockStmt:
+			cc.BranchingScore++
+			cc.Score++
+			visit(els, nesting+1)
+		}
+	}
+	if fn.Body != nil {
+		visit(fn.Body, 0)
 	}
 	cc.NestedDepth = maxDepth
 
@@ -874,90 +3403,48 @@ func ComputeCognitiveComplexity(fn *ast.FuncDecl) surrealtypes.CognitiveComplexi
 	}
 }
 
-func findFunctionDecl(file *ast.File, name string) *ast.FuncDecl {
-	for _, decl := range file.Decls {
-		if fn, ok := decl.(*ast.FuncDecl); ok {
-			fullName := fn.Name.Name
-			if fn.Recv != nil && len(fn.Recv.List) > 0 {
-				recvType := simpleTypeString(fn.Recv.List[0].Type)
-				fullName = fmt.Sprintf("%s.%s", recvType, fn.Name.Name)
-			}
-			if fullName == name {
-				return fn
-			}
+// logicalSequence flattens a tree of && and || operators, looking through
+// parentheses, into its operators and operands in source order.
+func logicalSequence(expr ast.Expr) (ops []token.Token, operands []ast.Expr) {
+	var flatten func(e ast.Expr)
+	flatten = func(e ast.Expr) {
+		if bin, ok := ast.Unparen(e).(*ast.BinaryExpr); ok && (bin.Op == token.LAND || bin.Op == token.LOR) {
+			flatten(bin.X)
+			ops = append(ops, bin.Op)
+			flatten(bin.Y)
+			return
 		}
+		operands = append(operands, e)
 	}
-	return nil
+	flatten(expr)
+	return ops, operands
 }
 
-type functionNode struct {
-	name    string
-	index   int
-	lowlink int
-	inStack bool
+// DetectRecursion marks the functions that call themselves, directly or
+// through other functions.
+func DetectRecursion(functions map[string]surrealtypes.FunctionCall) map[string]surrealtypes.FunctionCall {
+	functions, _ = recursionGroups(functions)
+	return functions
 }
 
-func detectRecursion(functions map[string]surrealtypes.FunctionCall) map[string]surrealtypes.FunctionCall {
-	index := 0
-	stack := []string{}
-	recData := map[string]*functionNode{}
-
-	var tarjan func(caller string)
-	tarjan = func(caller string) {
-		rec := &functionNode{
-			name:    caller,
-			index:   index,
-			lowlink: index,
-			inStack: true,
-		}
-		recData[caller] = rec
-		index++
-		stack = append(stack, caller)
-
-		if fn, exists := functions[caller]; exists {
-			for _, callee := range fn.Callees {
-				if callee == caller {
-					fn.IsRecursive = true
-					functions[caller] = fn
-					continue
-				}
-
-				if data, found := recData[callee]; !found {
-					tarjan(callee)
-					rec.lowlink = min(rec.lowlink, recData[callee].lowlink)
-				} else if data.inStack {
-					rec.lowlink = min(rec.lowlink, data.index)
-				}
-			}
-		}
-
-		if rec.lowlink == rec.index {
-			var sccNodes []string
-			for {
-				n := stack[len(stack)-1]
-				stack = stack[:len(stack)-1]
-				recData[n].inStack = false
-				sccNodes = append(sccNodes, n)
-				if n == caller {
-					break
-				}
-			}
-			if len(sccNodes) > 1 {
-				for _, n := range sccNodes {
-					if fn, exists := functions[n]; exists {
-						fn.IsRecursive = true
-						functions[n] = fn
-					}
-				}
-			}
+// recursionGroups marks the recursive functions and returns the groups of
+// functions calling each other, i.e. the strongly connected components of
+// the call graph holding a cycle. A directly recursive function forms a
+// group of its own, as does one already marked recursive. Members of each
+// group are sorted.
+func recursionGroups(functions map[string]surrealtypes.FunctionCall) (map[string]surrealtypes.FunctionCall, [][]string) {
+	graph := surrealtypes.NewCallGraph(functions)
+	var groups [][]string
+	for _, component := range graph.SCCs() {
+		if name := component[0]; len(component) == 1 && !functions[name].IsRecursive && !slices.Contains(graph.Successors(name), name) {
+			continue
 		}
-	}
-
-	for caller := range functions {
-		if _, found := recData[caller]; !found {
-			tarjan(caller)
+		for _, name := range component {
+			fn := functions[name]
+			fn.IsRecursive = true
+			functions[name] = fn
 		}
+		groups = append(groups, component)
 	}
-
-	return functions
+	return functions, groups
 }
@@ -1,10 +1,17 @@
 package analysis_test
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/TFMV/surrealcode/analysis"
 	"github.com/TFMV/surrealcode/db"
@@ -185,6 +192,226 @@ func TestAnalyzer_GetAnalysis(t *testing.T) {
 	assert.Len(t, report.Functions, 1)
 }
 
+func TestAnalyzer_AnalyzeDirectory(t *testing.T) {
+	mock := db.NewMockDB()
+	analyzer := analysis.NewAnalyzerWithDB(mock)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+		type Person struct{ Name string }
+		func (p Person) Greet() string { return "hi " + p.Name }
+		func main() {}`), 0644))
+
+	require.NoError(t, analyzer.AnalyzeDirectory(context.Background(), dir))
+	assert.Equal(t, 1, mock.StoreCount())
+	assert.Len(t, mock.StoredFunctions(), 2)
+	require.Len(t, mock.StoredStructs(), 1)
+	assert.Equal(t, []string{"Greet"}, mock.StoredStructs()[0].Methods)
+	assert.Equal(t, analyzer.Report, mock.LastReport)
+}
+
+func TestAnalyzer_Prune(t *testing.T) {
+	mock := db.NewMockDB()
+	analyzer := analysis.NewAnalyzerWithDB(mock)
+
+	dir := t.TempDir()
+	for _, name := range []string{"main.go", "util.go"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("package main\n"), 0644))
+	}
+	require.NoError(t, analyzer.AnalyzeDirectory(context.Background(), dir))
+	require.NoError(t, os.Remove(filepath.Join(dir, "util.go")))
+
+	require.NoError(t, analyzer.Prune(context.Background(), dir))
+	assert.Equal(t, []string{dir}, mock.PrunedRoots)
+	assert.Equal(t, []string{filepath.Join(dir, "main.go")}, mock.PrunedFiles)
+
+	// Backends that cannot prune are reported rather than ignored.
+	jsonAnalyzer := analysis.NewAnalyzerWithDB(db.NewJSONFileDB(filepath.Join(dir, "out.json")))
+	assert.ErrorContains(t, jsonAnalyzer.Prune(context.Background(), dir), "does not support pruning")
+}
+
+func TestAnalyzer_Progress(t *testing.T) {
+	var events []analysis.ProgressEvent
+	analyzer := analysis.NewAnalyzerWithDB(db.NewMockDB())
+	analyzer.Progress = func(event analysis.ProgressEvent) {
+		events = append(events, event)
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("package main\n"), 0644))
+	}
+	require.NoError(t, analyzer.AnalyzeDirectory(context.Background(), dir))
+
+	var stages []analysis.ProgressStage
+	for _, event := range events {
+		stages = append(stages, event.Stage)
+	}
+	assert.Equal(t, []analysis.ProgressStage{
+		analysis.ProgressScanStart,
+		analysis.ProgressFileParsed,
+		analysis.ProgressFileParsed,
+		analysis.ProgressAnalyzed,
+		analysis.ProgressStoreStart,
+		analysis.ProgressDone,
+	}, stages)
+	assert.Equal(t, analysis.ProgressEvent{
+		Stage: analysis.ProgressFileParsed,
+		Path:  filepath.Join(dir, "b.go"),
+		Index: 2,
+		Total: 2,
+	}, events[2])
+}
+
+func TestAnalyzer_Logger(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() { undefined() }\n"), 0644))
+
+	// Nothing is written to stdout by default, so reports written there
+	// stay valid.
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	_, err = analysis.NewAnalyzerWithoutDB().GetAnalysis(context.Background(), dir)
+	os.Stdout = stdout
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	written, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Empty(t, string(written))
+
+	var logged bytes.Buffer
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	analyzer.Logger = slog.New(slog.NewTextHandler(&logged, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	_, err = analyzer.GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Contains(t, logged.String(), "level=WARN msg=\"Type checking skipped\"")
+	assert.NotContains(t, logged.String(), "level=INFO")
+}
+
+func TestAnalyzer_ParamCounts(t *testing.T) {
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	tmpFile := filepath.Join(t.TempDir(), "main.go")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(`package main
+		func f(a, b, c int, d, e string) {}
+		func g(x, y, z, w, v, u int) (n int, err error) { return 0, nil }`), 0644))
+
+	fa, err := analyzer.AnalyzeFile(tmpFile)
+	require.NoError(t, err)
+	require.Len(t, fa.Functions, 2)
+
+	f := fa.Functions[0]
+	assert.Equal(t, 5, f.ParamCount)
+	assert.Equal(t, []string{"int", "int", "int", "string", "string"}, f.Params)
+	assert.Equal(t, 0, f.ReturnCount)
+
+	g := fa.Functions[1]
+	assert.Equal(t, 6, g.ParamCount)
+	assert.Equal(t, 2, g.ReturnCount)
+
+	summary := analyzer.GenerateCodeSummary(types.AnalysisReport{Functions: fa.Functions})
+	require.Len(t, summary.Hotspots, 1)
+	assert.Equal(t, "g", summary.Hotspots[0].Name)
+	assert.Contains(t, summary.Hotspots[0].Issues, "Long parameter list")
+}
+
+func TestAnalyzer_Signatures(t *testing.T) {
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	tmpFile := filepath.Join(t.TempDir(), "main.go")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(`package main
+		func f(a int, rest ...string) {}
+		func g() (n int, err error) { return 0, nil }`), 0644))
+
+	fa, err := analyzer.AnalyzeFile(tmpFile)
+	require.NoError(t, err)
+	require.Len(t, fa.Functions, 2)
+
+	f := fa.Functions[0]
+	assert.Equal(t, []string{"int", "...string"}, f.Params)
+	assert.True(t, f.IsVariadic)
+	assert.Nil(t, f.ReturnNames)
+
+	g := fa.Functions[1]
+	assert.False(t, g.IsVariadic)
+	assert.Equal(t, []string{"int", "error"}, g.Returns)
+	assert.Equal(t, []string{"n", "err"}, g.ReturnNames)
+}
+
+func TestAnalyzer_NakedReturnsAndIgnoredErrors(t *testing.T) {
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	tmpFile := filepath.Join(t.TempDir(), "main.go")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(`package main
+		import (
+			"fmt"
+			"os"
+			"strconv"
+		)
+		func parse(s string) int {
+			x, _ := strconv.Atoi(s)
+			return x
+		}
+		func remove(path string) {
+			os.Remove(path)
+		}
+		func checked(s string) (n int, err error) {
+			n, err = strconv.Atoi(s)
+			fmt.Println(n)
+			return n, err
+		}
+		func naked(s string) (n int, err error) {
+			n, err = strconv.Atoi(s)
+			return
+		}`), 0644))
+
+	fa, err := analyzer.AnalyzeFile(tmpFile)
+	require.NoError(t, err)
+
+	got := map[string][2]bool{}
+	for _, fn := range fa.Functions {
+		got[fn.Caller] = [2]bool{fn.HasNakedReturn, fn.IgnoresErrors}
+	}
+	assert.Equal(t, map[string][2]bool{
+		"parse":   {false, true},
+		"remove":  {false, true},
+		"checked": {false, false},
+		"naked":   {true, false},
+	}, got)
+}
+
+func TestAnalyzer_EmptyFunctions(t *testing.T) {
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	tmpFile := filepath.Join(t.TempDir(), "main.go")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(`package main
+		func empty() {}
+		func commented() {
+			// Nothing to do yet.
+		}
+		func todo() { panic("TODO") }
+		func notImplemented() error { panic("not implemented") }
+		func failing() { panic("unreachable") }
+		func real() int { return 1 }`), 0644))
+
+	fa, err := analyzer.AnalyzeFile(tmpFile)
+	require.NoError(t, err)
+
+	got := map[string]bool{}
+	for _, fn := range fa.Functions {
+		got[fn.Caller] = fn.IsEmpty
+	}
+	assert.Equal(t, map[string]bool{
+		"empty":          true,
+		"commented":      true,
+		"todo":           true,
+		"notImplemented": true,
+		"failing":        false,
+		"real":           false,
+	}, got)
+
+	summary := analyzer.GenerateCodeSummary(types.AnalysisReport{Functions: fa.Functions})
+	assert.Equal(t, 4, summary.StubFunctions)
+}
+
 func BenchmarkDetectRecursion(b *testing.B) {
 	functions := map[string]types.FunctionCall{
 		"a": {Caller: "a", Callees: []string{"b"}},
@@ -253,3 +480,1345 @@ func TestAnalyzerMetrics(t *testing.T) {
 	assert.Greater(t, metrics.Maintainability, -200.0, "Should have maintainability index")
 	assert.False(t, metrics.IsUnused, "Should not be marked as unused")
 }
+
+func TestAnalyzer_StructMethodSets(t *testing.T) {
+	analyzer := analysis.NewAnalyzerWithoutDB()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "person.go"), []byte(`package main
+		type Person struct { Name string }
+		func (p Person) Greet() string { return "Hello, " + p.Name }
+		func (p *Person) Rename(name string) { p.Name = name }
+		func main() {}`), 0644))
+
+	report, err := analyzer.GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+	require.Len(t, report.Structs, 1)
+	assert.Equal(t, []string{"Greet", "Rename"}, report.Structs[0].Methods)
+
+	methods := report.MethodsOf("Person")
+	require.Len(t, methods, 2)
+	receivers := map[string]bool{}
+	for _, m := range methods {
+		receivers[m.Caller] = m.PointerReceiver
+	}
+	assert.Equal(t, map[string]bool{"Person.Greet": false, "*Person.Rename": true}, receivers)
+	for _, m := range methods {
+		assert.Equal(t, "Person", m.Struct, m.Caller)
+	}
+}
+
+func TestAnalyzer_NamedTypes(t *testing.T) {
+	analyzer := analysis.NewAnalyzerWithoutDB()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "temp.go"), []byte(`package main
+		import "fmt"
+		type ID = string
+		type Celsius float64
+		type Handler func(ID) error
+		func (c Celsius) String() string { return fmt.Sprintf("%.1f°C", float64(c)) }
+		func main() { fmt.Println(Celsius(21)) }`), 0644))
+
+	report, err := analyzer.GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Empty(t, report.Structs)
+	file := filepath.Join(dir, "temp.go")
+	assert.Equal(t, []types.TypeDefinition{
+		{Name: "Celsius", Underlying: "float64", File: file, Package: "main", Methods: []string{"String"}},
+		{Name: "Handler", Underlying: "func(ID) error", File: file, Package: "main", Methods: []string{}},
+		{Name: "ID", Underlying: "string", IsAlias: true, File: file, Package: "main", Methods: []string{}},
+	}, report.Types)
+
+	methods := report.MethodsOf("Celsius")
+	require.Len(t, methods, 1)
+	assert.Equal(t, "Celsius.String", methods[0].Caller)
+}
+
+func TestAnalyzer_GenericReceivers(t *testing.T) {
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	tmpFile := filepath.Join(t.TempDir(), "list.go")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(`package main
+		type List[T any] struct{ items []T }
+		func (l *List[T]) Push(v T) { l.items = append(l.items, v) }
+		func (l List[T]) Len() int { return len(l.items) }
+		type Pair[K comparable, V any] struct{ k K; v V }
+		func (p Pair[K, V]) Key() K { return p.k }`), 0644))
+
+	fa, err := analyzer.AnalyzeFile(tmpFile)
+	require.NoError(t, err)
+
+	structs := map[string]string{}
+	for _, fn := range fa.Functions {
+		structs[fn.Caller] = fn.Struct
+	}
+	assert.Equal(t, map[string]string{
+		"*List.Push": "List",
+		"List.Len":   "List",
+		"Pair.Key":   "Pair",
+	}, structs)
+}
+
+func TestAnalyzer_CloseIsIdempotent(t *testing.T) {
+	closed := 0
+	mock := db.NewMockDB()
+	mock.CloseFunc = func() error {
+		closed++
+		return nil
+	}
+	analyzer := &analysis.Analyzer{
+		ExprCache: expr.NewExprCache(100),
+		DB:        mock,
+	}
+
+	assert.NoError(t, analyzer.Close())
+	assert.NoError(t, analyzer.Close())
+	assert.Equal(t, 1, closed)
+
+	assert.NoError(t, analysis.NewAnalyzerWithoutDB().Close())
+}
+
+func TestAnalyzer_Timeout(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.go"), []byte(`package main
+		func a() {}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.go"), []byte(`package main
+		func b() {}`), 0644))
+
+	t.Run("slow store", func(t *testing.T) {
+		// The deadline passes only once storing has begun, so the analysis
+		// itself always completes.
+		ctx := &expiringContext{Context: context.Background(), done: make(chan struct{})}
+		var stored types.AnalysisReport
+		mock := db.NewMockDB()
+		mock.StoreAnalysisFunc = func(storeCtx context.Context, report types.AnalysisReport) error {
+			stored = report
+			ctx.expire()
+			select {
+			case <-storeCtx.Done():
+				return storeCtx.Err()
+			case <-time.After(time.Minute):
+				return nil
+			}
+		}
+
+		err := analysis.NewAnalyzerWithDB(mock).AnalyzeDirectory(ctx, dir)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Len(t, stored.Functions, 2)
+	})
+
+	t.Run("expired before analysis", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+		defer cancel()
+		<-ctx.Done()
+
+		analyzer := analysis.NewAnalyzerWithoutDB()
+		report, err := analyzer.GetAnalysis(ctx, dir)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Empty(t, report.Functions)
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		analyzer := analysis.NewAnalyzerWithoutDB()
+		analyzer.Partial = true
+		analyzer.Progress = func(event analysis.ProgressEvent) {
+			if event.Stage == analysis.ProgressFileParsed {
+				cancel()
+			}
+		}
+		report, err := analyzer.GetAnalysis(ctx, dir)
+		assert.ErrorIs(t, err, context.Canceled)
+		require.Len(t, report.Functions, 1)
+		assert.Equal(t, "a", report.Functions[0].Caller)
+		assert.Equal(t, []types.FileError{{Path: filepath.Join(dir, "b.go"), Error: context.Canceled.Error()}}, report.FileErrors)
+		assert.Equal(t, report.Functions, analyzer.Report.Functions)
+		// A partial report covers no root, so stores keep the files it missed.
+		assert.Empty(t, report.Roots)
+	})
+}
+
+// expiringContext is a context whose deadline passes when expire is called.
+type expiringContext struct {
+	context.Context
+	done chan struct{}
+}
+
+func (c *expiringContext) expire() { close(c.done) }
+
+func (c *expiringContext) Done() <-chan struct{} { return c.done }
+
+func (c *expiringContext) Err() error {
+	select {
+	case <-c.done:
+		return context.DeadlineExceeded
+	default:
+		return nil
+	}
+}
+
+func TestAnalyzer_UnusedImportsAndGlobals(t *testing.T) {
+	analyzer := analysis.NewAnalyzerWithoutDB()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+		import (
+			"fmt"
+			"strings"
+			conv "strconv"
+		)
+		var debug = false
+		func main() { fmt.Println(conv.Quote(version)) }`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "version.go"), []byte(`package main
+		var version = "1.0.0"`), 0644))
+	// Imports and globals referenced only outside function bodies are used.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "decls.go"), []byte(`package main
+		import (
+			"io"
+			"log"
+			"time"
+		)
+		const size = 4
+		var timeout = time.Second
+		var budget = timeout * 2
+		type buffer struct {
+			data   [size]byte
+			logger *log.Logger
+		}
+		func copyTo(w io.Writer) {}`), 0644))
+
+	report, err := analyzer.GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+
+	unusedImports := map[string]bool{}
+	for _, imp := range report.Imports {
+		unusedImports[imp.Path] = imp.IsUnused
+	}
+	assert.Equal(t, map[string]bool{"fmt": false, "strings": true, "strconv": false, "io": false, "log": false, "time": false}, unusedImports)
+
+	unusedGlobals := map[string]bool{}
+	for _, g := range report.Globals {
+		unusedGlobals[g.Name] = g.IsUnused
+	}
+	assert.Equal(t, map[string]bool{"debug": true, "version": false, "size": false, "timeout": false, "budget": true}, unusedGlobals)
+	assert.Equal(t, []string{dir}, report.Roots)
+
+	require.Len(t, report.Functions, 2)
+	for _, fn := range report.Functions {
+		if fn.Caller == "main" {
+			assert.ElementsMatch(t, []string{"fmt", "strconv"}, fn.Dependencies)
+			assert.Equal(t, []string{"version"}, fn.ReferencedGlobals)
+		}
+	}
+}
+
+func TestAnalyzer_ImportLocalNames(t *testing.T) {
+	analyzer := analysis.NewAnalyzerWithoutDB()
+
+	tests := []struct {
+		name      string
+		input     string
+		wantNames map[string]string
+		wantDeps  []string
+	}{
+		{
+			name: "aliased import",
+			input: `package main
+				import r "math/rand"
+				func roll() int { return r.Intn(6) }`,
+			wantNames: map[string]string{"math/rand": "r"},
+			wantDeps:  []string{"math/rand"},
+		},
+		{
+			name: "crypto/rand vs math/rand",
+			input: `package main
+				import (
+					crand "crypto/rand"
+					"math/rand"
+				)
+				func roll() int { return rand.Intn(6) }`,
+			wantNames: map[string]string{"crypto/rand": "crand", "math/rand": "rand"},
+			wantDeps:  []string{"math/rand"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpFile := filepath.Join(t.TempDir(), "main.go")
+			require.NoError(t, os.WriteFile(tmpFile, []byte(tt.input), 0644))
+
+			fa, err := analyzer.AnalyzeFile(tmpFile)
+			require.NoError(t, err)
+
+			names := map[string]string{}
+			for _, imp := range fa.Imports {
+				names[imp.Path] = imp.Name
+			}
+			assert.Equal(t, tt.wantNames, names)
+			require.Len(t, fa.Functions, 1)
+			assert.Equal(t, tt.wantDeps, fa.Functions[0].Dependencies)
+		})
+	}
+}
+
+func TestAnalyzer_IncludeClosures(t *testing.T) {
+	input := `package main
+		func counters() []func() int {
+			var fns []func() int
+			for i := 0; i < 3; i++ {
+				fns = append(fns, func() int { return i })
+			}
+			return fns
+		}`
+	tmpFile := filepath.Join(t.TempDir(), "main.go")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(input), 0644))
+
+	find := func(fa analysis.FileAnalysis, name string) *types.FunctionCall {
+		for i := range fa.Functions {
+			if fa.Functions[i].Caller == name {
+				return &fa.Functions[i]
+			}
+		}
+		return nil
+	}
+
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	fa, err := analyzer.AnalyzeFile(tmpFile)
+	require.NoError(t, err)
+	assert.Nil(t, find(fa, "counters$1"))
+
+	analyzer.IncludeClosures = true
+	fa, err = analyzer.AnalyzeFile(tmpFile)
+	require.NoError(t, err)
+	closure := find(fa, "counters$1")
+	require.NotNil(t, closure)
+	assert.True(t, closure.IsClosure)
+	assert.Equal(t, []string{"i"}, closure.Captures)
+	assert.Equal(t, []string{"int"}, closure.Returns)
+	assert.Equal(t, 1, closure.Metrics.CyclomaticComplexity)
+}
+
+func TestAnalyzer_MultipleRoots(t *testing.T) {
+	root := t.TempDir()
+	svcA := filepath.Join(root, "svc-a")
+	svcB := filepath.Join(root, "svc-b")
+	for _, dir := range []string{svcA, svcB} {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "util.go"), []byte(`package util
+			func Join(a, b string) string { return a + b }
+			func unused() {}`), 0644))
+	}
+
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	report, err := analyzer.GetAnalysis(context.Background(), svcA, svcB)
+	require.NoError(t, err)
+
+	files := map[string]bool{}
+	for _, fn := range report.Functions {
+		if fn.Caller == "Join" {
+			files[fn.File] = true
+		}
+		if fn.Caller == "unused" {
+			assert.True(t, fn.Metrics.IsUnused)
+		}
+	}
+	assert.Len(t, report.Functions, 4)
+	assert.Equal(t, map[string]bool{
+		filepath.Join(svcA, "util.go"): true,
+		filepath.Join(svcB, "util.go"): true,
+	}, files)
+}
+
+func TestAnalyzer_SkipNestedModules(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "tools")
+	require.NoError(t, os.MkdirAll(nested, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "main.go"), []byte(`package main
+		func main() {}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(nested, "go.mod"), []byte("module tools\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(nested, "tool.go"), []byte(`package tools
+		func Run() {}`), 0644))
+
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	report, err := analyzer.GetAnalysis(context.Background(), root)
+	require.NoError(t, err)
+	assert.Len(t, report.Functions, 2)
+
+	analyzer.SkipNestedModules = true
+	report, err = analyzer.GetAnalysis(context.Background(), root)
+	require.NoError(t, err)
+	assert.Len(t, report.Functions, 1)
+}
+
+func TestAnalyzer_AnalyzeFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"src/main.go": {Data: []byte(`package main
+			type Person struct{ Name string }
+			func (p Person) Greet() string { return greeting + p.Name }
+			func main() { Person{}.Greet() }`)},
+		"src/vars.go":         {Data: []byte("package main\nvar greeting = \"hi \"\n")},
+		"src/README.md":       {Data: []byte("# not Go\n")},
+		"src/nested/go.mod":   {Data: []byte("module nested\n")},
+		"src/nested/lib.go":   {Data: []byte("package lib\nfunc Lib() {}\n")},
+		"elsewhere/skip.go":   {Data: []byte("package skip\nfunc Skip() {}\n")},
+		"src/util/helpers.go": {Data: []byte("package util\nfunc Help() {}\n")},
+	}
+
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	report, err := analyzer.AnalyzeFS(context.Background(), fsys, "src")
+	require.NoError(t, err)
+
+	files := map[string][]string{}
+	for _, fn := range report.Functions {
+		files[fn.File] = append(files[fn.File], fn.Caller)
+	}
+	assert.Equal(t, map[string][]string{
+		"src/main.go":         {"Person.Greet", "main"},
+		"src/nested/lib.go":   {"Lib"},
+		"src/util/helpers.go": {"Help"},
+	}, sortedValues(files))
+	require.Len(t, report.Globals, 1)
+	assert.Equal(t, "src/vars.go", report.Globals[0].File)
+	require.Len(t, report.Structs, 1)
+	assert.Equal(t, []string{"Greet"}, report.Structs[0].Methods)
+
+	for _, fn := range report.Functions {
+		if fn.Caller == "Person.Greet" {
+			assert.Equal(t, []string{"greeting"}, fn.ReferencedGlobals)
+		}
+	}
+}
+
+func sortedValues(m map[string][]string) map[string][]string {
+	for k := range m {
+		slices.Sort(m[k])
+	}
+	return m
+}
+
+func TestAnalyzer_GetAnalysisDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+		type Zebra struct{}
+		type Apple struct{}
+		func (Zebra) Run() {}
+		func zeta() {}
+		func alpha() { zeta() }
+		func main() { alpha() }`), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "lib"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "lib.go"), []byte(`package lib
+		var B, A = 1, 2
+		func Mid() {}
+		func Beta() {}`), 0644))
+
+	names := func(report types.AnalysisReport) []string {
+		var out []string
+		for _, fn := range report.Functions {
+			out = append(out, fn.Package+"."+fn.Caller)
+		}
+		for _, st := range report.Structs {
+			out = append(out, st.Package+"."+st.Name)
+		}
+		for _, g := range report.Globals {
+			out = append(out, g.Package+"."+g.Name)
+		}
+		return out
+	}
+
+	first, err := analysis.NewAnalyzerWithoutDB().GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"lib.Beta", "lib.Mid",
+		"main.Zebra.Run", "main.alpha", "main.main", "main.zeta",
+		"main.Apple", "main.Zebra",
+		"lib.A", "lib.B",
+	}, names(first))
+
+	for i := 0; i < 5; i++ {
+		again, err := analysis.NewAnalyzerWithoutDB().GetAnalysis(context.Background(), dir)
+		require.NoError(t, err)
+		assert.Equal(t, first, again)
+	}
+}
+
+func TestAnalyzer_CallSites(t *testing.T) {
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	tmpFile := filepath.Join(t.TempDir(), "main.go")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(`package main
+
+import "strings"
+
+type Counter struct{ n int }
+
+func (c *Counter) Inc() { c.n++ }
+
+func step(s string) string { return strings.TrimSpace(s) }
+
+func run(items []string) int {
+	var c Counter
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		out = append(out, step(item))
+		c.Inc()
+	}
+	return len(step(strings.Join(out, ",")))
+}
+`), 0644))
+
+	fa, err := analyzer.AnalyzeFile(tmpFile)
+	require.NoError(t, err)
+
+	var run types.FunctionCall
+	for _, fn := range fa.Functions {
+		if fn.Caller == "run" {
+			run = fn
+		}
+	}
+	require.Equal(t, "run", run.Caller)
+	assert.Equal(t, []string{"step", "*Counter.Inc"}, run.Callees)
+	assert.Equal(t, []types.CallSite{
+		{Callee: "step", Line: 15, Col: 21},
+		{Callee: "*Counter.Inc", Line: 16, Col: 5},
+		{Callee: "step", Line: 18, Col: 13},
+	}, run.CallSites)
+}
+
+func TestAnalyzer_GenerateCodeSummaryThresholds(t *testing.T) {
+	fn := func(name string, complexity int) types.FunctionCall {
+		return types.FunctionCall{
+			Caller: name,
+			File:   "main.go",
+			Metrics: types.FunctionMetrics{
+				CyclomaticComplexity: complexity,
+				Maintainability:      80,
+			},
+		}
+	}
+	report := types.AnalysisReport{Functions: []types.FunctionCall{fn("small", 3), fn("medium", 8), fn("large", 12)}}
+
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	summary := analyzer.GenerateCodeSummary(report)
+	assert.Equal(t, map[string]int{"Low": 1, "Medium": 1, "High": 1}, summary.ComplexityDistribution)
+	require.Len(t, summary.Hotspots, 1)
+	assert.Equal(t, "large", summary.Hotspots[0].Name)
+
+	// A team accepting complexity up to 15 sees no high-complexity code.
+	analyzer.Metrics.Thresholds.MediumComplexity = 15
+	analyzer.Metrics.Thresholds.MaxComplexity = 15
+	summary = analyzer.GenerateCodeSummary(report)
+	assert.Equal(t, map[string]int{"Low": 1, "Medium": 2}, summary.ComplexityDistribution)
+	assert.Empty(t, summary.Hotspots)
+}
+
+func TestAnalyzer_HotspotFlags(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+		func branchy(a, b, c, d int) int {
+			if a > 0 { return 1 }
+			if b > 0 { return 2 }
+			if c > 0 { return 3 }
+			if d > 0 { return 4 }
+			return 0
+		}
+		func main() { branchy(1, 2, 3, 4) }`), 0644))
+
+	hotspots := func(analyzer *analysis.Analyzer) (map[string]bool, float64) {
+		report, err := analyzer.GetAnalysis(context.Background(), dir)
+		require.NoError(t, err)
+		flags := map[string]bool{}
+		for _, fn := range report.Functions {
+			flags[fn.Caller] = fn.Metrics.IsHotspot
+		}
+		return flags, report.HealthScore()
+	}
+
+	flags, score := hotspots(analysis.NewAnalyzerWithoutDB())
+	assert.Equal(t, map[string]bool{"branchy": false, "main": false}, flags)
+
+	// The health score counts hotspots under the configured thresholds.
+	strict := analysis.NewAnalyzerWithoutDB()
+	strict.Metrics.Thresholds.MaxComplexity = 3
+	strictFlags, strictScore := hotspots(strict)
+	assert.Equal(t, map[string]bool{"branchy": true, "main": false}, strictFlags)
+	assert.InDelta(t, score-15, strictScore, 0.01)
+}
+
+func TestAnalyzer_RecursionGroups(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+		func a(n int) { if n > 0 { b(n - 1) } }
+		func b(n int) { c(n) }
+		func c(n int) { a(n) }
+		func fact(n int) int { if n == 0 { return 1 }; return n * fact(n-1) }
+		func main() { a(3); fact(3) }`), 0644))
+
+	report, err := analysis.NewAnalyzerWithoutDB().GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"main.a", "main.b", "main.c"}, {"main.fact"}}, report.RecursionGroups)
+
+	ids := map[string]int{}
+	for _, fn := range report.Functions {
+		ids[fn.Caller] = fn.RecursionGroupID
+	}
+	assert.Equal(t, map[string]int{"a": 1, "b": 1, "c": 1, "fact": 2, "main": 0}, ids)
+}
+
+func TestAnalyzer_PartialAnalysis(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "good.go"), []byte("package main\nfunc main() {}\n"), 0644))
+	broken := filepath.Join(dir, "broken.go")
+	require.NoError(t, os.WriteFile(broken, []byte("package main\nfunc oops( {\n"), 0644))
+
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	report, err := analyzer.GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+	require.Len(t, report.Functions, 1)
+	assert.Equal(t, "main", report.Functions[0].Caller)
+	require.Len(t, report.FileErrors, 1)
+	assert.Equal(t, broken, report.FileErrors[0].Path)
+	assert.Contains(t, report.FileErrors[0].Error, "failed to parse")
+
+	analyzer.Strict = true
+	_, err = analyzer.GetAnalysis(context.Background(), dir)
+	assert.ErrorContains(t, err, "failed to parse")
+
+	// Without a single parsable file there is nothing to report.
+	analyzer.Strict = false
+	_, err = analyzer.GetAnalysis(context.Background(), broken)
+	assert.ErrorContains(t, err, "no file could be analyzed")
+}
+
+func TestAnalyzer_SeparateTests(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo.go"), []byte(`package foo
+		func Foo() int { return helper() }
+		func helper() int { return 1 }
+		func onlyTested() int { return 2 }`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo_test.go"), []byte(`package foo
+		import "testing"
+		func TestFoo(t *testing.T) { check(t, Foo()+onlyTested()) }
+		func check(t *testing.T, n int) { if n == 0 { t.Fatal(n) } }
+		func leftover() {}`), 0644))
+
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	report, err := analyzer.GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+
+	type status struct{ test, unused bool }
+	got := map[string]status{}
+	for _, fn := range report.Functions {
+		got[fn.Caller] = status{fn.IsTest, fn.Metrics.IsUnused}
+	}
+	assert.Equal(t, map[string]status{
+		"Foo":        {false, false},
+		"helper":     {false, false},
+		"onlyTested": {false, true}, // only reachable from tests
+		"TestFoo":    {true, false},
+		"check":      {true, false},
+		"leftover":   {true, true},
+	}, got)
+
+	summary := analyzer.GenerateCodeSummary(report)
+	assert.Equal(t, 6, summary.TotalFunctions)
+	assert.Nil(t, summary.Tests)
+
+	analyzer.SeparateTests = true
+	summary = analyzer.GenerateCodeSummary(report)
+	assert.Equal(t, 3, summary.TotalFunctions)
+	require.NotNil(t, summary.Tests)
+	assert.Equal(t, 3, summary.Tests.TotalFunctions)
+	assert.Equal(t, 1, summary.Tests.UnusedFunctions)
+}
+
+func TestAnalyzer_Locals(t *testing.T) {
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	analyzer.Locals = true
+	tmpFile := filepath.Join(t.TempDir(), "main.go")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(`package main
+
+import "strconv"
+
+func parse(s string) (n int, err error) {
+	x := 5
+	n, err = strconv.Atoi(s)
+	if err != nil {
+		n, err := strconv.Atoi("0" + s)
+		return n, err
+	}
+	return n, nil
+}
+
+func main() { parse("1") }
+`), 0644))
+
+	report, err := analyzer.GetAnalysis(context.Background(), tmpFile)
+	require.NoError(t, err)
+	assert.Equal(t, []types.Finding{
+		{Func: "parse", File: tmpFile, Var: "x", Line: 6, Kind: types.FindingUnused},
+		{Func: "parse", File: tmpFile, Var: "n", Line: 9, Kind: types.FindingShadowed},
+		{Func: "parse", File: tmpFile, Var: "err", Line: 9, Kind: types.FindingShadowed},
+	}, report.Findings)
+
+	// Findings are only reported on request.
+	analyzer.Locals = false
+	report, err = analyzer.GetAnalysis(context.Background(), tmpFile)
+	require.NoError(t, err)
+	assert.Empty(t, report.Findings)
+}
+
+func TestAnalyzer_ConstBlocks(t *testing.T) {
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	tmpFile := filepath.Join(t.TempDir(), "level.go")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(`package main
+
+type Level int
+
+const (
+	A Level = iota
+	B
+	C
+)
+
+const X, Y = 1, 2
+
+var debug = true
+`), 0644))
+
+	fa, err := analyzer.AnalyzeFile(tmpFile)
+	require.NoError(t, err)
+
+	type global struct {
+		Value   string
+		IsConst bool
+	}
+	globals := map[string]global{}
+	for _, g := range fa.Globals {
+		globals[g.Name] = global{g.Value, g.IsConst}
+		if g.Name == "B" || g.Name == "C" {
+			assert.Equal(t, "Level", g.Type, g.Name)
+		}
+	}
+	assert.Equal(t, map[string]global{
+		"A":     {"iota", true},
+		"B":     {"iota", true},
+		"C":     {"iota", true},
+		"X":     {"1", true},
+		"Y":     {"2", true},
+		"debug": {"true", false},
+	}, globals)
+}
+
+func TestAnalyzer_DedupeDeclarations(t *testing.T) {
+	dir := t.TempDir()
+	for _, pkg := range []string{"a", "b"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, pkg), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, pkg, "config.go"), []byte("package "+pkg+"\ntype Config struct{}\n"), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a", "load.go"), []byte("package a\nfunc Load() Config { return Config{} }\n"), 0644))
+
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	report, err := analyzer.GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+	var packages []string
+	for _, st := range report.Structs {
+		assert.Equal(t, "Config", st.Name)
+		packages = append(packages, st.Package)
+	}
+	assert.Equal(t, []string{"a", "b"}, packages)
+
+	// Reaching the same package twice yields its declarations once.
+	t.Chdir(dir)
+	report, err = analyzer.GetAnalysis(context.Background(), "a", filepath.Join(dir, "a"))
+	require.NoError(t, err)
+	require.Len(t, report.Structs, 1)
+	assert.Equal(t, "a", report.Structs[0].Package)
+}
+
+func TestAnalyzer_UnusedInterfaceMethods(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "iface.go"), []byte(`package main
+
+type Doer interface {
+	Foo()
+	Bar()
+}
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+func run(d Doer) { d.Foo() }
+
+func main() { run(nil) }
+`), 0644))
+
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	report, err := analyzer.GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.Doer.Bar"}, report.UnusedInterfaceMethods)
+}
+
+func TestAnalyzer_DocComments(t *testing.T) {
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	report, err := analyzer.GetAnalysis(context.Background(), filepath.Join("..", "demo"))
+	require.NoError(t, err)
+
+	var add types.FunctionCall
+	for _, fn := range report.Functions {
+		if fn.Caller == "MathOps.Add" {
+			add = fn
+		}
+	}
+	assert.Equal(t, "Add performs addition\n", add.Doc)
+	// Doc comments are not source lines.
+	assert.Equal(t, 3, add.Metrics.SLOC)
+
+	require.Len(t, report.Structs, 1)
+	assert.Equal(t, "MathOps implements Calculator\n", report.Structs[0].Doc)
+	require.Len(t, report.Interfaces, 1)
+	assert.Equal(t, "Calculator defines an interface for mathematical operations\n", report.Interfaces[0].Doc)
+
+	tmpFile := filepath.Join(t.TempDir(), "api.go")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(`package api
+
+// Client is documented.
+type Client struct{}
+
+type Options struct{}
+
+type (
+	// Handler is documented.
+	Handler interface{ Serve() }
+	Router  interface{ Route() }
+)
+
+func (c Client) Do() {}
+
+func (o options) Apply() {}
+
+type options struct{}
+
+func New() Client { return Client{} }
+
+func helper() {}
+`), 0644))
+	report, err = analyzer.GetAnalysis(context.Background(), tmpFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api.Client.Do", "api.New", "api.Options", "api.Router"}, report.MissingDoc())
+}
+
+func TestAnalyzer_GenericImplementations(t *testing.T) {
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	tmpFile := filepath.Join(t.TempDir(), "box.go")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(`package main
+
+import "fmt"
+
+type Stringer interface{ String() string }
+
+type Getter[T any] interface{ Get() T }
+
+type Pair[K, V any] interface{ Key() K }
+
+type Number interface{ ~int | ~float64 }
+
+type Box[T any] struct{ v T }
+
+func (b Box[T]) String() string { return fmt.Sprint(b.v) }
+
+func (b *Box[T]) Get() T { return b.v }
+
+type Celsius struct{}
+`), 0644))
+
+	fa, err := analyzer.AnalyzeFile(tmpFile)
+	require.NoError(t, err)
+	var impls []string
+	for _, impl := range fa.Implements {
+		impls = append(impls, impl.Struct+":"+impl.Interface)
+	}
+	assert.ElementsMatch(t, []string{"Box:Stringer", "Box:Getter"}, impls)
+}
+
+func TestAnalyzer_AnonymousTypes(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+type Server struct {
+	limits struct{ Max, Min int }
+	log    interface{ Printf(format string, args ...any) }
+}
+
+func configure(opts struct{ Max, Min int }) {}
+
+func reconfigure(opts struct {
+	Max, Min int
+}) []interface{ Close() error } {
+	return nil
+}
+
+func named(s Server, v interface{}) {}
+`), 0644))
+
+	report, err := analysis.NewAnalyzerWithoutDB().GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+
+	functions := map[string]types.FunctionCall{}
+	for _, fn := range report.Functions {
+		functions[fn.Caller] = fn
+	}
+	assert.True(t, functions["configure"].UsesAnonymousTypes)
+	assert.Equal(t, []string{"struct{Max, Min int}"}, functions["configure"].Params)
+	assert.True(t, functions["reconfigure"].UsesAnonymousTypes)
+	assert.False(t, functions["named"].UsesAnonymousTypes)
+
+	// Differently formatted uses of a type are counted together.
+	assert.Equal(t, []types.AnonymousType{
+		{Type: "struct{Max, Min int}", Kind: "struct", Uses: 3},
+		{Type: "interface{Close() error}", Kind: "interface", Methods: []string{"Close"}, Uses: 1},
+		{Type: "interface{Printf(format string, args ...any)}", Kind: "interface", Methods: []string{"Printf"}, Uses: 1},
+	}, report.AnonymousTypes)
+}
+
+func TestAnalyzer_PackageDependencies(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "main.go")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(`package main
+
+import (
+	"fmt"
+	str "strings"
+)
+
+func greet(name string) {
+	fmt.Println("hello", str.ToUpper(name))
+	fmt.Println("bye")
+	_ = fmt.Sprint
+}
+`), 0644))
+
+	fa, err := analysis.NewAnalyzerWithoutDB().AnalyzeFile(tmpFile)
+	require.NoError(t, err)
+	require.Len(t, fa.Functions, 1)
+	assert.Equal(t, []types.PackageDependency{
+		{Caller: "greet", ImportPath: "fmt", Symbol: "Println"},
+		{Caller: "greet", ImportPath: "strings", Symbol: "ToUpper"},
+	}, fa.Functions[0].PackageDependencies)
+
+	report := types.AnalysisReport{Functions: fa.Functions}
+	assert.Equal(t, []types.PackageDependency{{Caller: "greet", ImportPath: "strings", Symbol: "ToUpper"}}, report.UsersOf("strings"))
+}
+
+func TestAnalyzer_ImportKinds(t *testing.T) {
+	t.Run("blank driver import", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+import (
+	"image"
+	_ "image/png"
+	"os"
+)
+
+func decode(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+`), 0644))
+
+		report, err := analysis.Analyze(context.Background(), dir, analysis.Options{})
+		require.NoError(t, err)
+		kinds := map[string]string{}
+		for _, imp := range report.Imports {
+			kinds[imp.Path] = imp.ImportKind
+			assert.False(t, imp.IsUnused, imp.Path)
+		}
+		assert.Equal(t, map[string]string{"image": types.ImportNormal, "image/png": types.ImportBlank, "os": types.ImportNormal}, kinds)
+		require.Len(t, report.Functions, 1)
+		assert.ElementsMatch(t, []string{"image", "os"}, report.Functions[0].Dependencies)
+		for _, dep := range report.Functions[0].PackageDependencies {
+			assert.NotEqual(t, "image/png", dep.ImportPath)
+		}
+	})
+
+	t.Run("dot import", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+import (
+	. "strings"
+	s "strings"
+)
+
+func shout(name string) string {
+	var b Builder
+	b.WriteString(ToUpper(name))
+	return s.TrimSpace(b.String())
+}
+`), 0644))
+
+		report, err := analysis.Analyze(context.Background(), dir, analysis.Options{})
+		require.NoError(t, err)
+		kinds := map[string]string{}
+		for _, imp := range report.Imports {
+			kinds[imp.Name] = imp.ImportKind
+			assert.False(t, imp.IsUnused, imp.Name)
+		}
+		assert.Equal(t, map[string]string{".": types.ImportDot, "s": types.ImportAlias}, kinds)
+		require.Len(t, report.Functions, 1)
+		fn := report.Functions[0]
+		assert.Equal(t, []string{"strings"}, fn.Dependencies)
+		assert.ElementsMatch(t, []types.PackageDependency{
+			{Caller: "shout", ImportPath: "strings", Symbol: "ToUpper"},
+			{Caller: "shout", ImportPath: "strings", Symbol: "TrimSpace"},
+		}, fn.PackageDependencies)
+		assert.Empty(t, fn.Callees)
+	})
+}
+
+func TestAnalyzer_RecursiveClosures(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+type Node struct{ Children []*Node }
+
+func count(root *Node) int {
+	n := 0
+	var walk func(*Node)
+	walk = func(node *Node) {
+		n++
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(root)
+	return n
+}
+
+func retry(attempts int) error {
+	try := func() error { return nil }
+	if err := try(); err != nil && attempts > 0 {
+		return func() error { return retry(attempts - 1) }()
+	}
+	return nil
+}
+
+func once() {
+	f := func() {}
+	f()
+}
+`), 0644))
+
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	analyzer.IncludeClosures = true
+	report, err := analyzer.GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+
+	recursive := map[string]bool{}
+	for _, fn := range report.Functions {
+		recursive[fn.Caller] = fn.IsRecursive
+	}
+	assert.True(t, recursive["count"], "a closure calling itself makes its function recursive")
+	assert.True(t, recursive["count$1"])
+	assert.True(t, recursive["retry"], "a closure calling its enclosing function makes it recursive")
+	assert.False(t, recursive["once"])
+	assert.False(t, recursive["once$1"])
+}
+
+func TestAnalyzer_IgnoreDirectives(t *testing.T) {
+	branches := strings.Repeat("\tif n > 0 {\n\t\tn--\n\t}\n", 12)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+func main() {
+	tangled(1)
+	legacy(1)
+}
+
+// tangled is generated.
+//
+//surrealcode:ignore
+func tangled(n int) int {
+`+branches+`	return n
+}
+
+func legacy(n int) int {
+`+branches+`	return n
+}
+
+//surrealcode:ignore=deadcode
+func keep() { helper() }
+
+func helper() {}
+
+//surrealcode:ignore=complexity
+func unused() {}
+`), 0644))
+
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	report, err := analyzer.GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+
+	functions := map[string]types.FunctionCall{}
+	for _, fn := range report.Functions {
+		functions[fn.Caller] = fn
+	}
+	assert.True(t, functions["tangled"].Ignored)
+	assert.Empty(t, functions["tangled"].IgnoredChecks)
+	assert.Equal(t, "tangled is generated.\n", functions["tangled"].Doc)
+	assert.False(t, functions["legacy"].Ignored)
+	assert.Equal(t, []string{"deadcode"}, functions["keep"].IgnoredChecks)
+
+	// Ignored functions are still analyzed, but not reported.
+	assert.Greater(t, functions["tangled"].Metrics.CyclomaticComplexity, 10)
+	summary := analyzer.GenerateCodeSummary(report)
+	require.Len(t, summary.Hotspots, 1)
+	assert.Equal(t, "legacy", summary.Hotspots[0].Name)
+
+	// Only the listed checks are ignored.
+	assert.False(t, functions["keep"].Metrics.IsUnused)
+	assert.True(t, functions["unused"].Metrics.IsUnused)
+	// Functions kept by a directive keep what they call.
+	assert.False(t, functions["helper"].Metrics.IsUnused)
+
+	var failed []string
+	for _, v := range report.CheckGates(types.Gates{MaxComplexity: 10, FailOnDeadCode: true}) {
+		failed = append(failed, v.Function)
+	}
+	assert.ElementsMatch(t, []string{"legacy", "unused"}, failed)
+}
+
+func TestAnalyzer_ErrorsIgnoredBy(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+func main() {
+	save()
+	_, _ = load()
+	if err := check(); err != nil {
+		panic(err)
+	}
+	n, _ := count()
+	_ = n
+}
+
+func retry() {
+	_ = save()
+	for range 3 {
+		save()
+	}
+}
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "store.go"), []byte(`package main
+
+func save() error { return nil }
+
+func load() (string, error) { return "", nil }
+
+func check() error { return nil }
+
+func count() (int, bool) { return 0, false }
+`), 0644))
+
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	report, err := analyzer.GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+
+	ignoredBy := map[string][]string{}
+	for _, fn := range report.Functions {
+		ignoredBy[fn.Caller] = fn.ErrorsIgnoredBy
+	}
+	assert.Equal(t, []string{"main", "retry"}, ignoredBy["save"])
+	assert.Equal(t, []string{"main"}, ignoredBy["load"])
+	assert.Empty(t, ignoredBy["check"], "the error is checked")
+	assert.Empty(t, ignoredBy["count"], "only error results count")
+
+	assert.Equal(t, 2, analyzer.GenerateCodeSummary(report).UncheckedErrors)
+}
+
+func TestAnalyzer_InitActions(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+import "os"
+
+var (
+	home    = os.Getenv("HOME")
+	limit   = 10
+	buf     = make([]byte, limit)
+	handler = func() { register("lazy") }
+)
+
+func init() {
+	register("a")
+}
+
+func main() {}
+
+func init() {
+	register("b")
+}
+
+func register(name string) {}
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.go"), []byte(`package main
+
+var defaults = load()
+
+func load() map[string]string { return nil }
+
+func init() {}
+`), 0644))
+
+	report, err := analysis.NewAnalyzerWithoutDB().GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+
+	main, config := filepath.Join(dir, "main.go"), filepath.Join(dir, "config.go")
+	assert.Equal(t, []types.InitAction{
+		{Name: "defaults", Package: "main", File: config, Line: 3, Kind: types.InitVariable},
+		{Name: "home", Package: "main", File: main, Line: 6, Kind: types.InitVariable},
+		{Name: "init@config.go#1", Package: "main", File: config, Line: 7, Kind: types.InitFunction},
+		{Name: "init@main.go#1", Package: "main", File: main, Line: 12, Kind: types.InitFunction},
+		{Name: "init@main.go#2", Package: "main", File: main, Line: 18, Kind: types.InitFunction},
+	}, report.InitActions)
+
+	// Each init function is kept, and none is dead code.
+	inits := map[string][]string{}
+	for _, fn := range report.Functions {
+		if strings.HasPrefix(fn.Caller, "init") {
+			inits[fn.Caller] = fn.Callees
+			assert.False(t, fn.Metrics.IsUnused, fn.Caller)
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"init@config.go#1": {},
+		"init@main.go#1":   {"register"},
+		"init@main.go#2":   {"register"},
+	}, inits)
+	for _, fn := range report.Functions {
+		if fn.Caller == "register" {
+			assert.False(t, fn.Metrics.IsUnused, "register is called by init functions")
+		}
+	}
+}
+
+func TestAnalyzer_ExternalCalls(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func main() {
+	var b strings.Builder
+	b.WriteString("hot")
+	fmt.Println(b.String(), Celsius(21))
+	helper()
+}
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "helper.go"), []byte(`package main
+
+type Celsius float64
+
+func helper() {}
+`), 0644))
+
+	for _, include := range []bool{false, true} {
+		analyzer := analysis.NewAnalyzerWithoutDB()
+		analyzer.IncludeExternalCalls = include
+		report, err := analyzer.GetAnalysis(context.Background(), dir)
+		require.NoError(t, err)
+
+		var main types.FunctionCall
+		for _, fn := range report.Functions {
+			if fn.Caller == "main" {
+				main = fn
+			}
+		}
+		// The conversion to a type of another file is not a dangling call.
+		assert.Equal(t, []string{"helper"}, main.Callees)
+		if !include {
+			assert.Empty(t, main.ExternalCalls)
+			continue
+		}
+		assert.Equal(t, []types.ExternalCall{
+			{Package: "strings", Function: "*Builder.WriteString"},
+			{Package: "fmt", Function: "Println"},
+			{Package: "strings", Function: "*Builder.String"},
+		}, main.ExternalCalls)
+	}
+}
+
+func TestAnalyzer_ExternalCallsToAnalyzedPackages(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":     "module example.com/app\n\ngo 1.24\n",
+		"lib/lib.go": "package lib\n\nfunc Greet() {}\n",
+		"main.go":    "package main\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/app/lib\"\n)\n\nfunc main() {\n\tlib.Greet()\n\tfmt.Println()\n}\n",
+	}
+	for name, src := range files {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0644))
+	}
+
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	analyzer.IncludeExternalCalls = true
+	report, err := analyzer.GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+	var calls []types.ExternalCall
+	for _, fn := range report.Functions {
+		calls = append(calls, fn.ExternalCalls...)
+	}
+	assert.Equal(t, []types.ExternalCall{
+		{Package: "example.com/app/lib", Function: "Greet", Dir: filepath.Join(dir, "lib"), Name: "lib"},
+		{Package: "fmt", Function: "Println"},
+	}, calls)
+}
+
+func TestAnalyzer_FanIn(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	require.NoError(t, os.WriteFile(path, []byte(`package main
+
+func main() {
+	helper()
+	helper()
+	once()
+	countdown(3)
+}
+
+func once() { helper() }
+
+func helper() {}
+
+func countdown(n int) {
+	if n > 0 {
+		countdown(n - 1)
+	}
+}
+
+func orphan() {}
+
+func Exported() {}
+`), 0644))
+
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	report, err := analyzer.GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+	summary := analyzer.GenerateCodeSummary(report)
+
+	assert.Equal(t, []types.FunctionRef{
+		{Function: "helper", File: path, Calls: 3},
+		{Function: "countdown", File: path, Calls: 1},
+		{Function: "once", File: path, Calls: 1},
+	}, summary.MostCalled)
+	// Entry points, exported functions and recursive calls don't count.
+	assert.Equal(t, []types.FunctionRef{{Function: "orphan", File: path}}, summary.NeverCalled)
+}
@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// WriteBundle writes a zip archive of the report for sharing: the full
+// report as report.json, the call graph as graph.dot and the Markdown
+// report as summary.md, written by the full, dot and md exporters, and the
+// hotspots as hotspots.csv. The summaries use the default thresholds.
+func WriteBundle(w io.Writer, report surrealtypes.AnalysisReport) error {
+	a := newLibraryAnalyzer(Options{})
+	export := func(format string) func(w io.Writer) error {
+		return func(w io.Writer) error {
+			e, err := a.Exporter(format)
+			if err != nil {
+				return err
+			}
+			return e.Write(w, report)
+		}
+	}
+	entries := []struct {
+		name  string
+		write func(w io.Writer) error
+	}{
+		{"report.json", export("full")},
+		{"graph.dot", export("dot")},
+		{"summary.md", export("md")},
+		{"hotspots.csv", func(w io.Writer) error { return WriteHotspotsCSV(w, a.GenerateCodeSummary(report).Hotspots) }},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, entry := range entries {
+		f, err := zw.Create(entry.name)
+		if err != nil {
+			return fmt.Errorf("failed to add %s to bundle: %w", entry.name, err)
+		}
+		if err := entry.write(f); err != nil {
+			return fmt.Errorf("failed to write %s: %w", entry.name, err)
+		}
+	}
+	return zw.Close()
+}
+
+// WriteDOT writes graph in the Graphviz DOT language, one node per function
+// and one edge per call.
+func WriteDOT(w io.Writer, graph *surrealtypes.CallGraph) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph calls {\n")
+	for _, node := range graph.Nodes() {
+		fmt.Fprintf(bw, "\t%s;\n", strconv.Quote(node))
+	}
+	for _, edge := range graph.Edges() {
+		fmt.Fprintf(bw, "\t%s -> %s;\n", strconv.Quote(edge[0]), strconv.Quote(edge[1]))
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
+
+// WriteHotspotsCSV writes hotspots as CSV with a header row. The issues of
+// a hotspot share a column, separated by semicolons.
+func WriteHotspotsCSV(w io.Writer, hotspots []surrealtypes.HotspotFunction) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"name", "file", "complexity", "cognitive_complexity", "lines_of_code", "maintainability", "issues"})
+	for _, h := range hotspots {
+		cw.Write([]string{
+			h.Name,
+			h.File,
+			strconv.Itoa(h.Complexity),
+			strconv.Itoa(h.CognitiveComplexity),
+			strconv.Itoa(h.LinesOfCode),
+			strconv.FormatFloat(h.Maintainability, 'f', 2, 64),
+			strings.Join(h.Issues, "; "),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,64 @@
+package analysis_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteBundle(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+func main() { run(3) }
+
+func run(n int) int {
+	if n > 0 {
+		return run(n - 1)
+	}
+	return 0
+}
+`), 0644))
+	report, err := analysis.NewAnalyzerWithoutDB().GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, analysis.WriteBundle(&buf, report))
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	entries := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		entries[f.Name] = string(data)
+	}
+	require.Len(t, entries, 4)
+	assert.Contains(t, entries["report.json"], `"caller": "run"`)
+	assert.Contains(t, entries["graph.dot"], `"main.main" -> "main.run";`)
+	assert.Contains(t, entries["graph.dot"], `"main.run" -> "main.run";`)
+	assert.Contains(t, entries["summary.md"], "# Code Analysis Report")
+	// The entries are those of the exporters of the same formats.
+	for name, format := range map[string]string{"report.json": "full", "graph.dot": "dot", "summary.md": "md"} {
+		e, err := analysis.NewAnalyzerWithoutDB().Exporter(format)
+		require.NoError(t, err)
+		var want bytes.Buffer
+		require.NoError(t, e.Write(&want, report))
+		assert.Equal(t, want.String(), entries[name], name)
+	}
+	rows, err := csv.NewReader(bytes.NewReader([]byte(entries["hotspots.csv"]))).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "file", "complexity", "cognitive_complexity", "lines_of_code", "maintainability", "issues"}, rows[0])
+}
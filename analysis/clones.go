@@ -0,0 +1,51 @@
+package analysis
+
+import (
+	"cmp"
+	"slices"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// CloneMember is a function in a group of clones.
+type CloneMember struct {
+	Function string `json:"function"`
+	Package  string `json:"package"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// ExactCloneGroups returns the groups of two or more functions whose bodies
+// are identical once printed by gofmt without comments, as recorded in
+// their BodyHash. Functions without a body and stubs are left out. Members
+// are ordered by location, and groups by their first member.
+func ExactCloneGroups(report surrealtypes.AnalysisReport) [][]CloneMember {
+	byHash := make(map[string][]CloneMember)
+	for _, fn := range report.Functions {
+		if fn.BodyHash == "" || fn.IsEmpty {
+			continue
+		}
+		byHash[fn.BodyHash] = append(byHash[fn.BodyHash], CloneMember{
+			Function: fn.Caller,
+			Package:  fn.Package,
+			File:     fn.File,
+			Line:     fn.Line,
+		})
+	}
+	var groups [][]CloneMember
+	for _, members := range byHash {
+		if len(members) < 2 {
+			continue
+		}
+		slices.SortFunc(members, compareCloneMembers)
+		groups = append(groups, members)
+	}
+	slices.SortFunc(groups, func(a, b []CloneMember) int {
+		return compareCloneMembers(a[0], b[0])
+	})
+	return groups
+}
+
+func compareCloneMembers(a, b CloneMember) int {
+	return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line), cmp.Compare(a.Function, b.Function))
+}
@@ -0,0 +1,63 @@
+package analysis_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExactCloneGroups(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.go"), []byte(`package util
+
+func clampA(x int) int {
+	if x < 0 {
+		return 0
+	}
+	return x
+}
+
+func clampB(x int) int {
+	// Negative values are clamped.
+	if x < 0 { return 0 }
+	return x
+}
+
+func clampUpper(x int) int {
+	if x > 0 {
+		return 0
+	}
+	return x
+}
+
+func stub() {}
+
+func otherStub() {}
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.go"), []byte(`package util
+
+func clampC(x int) int {
+	if x < 0 {
+		return 0
+	}
+
+	return x
+}
+`), 0644))
+
+	report, err := analysis.Analyze(context.Background(), dir, analysis.Options{})
+	require.NoError(t, err)
+
+	groups := analysis.ExactCloneGroups(report)
+	require.Len(t, groups, 1)
+	assert.Equal(t, []analysis.CloneMember{
+		{Function: "clampA", Package: "util", File: filepath.Join(dir, "a.go"), Line: 3},
+		{Function: "clampB", Package: "util", File: filepath.Join(dir, "a.go"), Line: 10},
+		{Function: "clampC", Package: "util", File: filepath.Join(dir, "b.go"), Line: 3},
+	}, groups[0])
+}
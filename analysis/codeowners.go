@@ -0,0 +1,144 @@
+package analysis
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// Codeowners maps file paths to their owners, as read from a CODEOWNERS
+// file.
+type Codeowners struct {
+	root  string // directory the patterns are relative to
+	rules []codeownersRule
+}
+
+// codeownersRule is a pattern of a CODEOWNERS file with its owners.
+type codeownersRule struct {
+	pattern *regexp.Regexp
+	owners  []string
+}
+
+// LoadCodeowners reads the CODEOWNERS file at path. Its patterns are
+// relative to the repository root: the directory holding the file, or its
+// parent for files in .github or docs, as GitHub looks for them there.
+func LoadCodeowners(path string) (*Codeowners, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	root, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	if base := filepath.Base(root); base == ".github" || base == "docs" {
+		root = filepath.Dir(root)
+	}
+	co := &Codeowners{root: root}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		owners := fields[1:]
+		if i := slices.IndexFunc(owners, func(owner string) bool { return strings.HasPrefix(owner, "#") }); i >= 0 {
+			owners = owners[:i]
+		}
+		pattern, err := codeownersPattern(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		co.rules = append(co.rules, codeownersRule{pattern: pattern, owners: owners})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return co, nil
+}
+
+// codeownersPattern compiles a gitignore-style CODEOWNERS pattern into a
+// regular expression matching slash-separated paths relative to the root.
+// A pattern starting with or holding a slash is anchored to the root, and
+// others match at any depth. A pattern matches the files under a matching
+// directory too, unless it ends in a single * like docs/*, which only
+// matches the files directly in docs.
+func codeownersPattern(pattern string) (*regexp.Regexp, error) {
+	dir := strings.HasSuffix(pattern, "/")
+	p := strings.Trim(pattern, "/")
+	if p == "" {
+		return nil, fmt.Errorf("invalid pattern %q", pattern)
+	}
+	var re strings.Builder
+	re.WriteString("^")
+	if !strings.HasPrefix(pattern, "/") && !strings.Contains(p, "/") {
+		re.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case p[i] == '*':
+			re.WriteString("[^/]*")
+		case p[i] == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+	switch {
+	case dir:
+		re.WriteString("/.*")
+	case strings.HasSuffix(p, "*") && !strings.HasSuffix(p, "**"):
+	default:
+		re.WriteString("(?:/.*)?")
+	}
+	re.WriteString("$")
+	return regexp.Compile(re.String())
+}
+
+// Match returns the owners of the file at path, relative to the repository
+// root, from the last rule matching it, as in GitHub. It returns nil if no
+// rule matches or the matching rule has no owners.
+func (co *Codeowners) Match(path string) []string {
+	path = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "/")
+	for i := len(co.rules) - 1; i >= 0; i-- {
+		if co.rules[i].pattern.MatchString(path) {
+			if len(co.rules[i].owners) == 0 {
+				return nil
+			}
+			return slices.Clone(co.rules[i].owners)
+		}
+	}
+	return nil
+}
+
+// assignOwners sets the Owners of functions from co. Files outside the
+// repository root have no owners.
+func assignOwners(functions []surrealtypes.FunctionCall, co *Codeowners) {
+	owners := make(map[string][]string)
+	for i, fn := range functions {
+		fileOwners, ok := owners[fn.File]
+		if !ok {
+			if abs, err := filepath.Abs(fn.File); err == nil {
+				if rel, err := filepath.Rel(co.root, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+					fileOwners = co.Match(rel)
+				}
+			}
+			owners[fn.File] = fileOwners
+		}
+		functions[i].Owners = fileOwners
+	}
+}
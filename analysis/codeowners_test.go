@@ -0,0 +1,61 @@
+package analysis_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeowners(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".github"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".github", "CODEOWNERS"), []byte(`# Services
+/svc/** @team-a
+/svc/billing/ @team-b @alice # billing moved
+*.md @docs
+docs/* @writers
+**/testdata @qa
+/svc/billing/legacy.go
+`), 0644))
+	co, err := analysis.LoadCodeowners(filepath.Join(dir, ".github", "CODEOWNERS"))
+	require.NoError(t, err)
+
+	tests := map[string][]string{
+		"svc/api/handler.go":         {"@team-a"},
+		"svc/billing/invoice.go":     {"@team-b", "@alice"},
+		"svc/billing/tax/rates.go":   {"@team-b", "@alice"},
+		"svc/billing/legacy.go":      nil,
+		"svc/README.md":              {"@docs"},
+		"docs/guide.txt":             {"@writers"},
+		"docs/api/guide.txt":         nil,
+		"pkg/parse/testdata/a.go":    {"@qa"},
+		"main.go":                    nil,
+		"/svc/api/handler.go":        {"@team-a"},
+		filepath.Join("svc", "x.go"): {"@team-a"},
+	}
+	for path, want := range tests {
+		assert.Equal(t, want, co.Match(path), path)
+	}
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "svc", "api"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "svc", "api", "handler.go"), []byte("package api\n\nfunc Handle() {}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644))
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	analyzer.Codeowners = co
+	report, err := analyzer.GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+	owners := map[string][]string{}
+	for _, fn := range report.Functions {
+		owners[fn.Caller] = fn.Owners
+	}
+	assert.Equal(t, map[string][]string{"Handle": {"@team-a"}, "main": nil}, owners)
+	assert.Equal(t, []string{"@team-a"}, report.BuildSummary().Functions[0].Owners)
+
+	_, err = analysis.LoadCodeowners(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
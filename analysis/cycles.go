@@ -0,0 +1,102 @@
+package analysis
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// importCycles returns the strongly connected components of more than one
+// package in the import graph of the analyzed packages. Imports of other
+// packages and imports of test files are left out. dirPaths maps the directory of each analyzed file
+// to the import path of its package, or "" if it is not in a module.
+// Cycles list their packages sorted, and are ordered by their first
+// package.
+func importCycles(imports []surrealtypes.ImportDefinition, dirPaths map[string]string) []surrealtypes.ImportCycle {
+	// The import graph is built as a call graph whose functions are the
+	// packages and whose calls are their imports.
+	graph := make(map[string]surrealtypes.FunctionCall)
+	for _, pkg := range dirPaths {
+		if pkg != "" {
+			graph[pkg] = surrealtypes.FunctionCall{Caller: pkg}
+		}
+	}
+	for _, imp := range imports {
+		// Test files are compiled with their package only when testing, and
+		// external test packages may import what imports their package.
+		if isTestFile(imp.File) || strings.HasSuffix(imp.Package, "_test") {
+			continue
+		}
+		from := dirPaths[filepath.Dir(imp.File)]
+		if _, internal := graph[imp.Path]; from == "" || !internal || imp.Path == from {
+			continue
+		}
+		pkg := graph[from]
+		pkg.Callees = append(pkg.Callees, imp.Path)
+		graph[from] = pkg
+	}
+
+	var cycles []surrealtypes.ImportCycle
+	for _, component := range surrealtypes.NewCallGraph(graph).SCCs() {
+		if len(component) > 1 {
+			cycles = append(cycles, surrealtypes.ImportCycle{Packages: component})
+		}
+	}
+	return cycles
+}
+
+// moduleImportPath returns the import path of the package in directory dir
+// of fsys, a slash-separated path, from the nearest go.mod at or above it.
+// It returns "" outside a module.
+func moduleImportPath(fsys fs.FS, dir string) string {
+	for root := dir; ; root = path.Dir(root) {
+		if data, err := fs.ReadFile(fsys, path.Join(root, "go.mod")); err == nil {
+			module := modulePath(data)
+			if module == "" {
+				return ""
+			}
+			rel := strings.TrimPrefix(strings.TrimPrefix(dir, root), "/")
+			if root == "." {
+				rel = strings.TrimPrefix(dir, ".")
+			}
+			return path.Join(module, rel)
+		}
+		if root == "." || root == "/" || root == "" {
+			return ""
+		}
+	}
+}
+
+// diskImportPath is moduleImportPath for directory dir on disk.
+func diskImportPath(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	volume := filepath.VolumeName(abs)
+	name := strings.TrimPrefix(filepath.ToSlash(abs[len(volume):]), "/")
+	if name == "" {
+		name = "."
+	}
+	return moduleImportPath(os.DirFS(volume+string(filepath.Separator)), name)
+}
+
+// modulePath returns the module path declared by the go.mod file data.
+func modulePath(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "module" {
+			continue
+		}
+		if module, err := strconv.Unquote(fields[1]); err == nil {
+			return module
+		}
+		return fields[1]
+	}
+	return ""
+}
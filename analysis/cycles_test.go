@@ -0,0 +1,55 @@
+package analysis_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportCycles(t *testing.T) {
+	files := map[string]string{
+		"go.mod":             "module example.com/shop\n\ngo 1.24\n",
+		"orders/orders.go":   "package orders\n\nimport \"example.com/shop/billing\"\n\nvar _ = billing.Charge\n",
+		"billing/billing.go": "package billing\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/shop/orders\"\n)\n\nvar _ = orders.Place\n\nfunc Charge() { fmt.Println() }\n",
+		"orders/place.go":    "package orders\n\nfunc Place() {}\n",
+		"cmd/main.go":        "package main\n\nimport \"example.com/shop/orders\"\n\nfunc main() { orders.Place() }\n",
+		// An external test package may import a package importing its own.
+		"cmd/main_test.go":        "package main_test\n\nimport \"example.com/shop/catalog\"\n\nvar _ = catalog.List\n",
+		"catalog/catalog.go":      "package catalog\n\nfunc List() {}\n",
+		"catalog/catalog_test.go": "package catalog_test\n\nimport \"example.com/shop/cmd\"\n\nvar _ = cmd.Run\n",
+		"catalog/export_test.go":  "package catalog\n\nimport \"example.com/shop/orders\"\n\nvar _ = orders.Place\n",
+		"orders/orders_test.go":   "package orders\n\nimport \"example.com/shop/catalog\"\n\nvar _ = catalog.List\n",
+	}
+	want := []types.ImportCycle{{Packages: []string{"example.com/shop/billing", "example.com/shop/orders"}}}
+
+	// The module root may lie above the analyzed directory.
+	dir := t.TempDir()
+	for name, src := range files {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0644))
+	}
+	report, err := analysis.NewAnalyzerWithoutDB().GetAnalysis(context.Background(), filepath.Join(dir, "orders"), filepath.Join(dir, "billing"), filepath.Join(dir, "cmd"), filepath.Join(dir, "catalog"))
+	require.NoError(t, err)
+	assert.Equal(t, want, report.ImportCycles)
+
+	fsys := fstest.MapFS{}
+	for name, src := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(src)}
+	}
+	report, err = analysis.NewAnalyzerWithoutDB().AnalyzeFS(context.Background(), fsys, ".")
+	require.NoError(t, err)
+	assert.Equal(t, want, report.ImportCycles)
+
+	// Without a go.mod, no package is known to be internal.
+	delete(fsys, "go.mod")
+	report, err = analysis.NewAnalyzerWithoutDB().AnalyzeFS(context.Background(), fsys, ".")
+	require.NoError(t, err)
+	assert.Empty(t, report.ImportCycles)
+}
@@ -0,0 +1,100 @@
+package analysis
+
+import (
+	"sort"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// FunctionDelta holds per-metric changes (head minus base) for a function
+// present in both reports.
+type FunctionDelta struct {
+	Caller          string  `json:"caller"`
+	File            string  `json:"file"`
+	Complexity      int     `json:"complexity"`
+	Maintainability float64 `json:"maintainability"`
+	LinesOfCode     int     `json:"lines_of_code"`
+	Cognitive       int     `json:"cognitive"`
+}
+
+// ReportDiff describes how functions changed between two analysis reports.
+// Functions are matched by Caller and File, so a rename shows up as a
+// removal plus an addition.
+type ReportDiff struct {
+	Added   []surrealtypes.FunctionCall `json:"added"`
+	Removed []surrealtypes.FunctionCall `json:"removed"`
+	Changed []FunctionDelta             `json:"changed"`
+}
+
+type functionKey struct {
+	caller string
+	file   string
+}
+
+// DiffReports compares base against head and returns the added, removed and
+// changed functions. Results are sorted by file and caller.
+func DiffReports(base, head surrealtypes.AnalysisReport) ReportDiff {
+	baseFns := make(map[functionKey]surrealtypes.FunctionCall, len(base.Functions))
+	for _, fn := range base.Functions {
+		baseFns[functionKey{fn.Caller, fn.File}] = fn
+	}
+
+	var diff ReportDiff
+	seen := make(map[functionKey]bool, len(head.Functions))
+	for _, fn := range head.Functions {
+		key := functionKey{fn.Caller, fn.File}
+		seen[key] = true
+		old, ok := baseFns[key]
+		if !ok {
+			diff.Added = append(diff.Added, fn)
+			continue
+		}
+		delta := FunctionDelta{
+			Caller:          fn.Caller,
+			File:            fn.File,
+			Complexity:      fn.Metrics.CyclomaticComplexity - old.Metrics.CyclomaticComplexity,
+			Maintainability: fn.Metrics.Maintainability - old.Metrics.Maintainability,
+			LinesOfCode:     fn.Metrics.LinesOfCode - old.Metrics.LinesOfCode,
+			Cognitive:       fn.Metrics.CognitiveComplexity.Score - old.Metrics.CognitiveComplexity.Score,
+		}
+		if delta != (FunctionDelta{Caller: fn.Caller, File: fn.File}) {
+			diff.Changed = append(diff.Changed, delta)
+		}
+	}
+	for _, fn := range base.Functions {
+		if !seen[functionKey{fn.Caller, fn.File}] {
+			diff.Removed = append(diff.Removed, fn)
+		}
+	}
+
+	sortFunctions(diff.Added)
+	sortFunctions(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		if diff.Changed[i].File != diff.Changed[j].File {
+			return diff.Changed[i].File < diff.Changed[j].File
+		}
+		return diff.Changed[i].Caller < diff.Changed[j].Caller
+	})
+	return diff
+}
+
+// ComplexityIncreases returns the changed functions whose cyclomatic
+// complexity grew by more than threshold.
+func (d ReportDiff) ComplexityIncreases(threshold int) []FunctionDelta {
+	var out []FunctionDelta
+	for _, delta := range d.Changed {
+		if delta.Complexity > threshold {
+			out = append(out, delta)
+		}
+	}
+	return out
+}
+
+func sortFunctions(fns []surrealtypes.FunctionCall) {
+	sort.Slice(fns, func(i, j int) bool {
+		if fns[i].File != fns[j].File {
+			return fns[i].File < fns[j].File
+		}
+		return fns[i].Caller < fns[j].Caller
+	})
+}
@@ -0,0 +1,48 @@
+package analysis_test
+
+import (
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffReports(t *testing.T) {
+	fn := func(caller string, complexity int, maintainability float64) types.FunctionCall {
+		return types.FunctionCall{
+			Caller: caller,
+			File:   "main.go",
+			Metrics: types.FunctionMetrics{
+				CyclomaticComplexity: complexity,
+				Maintainability:      maintainability,
+			},
+		}
+	}
+
+	base := types.AnalysisReport{Functions: []types.FunctionCall{
+		fn("same", 2, 90),
+		fn("grows", 2, 90),
+		fn("oldName", 1, 95),
+	}}
+	head := types.AnalysisReport{Functions: []types.FunctionCall{
+		fn("same", 2, 90),
+		fn("grows", 9, 70),
+		fn("newName", 1, 95),
+	}}
+
+	diff := analysis.DiffReports(base, head)
+
+	require.Len(t, diff.Added, 1)
+	assert.Equal(t, "newName", diff.Added[0].Caller)
+	require.Len(t, diff.Removed, 1)
+	assert.Equal(t, "oldName", diff.Removed[0].Caller)
+	require.Len(t, diff.Changed, 1)
+	assert.Equal(t, "grows", diff.Changed[0].Caller)
+	assert.Equal(t, 7, diff.Changed[0].Complexity)
+	assert.InDelta(t, -20.0, diff.Changed[0].Maintainability, 1e-9)
+
+	assert.Len(t, diff.ComplexityIncreases(5), 1)
+	assert.Empty(t, diff.ComplexityIncreases(7))
+}
@@ -0,0 +1,172 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// Exporter writes a report in one output format.
+type Exporter interface {
+	// Name is the format's name, as in --format. Unless the exporter has
+	// an Extension method, it is also the extension of the files
+	// WriteExports writes.
+	Name() string
+	Write(w io.Writer, report surrealtypes.AnalysisReport) error
+}
+
+// exporter is an Exporter calling write.
+type exporter struct {
+	name, ext string
+	write     func(w io.Writer, report surrealtypes.AnalysisReport) error
+}
+
+func (e exporter) Name() string { return e.name }
+
+// Extension returns the extension of the files the exporter writes,
+// including the dot.
+func (e exporter) Extension() string { return e.ext }
+
+func (e exporter) Write(w io.Writer, report surrealtypes.AnalysisReport) error {
+	return e.write(w, report)
+}
+
+// NewExporter returns an Exporter of the format called name, writing files
+// with the extension ext, such as ".csv".
+func NewExporter(name, ext string, write func(w io.Writer, report surrealtypes.AnalysisReport) error) Exporter {
+	return exporter{name: name, ext: ext, write: write}
+}
+
+var (
+	exportersMu sync.RWMutex
+	exporters   = make(map[string]Exporter)
+)
+
+// builtinFormats are the formats of Analyzer.Exporter that exporters cannot
+// replace.
+var builtinFormats = []string{"summary", "json", "full", "md", "dot"}
+
+// RegisterExporter makes e available to Analyzer.Exporter under its name,
+// replacing any exporter registered under the same name before. Built-in
+// formats cannot be replaced, and registering one is an error.
+func RegisterExporter(e Exporter) error {
+	if slices.Contains(builtinFormats, e.Name()) {
+		return fmt.Errorf("format %q is built in", e.Name())
+	}
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	exporters[e.Name()] = e
+	return nil
+}
+
+// Exporter returns the exporter of the named format: summary, or json, for
+// the summary, full for the complete report, md for a Markdown report, dot
+// for the call graph in the Graphviz DOT language, or one registered with
+// RegisterExporter. The summaries follow the analyzer's settings.
+func (a *Analyzer) Exporter(name string) (Exporter, error) {
+	switch name {
+	case "summary", "json":
+		return NewExporter(name, ".json", func(w io.Writer, report surrealtypes.AnalysisReport) error {
+			var data []byte
+			var err error
+			if a.SeparateTests {
+				data, err = json.MarshalIndent(report.BuildSeparateSummary(), "", "  ")
+			} else {
+				data, err = report.PrettyPrint()
+			}
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(append(data, '\n'))
+			return err
+		}), nil
+	case "full":
+		return NewExporter(name, ".full.json", func(w io.Writer, report surrealtypes.AnalysisReport) error {
+			data, err := report.FullReport()
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(append(data, '\n'))
+			return err
+		}), nil
+	case "md":
+		return NewExporter(name, ".md", func(w io.Writer, report surrealtypes.AnalysisReport) error {
+			summary := report.BuildSummary()
+			if a.SeparateTests {
+				summary = report.BuildSeparateSummary()
+			}
+			return WriteMarkdown(w, summary, a.GenerateCodeSummary(report))
+		}), nil
+	case "dot":
+		return NewExporter(name, ".dot", func(w io.Writer, report surrealtypes.AnalysisReport) error {
+			return WriteDOT(w, report.CallGraph())
+		}), nil
+	}
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+	if e, ok := exporters[name]; ok {
+		return e, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", name)
+}
+
+// Exporters returns the exporters of the comma-separated formats, such as
+// dot,json,md.
+func (a *Analyzer) Exporters(formats string) ([]Exporter, error) {
+	var list []Exporter
+	for _, name := range strings.Split(formats, ",") {
+		e, err := a.Exporter(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, nil
+}
+
+// ExportFile returns the file WriteExports writes e to: prefix followed by
+// e's extension, such as report.md.
+func ExportFile(prefix string, e Exporter) string {
+	if x, ok := e.(interface{ Extension() string }); ok {
+		return prefix + x.Extension()
+	}
+	return prefix + "." + e.Name()
+}
+
+// WriteExports writes report with each of exporters to its ExportFile and
+// returns the files written. Exporters writing the same file are an error.
+func WriteExports(prefix string, report surrealtypes.AnalysisReport, exporters []Exporter) ([]string, error) {
+	files := make([]string, 0, len(exporters))
+	for _, e := range exporters {
+		file := ExportFile(prefix, e)
+		for i, written := range files {
+			if written == file {
+				return files, fmt.Errorf("formats %s and %s both write %s", exporters[i].Name(), e.Name(), file)
+			}
+		}
+		if err := writeExport(file, report, e); err != nil {
+			return files, fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// writeExport writes report with e to the file at path.
+func writeExport(path string, report surrealtypes.AnalysisReport, e Exporter) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := e.Write(f, report); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,72 @@
+package analysis_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteExports(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "main.go"), []byte(`package main
+		func main() { helper() }
+		func helper() {}`), 0644))
+
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	report, err := analyzer.GetAnalysis(context.Background(), src)
+	require.NoError(t, err)
+
+	exporters, err := analyzer.Exporters("dot,json")
+	require.NoError(t, err)
+	prefix := filepath.Join(t.TempDir(), "report")
+	files, err := analysis.WriteExports(prefix, report, exporters)
+	require.NoError(t, err)
+	assert.Equal(t, []string{prefix + ".dot", prefix + ".json"}, files)
+
+	dot, err := os.ReadFile(prefix + ".dot")
+	require.NoError(t, err)
+	assert.Contains(t, string(dot), `"main.main" -> "main.helper";`)
+
+	data, err := os.ReadFile(prefix + ".json")
+	require.NoError(t, err)
+	var summary map[string]any
+	require.NoError(t, json.Unmarshal(data, &summary))
+	assert.EqualValues(t, 2, summary["total_functions"])
+
+	_, err = analyzer.Exporters("dot,pdf")
+	assert.EqualError(t, err, `unknown format "pdf"`)
+
+	exporters, err = analyzer.Exporters("summary,json")
+	require.NoError(t, err)
+	_, err = analysis.WriteExports(prefix, report, exporters)
+	assert.ErrorContains(t, err, "formats summary and json both write")
+}
+
+func TestRegisterExporter(t *testing.T) {
+	names := analysis.NewExporter("names", ".txt", func(w io.Writer, report types.AnalysisReport) error {
+		for _, fn := range report.Functions {
+			fmt.Fprintln(w, fn.Caller)
+		}
+		return nil
+	})
+	require.NoError(t, analysis.RegisterExporter(names))
+	e, err := analysis.NewAnalyzerWithoutDB().Exporter("names")
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, e.Write(&buf, types.AnalysisReport{Functions: []types.FunctionCall{{Caller: "main"}}}))
+	assert.Equal(t, "main\n", buf.String())
+
+	// Built-in formats cannot be replaced.
+	md := analysis.NewExporter("md", ".md", func(io.Writer, types.AnalysisReport) error { return nil })
+	assert.EqualError(t, analysis.RegisterExporter(md), `format "md" is built in`)
+}
@@ -0,0 +1,60 @@
+package analysis_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+)
+
+// FuzzAnalyzeSource checks that the analyzer never panics, whatever the
+// source. Run it with go test -fuzz=FuzzAnalyzeSource ./analysis.
+func FuzzAnalyzeSource(f *testing.F) {
+	for _, src := range []string{
+		"package p\n\nconst ()\n",
+		"package p\n\nvar ()\n\ntype ()\n",
+		"package p\n\nvar x = y\n",
+		"package p\n\nvar a, b = f()\n",
+		"package p\n\nvar a, b, c = 1, 2\n",
+		"package p\n\nconst (\n\tA = iota\n\tB\n)\n",
+		"package p\n\nfunc (int) M() {}\n",
+		"package p\n\nfunc () M() {}\n",
+		"package p\n\nfunc (*) M() {}\n",
+		"package p\n\nfunc (r T[K, V]) M() {}\n",
+		"package p\n\ntype I interface {\n\tfunc()\n}\n",
+		"package p\n\ntype I interface {\n\tM(func())\n\tfmt.Stringer\n\t~int | string\n}\n",
+		"package p\n\ntype S struct {\n\t*T\n\tstruct{}\n\tx, y int `tag`\n}\n",
+		"package p\n\nfunc f() (int, error)\n",
+		"package p\n\nfunc f() {\n\tgo func() {}()\n\tdefer recover()\n\tselect {}\n}\n",
+		"package p\n\nfunc init() {}\n\nfunc init() {}\n",
+		"package p\n\nfunc f[T any, U ~int](t T) U { return U(0) }\n",
+		"package p\n\nfunc f() {\n\tx := func() func() { return nil }()\n\t_ = x\n}\n",
+		"package p\n\nfunc f() {\n\tswitch x := any(1).(type) {\n\tcase int:\n\t\t_ = x\n\t}\n}\n",
+		"package p\n\nfunc f() {\n\tfor range 3 {\n\t\tgoto L\n\t}\nL:\n}\n",
+		"package p\n\n//surrealcode:ignore=\nfunc f() {}\n",
+		"package p\n",
+	} {
+		f.Add(src)
+	}
+	paths, err := filepath.Glob(filepath.Join("..", "demo", "*.go"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(src))
+	}
+
+	f.Fuzz(func(t *testing.T, src string) {
+		analyzer := analysis.NewAnalyzerWithoutDB()
+		analyzer.IncludeClosures = true
+		analyzer.IncludeExternalCalls = true
+		analyzer.Locals = true
+		// Errors are expected for malformed source; panics are not.
+		_, _ = analyzer.AnalyzeSource("fuzz.go", []byte(src))
+	})
+}
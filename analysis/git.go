@@ -0,0 +1,108 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// ChangedGoFiles returns the Go files of the git repository at repoDir that
+// differ from baseRef: those added, modified or renamed since the current
+// branch forked from baseRef, whether committed or not, and untracked files
+// that are not ignored. Deleted files are left out, and renamed files are
+// returned under their new name. Paths are joined to the repository's
+// top-level directory and sorted.
+func ChangedGoFiles(repoDir, baseRef string) ([]string, error) {
+	top, err := git(repoDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	base, err := git(repoDir, "merge-base", baseRef, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	changed, err := git(repoDir, "diff", "--name-only", "-z", "--find-renames", "--diff-filter=d", strings.TrimSpace(base), "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git(repoDir, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, name := range strings.Split(changed+untracked, "\x00") {
+		if strings.HasSuffix(name, ".go") {
+			files = append(files, filepath.Join(strings.TrimSpace(top), filepath.FromSlash(name)))
+		}
+	}
+	slices.Sort(files)
+	return slices.Compact(files), nil
+}
+
+// GetChangedAnalysis analyzes files, such as those returned by
+// ChangedGoFiles, in the context of their packages: the other Go files of
+// their directories are analyzed too, so calls into them resolve and dead
+// code is detected across the package, but only the given files are
+// reported.
+func (a *Analyzer) GetChangedAnalysis(ctx context.Context, files []string) (surrealtypes.AnalysisReport, error) {
+	paths := slices.Clone(files)
+	dirs := make(map[string]bool)
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return surrealtypes.AnalysisReport{}, fmt.Errorf("failed to read package of %s: %w", file, err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".go") && !a.excluded(entry.Name(), ".") {
+				paths = append(paths, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	report, err := a.GetAnalysis(ctx, paths...)
+	if err != nil && (!a.Partial || ctx.Err() == nil) {
+		return surrealtypes.AnalysisReport{}, err
+	}
+	keep := make([]string, len(files))
+	for i, file := range files {
+		keep[i] = filepath.Clean(file)
+	}
+	a.Report = report.ForFiles(keep)
+	return a.Report, err
+}
+
+// AnalyzeChangedFiles stores the analysis of GetChangedAnalysis.
+func (a *Analyzer) AnalyzeChangedFiles(ctx context.Context, files []string) error {
+	report, err := a.GetChangedAnalysis(ctx, files)
+	if err != nil {
+		return fmt.Errorf("failed to analyze changed files: %w", err)
+	}
+	return a.store(ctx, report)
+}
+
+// git runs git with args in the repository at repoDir and returns its
+// output. Errors hold what git printed to stderr.
+func git(repoDir string, args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", repoDir}, args...)...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("git %s: %s", args[0], bytes.TrimSpace(exitErr.Stderr))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,79 @@
+package analysis_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangedGoFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	write := func(name, src string) {
+		t.Helper()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0644))
+	}
+
+	write("main.go", "package main\n\nfunc main() { helper() }\n")
+	write("helper.go", "package main\n\nfunc helper() {}\n")
+	write("old.go", "package main\n\nfunc old() {}\n")
+	write("gone.go", "package main\n\nfunc gone() {}\n")
+	write("README.md", "docs\n")
+	git("init", "-q", "-b", "main")
+	git("add", ".")
+	git("commit", "-q", "-m", "base")
+	git("checkout", "-q", "-b", "feature")
+
+	write("main.go", "package main\n\nfunc main() {\n\thelper()\n\tadded()\n}\n")
+	write("pkg/added.go", "package pkg\n\nfunc Added() {}\n")
+	write("README.md", "more docs\n")
+	git("mv", "old.go", "renamed.go")
+	git("rm", "-q", "gone.go")
+	git("add", ".")
+	git("commit", "-q", "-m", "change")
+	write("untracked.go", "package main\n\nfunc added() {}\n")
+
+	files, err := analysis.ChangedGoFiles(dir, "main")
+	require.NoError(t, err)
+	top, err := filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(top, "main.go"),
+		filepath.Join(top, "pkg", "added.go"),
+		filepath.Join(top, "renamed.go"),
+		filepath.Join(top, "untracked.go"),
+	}, files)
+
+	// Calls into unchanged files resolve, but only changed files are reported.
+	report, err := analysis.NewAnalyzerWithoutDB().GetChangedAnalysis(context.Background(), files)
+	require.NoError(t, err)
+	var names []string
+	for _, fn := range report.Functions {
+		names = append(names, fn.Caller)
+	}
+	assert.ElementsMatch(t, []string{"main", "Added", "old", "added"}, names)
+	for _, fn := range report.Functions {
+		if fn.Caller == "main" {
+			assert.ElementsMatch(t, []string{"helper", "added"}, fn.Callees)
+		}
+	}
+
+	_, err = analysis.ChangedGoFiles(dir, "no-such-ref")
+	assert.Error(t, err)
+}
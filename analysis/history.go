@@ -0,0 +1,218 @@
+package analysis
+
+import (
+	"archive/tar"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// Commit is a commit of a git repository.
+type Commit struct {
+	SHA  string
+	Time time.Time // committer date
+}
+
+// SampleCommits returns every step-th commit changing files under repoDir
+// in the first-parent history of HEAD in its repository, counting back from
+// HEAD so the latest commit is always included, oldest first. If since is set, only commits
+// made since then are sampled; it accepts any date git log does, such as
+// 2024-01-01 or "3 months ago".
+func SampleCommits(repoDir, since string, step int) ([]Commit, error) {
+	if step < 1 {
+		return nil, fmt.Errorf("step must be at least 1, got %d", step)
+	}
+	args := []string{"log", "--first-parent", "--format=%H %cI"}
+	if since != "" {
+		args = append(args, "--since="+since)
+	}
+	out, err := git(repoDir, append(args, "HEAD", "--", ".")...)
+	if err != nil {
+		return nil, err
+	}
+	var commits []Commit
+	for i, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line == "" || i%step != 0 {
+			continue
+		}
+		sha, date, _ := strings.Cut(line, " ")
+		committed, err := time.Parse(time.RFC3339, date)
+		if err != nil {
+			return nil, fmt.Errorf("commit %s: %w", sha, err)
+		}
+		commits = append(commits, Commit{SHA: sha, Time: committed})
+	}
+	slices.Reverse(commits)
+	return commits, nil
+}
+
+// AnalyzeCommit analyzes the Go files under repoDir, a directory of a git
+// repository, as of commit sha, without touching its working tree.
+func AnalyzeCommit(repoDir, sha string) (surrealtypes.AnalysisReport, error) {
+	return NewAnalyzerWithoutDB().AnalyzeCommit(context.Background(), repoDir, sha)
+}
+
+// AnalyzeCommit analyzes the Go files under repoDir, a directory of a git
+// repository, as of commit sha. The directory's tree at the commit, with
+// the go.mod files above it, is extracted to a temporary directory, so the
+// working tree is left alone, and files are reported under their path in
+// the repository.
+func (a *Analyzer) AnalyzeCommit(ctx context.Context, repoDir, sha string) (surrealtypes.AnalysisReport, error) {
+	out, err := git(repoDir, "rev-parse", "--show-toplevel", "--show-prefix")
+	if err != nil {
+		return surrealtypes.AnalysisReport{}, err
+	}
+	top, prefix, _ := strings.Cut(strings.TrimSuffix(out, "\n"), "\n")
+	root := cmp.Or(strings.TrimSuffix(prefix, "/"), ".")
+	paths := []string{root}
+	if root != "." {
+		// The go.mod files above the directory give its packages their
+		// import paths.
+		var modules []string
+		for dir := path.Dir(root); ; dir = path.Dir(dir) {
+			modules = append(modules, path.Join(dir, "go.mod"))
+			if dir == "." {
+				break
+			}
+		}
+		found, err := git(top, append([]string{"ls-tree", "--name-only", sha, "--"}, modules...)...)
+		if err != nil {
+			return surrealtypes.AnalysisReport{}, err
+		}
+		for _, name := range strings.Split(found, "\n") {
+			if name != "" {
+				paths = append(paths, name)
+			}
+		}
+	}
+	archive, err := git(top, append([]string{"archive", "--format=tar", sha, "--"}, paths...)...)
+	if err != nil {
+		return surrealtypes.AnalysisReport{}, err
+	}
+	dir, err := os.MkdirTemp("", "surrealcode-commit-")
+	if err != nil {
+		return surrealtypes.AnalysisReport{}, err
+	}
+	defer os.RemoveAll(dir)
+	if err := extractTar(strings.NewReader(archive), dir); err != nil {
+		return surrealtypes.AnalysisReport{}, fmt.Errorf("failed to extract %s: %w", sha, err)
+	}
+	return a.AnalyzeFS(ctx, os.DirFS(dir), root)
+}
+
+// extractTar writes the directories and regular files of the tar archive r
+// under dir. Other entries, such as symbolic links, are skipped.
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.FromSlash(strings.TrimSuffix(hdr.Name, "/"))
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid path %q", hdr.Name)
+		}
+		target := filepath.Join(dir, name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.Create(target)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// HistoryPoint holds the aggregate metrics of the code at a commit.
+type HistoryPoint struct {
+	Commit             string    `json:"commit"`
+	Time               time.Time `json:"time"`
+	Functions          int       `json:"functions"`
+	LinesOfCode        int       `json:"lines_of_code"`
+	AvgComplexity      float64   `json:"avg_complexity"`
+	AvgMaintainability float64   `json:"avg_maintainability"`
+	HealthScore        float64   `json:"health_score"`
+}
+
+// AnalyzeHistory analyzes the Go files under repoDir at each of commits, in
+// order, and returns their aggregate metrics. If each is set, it is called
+// with the report of every commit, such as to store it as a snapshot, and
+// an error it returns stops the walk.
+func (a *Analyzer) AnalyzeHistory(ctx context.Context, repoDir string, commits []Commit, each func(Commit, surrealtypes.AnalysisReport) error) ([]HistoryPoint, error) {
+	points := make([]HistoryPoint, 0, len(commits))
+	for _, c := range commits {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		report, err := a.AnalyzeCommit(ctx, repoDir, c.SHA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to analyze commit %s: %w", c.SHA, err)
+		}
+		if each != nil {
+			if err := each(c, report); err != nil {
+				return nil, err
+			}
+		}
+		summary := a.GenerateCodeSummary(report)
+		points = append(points, HistoryPoint{
+			Commit:             c.SHA,
+			Time:               c.Time,
+			Functions:          summary.TotalFunctions,
+			LinesOfCode:        summary.TotalLines,
+			AvgComplexity:      summary.AvgComplexity,
+			AvgMaintainability: summary.AvgMaintainability,
+			HealthScore:        report.HealthScore(),
+		})
+	}
+	return points, nil
+}
+
+// WriteHistoryCSV writes points as CSV with a header row, one row per
+// commit.
+func WriteHistoryCSV(w io.Writer, points []HistoryPoint) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"commit", "time", "functions", "lines_of_code", "avg_complexity", "avg_maintainability", "health_score"})
+	for _, p := range points {
+		cw.Write([]string{
+			p.Commit,
+			p.Time.Format(time.RFC3339),
+			strconv.Itoa(p.Functions),
+			strconv.Itoa(p.LinesOfCode),
+			strconv.FormatFloat(p.AvgComplexity, 'f', 2, 64),
+			strconv.FormatFloat(p.AvgMaintainability, 'f', 2, 64),
+			strconv.FormatFloat(p.HealthScore, 'f', 2, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
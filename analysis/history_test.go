@@ -0,0 +1,103 @@
+package analysis_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	write := func(name, src string) {
+		t.Helper()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0644))
+	}
+
+	write("main.go", "package main\n\nfunc main() {}\n")
+	git("init", "-q", "-b", "main")
+	git("add", ".")
+	git("commit", "-q", "-m", "first")
+	first := git("rev-parse", "HEAD")
+	write("main.go", "package main\n\nfunc main() { helper(1) }\n")
+	write("pkg/helper.go", "package main\n\nfunc helper(n int) int {\n\tif n > 0 {\n\t\treturn n\n\t}\n\treturn -n\n}\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "second")
+	second := git("rev-parse", "HEAD")
+	// Uncommitted changes are not analyzed.
+	write("main.go", "package main\n\nfunc main() {}\n\nfunc dirty() {}\n")
+
+	report, err := analysis.AnalyzeCommit(dir, first)
+	require.NoError(t, err)
+	require.Len(t, report.Functions, 1)
+	assert.Equal(t, "main.go", report.Functions[0].File)
+
+	commits, err := analysis.SampleCommits(dir, "", 1)
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+	assert.Equal(t, first, commits[0].SHA)
+	assert.Equal(t, second, commits[1].SHA)
+
+	// Sampling every other commit keeps the latest.
+	sampled, err := analysis.SampleCommits(dir, "", 2)
+	require.NoError(t, err)
+	require.Len(t, sampled, 1)
+	assert.Equal(t, second, sampled[0].SHA)
+
+	var snapshots []string
+	points, err := analysis.NewAnalyzerWithoutDB().AnalyzeHistory(context.Background(), dir, commits, func(c analysis.Commit, report types.AnalysisReport) error {
+		snapshots = append(snapshots, c.SHA)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{first, second}, snapshots)
+	require.Len(t, points, 2)
+	assert.Equal(t, 1, points[0].Functions)
+	assert.Equal(t, 2, points[1].Functions)
+	assert.Equal(t, 1.0, points[0].AvgComplexity)
+	assert.Equal(t, 1.5, points[1].AvgComplexity)
+
+	var buf bytes.Buffer
+	require.NoError(t, analysis.WriteHistoryCSV(&buf, points))
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, []string{"commit", "time", "functions", "lines_of_code", "avg_complexity", "avg_maintainability", "health_score"}, rows[0])
+	assert.Equal(t, []string{second, "2", "1.50"}, []string{rows[2][0], rows[2][2], rows[2][4]})
+
+	// A subdirectory limits both the commits and the files analyzed.
+	sub := filepath.Join(dir, "pkg")
+	commits, err = analysis.SampleCommits(sub, "", 1)
+	require.NoError(t, err)
+	require.Len(t, commits, 1)
+	assert.Equal(t, second, commits[0].SHA)
+	report, err = analysis.AnalyzeCommit(sub, second)
+	require.NoError(t, err)
+	require.Len(t, report.Functions, 1)
+	assert.Equal(t, "helper", report.Functions[0].Caller)
+	assert.Equal(t, "pkg/helper.go", report.Functions[0].File)
+
+	_, err = analysis.AnalyzeCommit(dir, "no-such-commit")
+	assert.Error(t, err)
+}
@@ -0,0 +1,122 @@
+package analysis
+
+import (
+	"bytes"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"path/filepath"
+)
+
+// importCache imports packages from source for the type checker, like
+// importer.ForCompiler(fset, "source", nil), and keeps them for the rest of
+// an analysis run, so the files of a package, and of packages importing the
+// same ones, type check their imports once. Failed imports are kept too.
+type importCache struct {
+	importer types.ImporterFrom
+	packages map[importKey]importResult
+
+	hits, misses int
+}
+
+// importKey identifies an import: the same path may resolve to different
+// packages from different directories, as with vendoring or nested modules.
+type importKey struct {
+	path, dir string
+}
+
+type importResult struct {
+	pkg *types.Package
+	err error
+}
+
+// newImportCache returns an empty import cache. Imported packages have
+// positions in a file set of their own, as only the positions of the
+// analyzed files are reported.
+func newImportCache() *importCache {
+	return &importCache{
+		importer: importer.ForCompiler(token.NewFileSet(), "source", nil).(types.ImporterFrom),
+		packages: make(map[importKey]importResult),
+	}
+}
+
+// Import implements types.Importer.
+func (c *importCache) Import(path string) (*types.Package, error) {
+	return c.ImportFrom(path, "", 0)
+}
+
+// ImportFrom implements types.ImporterFrom.
+func (c *importCache) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	key := importKey{path: path, dir: dir}
+	if result, ok := c.packages[key]; ok {
+		c.hits++
+		return result.pkg, result.err
+	}
+	c.misses++
+	pkg, err := c.importer.ImportFrom(path, dir, mode)
+	c.packages[key] = importResult{pkg: pkg, err: err}
+	return pkg, err
+}
+
+// packageCache holds the analyzed files of a run, type checked package by
+// package: the first time a file of a directory is analyzed, every file of
+// that directory is read and parsed, and those the default build context
+// would compile are grouped by package name. The files of a group are type
+// checked together when the first of them is analyzed, so symbols declared
+// in sibling files resolve, and the rest reuse its type information. Files
+// excluded by their build constraints are type checked alone.
+type packageCache struct {
+	imports *importCache
+	dirs    map[string][]sourceFile // files to analyze, by directory
+	files   map[string]loadedFile   // loaded files not analyzed yet, by path
+
+	hits, misses int
+}
+
+// loadedFile is a file parsed with the other files of its directory.
+type loadedFile struct {
+	file *ast.File
+	pkg  *checkedPackage
+	err  error // the error reading or parsing the file
+}
+
+// checkedPackage is a package of analyzed files, type checked at most once.
+type checkedPackage struct {
+	name  string
+	fset  *token.FileSet
+	paths []string
+	files []*ast.File
+
+	checked bool
+	pkg     *types.Package
+	info    *types.Info
+	errs    map[string]error // the first hard type error of each file, by path
+}
+
+// newPackageCache returns a cache for analyzing files.
+func newPackageCache(files []sourceFile) *packageCache {
+	c := &packageCache{
+		imports: newImportCache(),
+		dirs:    make(map[string][]sourceFile),
+		files:   make(map[string]loadedFile),
+	}
+	for _, f := range files {
+		dir := filepath.Dir(f.path)
+		c.dirs[dir] = append(c.dirs[dir], f)
+	}
+	return c
+}
+
+// buildable reports whether the default build context compiles the Go file
+// at path with contents src, given its name and build constraints.
+func buildable(path string, src []byte) bool {
+	ctxt := build.Default
+	ctxt.OpenFile = func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(src)), nil
+	}
+	ok, err := ctxt.MatchFile(filepath.Dir(path), filepath.Base(path))
+	return ok && err == nil
+}
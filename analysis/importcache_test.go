@@ -0,0 +1,110 @@
+package analysis_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePackage writes a package of n files to a temporary directory, each
+// importing the same standard library packages. The first file declares the
+// Shape type the others use.
+func writePackage(tb testing.TB, n int) string {
+	tb.Helper()
+	dir := tb.TempDir()
+	for i := range n {
+		src := fmt.Sprintf(`package shapes
+
+import (
+	"fmt"
+	"strings"
+)
+
+func Describe%d(s Shape) string {
+	return fmt.Sprintf("%%d: %%s", %d, strings.ToUpper(s.Name()))
+}
+`, i, i)
+		if i == 0 {
+			src += `
+type Shape struct{ name string }
+
+func (s Shape) Name() string { return s.name }
+`
+		}
+		require.NoError(tb, os.WriteFile(filepath.Join(dir, fmt.Sprintf("shape%d.go", i)), []byte(src), 0644))
+	}
+	return dir
+}
+
+func TestAnalyzer_PackageCache(t *testing.T) {
+	dir := writePackage(t, 3)
+	// A file the build ignores redeclares Shape; it is type checked alone.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "gen.go"), []byte(`//go:build ignore
+
+package shapes
+
+type Shape int
+`), 0644))
+	var analyzed analysis.ProgressEvent
+	var skipped []string
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	analyzer.Progress = func(event analysis.ProgressEvent) {
+		switch event.Stage {
+		case analysis.ProgressAnalyzed:
+			analyzed = event
+		case analysis.ProgressTypeCheckSkipped:
+			skipped = append(skipped, event.Path)
+		}
+	}
+	report, err := analyzer.GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+	require.Len(t, report.Functions, 4)
+
+	// The shapes files are type checked together, and gen.go on its own.
+	assert.Equal(t, 2, analyzed.PackageMisses)
+	assert.Equal(t, 2, analyzed.PackageHits)
+	// fmt and strings are type checked once.
+	assert.Equal(t, 2, analyzed.ImportMisses)
+	assert.Equal(t, 0, analyzed.ImportHits)
+
+	// Shape, declared in shape0.go, resolves in the other files.
+	assert.Empty(t, skipped)
+	functions := make(map[string]types.FunctionCall)
+	for _, fn := range report.Functions {
+		functions[fn.Caller] = fn
+	}
+	assert.Equal(t, []string{"Shape.Name"}, functions["Describe2"].Callees)
+
+	// A file analyzed on its own keeps the type information of its imports.
+	fa, err := analyzer.AnalyzeFile(filepath.Join(dir, "shape0.go"))
+	require.NoError(t, err)
+	assert.Equal(t, functions["Describe0"].PackageDependencies, fa.Functions[0].PackageDependencies)
+}
+
+// BenchmarkAnalyzer_PackageCache analyzes a package of 20 files, reporting
+// the type checks per run against the files, each of which was type checked
+// on its own before.
+func BenchmarkAnalyzer_PackageCache(b *testing.B) {
+	dir := writePackage(b, 20)
+	var analyzed analysis.ProgressEvent
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	analyzer.Progress = func(event analysis.ProgressEvent) {
+		if event.Stage == analysis.ProgressAnalyzed {
+			analyzed = event
+		}
+	}
+	for b.Loop() {
+		if _, err := analyzer.GetAnalysis(context.Background(), dir); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(analyzed.PackageMisses), "typechecks/op")
+	b.ReportMetric(float64(analyzed.Total), "files/op")
+}
@@ -0,0 +1,152 @@
+package analysis
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// DefaultResources are the functions whose results must be closed, unless a
+// rules file lists its own, named as types.Func.FullName names them.
+var DefaultResources = []string{
+	"os.Open",
+	"os.OpenFile",
+	"os.Create",
+	"net.Dial",
+	"net.DialTimeout",
+	"net.Listen",
+	"(*database/sql.DB).Query",
+	"(*database/sql.DB).QueryContext",
+}
+
+// resources returns the functions whose results leak detection tracks.
+func (a *Analyzer) resources() []string {
+	if a.Rules != nil && len(a.Rules.Resources) > 0 {
+		return a.Rules.Resources
+	}
+	return DefaultResources
+}
+
+// leakSuspects returns the calls of fn to resources whose result fn neither
+// closes nor hands on: the result is discarded, or the variable it is
+// assigned to is never the receiver of a Close call, deferred or not, and
+// is not returned, passed to a deferred call, sent or stored elsewhere.
+// Calls are only recognized with type information.
+func leakSuspects(fn *ast.FuncDecl, caller, path string, fset *token.FileSet, info *types.Info, resources []string) []surrealtypes.LeakSuspect {
+	if fn.Body == nil {
+		return nil
+	}
+	type opened struct {
+		obj      types.Object
+		resource string
+		pos      token.Pos
+	}
+	var opens []opened
+	var suspects []surrealtypes.LeakSuspect
+	suspect := func(resource string, pos token.Pos) {
+		suspects = append(suspects, surrealtypes.LeakSuspect{Func: caller, File: path, Resource: resource, Line: fset.Position(pos).Line})
+	}
+	track := func(lhs []ast.Expr, rhs []ast.Expr) {
+		if len(lhs) == 0 || len(rhs) != 1 {
+			return
+		}
+		resource := resourceCall(rhs[0], info, resources)
+		if resource == "" {
+			return
+		}
+		ident, ok := lhs[0].(*ast.Ident)
+		if !ok {
+			return // stored in a field or element
+		}
+		if ident.Name == "_" {
+			suspect(resource, rhs[0].Pos())
+			return
+		}
+		obj := info.Defs[ident]
+		if obj == nil {
+			obj = info.Uses[ident]
+		}
+		if obj != nil {
+			opens = append(opens, opened{obj, resource, rhs[0].Pos()})
+		}
+	}
+
+	closed := make(map[types.Object]bool)
+	handOn := func(exprs ...ast.Expr) {
+		for _, expr := range exprs {
+			if ident, ok := ast.Unparen(expr).(*ast.Ident); ok && info.Uses[ident] != nil {
+				closed[info.Uses[ident]] = true
+			}
+		}
+	}
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.AssignStmt:
+			track(node.Lhs, node.Rhs)
+			handOn(node.Rhs...)
+		case *ast.ValueSpec:
+			lhs := make([]ast.Expr, len(node.Names))
+			for i, name := range node.Names {
+				lhs[i] = name
+			}
+			track(lhs, node.Values)
+		case *ast.ExprStmt:
+			if resource := resourceCall(node.X, info, resources); resource != "" {
+				suspect(resource, node.X.Pos())
+			}
+		case *ast.CallExpr:
+			if sel, ok := ast.Unparen(node.Fun).(*ast.SelectorExpr); ok && sel.Sel.Name == "Close" {
+				handOn(sel.X)
+			}
+		case *ast.DeferStmt:
+			handOn(node.Call.Args...)
+		case *ast.ReturnStmt:
+			handOn(node.Results...)
+		case *ast.SendStmt:
+			handOn(node.Value)
+		case *ast.CompositeLit:
+			for _, elt := range node.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					elt = kv.Value
+				}
+				handOn(elt)
+			}
+		}
+		return true
+	})
+	for _, open := range opens {
+		if !closed[open.obj] {
+			suspect(open.resource, open.pos)
+		}
+	}
+	slices.SortStableFunc(suspects, func(a, b surrealtypes.LeakSuspect) int {
+		return a.Line - b.Line
+	})
+	return suspects
+}
+
+// resourceCall returns the name of the resource expr opens if it calls one
+// of resources, or "".
+func resourceCall(expr ast.Expr, info *types.Info, resources []string) string {
+	call, ok := ast.Unparen(expr).(*ast.CallExpr)
+	if !ok {
+		return ""
+	}
+	var ident *ast.Ident
+	switch f := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		ident = f
+	case *ast.SelectorExpr:
+		ident = f.Sel
+	default:
+		return ""
+	}
+	fn, ok := info.Uses[ident].(*types.Func)
+	if !ok || !slices.Contains(resources, fn.FullName()) {
+		return ""
+	}
+	return fn.FullName()
+}
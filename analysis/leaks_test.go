@@ -0,0 +1,95 @@
+package analysis_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const leakySource = `package files
+
+import (
+	"io"
+	"os"
+)
+
+func leak(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(f)
+}
+
+func closed(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func closedInClosure(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() { f.Close() }()
+	_, err = f.WriteString("hello")
+	return err
+}
+
+func open(path string) (*os.File, error) {
+	f, err := os.Open(path)
+	return f, err
+}
+
+func discard(path string) {
+	_, _ = os.Create(path)
+}
+
+func closedByHelper(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	closeFile(f)
+	return nil
+}
+
+func closeFile(f *os.File) { f.Close() }
+`
+
+func TestLeakSuspects(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "files.go")
+	require.NoError(t, os.WriteFile(file, []byte(leakySource), 0644))
+
+	report, err := analysis.Analyze(context.Background(), dir, analysis.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []types.LeakSuspect{
+		{Func: "leak", File: file, Resource: "os.Open", Line: 9},
+		{Func: "discard", File: file, Resource: "os.Create", Line: 41},
+		// A close in a helper is not seen.
+		{Func: "closedByHelper", File: file, Resource: "os.Open", Line: 45},
+	}, report.LeakSuspects)
+
+	summary := analysis.NewAnalyzerWithoutDB().GenerateCodeSummary(report)
+	assert.Equal(t, 3, summary.LeakSuspects)
+
+	// A rules file replaces the resources tracked.
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	analyzer.Rules = &analysis.Rules{Resources: []string{"os.Create"}}
+	report, err = analyzer.GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Equal(t, []types.LeakSuspect{
+		{Func: "discard", File: file, Resource: "os.Create", Line: 41},
+	}, report.LeakSuspects)
+}
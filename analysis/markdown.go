@@ -0,0 +1,106 @@
+package analysis
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// distributionOrder is the order in which complexity buckets are listed.
+var distributionOrder = []string{"Low", "Medium", "High"}
+
+// WriteMarkdown writes a human-readable report with an overview, the
+// hotspots, the complexity distribution, test metrics when cs has them and
+// the unused functions. Every list is sorted, so reports of the same code
+// are identical.
+func WriteMarkdown(w io.Writer, summary surrealtypes.Summary, cs surrealtypes.CodeSummary) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "# Code Analysis Report\n\n")
+
+	fmt.Fprintf(bw, "## Overview\n\n")
+	fmt.Fprintf(bw, "| Metric | Value |\n|---|---|\n")
+	fmt.Fprintf(bw, "| Health score | %.2f |\n", summary.HealthScore)
+	fmt.Fprintf(bw, "| Functions | %d |\n", cs.TotalFunctions)
+	fmt.Fprintf(bw, "| Structs | %d |\n", summary.TotalStructs)
+	fmt.Fprintf(bw, "| Imports | %d |\n", summary.TotalImports)
+	fmt.Fprintf(bw, "| Lines of code | %d |\n", cs.TotalLines)
+	fmt.Fprintf(bw, "| Unused functions | %d |\n", cs.UnusedFunctions)
+	fmt.Fprintf(bw, "| Recursive functions | %d |\n", cs.RecursiveFunctions)
+	fmt.Fprintf(bw, "| Duplicate functions | %d |\n", cs.DuplicateCode)
+	fmt.Fprintf(bw, "| Stub functions | %d |\n", cs.StubFunctions)
+	fmt.Fprintf(bw, "| Average complexity | %.2f |\n", cs.AvgComplexity)
+	fmt.Fprintf(bw, "| Average maintainability | %.2f |\n", cs.AvgMaintainability)
+	fmt.Fprintf(bw, "| Average nesting depth | %.2f |\n", cs.AvgNestingDepth)
+	fmt.Fprintf(bw, "| Max call depth | %d |\n", cs.MaxCallDepth)
+
+	fmt.Fprintf(bw, "\n## Hotspots\n\n")
+	if len(cs.Hotspots) == 0 {
+		fmt.Fprintf(bw, "No hotspots.\n")
+	} else {
+		hotspots := slices.Clone(cs.Hotspots)
+		slices.SortFunc(hotspots, func(a, b surrealtypes.HotspotFunction) int {
+			return cmp.Or(
+				cmp.Compare(b.Complexity, a.Complexity),
+				cmp.Compare(a.File, b.File),
+				cmp.Compare(a.Name, b.Name),
+			)
+		})
+		fmt.Fprintf(bw, "| Function | File | Complexity | Maintainability | Issues |\n|---|---|---|---|---|\n")
+		for _, h := range hotspots {
+			fmt.Fprintf(bw, "| `%s` | %s | %d | %.2f | %s |\n",
+				h.Name, h.File, h.Complexity, h.Maintainability, strings.Join(h.Issues, ", "))
+		}
+	}
+
+	fmt.Fprintf(bw, "\n## Complexity Distribution\n\n")
+	fmt.Fprintf(bw, "| Bucket | Functions |\n|---|---|\n")
+	for _, bucket := range distributionOrder {
+		fmt.Fprintf(bw, "| %s | %d |\n", bucket, cs.ComplexityDistribution[bucket])
+	}
+	var other []string
+	for bucket := range cs.ComplexityDistribution {
+		if !slices.Contains(distributionOrder, bucket) {
+			other = append(other, bucket)
+		}
+	}
+	slices.Sort(other)
+	for _, bucket := range other {
+		fmt.Fprintf(bw, "| %s | %d |\n", bucket, cs.ComplexityDistribution[bucket])
+	}
+
+	if cs.Tests != nil {
+		fmt.Fprintf(bw, "\n## Tests\n\n")
+		fmt.Fprintf(bw, "| Metric | Value |\n|---|---|\n")
+		fmt.Fprintf(bw, "| Functions | %d |\n", cs.Tests.TotalFunctions)
+		fmt.Fprintf(bw, "| Lines of code | %d |\n", cs.Tests.TotalLines)
+		fmt.Fprintf(bw, "| Unused functions | %d |\n", cs.Tests.UnusedFunctions)
+		fmt.Fprintf(bw, "| Average complexity | %.2f |\n", cs.Tests.AvgComplexity)
+		fmt.Fprintf(bw, "| Average maintainability | %.2f |\n", cs.Tests.AvgMaintainability)
+	}
+
+	fmt.Fprintf(bw, "\n## Dead Code\n\n")
+	var unused []surrealtypes.FunctionSummary
+	for _, fn := range summary.Functions {
+		if fn.IsUnused {
+			unused = append(unused, fn)
+		}
+	}
+	if len(unused) == 0 {
+		fmt.Fprintf(bw, "No unused functions.\n")
+	} else {
+		slices.SortFunc(unused, func(a, b surrealtypes.FunctionSummary) int {
+			return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Name, b.Name))
+		})
+		for _, fn := range unused {
+			fmt.Fprintf(bw, "- `%s` (%s)\n", fn.Name, fn.File)
+		}
+	}
+
+	return bw.Flush()
+}
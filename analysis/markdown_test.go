@@ -0,0 +1,96 @@
+package analysis_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	summary := types.Summary{
+		TotalFunctions: 3,
+		TotalStructs:   1,
+		TotalImports:   2,
+		HealthScore:    72.5,
+		Functions: []types.FunctionSummary{
+			{Name: "main", File: "main.go"},
+			{Name: "zombie", File: "util.go", IsUnused: true},
+			{Name: "helper", File: "main.go", IsUnused: true},
+		},
+	}
+	cs := types.CodeSummary{
+		TotalFunctions:         3,
+		TotalLines:             42,
+		UnusedFunctions:        2,
+		AvgComplexity:          7,
+		AvgMaintainability:     61.5,
+		AvgNestingDepth:        1.25,
+		MaxCallDepth:           2,
+		ComplexityDistribution: map[string]int{"High": 2, "Low": 1},
+		Hotspots: []types.HotspotFunction{
+			{Name: "parse", File: "parse.go", Complexity: 12, Maintainability: 40, Issues: []string{"High cyclomatic complexity", "Low maintainability"}},
+			{Name: "render", File: "render.go", Complexity: 15, Maintainability: 35.25},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, analysis.WriteMarkdown(&buf, summary, cs))
+	assert.Equal(t, `# Code Analysis Report
+
+## Overview
+
+| Metric | Value |
+|---|---|
+| Health score | 72.50 |
+| Functions | 3 |
+| Structs | 1 |
+| Imports | 2 |
+| Lines of code | 42 |
+| Unused functions | 2 |
+| Recursive functions | 0 |
+| Duplicate functions | 0 |
+| Stub functions | 0 |
+| Average complexity | 7.00 |
+| Average maintainability | 61.50 |
+| Average nesting depth | 1.25 |
+| Max call depth | 2 |
+
+## Hotspots
+
+| Function | File | Complexity | Maintainability | Issues |
+|---|---|---|---|---|
+| `+"`render`"+` | render.go | 15 | 35.25 |  |
+| `+"`parse`"+` | parse.go | 12 | 40.00 | High cyclomatic complexity, Low maintainability |
+
+## Complexity Distribution
+
+| Bucket | Functions |
+|---|---|
+| Low | 1 |
+| Medium | 0 |
+| High | 2 |
+
+## Dead Code
+
+- `+"`helper`"+` (main.go)
+- `+"`zombie`"+` (util.go)
+`, buf.String())
+
+	// Reordering the input must not change the output.
+	summary.Functions[1], summary.Functions[2] = summary.Functions[2], summary.Functions[1]
+	cs.Hotspots[0], cs.Hotspots[1] = cs.Hotspots[1], cs.Hotspots[0]
+	var again bytes.Buffer
+	require.NoError(t, analysis.WriteMarkdown(&again, summary, cs))
+	assert.Equal(t, buf.String(), again.String())
+}
+
+func TestWriteMarkdown_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, analysis.WriteMarkdown(&buf, types.Summary{}, types.CodeSummary{}))
+	assert.Contains(t, buf.String(), "No hotspots.")
+	assert.Contains(t, buf.String(), "No unused functions.")
+}
@@ -42,6 +42,34 @@ func TestComputeHalsteadMetrics(t *testing.T) {
 	metrics := fn.Metrics.HalsteadMetrics
 	assert.Greater(t, metrics.Volume, 0.0)
 	assert.Greater(t, metrics.Effort, 0.0)
+
+	// Operators: func, if, <=, return (x2), *, example(), -
+	// Operands: n (x4), int (x2), 1 (x3)
+	assert.Equal(t, 7, metrics.UniqueOperators)
+	assert.Equal(t, 3, metrics.UniqueOperands)
+	assert.Equal(t, 8, metrics.Operators)
+	assert.Equal(t, 9, metrics.Operands)
+}
+
+func TestHalsteadOperatorClassification(t *testing.T) {
+	src := `package test
+        func sum(xs []int) string {
+            total := 0
+            for i := range xs {
+                total += xs[i]
+            }
+            return fmt.Sprint(total)
+        }`
+
+	_, functions := setupAnalyzer(t, src)
+	metrics := functions[0].Metrics.HalsteadMetrics
+
+	// Operators: func, :=, range, +=, [], return, Sprint(), .
+	// Operands: xs (x3), int, string, total (x3), 0, i (x2), fmt
+	assert.Equal(t, 8, metrics.UniqueOperators)
+	assert.Equal(t, 8, metrics.Operators)
+	assert.Equal(t, 7, metrics.UniqueOperands)
+	assert.Equal(t, 12, metrics.Operands)
 }
 
 func TestDetectDuplication(t *testing.T) {
@@ -85,8 +113,53 @@ func TestMaintainabilityIndex(t *testing.T) {
 	_, functions := setupAnalyzer(t, src)
 	maintainability := functions[0].Metrics.Maintainability
 
-	assert.Less(t, maintainability, 171.0)
-	assert.Greater(t, maintainability, -200.0)
+	assert.LessOrEqual(t, maintainability, 100.0)
+	assert.GreaterOrEqual(t, maintainability, 0.0)
+	assert.InDelta(t, functions[0].Metrics.MaintainabilityRaw*100/171, maintainability, 1e-9)
+}
+
+func TestMaintainabilityIndexRange(t *testing.T) {
+	trivial := `package test
+        func id(n int) int { return n }`
+	tangled := `package test
+        func classify(items []int, limit int, strict bool) (int, int, error) {
+            small, large := 0, 0
+            for i, item := range items {
+                switch {
+                case item < 0 && strict:
+                    return 0, 0, nil
+                case item < limit || i%2 == 0:
+                    small += item
+                    if small > limit*2 {
+                        for j := 0; j < item; j++ {
+                            if j%3 == 0 && !strict {
+                                large -= j
+                            } else if j%5 == 0 || j%7 == 0 {
+                                large += j * item
+                            }
+                        }
+                    }
+                default:
+                    large += item * limit
+                    if large > 1000 && (strict || limit > 10) {
+                        large = large/2 + small%limit
+                    }
+                }
+            }
+            if small > large {
+                return small - large, large, nil
+            }
+            return small, large - small, nil
+        }`
+
+	_, functions := setupAnalyzer(t, trivial)
+	simpleMI := functions[0].Metrics.Maintainability
+	assert.Greater(t, simpleMI, 90.0)
+
+	_, functions = setupAnalyzer(t, tangled)
+	complexMI := functions[0].Metrics.Maintainability
+	assert.Less(t, complexMI, 50.0)
+	assert.Less(t, complexMI, simpleMI-40)
 }
 
 func TestCountLines(t *testing.T) {
@@ -193,10 +266,63 @@ func TestCognitiveComplexity(t *testing.T) {
 					}
 					return x
 				}`,
-			wantScore:      6,
+			// if +1, nested if +2, && +1, for +3, innermost if +4.
+			wantScore:      11,
 			wantNesting:    4,
 			wantLogicalOps: 1,
 		},
+		{
+			name: "else chains and operator sequences",
+			src: `package test
+				func classify(x, y int) string {
+					if x > 0 && y > 0 && x > y {
+						return "a"
+					} else if x < 0 || (y < 0 && x != y) {
+						return "b"
+					} else {
+						for i := 0; i < x; i++ {
+							switch {
+							case i > y:
+								continue
+							}
+						}
+					}
+					return "c"
+				}`,
+			// if +1, && && +1, else if +1, || +1, && +1, else +1,
+			// for +2 (nesting 1), switch +3 (nesting 2).
+			wantScore:      11,
+			wantNesting:    3,
+			wantLogicalOps: 4,
+		},
+		{
+			name: "labeled jumps and closures",
+			src: `package test
+				func search(rows [][]int, target int) bool {
+					found := false
+				outer:
+					for _, row := range rows {
+						check := func(v int) bool {
+							if v == target {
+								return true
+							}
+							return false
+						}
+						for _, v := range row {
+							if check(v) {
+								found = true
+								break outer
+							}
+						}
+					}
+					return found
+				}`,
+			// range +1, if in closure +3 (nesting 2), inner range +2,
+			// if +3, break outer +1.
+			wantScore:      10,
+			wantNesting:    3,
+			wantLogicalOps: 0,
+		},
 	}
 
 	for _, tt := range tests {
@@ -261,3 +387,192 @@ func TestDetectDeadCode(t *testing.T) {
 		})
 	}
 }
+
+func TestComputeComplexity(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want int
+	}{
+		{
+			name: "type switch",
+			src: `package test
+				func kind(v any) string {
+					switch v.(type) {
+					case int, int64:
+						return "int"
+					case string:
+						return "string"
+					default:
+						return "other"
+					}
+				}`,
+			want: 3,
+		},
+		{
+			name: "multi-case switch",
+			src: `package test
+				func day(n int) string {
+					switch n {
+					case 0, 6:
+						return "weekend"
+					case 1:
+						return "monday"
+					case 5:
+						return "friday"
+					}
+					return "weekday"
+				}`,
+			want: 4,
+		},
+		{
+			name: "boolean chains",
+			src: `package test
+				func valid(a, b, c bool) bool {
+					if a && b || c {
+						return true
+					}
+					return a || b && c
+				}`,
+			want: 6,
+		},
+		{
+			name: "select with labeled break",
+			src: `package test
+				func drain(a, b chan int, done chan struct{}) {
+				loop:
+					for {
+						select {
+						case <-a:
+						case <-b:
+						case <-done:
+							break loop
+						default:
+						}
+					}
+				}`,
+			want: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, functions := setupAnalyzer(t, tt.src)
+			require.Len(t, functions, 1)
+			assert.Equal(t, tt.want, functions[0].Metrics.CyclomaticComplexity)
+		})
+	}
+}
+
+func TestComputeSLOC(t *testing.T) {
+	src := `package test
+
+func sum(xs []int) int {
+	// total accumulates the result.
+	total := 0
+
+	/*
+		Iterate over every element.
+	*/
+	for _, x := range xs {
+		total += x // running sum
+	}
+
+	return total
+}`
+
+	_, functions := setupAnalyzer(t, src)
+	require.Len(t, functions, 1)
+	metrics := functions[0].Metrics
+	assert.Equal(t, 13, metrics.LinesOfCode)
+	assert.Equal(t, 7, metrics.SLOC)
+	assert.Less(t, metrics.SLOC, metrics.LinesOfCode)
+}
+
+func TestComputeStatementCount(t *testing.T) {
+	src := `package test
+
+func dense(xs []int) (n int) {
+	for _, x := range xs { if x > 0 { n++ } else { n-- } }
+	a, b := 1, 2; a, b = b, a; n += a - b
+	switch { case n > 10: n = 10; case n < -10: n = -10 }
+	return
+}
+
+func sparse(
+	first int,
+	second int,
+) int {
+	// The sum spans lines, one operand each.
+	total := first +
+		second +
+		1
+
+	return total
+}`
+
+	_, functions := setupAnalyzer(t, src)
+	require.Len(t, functions, 2)
+	dense, sparse := functions[0].Metrics, functions[1].Metrics
+	// range, if, an increment and a decrement, a short variable
+	// declaration, two assignments, switch, two cases with an assignment each
+	// and return.
+	assert.Equal(t, 13, dense.StatementCount)
+	assert.Equal(t, 6, dense.LinesOfCode)
+	assert.Equal(t, 2, sparse.StatementCount)
+	assert.Greater(t, sparse.LinesOfCode, dense.LinesOfCode)
+
+	long := types.FunctionCall{Caller: "long", File: "long.go", Metrics: types.FunctionMetrics{
+		StatementCount:  analysis.DefaultComplexityThresholds().MaxStatements + 1,
+		Maintainability: 100,
+	}}
+	summary := analysis.NewAnalyzerWithoutDB().GenerateCodeSummary(types.AnalysisReport{Functions: []types.FunctionCall{long}})
+	require.Len(t, summary.Hotspots, 1)
+	assert.Equal(t, []string{"Long function"}, summary.Hotspots[0].Issues)
+}
+
+func TestComputePageRank(t *testing.T) {
+	// The three-page example of Page and Brin, with damping 0.85.
+	ranks := analysis.ComputePageRank(map[string]types.FunctionCall{
+		"a": {Caller: "a", Callees: []string{"b", "c"}},
+		"b": {Caller: "b", Callees: []string{"c"}},
+		"c": {Caller: "c", Callees: []string{"a", "fmt.Println"}},
+	})
+	assert.InDelta(t, 0.387789, ranks["a"], 1e-6)
+	assert.InDelta(t, 0.214811, ranks["b"], 1e-6)
+	assert.InDelta(t, 0.397400, ranks["c"], 1e-6)
+
+	// A function without calls spreads its rank over every function.
+	ranks = analysis.ComputePageRank(map[string]types.FunctionCall{
+		"main":   {Caller: "main", Callees: []string{"helper"}},
+		"helper": {Caller: "helper"},
+	})
+	assert.InDelta(t, 0.350877, ranks["main"], 1e-6)
+	assert.InDelta(t, 0.649123, ranks["helper"], 1e-6)
+
+	assert.Empty(t, analysis.ComputePageRank(nil))
+}
+
+func TestMaxCallDepth(t *testing.T) {
+	depth, path := analysis.MaxCallDepth(map[string]types.FunctionCall{
+		"main":  {Caller: "main", Callees: []string{"load", "fmt.Println"}},
+		"load":  {Caller: "load", Callees: []string{"parse"}},
+		"parse": {Caller: "parse", Callees: []string{"lex"}},
+		"lex":   {Caller: "lex"},
+	}, "main")
+	assert.Equal(t, 4, depth)
+	assert.Equal(t, []string{"main", "load", "parse", "lex"}, path)
+
+	// Recursion ends a chain instead of extending it forever.
+	depth, path = analysis.MaxCallDepth(map[string]types.FunctionCall{
+		"main":  {Caller: "main", Callees: []string{"walk"}},
+		"walk":  {Caller: "walk", Callees: []string{"walk", "visit"}},
+		"visit": {Caller: "visit", Callees: []string{"walk", "main"}},
+	}, "main")
+	assert.Equal(t, 3, depth)
+	assert.Equal(t, []string{"main", "walk", "visit"}, path)
+
+	depth, path = analysis.MaxCallDepth(nil, "main")
+	assert.Zero(t, depth)
+	assert.Nil(t, path)
+}
@@ -0,0 +1,75 @@
+package analysis
+
+import "log/slog"
+
+// ProgressStage identifies a step of an analysis run.
+type ProgressStage string
+
+const (
+	ProgressScanStart        ProgressStage = "scan-start"         // Path is the directory being scanned
+	ProgressFileParsed       ProgressStage = "file-parsed"        // Path is the file, Index and Total its position, Err set if it was skipped
+	ProgressTypeCheckSkipped ProgressStage = "type-check-skipped" // Path is the file, Err the type error
+	ProgressAnalyzed         ProgressStage = "analyzed"           // Total is the number of files analyzed, the hits and misses the reuse of type information
+	ProgressStoreStart       ProgressStage = "store-start"
+	ProgressDone             ProgressStage = "done"
+)
+
+// ProgressEvent reports the progress of an analysis run.
+type ProgressEvent struct {
+	Stage ProgressStage
+	Path  string
+	Index int // 1-based
+	Total int
+	Err   error
+
+	// PackageMisses counts the packages of analyzed files type checked in
+	// the run, and PackageHits the files that reused the type check of
+	// their package.
+	PackageHits   int
+	PackageMisses int
+	// ImportHits counts the imports of analyzed packages served from the
+	// packages already type checked in the run, and ImportMisses those
+	// type checked from source.
+	ImportHits   int
+	ImportMisses int
+}
+
+// LogProgress is a progress handler that logs every event to the default
+// slog logger.
+func LogProgress(event ProgressEvent) {
+	logProgress(slog.Default(), event)
+}
+
+// logProgress logs event to logger: skipped files and type checks at Warn
+// level, everything else at Info level.
+func logProgress(logger *slog.Logger, event ProgressEvent) {
+	switch event.Stage {
+	case ProgressScanStart:
+		logger.Info("Scanning directory", "path", event.Path)
+	case ProgressFileParsed:
+		if event.Err != nil {
+			logger.Warn("Skipped file", "path", event.Path, "index", event.Index, "total", event.Total, "error", event.Err)
+			break
+		}
+		logger.Info("Processed file", "path", event.Path, "index", event.Index, "total", event.Total)
+	case ProgressTypeCheckSkipped:
+		logger.Warn("Type checking skipped", "path", event.Path, "error", event.Err)
+	case ProgressAnalyzed:
+		logger.Info("Analyzed Go files", "count", event.Total, "package_hits", event.PackageHits, "package_misses", event.PackageMisses, "import_hits", event.ImportHits, "import_misses", event.ImportMisses)
+	case ProgressStoreStart:
+		logger.Info("Storing results")
+	case ProgressDone:
+		logger.Info("Results stored")
+	}
+}
+
+// progress reports event to the analyzer's logger and progress handler, if
+// any.
+func (a *Analyzer) progress(event ProgressEvent) {
+	if a.Logger != nil {
+		logProgress(a.Logger, event)
+	}
+	if a.Progress != nil {
+		a.Progress(event)
+	}
+}
@@ -0,0 +1,64 @@
+package analysis
+
+import (
+	"cmp"
+	"slices"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// DetectDataRaceSuspects returns the functions that write globals without
+// a lock, as recorded in their UnguardedGlobalWrites, and may run in a
+// goroutine: they have a go statement, or are reached through the calls of
+// a function of their package that has one. Each suspect names the first
+// such function by location. Suspects are ordered by location.
+func DetectDataRaceSuspects(functions []surrealtypes.FunctionCall) []surrealtypes.DataRaceSuspect {
+	// Callees are unqualified, so calls are followed within a package.
+	packages := make(map[string]map[string]surrealtypes.FunctionCall)
+	var launchers []surrealtypes.FunctionCall
+	for _, fn := range functions {
+		pkg := packageKey(fn.File, fn.Package)
+		if packages[pkg] == nil {
+			packages[pkg] = make(map[string]surrealtypes.FunctionCall)
+		}
+		packages[pkg][fn.Caller] = fn
+		if fn.HasGoroutines {
+			launchers = append(launchers, fn)
+		}
+	}
+	slices.SortFunc(launchers, compareLocation)
+
+	var suspects []surrealtypes.DataRaceSuspect
+	concurrent := make(map[string]bool)
+	for _, launcher := range launchers {
+		pkg := packageKey(launcher.File, launcher.Package)
+		queue := []string{launcher.Caller}
+		for len(queue) > 0 {
+			name := queue[0]
+			queue = queue[1:]
+			fn, ok := packages[pkg][name]
+			if !ok || concurrent[pkg+"."+name] {
+				continue
+			}
+			concurrent[pkg+"."+name] = true
+			if len(fn.UnguardedGlobalWrites) > 0 {
+				suspects = append(suspects, surrealtypes.DataRaceSuspect{
+					Function:  fn.Caller,
+					Package:   fn.Package,
+					File:      fn.File,
+					Globals:   fn.UnguardedGlobalWrites,
+					Goroutine: launcher.Caller,
+				})
+			}
+			queue = append(queue, fn.Callees...)
+		}
+	}
+	slices.SortFunc(suspects, func(a, b surrealtypes.DataRaceSuspect) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Function, b.Function))
+	})
+	return suspects
+}
+
+func compareLocation(a, b surrealtypes.FunctionCall) int {
+	return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line), cmp.Compare(a.Caller, b.Caller))
+}
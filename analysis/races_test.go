@@ -0,0 +1,82 @@
+package analysis_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectDataRaceSuspects(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+import "sync"
+
+var (
+	hits    int
+	guarded int
+	cache   = map[string]int{}
+	mu      sync.Mutex
+)
+
+func serve() {
+	go func() {
+		hits++
+	}()
+	go record("a")
+	go safe()
+}
+
+func record(key string) {
+	cache[key] = 1
+}
+
+func safe() {
+	mu.Lock()
+	guarded++
+	mu.Unlock()
+}
+
+func sequential() {
+	hits = 0
+}
+
+func main() {
+	var hits int
+	hits++
+	serve()
+	sequential()
+}
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "reset.go"), []byte(`package main
+
+func reset() {
+	go func() { guarded = 0 }()
+}
+`), 0644))
+
+	report, err := analysis.NewAnalyzerWithoutDB().GetAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+
+	functions := map[string]types.FunctionCall{}
+	for _, fn := range report.Functions {
+		functions[fn.Caller] = fn
+	}
+	assert.True(t, functions["serve"].HasGoroutines)
+	assert.False(t, functions["record"].HasGoroutines)
+	assert.Empty(t, functions["safe"].UnguardedGlobalWrites, "writes under a lock are guarded")
+	assert.Empty(t, functions["main"].UnguardedGlobalWrites, "locals shadow globals")
+	assert.Equal(t, []string{"hits"}, functions["sequential"].UnguardedGlobalWrites)
+
+	assert.Equal(t, []types.DataRaceSuspect{
+		{Function: "record", Package: "main", File: filepath.Join(dir, "main.go"), Globals: []string{"cache"}, Goroutine: "serve"},
+		{Function: "serve", Package: "main", File: filepath.Join(dir, "main.go"), Globals: []string{"hits"}, Goroutine: "serve"},
+		{Function: "reset", Package: "main", File: filepath.Join(dir, "reset.go"), Globals: []string{"guarded"}, Goroutine: "reset"},
+	}, report.DataRaceSuspects)
+}
@@ -0,0 +1,198 @@
+package analysis_test
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// referenceReadabilityMetrics is the earlier recursive implementation of
+// ComputeReadabilityMetrics, which collected the children of every node with
+// a separate ast.Inspect, extended to range, type switch and select
+// statements.
+func referenceReadabilityMetrics(fn *ast.FuncDecl, fset *token.FileSet) analysis.CodeReadabilityMetrics {
+	loc := analysis.CountLines(fn, fset)
+	var branchCount, commentCount, maxNesting, loops, switches, selects int
+	children := func(n ast.Node) []ast.Node {
+		var out []ast.Node
+		ast.Inspect(n, func(child ast.Node) bool {
+			if child != n && child != nil {
+				out = append(out, child)
+				return false
+			}
+			return true
+		})
+		return out
+	}
+	var rec func(n ast.Node, nesting int)
+	rec = func(n ast.Node, nesting int) {
+		if n == nil {
+			return
+		}
+		maxNesting = max(maxNesting, nesting)
+		switch node := n.(type) {
+		case *ast.IfStmt:
+			branchCount++
+			rec(node.Cond, nesting)
+			rec(node.Body, nesting+1)
+			if node.Else != nil {
+				rec(node.Else, nesting+1)
+			}
+			return
+		case *ast.ForStmt:
+			branchCount++
+			loops++
+			rec(node.Init, nesting)
+			rec(node.Cond, nesting)
+			rec(node.Post, nesting)
+			rec(node.Body, nesting+1)
+			return
+		case *ast.RangeStmt:
+			branchCount++
+			loops++
+			rec(node.Key, nesting)
+			rec(node.Value, nesting)
+			rec(node.X, nesting)
+			rec(node.Body, nesting+1)
+			return
+		case *ast.SwitchStmt:
+			branchCount++
+			switches++
+			rec(node.Tag, nesting)
+			rec(node.Body, nesting+1)
+			return
+		case *ast.TypeSwitchStmt:
+			branchCount++
+			switches++
+			rec(node.Assign, nesting)
+			rec(node.Body, nesting+1)
+			return
+		case *ast.SelectStmt:
+			branchCount++
+			selects++
+			rec(node.Body, nesting+1)
+			return
+		case *ast.Comment:
+			commentCount++
+		}
+		for _, child := range children(n) {
+			rec(child, nesting)
+		}
+	}
+	rec(fn, 0)
+	commentDensity, branchDensity := 0.0, 0.0
+	if loc > 0 {
+		commentDensity = float64(commentCount) / float64(loc)
+		branchDensity = float64(branchCount) / float64(loc)
+	}
+	return analysis.CodeReadabilityMetrics{
+		FunctionLength:   loc,
+		NestingDepth:     maxNesting,
+		CommentDensity:   commentDensity,
+		CyclomaticPoints: branchCount + 1,
+		BranchDensity:    branchDensity,
+		NumLoops:         loops,
+		NumSwitches:      switches,
+		NumSelects:       selects,
+	}
+}
+
+func TestComputeReadabilityMetrics_Constructs(t *testing.T) {
+	src := `package p
+
+func walk(items []any, done chan bool) {
+	for _, item := range items {
+		switch v := item.(type) {
+		case []any:
+			for range v {
+				select {
+				case <-done:
+					return
+				default:
+				}
+			}
+		}
+	}
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "walk.go", src, parser.ParseComments)
+	require.NoError(t, err)
+	metrics := analysis.ComputeReadabilityMetrics(file.Decls[0].(*ast.FuncDecl), fset)
+
+	// range, type switch, range and select bodies each nest one level.
+	assert.Equal(t, 4, metrics.NestingDepth)
+	assert.Equal(t, 2, metrics.NumLoops)
+	assert.Equal(t, 1, metrics.NumSwitches)
+	assert.Equal(t, 1, metrics.NumSelects)
+	assert.Equal(t, 5, metrics.CyclomaticPoints)
+}
+
+func TestComputeReadabilityMetrics_MatchesReference(t *testing.T) {
+	fset := token.NewFileSet()
+	var decls []*ast.FuncDecl
+	// The analysis package itself and the demo cover most statement kinds.
+	for _, pattern := range []string{"*.go", filepath.Join("..", "demo", "*.go")} {
+		paths, err := filepath.Glob(pattern)
+		require.NoError(t, err)
+		for _, path := range paths {
+			file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+			require.NoError(t, err)
+			for _, decl := range file.Decls {
+				if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil {
+					decls = append(decls, fn)
+				}
+			}
+		}
+	}
+	require.NotEmpty(t, decls)
+	for _, fn := range decls {
+		assert.Equal(t, referenceReadabilityMetrics(fn, fset), analysis.ComputeReadabilityMetrics(fn, fset), fn.Name.Name)
+	}
+}
+
+// syntheticFunction returns a function of n nested if/for/switch blocks with
+// statements between them.
+func syntheticFunction(tb testing.TB, n int) (*ast.FuncDecl, *token.FileSet) {
+	tb.Helper()
+	var src strings.Builder
+	src.WriteString("package p\n\n// big is generated.\nfunc big(x int) int {\n")
+	for i := range n {
+		switch i % 3 {
+		case 0:
+			fmt.Fprintf(&src, "if x > %d {\nx += %d\n", i, i)
+		case 1:
+			fmt.Fprintf(&src, "for j := 0; j < %d; j++ {\nx -= j\n", i)
+		default:
+			fmt.Fprintf(&src, "switch x %% %d {\ncase 0:\nx *= 2\n}\n{\n", i)
+		}
+	}
+	src.WriteString(strings.Repeat("}\n", n))
+	src.WriteString("return x\n}\n")
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "big.go", src.String(), parser.ParseComments)
+	require.NoError(tb, err)
+	return file.Decls[0].(*ast.FuncDecl), fset
+}
+
+func BenchmarkComputeReadabilityMetrics(b *testing.B) {
+	fn, fset := syntheticFunction(b, 300)
+	b.Run("single-pass", func(b *testing.B) {
+		for b.Loop() {
+			analysis.ComputeReadabilityMetrics(fn, fset)
+		}
+	})
+	b.Run("reference", func(b *testing.B) {
+		for b.Loop() {
+			referenceReadabilityMetrics(fn, fset)
+		}
+	})
+}
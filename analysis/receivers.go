@@ -0,0 +1,56 @@
+package analysis
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// DetectReceiverInconsistencies returns the types whose methods among
+// functions mix value and pointer receivers, with their methods of each kind
+// sorted. A type is located by its declaration among structs, or else by its
+// first method. Inconsistencies are ordered by location.
+func DetectReceiverInconsistencies(functions []surrealtypes.FunctionCall, structs []surrealtypes.StructDefinition) []surrealtypes.ReceiverInconsistency {
+	declared := make(map[string]string, len(structs))
+	for _, st := range structs {
+		declared[packageKey(st.File, st.Package)+"."+st.Name] = st.File
+	}
+
+	byType := make(map[string]*surrealtypes.ReceiverInconsistency)
+	for _, fn := range functions {
+		if !fn.IsMethod || fn.IsClosure || fn.Struct == "" {
+			continue
+		}
+		key := packageKey(fn.File, fn.Package) + "." + fn.Struct
+		ri, ok := byType[key]
+		if !ok {
+			ri = &surrealtypes.ReceiverInconsistency{Struct: fn.Struct, Package: fn.Package, File: declared[key]}
+			byType[key] = ri
+		}
+		if declared[key] == "" && (ri.File == "" || fn.File < ri.File) {
+			ri.File = fn.File
+		}
+		method := fn.Caller[strings.LastIndex(fn.Caller, ".")+1:]
+		if fn.PointerReceiver {
+			ri.PointerMethods = append(ri.PointerMethods, method)
+		} else {
+			ri.ValueMethods = append(ri.ValueMethods, method)
+		}
+	}
+
+	var inconsistencies []surrealtypes.ReceiverInconsistency
+	for _, ri := range byType {
+		if len(ri.ValueMethods) == 0 || len(ri.PointerMethods) == 0 {
+			continue
+		}
+		slices.Sort(ri.ValueMethods)
+		slices.Sort(ri.PointerMethods)
+		inconsistencies = append(inconsistencies, *ri)
+	}
+	slices.SortFunc(inconsistencies, func(a, b surrealtypes.ReceiverInconsistency) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Struct, b.Struct))
+	})
+	return inconsistencies
+}
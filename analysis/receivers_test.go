@@ -0,0 +1,50 @@
+package analysis_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectReceiverInconsistencies(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "counter.go"), []byte(`package counter
+
+type Counter struct{ n int }
+
+func (c Counter) Value() int { return c.n }
+
+func (c *Counter) Inc() { c.n++ }
+
+type Point struct{ X, Y int }
+
+func (p Point) Add(q Point) Point { return Point{p.X + q.X, p.Y + q.Y} }
+
+func (p Point) Scale(k int) Point { return Point{p.X * k, p.Y * k} }
+`), 0644))
+
+	report, err := analysis.Analyze(context.Background(), dir, analysis.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []types.ReceiverInconsistency{{
+		Struct:         "Counter",
+		Package:        "counter",
+		File:           filepath.Join(dir, "counter.go"),
+		ValueMethods:   []string{"Value"},
+		PointerMethods: []string{"Inc"},
+	}}, report.ReceiverInconsistencies)
+
+	smells := analysis.DetectSmells(report, analysis.DefaultSmellConfig())
+	require.Len(t, smells, 1)
+	assert.Equal(t, "Counter", smells[0].Struct)
+	assert.Equal(t, "Mixed receivers", smells[0].Kind)
+	assert.Equal(t, []string{"value receivers: Value", "pointer receivers: Inc"}, smells[0].Reasons)
+
+	summary := analysis.NewAnalyzerWithoutDB().GenerateCodeSummary(report)
+	assert.Equal(t, 1, summary.MixedReceivers)
+}
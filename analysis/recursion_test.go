@@ -0,0 +1,112 @@
+package analysis_test
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// referenceRecursion is the earlier recursive Tarjan implementation of
+// DetectRecursion, returning the recursive functions.
+func referenceRecursion(functions map[string]types.FunctionCall) map[string]bool {
+	type node struct {
+		index, lowlink int
+		onStack        bool
+	}
+	recursive := make(map[string]bool)
+	nodes := make(map[string]*node)
+	var stack []string
+	var tarjan func(name string)
+	tarjan = func(name string) {
+		n := &node{index: len(nodes), lowlink: len(nodes), onStack: true}
+		nodes[name] = n
+		stack = append(stack, name)
+		for _, callee := range functions[name].Callees {
+			if _, ok := functions[callee]; !ok {
+				continue
+			}
+			if callee == name {
+				recursive[name] = true
+			}
+			if m, found := nodes[callee]; !found {
+				tarjan(callee)
+				n.lowlink = min(n.lowlink, nodes[callee].lowlink)
+			} else if m.onStack {
+				n.lowlink = min(n.lowlink, m.index)
+			}
+		}
+		if n.lowlink == n.index {
+			var component []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				nodes[top].onStack = false
+				component = append(component, top)
+				if top == name {
+					break
+				}
+			}
+			for _, member := range component {
+				recursive[member] = recursive[member] || len(component) > 1
+			}
+		}
+	}
+	for name := range functions {
+		if _, found := nodes[name]; !found {
+			tarjan(name)
+		}
+	}
+	return recursive
+}
+
+func TestDetectRecursion_MatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := range 200 {
+		n := 1 + rng.IntN(12)
+		functions := make(map[string]types.FunctionCall, n)
+		for j := range n {
+			fn := types.FunctionCall{Caller: fmt.Sprintf("f%d", j)}
+			for range rng.IntN(4) {
+				fn.Callees = append(fn.Callees, fmt.Sprintf("f%d", rng.IntN(n+2)))
+			}
+			functions[fn.Caller] = fn
+		}
+		want := referenceRecursion(functions)
+
+		got := analysis.DetectRecursion(functions)
+		for name, fn := range got {
+			assert.Equal(t, want[name], fn.IsRecursive, "graph %d: %s in %v", i, name, functions)
+		}
+	}
+}
+
+func TestDetectRecursion_LongChain(t *testing.T) {
+	const n = 100_000
+	functions := make(map[string]types.FunctionCall, n)
+	for i := range n {
+		fn := types.FunctionCall{Caller: fmt.Sprintf("f%d", i)}
+		if i+1 < n {
+			fn.Callees = []string{fmt.Sprintf("f%d", i+1)}
+		}
+		functions[fn.Caller] = fn
+	}
+
+	result := analysis.DetectRecursion(functions)
+	require.Len(t, result, n)
+	for _, fn := range result {
+		require.False(t, fn.IsRecursive, fn.Caller)
+	}
+
+	// Closing the chain makes every function recursive.
+	last := functions[fmt.Sprintf("f%d", n-1)]
+	last.Callees = []string{"f0"}
+	functions[last.Caller] = last
+	for _, fn := range analysis.DetectRecursion(functions) {
+		require.True(t, fn.IsRecursive, fn.Caller)
+	}
+}
@@ -0,0 +1,149 @@
+package analysis
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Rules label functions and scope quality gates to labels, as read from a
+// rules file such as:
+//
+//	rules:
+//	  - name: ^Legacy
+//	    file: internal/old/*.go
+//	    labels: [legacy]
+//	  - complexity: {min: 15}
+//	    labels: [critical]
+//	gates:
+//	  - label: critical
+//	    max_complexity: 8
+//	resources:
+//	  - os.Open
+//	  - (*database/sql.DB).Query
+type Rules struct {
+	Rules []Rule               `yaml:"rules"`
+	Gates []surrealtypes.Gates `yaml:"gates"`
+	// Resources, if set, replaces DefaultResources as the functions whose
+	// results leak detection expects to be closed.
+	Resources []string `yaml:"resources"`
+}
+
+// Rule adds its labels to the functions matching all of its matchers. A
+// rule without matchers matches every function.
+type Rule struct {
+	// Name is a regular expression matched against the function's name,
+	// such as Parse or *Buffer.Write.
+	Name string `yaml:"name"`
+	// File is a path.Match pattern matched against the function's
+	// slash-separated file path and each of its trailing parts, so
+	// legacy/*.go matches every Go file in a legacy directory.
+	File string `yaml:"file"`
+	// Complexity bounds the cyclomatic complexity; zero bounds are unset.
+	Complexity struct {
+		Min int `yaml:"min"`
+		Max int `yaml:"max"`
+	} `yaml:"complexity"`
+	Labels []string `yaml:"labels"`
+
+	name *regexp.Regexp
+}
+
+// LoadRules reads and validates the rules file at path.
+func LoadRules(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules Rules
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i := range rules.Rules {
+		if err := rules.Rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %w", path, i+1, err)
+		}
+	}
+	return &rules, nil
+}
+
+// compile checks the rule and compiles its name pattern.
+func (r *Rule) compile() error {
+	if len(r.Labels) == 0 {
+		return fmt.Errorf("no labels")
+	}
+	if r.Name != "" {
+		re, err := regexp.Compile(r.Name)
+		if err != nil {
+			return fmt.Errorf("invalid name: %w", err)
+		}
+		r.name = re
+	}
+	if _, err := path.Match(r.File, ""); err != nil {
+		return fmt.Errorf("invalid file: %w", err)
+	}
+	return nil
+}
+
+// Match reports whether fn matches every matcher of the rule. A name that
+// is not a valid regular expression matches nothing.
+func (r *Rule) Match(fn surrealtypes.FunctionCall) bool {
+	if r.Name != "" {
+		if r.name == nil {
+			re, err := regexp.Compile(r.Name)
+			if err != nil {
+				return false
+			}
+			r.name = re
+		}
+		if !r.name.MatchString(fn.Caller) {
+			return false
+		}
+	}
+	if r.File != "" && !matchFileSuffix(r.File, fn.File) {
+		return false
+	}
+	complexity := fn.Metrics.CyclomaticComplexity
+	if (r.Complexity.Min > 0 && complexity < r.Complexity.Min) || (r.Complexity.Max > 0 && complexity > r.Complexity.Max) {
+		return false
+	}
+	return true
+}
+
+// matchFileSuffix reports whether pattern matches file, slash-separated, or
+// any of its trailing parts, such as b/c.go of a/b/c.go.
+func matchFileSuffix(pattern, file string) bool {
+	elems := strings.Split(filepath.ToSlash(file), "/")
+	for i := range elems {
+		if ok, _ := path.Match(pattern, strings.Join(elems[i:], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply sets the Labels of functions to the labels of the rules matching
+// them, in rule order without duplicates.
+func (rs *Rules) Apply(functions []surrealtypes.FunctionCall) {
+	for i := range functions {
+		var labels []string
+		for j := range rs.Rules {
+			if !rs.Rules[j].Match(functions[i]) {
+				continue
+			}
+			for _, label := range rs.Rules[j].Labels {
+				if !slices.Contains(labels, label) {
+					labels = append(labels, label)
+				}
+			}
+		}
+		functions[i].Labels = labels
+	}
+}
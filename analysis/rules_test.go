@@ -0,0 +1,56 @@
+package analysis_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`rules:
+  - name: ^Legacy
+    labels: [legacy]
+  - file: billing/*.go
+    complexity: {min: 5}
+    labels: [critical, legacy]
+gates:
+  - label: critical
+    max_complexity: 8
+`), 0644))
+	rules, err := analysis.LoadRules(path)
+	require.NoError(t, err)
+	assert.Equal(t, []types.Gates{{Label: "critical", MaxComplexity: 8}}, rules.Gates)
+
+	complexity := func(n int) types.FunctionMetrics {
+		return types.FunctionMetrics{CyclomaticComplexity: n}
+	}
+	functions := []types.FunctionCall{
+		{Caller: "LegacyParse", File: "/repo/parse.go", Metrics: complexity(2)},
+		{Caller: "parseLegacy", File: "/repo/parse.go", Metrics: complexity(2)},
+		{Caller: "Charge", File: "/repo/billing/charge.go", Metrics: complexity(9)},
+		{Caller: "Refund", File: "/repo/billing/refund.go", Metrics: complexity(3)},
+	}
+	rules.Apply(functions)
+	assert.Equal(t, []string{"legacy"}, functions[0].Labels)
+	assert.Empty(t, functions[1].Labels)
+	assert.Equal(t, []string{"critical", "legacy"}, functions[2].Labels)
+	assert.Empty(t, functions[3].Labels)
+
+	violations := types.AnalysisReport{Functions: functions}.CheckGates(rules.Gates[0])
+	require.Len(t, violations, 1)
+	assert.Equal(t, "Charge", violations[0].Function)
+	assert.Equal(t, "cyclomatic complexity 9 exceeds 8 (critical)", violations[0].Message)
+
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - name: \"(\"\n    labels: [x]\n"), 0644))
+	_, err = analysis.LoadRules(path)
+	assert.ErrorContains(t, err, "rule 1: invalid name")
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - name: x\n"), 0644))
+	_, err = analysis.LoadRules(path)
+	assert.ErrorContains(t, err, "no labels")
+}
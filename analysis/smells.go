@@ -0,0 +1,141 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// SmellConfig tunes design-smell detection. Each signal contributes its
+// weight times its value relative to its limit, capped at one, so a function
+// is only flagged when several signals are high at once.
+type SmellConfig struct {
+	LOCLimit     int // lines of code
+	FanOutLimit  int // distinct callees
+	PackageLimit int // distinct imported packages used
+	GlobalLimit  int // referenced globals
+
+	LOCWeight     float64
+	FanOutWeight  float64
+	PackageWeight float64
+	GlobalWeight  float64
+
+	// Threshold is the score at or above which a function is a God function.
+	Threshold float64
+
+	// StructFieldLimit is the number of fields above which a struct is a
+	// large struct.
+	StructFieldLimit int
+}
+
+// DefaultSmellConfig returns the thresholds used for hotspot issues.
+func DefaultSmellConfig() SmellConfig {
+	return SmellConfig{
+		LOCLimit:      60,
+		FanOutLimit:   10,
+		PackageLimit:  5,
+		GlobalLimit:   3,
+		LOCWeight:     1,
+		FanOutWeight:  1,
+		PackageWeight: 1,
+		GlobalWeight:  1,
+		Threshold:     3,
+
+		StructFieldLimit: 10,
+	}
+}
+
+// Smell is a function or struct showing a design smell.
+type Smell struct {
+	Function string   `json:"function,omitempty"`
+	Struct   string   `json:"struct,omitempty"`
+	File     string   `json:"file"`
+	Kind     string   `json:"kind"`
+	Score    float64  `json:"score"`
+	Reasons  []string `json:"reasons"`
+}
+
+// DetectSmells returns the functions in the report whose combined size,
+// fan-out, package and global usage mark them as God functions, the structs
+// with too many fields and the types mixing value and pointer receivers,
+// highest score first. A large struct scores its field count relative to the
+// limit, and mixed receivers score 1.
+func DetectSmells(report surrealtypes.AnalysisReport, cfg SmellConfig) []Smell {
+	var smells []Smell
+	for _, fn := range report.Functions {
+		if smell, ok := godFunction(fn, cfg); ok {
+			smells = append(smells, smell)
+		}
+	}
+	for _, st := range report.Structs {
+		if cfg.StructFieldLimit > 0 && st.FieldCount > cfg.StructFieldLimit {
+			smells = append(smells, Smell{
+				Struct:  st.Name,
+				File:    st.File,
+				Kind:    "Large struct",
+				Score:   float64(st.FieldCount) / float64(cfg.StructFieldLimit),
+				Reasons: []string{fmt.Sprintf("%d fields (limit %d)", st.FieldCount, cfg.StructFieldLimit)},
+			})
+		}
+	}
+	for _, ri := range report.ReceiverInconsistencies {
+		smells = append(smells, Smell{
+			Struct: ri.Struct,
+			File:   ri.File,
+			Kind:   "Mixed receivers",
+			Score:  1,
+			Reasons: []string{
+				"value receivers: " + strings.Join(ri.ValueMethods, ", "),
+				"pointer receivers: " + strings.Join(ri.PointerMethods, ", "),
+			},
+		})
+	}
+	sort.Slice(smells, func(i, j int) bool {
+		if smells[i].Score != smells[j].Score {
+			return smells[i].Score > smells[j].Score
+		}
+		if smells[i].Function != smells[j].Function {
+			return smells[i].Function < smells[j].Function
+		}
+		return smells[i].Struct < smells[j].Struct
+	})
+	return smells
+}
+
+// godFunction scores fn against cfg and reports whether it is a God function.
+func godFunction(fn surrealtypes.FunctionCall, cfg SmellConfig) (Smell, bool) {
+	smell := Smell{Function: fn.Caller, File: fn.File, Kind: "God function"}
+	signals := []struct {
+		name   string
+		value  int
+		limit  int
+		weight float64
+	}{
+		{"lines of code", fn.Metrics.LinesOfCode, cfg.LOCLimit, cfg.LOCWeight},
+		{"callees", len(distinct(fn.Callees)), cfg.FanOutLimit, cfg.FanOutWeight},
+		{"packages used", len(distinct(fn.Dependencies)), cfg.PackageLimit, cfg.PackageWeight},
+		{"globals referenced", len(distinct(fn.ReferencedGlobals)), cfg.GlobalLimit, cfg.GlobalWeight},
+	}
+	for _, s := range signals {
+		if s.limit <= 0 {
+			continue
+		}
+		smell.Score += s.weight * math.Min(float64(s.value)/float64(s.limit), 1)
+		if s.value >= s.limit {
+			smell.Reasons = append(smell.Reasons, fmt.Sprintf("%d %s (limit %d)", s.value, s.name, s.limit))
+		}
+	}
+	return smell, cfg.Threshold > 0 && smell.Score >= cfg.Threshold
+}
+
+// distinct returns the unique values of values.
+func distinct(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
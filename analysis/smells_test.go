@@ -0,0 +1,108 @@
+package analysis_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectSmells(t *testing.T) {
+	var callees []string
+	for i := 0; i < 15; i++ {
+		callees = append(callees, fmt.Sprintf("step%d", i))
+	}
+	god := types.FunctionCall{
+		Caller:            "handleEverything",
+		File:              "main.go",
+		Callees:           callees,
+		Dependencies:      []string{"fmt", "os", "net/http", "encoding/json", "database/sql", "os"},
+		ReferencedGlobals: []string{"config", "cache", "logger", "db"},
+		Metrics:           types.FunctionMetrics{LinesOfCode: 240},
+	}
+	long := types.FunctionCall{
+		Caller:  "generatedTable",
+		File:    "table.go",
+		Metrics: types.FunctionMetrics{LinesOfCode: 500},
+	}
+	small := types.FunctionCall{
+		Caller:       "add",
+		File:         "math.go",
+		Callees:      []string{"check"},
+		Dependencies: []string{"fmt"},
+		Metrics:      types.FunctionMetrics{LinesOfCode: 5},
+	}
+	report := types.AnalysisReport{Functions: []types.FunctionCall{small, long, god}}
+
+	smells := analysis.DetectSmells(report, analysis.DefaultSmellConfig())
+	require.Len(t, smells, 1)
+	assert.Equal(t, "handleEverything", smells[0].Function)
+	assert.Equal(t, "God function", smells[0].Kind)
+	assert.InDelta(t, 4.0, smells[0].Score, 1e-9)
+	assert.Len(t, smells[0].Reasons, 4)
+
+	// Weighting size heavily flags long functions on their own.
+	cfg := analysis.DefaultSmellConfig()
+	cfg.LOCWeight = 3
+	smells = analysis.DetectSmells(report, cfg)
+	require.Len(t, smells, 2)
+	assert.Equal(t, "handleEverything", smells[0].Function)
+	assert.Equal(t, "generatedTable", smells[1].Function)
+
+	summary := analysis.NewAnalyzerWithoutDB().GenerateCodeSummary(report)
+	var issues []string
+	for _, hotspot := range summary.Hotspots {
+		if hotspot.Name == "handleEverything" {
+			issues = hotspot.Issues
+		}
+	}
+	assert.Contains(t, issues, "God function")
+}
+
+func TestLargeStructs(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.go"), []byte(`package config
+
+type Config struct {
+	Host, Addr string
+	Port       int
+	User       string
+	Password   string
+	Database   string
+	Timeout    int64
+	Retries    int
+	Verbose    bool
+	Debug      bool
+	Labels     map[string]string
+	Tags       []string
+}
+
+type Point struct{ X, Y int32 }
+`), 0644))
+
+	report, err := analysis.Analyze(context.Background(), dir, analysis.Options{})
+	require.NoError(t, err)
+	structs := map[string]types.StructDefinition{}
+	for _, st := range report.Structs {
+		structs[st.Name] = st
+	}
+	assert.Equal(t, 12, structs["Config"].FieldCount)
+	assert.Equal(t, 2, structs["Point"].FieldCount)
+	assert.Equal(t, int64(8), structs["Point"].SizeBytes)
+
+	smells := analysis.DetectSmells(report, analysis.DefaultSmellConfig())
+	require.Len(t, smells, 1)
+	assert.Equal(t, "Config", smells[0].Struct)
+	assert.Equal(t, "Large struct", smells[0].Kind)
+	assert.Equal(t, []string{"12 fields (limit 10)"}, smells[0].Reasons)
+
+	cfg := analysis.DefaultSmellConfig()
+	cfg.StructFieldLimit = 12
+	assert.Empty(t, analysis.DetectSmells(report, cfg))
+}
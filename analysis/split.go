@@ -0,0 +1,74 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// PackageIndex is the index.json of WritePackageReports: the packages with
+// their coupling metrics and report files, and the calls between them.
+type PackageIndex struct {
+	Packages []PackageIndexEntry        `json:"packages"`
+	Calls    []surrealtypes.PackageCall `json:"calls"`
+}
+
+// PackageIndexEntry is a package of a PackageIndex.
+type PackageIndexEntry struct {
+	surrealtypes.PackageSummary
+	File string `json:"file"` // name of the package's report in the directory
+}
+
+// WritePackageReports writes the report to dir, which is created if needed,
+// split by package: one full JSON report per package, named after it, and
+// an index.json. Packages sharing a name, such as several main packages, are
+// numbered in directory order, as in main.json and main-2.json.
+func WritePackageReports(dir string, report surrealtypes.AnalysisReport) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	parts := report.SplitByPackage()
+	index := PackageIndex{
+		Packages: []PackageIndexEntry{},
+		Calls:    report.PackageCalls(),
+	}
+	if index.Calls == nil {
+		index.Calls = []surrealtypes.PackageCall{}
+	}
+	taken := map[string]int{"index": 1}
+	packages := make(map[string]surrealtypes.PackageSummary)
+	for _, ps := range report.Packages() {
+		packages[ps.Dir] = ps
+	}
+	for _, pkgDir := range slices.Sorted(maps.Keys(parts)) {
+		ps := packages[pkgDir]
+		name := ps.Package
+		if taken[name]++; taken[name] > 1 {
+			name += "-" + strconv.Itoa(taken[name])
+		}
+		entry := PackageIndexEntry{PackageSummary: ps, File: name + ".json"}
+		data, err := parts[pkgDir].FullReport()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, entry.File), append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("failed to write report of %s: %w", pkgDir, err)
+		}
+		index.Packages = append(index.Packages, entry)
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error generating index: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.json"), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
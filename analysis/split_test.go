@@ -0,0 +1,73 @@
+package analysis_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePackageReports(t *testing.T) {
+	report := types.AnalysisReport{
+		Functions: []types.FunctionCall{
+			{Caller: "main", File: "/repo/cmd/app/main.go", Package: "main", PackageDependencies: []types.PackageDependency{
+				{Caller: "main", ImportPath: "example.com/repo/lib", Symbol: "Parse"},
+				{Caller: "main", ImportPath: "fmt", Symbol: "Println"},
+			}},
+			{Caller: "Parse", File: "/repo/lib/parse.go", Package: "lib"},
+		},
+		Structs: []types.StructDefinition{{Name: "Token", File: "/repo/lib/token.go", Package: "lib"}},
+		Imports: []types.ImportDefinition{
+			{Path: "example.com/repo/lib", Name: "lib", File: "/repo/cmd/app/main.go", Package: "main"},
+			{Path: "fmt", Name: "fmt", File: "/repo/cmd/app/main.go", Package: "main"},
+		},
+	}
+
+	parts := report.SplitByPackage()
+	require.Len(t, parts, 2)
+	assert.Equal(t, []string{"main"}, callers(parts["/repo/cmd/app"]))
+	assert.Equal(t, []string{"Parse"}, callers(parts["/repo/lib"]))
+	assert.Len(t, parts["/repo/lib"].Structs, 1)
+	assert.Empty(t, parts["/repo/lib"].Imports)
+
+	dir := filepath.Join(t.TempDir(), "out")
+	require.NoError(t, analysis.WritePackageReports(dir, report))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"index.json", "lib.json", "main.json"}, names)
+
+	var lib types.AnalysisReport
+	data, err := os.ReadFile(filepath.Join(dir, "lib.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &lib))
+	assert.Equal(t, []string{"Parse"}, callers(lib))
+
+	var index analysis.PackageIndex
+	data, err = os.ReadFile(filepath.Join(dir, "index.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &index))
+	require.Len(t, index.Packages, 2)
+	assert.Equal(t, "/repo/cmd/app", index.Packages[0].Dir)
+	assert.Equal(t, "main.json", index.Packages[0].File)
+	assert.Equal(t, 1, index.Packages[1].Afferent)
+	assert.Equal(t, "lib.json", index.Packages[1].File)
+	// The call into the standard library is not between analyzed packages.
+	assert.Equal(t, []types.PackageCall{{From: "/repo/cmd/app", To: "/repo/lib", Caller: "main", Callee: "Parse"}}, index.Calls)
+}
+
+func callers(report types.AnalysisReport) []string {
+	var names []string
+	for _, fn := range report.Functions {
+		names = append(names, fn.Caller)
+	}
+	return names
+}
@@ -0,0 +1,164 @@
+package analysis
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// FunctionRef identifies a function in a stream summary.
+type FunctionRef = surrealtypes.FunctionRef
+
+// GlobalRef is a reference from a function to a global declared in another
+// file of the same package.
+type GlobalRef struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Global   string `json:"global"`
+}
+
+// StreamSummary is the trailing record of StreamNDJSON. It carries the
+// results that need every file, which the per-file records cannot include.
+type StreamSummary struct {
+	Files              int           `json:"files"`
+	RecursiveFunctions []FunctionRef `json:"recursive_functions"`
+	RecursionGroups    [][]string    `json:"recursion_groups"`
+	UnusedFunctions    []FunctionRef `json:"unused_functions"`
+	GlobalReferences   []GlobalRef   `json:"global_references"`
+}
+
+// StreamNDJSON analyzes dir file by file and writes one JSON object per line
+// to w as results are produced: every import, global, struct, interface,
+// other named type, implementation and function, tagged with a "kind"
+// field. Function records
+// only hold what a single file reveals; recursion, dead code and references
+// to globals in other files follow in a trailing "summary" record.
+func (a *Analyzer) StreamNDJSON(ctx context.Context, dir string, w io.Writer) error {
+	files, err := a.collectFiles([]string{dir})
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	functionMap := make(map[string]surrealtypes.FunctionCall)
+	unresolved := make(map[string][]string)
+	streamedGlobals := make(map[string]int)
+	var globals []surrealtypes.GlobalVariable
+
+	cache := newPackageCache(files)
+	for i, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		analysis, err := a.analyzeSource(f, cache)
+		if err != nil {
+			return err
+		}
+		a.progress(ProgressEvent{Stage: ProgressFileParsed, Path: f.path, Index: i + 1, Total: len(files)})
+
+		for _, imp := range analysis.Imports {
+			if err := writeRecord(bw, "import", imp); err != nil {
+				return err
+			}
+		}
+		for _, global := range analysis.Globals {
+			if err := writeRecord(bw, "global", global); err != nil {
+				return err
+			}
+		}
+		for _, st := range analysis.Structs {
+			st.Methods = methodNames(analysis.Functions, st.Package, st.Name)
+			if err := writeRecord(bw, "struct", st); err != nil {
+				return err
+			}
+		}
+		for _, iface := range analysis.Interfaces {
+			if err := writeRecord(bw, "interface", iface); err != nil {
+				return err
+			}
+		}
+		for _, typ := range analysis.Types {
+			typ.Methods = methodNames(analysis.Functions, typ.Package, typ.Name)
+			if err := writeRecord(bw, "type", typ); err != nil {
+				return err
+			}
+		}
+		for _, impl := range analysis.Implements {
+			if err := writeRecord(bw, "implements", impl); err != nil {
+				return err
+			}
+		}
+		for _, fn := range analysis.Functions {
+			if err := writeRecord(bw, "function", fn); err != nil {
+				return err
+			}
+			// Keep only what the summary needs.
+			key := packageKey(fn.File, fn.Package) + "." + fn.Caller
+			fn.Metrics = surrealtypes.FunctionMetrics{}
+			functionMap[key] = fn
+			unresolved[key] = analysis.unresolved[fn.Caller]
+			streamedGlobals[key] = len(fn.ReferencedGlobals)
+		}
+		globals = append(globals, analysis.Globals...)
+	}
+
+	functions, groups, _ := resolvePackages(functionMap, unresolved, globals, a.entryPoints())
+	summary := StreamSummary{
+		Files:              len(files),
+		RecursiveFunctions: []FunctionRef{},
+		RecursionGroups:    append([][]string{}, groups...),
+		UnusedFunctions:    []FunctionRef{},
+		GlobalReferences:   []GlobalRef{},
+	}
+	for _, fn := range functions {
+		ref := FunctionRef{Function: fn.Caller, File: fn.File}
+		if fn.IsRecursive {
+			summary.RecursiveFunctions = append(summary.RecursiveFunctions, ref)
+		}
+		if fn.Metrics.IsUnused {
+			summary.UnusedFunctions = append(summary.UnusedFunctions, ref)
+		}
+		key := packageKey(fn.File, fn.Package) + "." + fn.Caller
+		for _, global := range fn.ReferencedGlobals[streamedGlobals[key]:] {
+			summary.GlobalReferences = append(summary.GlobalReferences, GlobalRef{Function: fn.Caller, File: fn.File, Global: global})
+		}
+	}
+	compareRefs := func(x, y FunctionRef) int {
+		if x.File != y.File {
+			return cmp.Compare(x.File, y.File)
+		}
+		return cmp.Compare(x.Function, y.Function)
+	}
+	slices.SortFunc(summary.RecursiveFunctions, compareRefs)
+	slices.SortFunc(summary.UnusedFunctions, compareRefs)
+	slices.SortFunc(summary.GlobalReferences, func(x, y GlobalRef) int {
+		if c := compareRefs(FunctionRef{Function: x.Function, File: x.File}, FunctionRef{Function: y.Function, File: y.File}); c != 0 {
+			return c
+		}
+		return cmp.Compare(x.Global, y.Global)
+	})
+
+	if err := writeRecord(bw, "summary", summary); err != nil {
+		return err
+	}
+	a.progress(ProgressEvent{Stage: ProgressAnalyzed, Total: len(files), PackageHits: cache.hits, PackageMisses: cache.misses, ImportHits: cache.imports.hits, ImportMisses: cache.imports.misses})
+	return bw.Flush()
+}
+
+// writeRecord writes v as a single JSON line with a leading "kind" field.
+func writeRecord(w io.Writer, kind string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", kind, err)
+	}
+	if _, err := fmt.Fprintf(w, `{"kind":%q,%s`+"\n", kind, data[1:]); err != nil {
+		return fmt.Errorf("failed to write %s: %w", kind, err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package analysis_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzer_StreamNDJSON(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+		import "fmt"
+		type Person struct{ Name string }
+		func (p Person) Greet() { fmt.Println(greeting, p.Name) }
+		func main() {}
+		func unused() {}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "vars.go"), []byte(`package main
+		var greeting = "hello"`), 0644))
+
+	var buf bytes.Buffer
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	require.NoError(t, analyzer.StreamNDJSON(context.Background(), dir, &buf))
+
+	kinds := map[string]int{}
+	var last map[string]interface{}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record), scanner.Text())
+		kinds[record["kind"].(string)]++
+		last = record
+	}
+	assert.Equal(t, map[string]int{
+		"import":   1,
+		"global":   1,
+		"struct":   1,
+		"function": 3,
+		"summary":  1,
+	}, kinds)
+
+	require.Equal(t, "summary", last["kind"])
+	var summary analysis.StreamSummary
+	data, err := json.Marshal(last)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &summary))
+	assert.Equal(t, 2, summary.Files)
+	assert.Equal(t, []analysis.FunctionRef{{Function: "unused", File: filepath.Join(dir, "main.go")}}, summary.UnusedFunctions)
+	assert.Equal(t, []analysis.GlobalRef{{Function: "Person.Greet", File: filepath.Join(dir, "main.go"), Global: "greeting"}}, summary.GlobalReferences)
+}
@@ -0,0 +1,81 @@
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// TemplateData is what output templates are executed against.
+type TemplateData struct {
+	Report  surrealtypes.AnalysisReport
+	Summary surrealtypes.Summary
+	Code    surrealtypes.CodeSummary
+}
+
+// builtinTemplates are the templates LoadTemplate knows by name.
+var builtinTemplates = map[string]string{
+	"compact": `{{.Code.TotalFunctions}} functions, {{.Code.TotalLines}} lines, health {{printf "%.1f" .Summary.HealthScore}}
+complexity {{printf "%.2f" .Code.AvgComplexity}} avg, {{len .Code.Hotspots}} hotspots, {{.Code.UnusedFunctions}} unused, {{.Code.DuplicateCode}} duplicated
+`,
+	"detailed": `Health score: {{printf "%.1f" .Summary.HealthScore}}
+Functions: {{.Code.TotalFunctions}} ({{.Code.TotalLines}} lines)
+Average complexity: {{printf "%.2f" .Code.AvgComplexity}}
+Average maintainability: {{printf "%.2f" .Code.AvgMaintainability}}
+
+Functions:
+{{- range .Report.Functions}}
+  {{.Package}}.{{.Caller}} ({{.File}}): complexity {{.Metrics.CyclomaticComplexity}} [{{band .Metrics.CyclomaticComplexity}}], maintainability {{printf "%.1f" .Metrics.Maintainability}}
+{{- if .Metrics.IsUnused}}, unused{{end}}{{if .IsDuplicate}}, duplicated{{end}}
+{{- end}}
+{{- with .Code.Hotspots}}
+
+Hotspots:
+{{- range .}}
+  {{.Name}} ({{.File}}): {{join .Issues ", "}}
+{{- end}}
+{{- end}}
+`,
+}
+
+// TemplateFuncs returns the functions available to output templates, in
+// addition to the text/template builtins: band classifies a cyclomatic
+// complexity with thresholds, and join joins strings.
+func TemplateFuncs(thresholds ComplexityThresholds) template.FuncMap {
+	return template.FuncMap{
+		"printf": fmt.Sprintf,
+		"band":   thresholds.band,
+		"join":   strings.Join,
+	}
+}
+
+// LoadTemplate returns the built-in template called name, compact or
+// detailed, or else parses the template file at name.
+func LoadTemplate(name string, thresholds ComplexityThresholds) (*template.Template, error) {
+	text, ok := builtinTemplates[name]
+	if !ok {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		text, name = string(data), filepath.Base(name)
+	}
+	tmpl, err := template.New(name).Funcs(TemplateFuncs(thresholds)).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// WriteTemplate executes tmpl against data and writes the result to w.
+func WriteTemplate(w io.Writer, tmpl *template.Template, data TemplateData) error {
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	return nil
+}
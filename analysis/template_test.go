@@ -0,0 +1,49 @@
+package analysis_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTemplate(t *testing.T) {
+	report := types.AnalysisReport{Functions: []types.FunctionCall{
+		{Caller: "main", Package: "main", File: "main.go", Metrics: types.FunctionMetrics{CyclomaticComplexity: 2}},
+		{Caller: "parse", Package: "main", File: "parse.go", Metrics: types.FunctionMetrics{CyclomaticComplexity: 12}},
+	}}
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	data := analysis.TemplateData{
+		Report:  report,
+		Summary: report.BuildSummary(),
+		Code:    analyzer.GenerateCodeSummary(report),
+	}
+
+	path := filepath.Join(t.TempDir(), "count.tmpl")
+	require.NoError(t, os.WriteFile(path, []byte(`{{.Code.TotalFunctions}} functions{{range .Report.Functions}} {{.Caller}}:{{band .Metrics.CyclomaticComplexity}}{{end}}`), 0644))
+	tmpl, err := analysis.LoadTemplate(path, analysis.DefaultComplexityThresholds())
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, analysis.WriteTemplate(&buf, tmpl, data))
+	assert.Equal(t, "2 functions main:Low parse:High", buf.String())
+
+	builtins := map[string]string{
+		"compact":  "2 functions, 0 lines",
+		"detailed": "main.parse (parse.go): complexity 12 [High]",
+	}
+	for name, want := range builtins {
+		tmpl, err := analysis.LoadTemplate(name, analysis.DefaultComplexityThresholds())
+		require.NoError(t, err, name)
+		buf.Reset()
+		require.NoError(t, analysis.WriteTemplate(&buf, tmpl, data), name)
+		assert.Contains(t, buf.String(), want, name)
+	}
+
+	_, err = analysis.LoadTemplate(filepath.Join(t.TempDir(), "missing.tmpl"), analysis.DefaultComplexityThresholds())
+	assert.Error(t, err)
+}
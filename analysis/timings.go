@@ -0,0 +1,56 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	surrealtypes "github.com/TFMV/surrealcode/types"
+)
+
+// Timings records the wall time an analysis spent in each of its phases.
+type Timings struct {
+	Scan      time.Duration // finding the Go files
+	Parse     time.Duration // reading and parsing files
+	TypeCheck time.Duration // type checking files and the packages they import
+	Metrics   time.Duration // extracting declarations and metrics, and merging files
+	Store     time.Duration // storing the report; zero without a database
+}
+
+// Total returns the time spent in all phases.
+func (t Timings) Total() time.Duration {
+	return t.Scan + t.Parse + t.TypeCheck + t.Metrics + t.Store
+}
+
+// TimedAnalysis analyzes the Go files under the given directories like
+// AnalyzeDirectory, storing the report if the analyzer has a database, and
+// returns the report with the time spent in each phase.
+func (a *Analyzer) TimedAnalysis(ctx context.Context, dirs ...string) (surrealtypes.AnalysisReport, Timings, error) {
+	var timings Timings
+	a.timings = &timings
+	defer func() { a.timings = nil }()
+
+	start := time.Now()
+	files, err := a.collectFiles(dirs)
+	if err != nil {
+		return surrealtypes.AnalysisReport{}, timings, err
+	}
+	timings.Scan = time.Since(start)
+
+	start = time.Now()
+	report, err := a.analyzeFiles(ctx, dirs, files)
+	if err != nil {
+		return surrealtypes.AnalysisReport{}, timings, fmt.Errorf("failed to analyze directory: %w", err)
+	}
+	// Parsing and type checking are timed per file, within the analysis.
+	timings.Metrics = max(time.Since(start)-timings.Parse-timings.TypeCheck, 0)
+
+	if a.DB != nil {
+		start = time.Now()
+		if err := a.store(ctx, report); err != nil {
+			return report, timings, err
+		}
+		timings.Store = time.Since(start)
+	}
+	return report, timings, nil
+}
@@ -0,0 +1,60 @@
+package analysis_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzer_TimedAnalysis(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func main() {
+	fmt.Println(shout("hello"))
+}
+
+func shout(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s) + "!"
+}
+`), 0644))
+
+	mockDB := db.NewMockDB()
+	analyzer := analysis.NewAnalyzerWithDB(mockDB)
+	report, timings, err := analyzer.TimedAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Len(t, report.Functions, 2)
+	assert.Len(t, mockDB.Reports, 1)
+
+	for phase, d := range map[string]time.Duration{
+		"scan":       timings.Scan,
+		"parse":      timings.Parse,
+		"type check": timings.TypeCheck,
+		"metrics":    timings.Metrics,
+		"store":      timings.Store,
+	} {
+		assert.Positive(t, d, phase)
+	}
+	assert.Equal(t, timings.Scan+timings.Parse+timings.TypeCheck+timings.Metrics+timings.Store, timings.Total())
+
+	// Without a database nothing is stored.
+	_, again, err := analysis.NewAnalyzerWithoutDB().TimedAnalysis(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Zero(t, again.Store)
+	assert.Positive(t, again.Parse)
+}
@@ -1,72 +1,859 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/TFMV/surrealcode/analysis"
 	"github.com/TFMV/surrealcode/db"
+	"github.com/TFMV/surrealcode/schema"
+	"github.com/TFMV/surrealcode/server"
+	"github.com/TFMV/surrealcode/types"
 	"github.com/docopt/docopt-go"
 )
 
 const usage = `SurrealCode - Go Code Analysis Tool.
 
 Usage:
-  surrealcode analyze [options]
+  surrealcode analyze [options] [<path>...]
+  surrealcode prune [options]
+  surrealcode history [--since=<date>] [--step=<n>] [options]
+  surrealcode query (callers | callees) <func> [options]
+  surrealcode diff --base=<file> --head=<file> [--threshold=<n>]
+  surrealcode serve [--addr=<addr>] [--include-closures]
+  surrealcode schema --json
+  surrealcode version
   surrealcode -h | --help
   surrealcode --version
 
 Options:
   -h --help            Show this help message.
   --version            Show version.
-  --dir=<path>        Directory to scan for Go files [default: .].
+  --file=<path>       Print the metrics of a single file's functions without walking directories or storing them.
+  --stdin             Like --file, but read the Go source from stdin.
+  --filename=<name>   File name to report source read from stdin under [default: stdin.go].
+  --dir=<path>        Directory to scan for Go files when no paths are given, or to prune against [default: .].
+  --git-diff=<ref>    Analyze only the Go files changed since the current branch forked from this git ref, in the context of their packages.
+  --skip-nested-modules  Skip nested modules (directories with their own go.mod).
   --db=<url>          SurrealDB connection URL [default: ws://localhost:8000].
   --namespace=<ns>    SurrealDB namespace [default: test].
   --database=<db>     SurrealDB database [default: test].
   --db-user=<user>    SurrealDB username [default: root].
   --db-pass=<pass>    SurrealDB password [default: root].
+  --db-auth-level=<level>  Level the SurrealDB user signs in at: root, namespace or database [default: root].
+  --db-token=<token>  Authenticate to SurrealDB with a token instead of a username and password.
+  --db-scope=<scope>  Sign in to SurrealDB as a record user of this scope.
+  --connect-timeout=<d>  Give up connecting to SurrealDB after this long [default: 30s].
+  --max-retries=<n>   Connection attempts to retry while SurrealDB starts [default: 5].
+  --store-concurrency=<n>  Records written to SurrealDB at once [default: 4].
+  --backend=<name>    Storage backend: surreal, json or sqlite [default: surreal].
+  --out=<path>        Output file for the json and sqlite backends.
+  --replace           Delete all previously stored analysis before storing.
+  --store=<fields>    Fields stored in SurrealDB: graph for nodes and edges only, metrics to add function metrics, or full to add docs and positions [default: full].
+  --dry-run           Print how many records would be stored in SurrealDB, per table, without connecting to it; with history, only print the metrics.
+  --commit=<sha>      Also record the metrics as a snapshot of this commit in the SurrealDB history.
+  --include-closures  Report closures as separate functions.
+  --include-stdlib-calls  Store calls to functions of other packages, standard library or third-party, as edges to external function nodes.
+  --locals            Report unused and shadowed local variables; needs files that type check.
+  --codeowners=<file>  Attach the owners of each function's file from this CODEOWNERS file.
+  --rules=<file>      Label functions with the rules of this YAML file, and check its quality gates scoped to labels.
+  --separate-tests    Summarize test files separately from production code.
+  --strict            Fail on the first file that cannot be parsed instead of skipping it.
+  --timeout=<d>       Abort the analysis, and storing it, if it takes longer than this, such as 5m.
+  --partial-on-timeout  On --timeout, still write the report of the files analyzed in time, listing the others as skipped.
+  --verbose           Log analysis progress to stderr; the same as --log-level=info.
+  --log-level=<level>  Level of the messages logged to stderr: debug, info, warn or error [default: warn].
+  --timings           Print the time spent scanning, parsing, type checking, computing metrics and storing to stderr.
+  --cpuprofile=<file>  Write a CPU profile of the analysis to file.
+  --memprofile=<file>  Write a memory profile to file once the analysis completes.
+  --bundle=<file>     Also write a zip of the full report, call graph, Markdown report and hotspots to file.
+  --output-dir=<dir>  Also write the full report split by package to dir, one <package>.json each, with an index.json of the packages and the calls between them.
+  --template=<file>   Write the report with a Go text/template file, or the built-in compact or detailed template, instead of --format.
+  --format=<format>   Output format: summary, full for the complete report with all metrics and edges, md for a Markdown report, dot for the call graph, or ndjson to stream one record per line without storing. Several formats, comma-separated, need --out-prefix [default: summary].
+  --out-prefix=<prefix>  Write each --format to a file named after this prefix and the format, such as report.json and report.md, instead of stdout.
+  --top=<n>           Hotspots to report in md and template output; 0 reports all [default: 10].
+  --sort-by=<key>     Rank hotspots by complexity, maintainability, cognitive or loc [default: complexity].
+  --fail-on-complexity=<n>       Fail if a function's cyclomatic complexity exceeds n.
+  --fail-on-maintainability=<n>  Fail if a function's maintainability index is below n.
+  --fail-on-duplicate            Fail if any function is duplicated.
+  --fail-on-dead-code            Fail if any function is unused.
+  --base=<file>       Base analysis report (JSON) to diff against.
+  --head=<file>       Head analysis report (JSON) to diff.
+  --threshold=<n>     Maximum allowed complexity increase per function [default: 0].
+  --depth=<n>         Levels of callers or callees to print [default: 1].
+  --since=<date>      Sample the commits made since this date, in any format git log accepts.
+  --step=<n>          Analyze every n-th commit of the history, counting back from HEAD [default: 1].
+  --addr=<addr>       Address for the HTTP server to listen on [default: :8080].
+  --json              Print the JSON Schema of the JSON reports.
 `
 
-const version = "0.1.0"
+// version is the release of surrealcode, set at build time with
+// -ldflags "-X main.version=v1.2.3".
+var version = "0.1.0"
 
 func main() {
 	opts, err := docopt.ParseArgs(usage, os.Args[1:], version)
 	if err != nil {
 		log.Fatalf("Error parsing arguments: %v", err)
 	}
+	logger, err := newLogger(os.Stderr, opts)
+	if err != nil {
+		log.Fatalf("Invalid --log-level: %v", err)
+	}
 
 	if cmd, _ := opts.Bool("analyze"); cmd {
+		cpuProfile, _ := opts.String("--cpuprofile")
+		memProfile, _ := opts.String("--memprofile")
+		if err := startProfiles(cpuProfile, memProfile); err != nil {
+			log.Fatalf("Failed to start profiling: %v", err)
+		}
+		defer stopProfiles()
+
+		if file, _ := opts.String("--file"); file != "" {
+			analyzer := analysis.NewAnalyzerWithoutDB()
+			configureAnalyzer(analyzer, opts, logger)
+			format, _ := opts.String("--format")
+			if err := writeFileMetrics(os.Stdout, analyzer, file, format); err != nil {
+				fatalf("Failed to analyze file: %v", err)
+			}
+			return
+		}
+
+		if stdin, _ := opts.Bool("--stdin"); stdin {
+			analyzer := analysis.NewAnalyzerWithoutDB()
+			configureAnalyzer(analyzer, opts, logger)
+			filename, _ := opts.String("--filename")
+			format, _ := opts.String("--format")
+			if err := writeSourceMetrics(os.Stdout, analyzer, os.Stdin, filename, format); err != nil {
+				fatalf("Failed to analyze stdin: %v", err)
+			}
+			return
+		}
+
+		dirs, _ := opts["<path>"].([]string)
+		if len(dirs) == 0 {
+			dir, _ := opts.String("--dir")
+			dirs = []string{dir}
+		}
+		var changed []string
+		if base, _ := opts.String("--git-diff"); base != "" {
+			dir, _ := opts.String("--dir")
+			files, err := analysis.ChangedGoFiles(dir, base)
+			if err != nil {
+				fatalf("Failed to list changed files: %v", err)
+			}
+			if len(files) == 0 {
+				fmt.Fprintf(os.Stderr, "No Go files changed since %s\n", base)
+				return
+			}
+			changed = files
+		}
+		ctx, cancel, err := timeoutContext(opts)
+		if err != nil {
+			fatalf("Invalid --timeout: %v", err)
+		}
+		defer cancel()
+		switch format, _ := opts.String("--format"); format {
+		case "ndjson":
+			// Streamed results are written to stdout rather than stored.
+			if changed != nil {
+				fatalf("--git-diff is not supported with --format=ndjson")
+			}
+			analyzer := analysis.NewAnalyzerWithoutDB()
+			configureAnalyzer(analyzer, opts, logger)
+			for _, dir := range dirs {
+				if err := analyzer.StreamNDJSON(ctx, dir, os.Stdout); err != nil {
+					fatalf("Failed to analyze directory: %v", err)
+				}
+			}
+			return
+		default:
+			if _, err := new(analysis.Analyzer).Exporters(format); err != nil {
+				fatalf("Invalid --format: %v", err)
+			}
+			if prefix, _ := opts.String("--out-prefix"); strings.Contains(format, ",") && prefix == "" {
+				fatalf("--format=%s needs --out-prefix", format)
+			}
+		}
+		timed, _ := opts.Bool("--timings")
+		if timed && changed != nil {
+			fatalf("--git-diff is not supported with --timings")
+		}
+		if dryRun, _ := opts.Bool("--dry-run"); dryRun {
+			analyzer := analysis.NewAnalyzerWithoutDB()
+			configureAnalyzer(analyzer, opts, logger)
+			var report types.AnalysisReport
+			if changed != nil {
+				report, err = analyzer.GetChangedAnalysis(ctx, changed)
+			} else {
+				report, err = analyzer.GetAnalysis(ctx, dirs...)
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				timeout, _ := opts.String("--timeout")
+				if analyzer.Partial {
+					if err := writePlan(os.Stdout, new(db.SurrealDB).PlanStore(report)); err != nil {
+						fatalf("Failed to write partial plan: %v", err)
+					}
+				}
+				fatalf("Analysis exceeded --timeout=%s: %v", timeout, err)
+			}
+			if err != nil {
+				fatalf("Failed to analyze directory: %v", err)
+			}
+			if err := writePlan(os.Stdout, new(db.SurrealDB).PlanStore(report)); err != nil {
+				fatalf("Failed to write plan: %v", err)
+			}
+			return
+		}
+		config, err := dbConfig(opts)
+		if err != nil {
+			fatalf("Invalid database option: %v", err)
+		}
+		backend, _ := opts.String("--backend")
+		out, _ := opts.String("--out")
+		analyzer, err := newAnalyzer(backend, out, config)
+		if err != nil {
+			fatalf("Failed to create analyzer: %v", err)
+		}
+		defer analyzer.Close()
+		configureAnalyzer(analyzer, opts, logger)
+		if err := configureHotspots(analyzer, opts); err != nil {
+			fatalf("Invalid hotspot option: %v", err)
+		}
+
+		if err := analyzer.Initialize(ctx); err != nil {
+			fatalf("Failed to initialize analyzer: %v", err)
+		}
+
+		var timings analysis.Timings
+		switch {
+		case changed != nil:
+			err = analyzer.AnalyzeChangedFiles(ctx, changed)
+		case timed:
+			_, timings, err = analyzer.TimedAnalysis(ctx, dirs...)
+		default:
+			err = analyzer.AnalyzeDirectory(ctx, dirs...)
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			timeout, _ := opts.String("--timeout")
+			if analyzer.Partial {
+				if err := writeOutput(opts, analyzer); err != nil {
+					fatalf("Failed to write partial report: %v", err)
+				}
+			}
+			fatalf("Analysis exceeded --timeout=%s: %v", timeout, err)
+		}
+		if err != nil {
+			fatalf("Failed to analyze directory: %v", err)
+		}
+		if timed {
+			if err := writeTimings(os.Stderr, timings); err != nil {
+				fatalf("Failed to write timings: %v", err)
+			}
+		}
+		for _, fe := range analyzer.Report.FileErrors {
+			log.Printf("Skipped %s: %s", fe.Path, fe.Error)
+		}
+		if commit, _ := opts.String("--commit"); commit != "" {
+			store, ok := analyzer.DB.(db.SnapshotStore)
+			if !ok {
+				fatalf("--commit needs the surreal backend")
+			}
+			meta := db.SnapshotMeta{Commit: commit, Time: time.Now()}
+			if err := store.StoreSnapshot(ctx, analyzer.Report, meta); err != nil {
+				fatalf("Failed to store snapshot: %v", err)
+			}
+		}
+		if err := writeOutput(opts, analyzer); err != nil {
+			fatalf("Failed to write report: %v", err)
+		}
+		if path, _ := opts.String("--bundle"); path != "" {
+			if err := writeBundle(path, analyzer.Report); err != nil {
+				fatalf("Failed to write bundle: %v", err)
+			}
+		}
+		if dir, _ := opts.String("--output-dir"); dir != "" {
+			if err := analysis.WritePackageReports(dir, analyzer.Report); err != nil {
+				fatalf("Failed to write package reports: %v", err)
+			}
+		}
+
+		gates, err := parseGates(opts)
+		if err != nil {
+			fatalf("Invalid quality gate: %v", err)
+		}
+		violations := analyzer.Report.CheckGates(gates)
+		if analyzer.Rules != nil {
+			for _, g := range analyzer.Rules.Gates {
+				violations = append(violations, analyzer.Report.CheckGates(g)...)
+			}
+		}
+		if len(violations) > 0 {
+			fmt.Fprintf(os.Stderr, "%d quality gate violation(s):\n", len(violations))
+			for _, v := range violations {
+				fmt.Fprintf(os.Stderr, "  %s\n", v)
+			}
+			analyzer.Close()
+			stopProfiles()
+			os.Exit(1)
+		}
+	} else if cmd, _ := opts.Bool("prune"); cmd {
 		dir, _ := opts.String("--dir")
-		dbURL, _ := opts.String("--db")
-		namespace, _ := opts.String("--namespace")
-		database, _ := opts.String("--database")
-		dbUser, _ := opts.String("--db-user")
-		dbPass, _ := opts.String("--db-pass")
-
-		analyzer, err := analysis.NewAnalyzer(db.Config{
-			URL:       dbURL,
-			Namespace: namespace,
-			Database:  database,
-			Username:  dbUser,
-			Password:  dbPass,
-		})
+		config, err := dbConfig(opts)
+		if err != nil {
+			log.Fatalf("Invalid database option: %v", err)
+		}
+		backend, _ := opts.String("--backend")
+		out, _ := opts.String("--out")
+		analyzer, err := newAnalyzer(backend, out, config)
 		if err != nil {
 			log.Fatalf("Failed to create analyzer: %v", err)
 		}
+		defer analyzer.Close()
+		configureAnalyzer(analyzer, opts, logger)
 
 		if err := analyzer.Initialize(context.Background()); err != nil {
 			log.Fatalf("Failed to initialize analyzer: %v", err)
 		}
+		if err := analyzer.Prune(context.Background(), dir); err != nil {
+			log.Fatalf("Failed to prune: %v", err)
+		}
+	} else if cmd, _ := opts.Bool("history"); cmd {
+		dir, _ := opts.String("--dir")
+		since, _ := opts.String("--since")
+		step, err := opts.Int("--step")
+		if err != nil {
+			log.Fatalf("Invalid --step: %v", err)
+		}
+		commits, err := analysis.SampleCommits(dir, since, step)
+		if err != nil {
+			log.Fatalf("Failed to list commits: %v", err)
+		}
 
-		if err := analyzer.AnalyzeDirectory(context.Background(), dir); err != nil {
-			log.Fatalf("Failed to analyze directory: %v", err)
+		analyzer := analysis.NewAnalyzerWithoutDB()
+		var storeSnapshot func(analysis.Commit, types.AnalysisReport) error
+		if dryRun, _ := opts.Bool("--dry-run"); !dryRun {
+			config, err := dbConfig(opts)
+			if err != nil {
+				log.Fatalf("Invalid database option: %v", err)
+			}
+			backend, _ := opts.String("--backend")
+			out, _ := opts.String("--out")
+			if analyzer, err = newAnalyzer(backend, out, config); err != nil {
+				log.Fatalf("Failed to create analyzer: %v", err)
+			}
+			defer analyzer.Close()
+			store, ok := analyzer.DB.(db.SnapshotStore)
+			if !ok {
+				log.Fatalf("history needs the surreal backend")
+			}
+			if err := analyzer.Initialize(context.Background()); err != nil {
+				log.Fatalf("Failed to initialize analyzer: %v", err)
+			}
+			storeSnapshot = func(c analysis.Commit, report types.AnalysisReport) error {
+				return store.StoreSnapshot(context.Background(), report, db.SnapshotMeta{Commit: c.SHA, Time: c.Time})
+			}
+		}
+		configureAnalyzer(analyzer, opts, logger)
+
+		points, err := analyzer.AnalyzeHistory(context.Background(), dir, commits, storeSnapshot)
+		if err != nil {
+			log.Fatalf("Failed to analyze history: %v", err)
+		}
+		if err := analysis.WriteHistoryCSV(os.Stdout, points); err != nil {
+			log.Fatalf("Failed to write history: %v", err)
+		}
+	} else if cmd, _ := opts.Bool("query"); cmd {
+		function, _ := opts.String("<func>")
+		callers, _ := opts.Bool("callers")
+		depth, err := opts.Int("--depth")
+		if err != nil {
+			log.Fatalf("Invalid --depth: %v", err)
+		}
+		config, err := dbConfig(opts)
+		if err != nil {
+			log.Fatalf("Invalid database option: %v", err)
+		}
+		sdb, err := db.NewSurrealDB(config)
+		if err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		defer sdb.Close()
+		if err := sdb.Initialize(context.Background()); err != nil {
+			log.Fatalf("Failed to initialize database: %v", err)
+		}
+		if err := runQuery(context.Background(), os.Stdout, sdb, callers, function, depth); err != nil {
+			log.Fatalf("Query failed: %v", err)
+		}
+	} else if cmd, _ := opts.Bool("diff"); cmd {
+		basePath, _ := opts.String("--base")
+		headPath, _ := opts.String("--head")
+		threshold, err := opts.Int("--threshold")
+		if err != nil {
+			log.Fatalf("Invalid --threshold: %v", err)
+		}
+		if !runDiff(basePath, headPath, threshold) {
+			os.Exit(1)
+		}
+	} else if cmd, _ := opts.Bool("serve"); cmd {
+		addr, _ := opts.String("--addr")
+		analyzer := analysis.NewAnalyzerWithoutDB()
+		configureAnalyzer(analyzer, opts, logger)
+
+		log.Printf("Listening on %s", addr)
+		if err := http.ListenAndServe(addr, server.New(analyzer)); err != nil {
+			log.Fatalf("Server failed: %v", err)
+		}
+	} else if cmd, _ := opts.Bool("schema"); cmd {
+		if _, err := os.Stdout.Write(append(types.ReportJSONSchema(), '\n')); err != nil {
+			log.Fatalf("Failed to write schema: %v", err)
+		}
+	} else if cmd, _ := opts.Bool("version"); cmd {
+		info, _ := debug.ReadBuildInfo()
+		if err := writeVersion(os.Stdout, info); err != nil {
+			log.Fatalf("Failed to write version: %v", err)
 		}
-		// Pretty print the report
-		fmt.Print(analyzer.Report.PrettyPrint())
 	} else {
 		fmt.Print(usage)
 		os.Exit(1)
 	}
 }
+
+// writeVersion writes the version of surrealcode with the Go version,
+// platform and VCS state it was built with, from info if it is not nil, and
+// the version of the database schema, for bug reports.
+func writeVersion(w io.Writer, info *debug.BuildInfo) error {
+	goVersion, commit, modified := runtime.Version(), "unknown", "unknown"
+	if info != nil {
+		goVersion = info.GoVersion
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				commit = setting.Value
+			case "vcs.modified":
+				modified = setting.Value
+			}
+		}
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "version:\t%s\n", version)
+	fmt.Fprintf(tw, "go:\t%s\n", goVersion)
+	fmt.Fprintf(tw, "os/arch:\t%s/%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(tw, "commit:\t%s\n", commit)
+	fmt.Fprintf(tw, "dirty:\t%s\n", modified)
+	fmt.Fprintf(tw, "schema:\t%d\n", schema.Version)
+	return tw.Flush()
+}
+
+// writeOutput writes the analyzer's report as opts ask: with --template,
+// to the --out-prefix files in each --format, or to stdout.
+func writeOutput(opts docopt.Opts, analyzer *analysis.Analyzer) error {
+	format, _ := opts.String("--format")
+	if name, _ := opts.String("--template"); name != "" {
+		return writeTemplate(os.Stdout, analyzer, name)
+	}
+	if prefix, _ := opts.String("--out-prefix"); prefix != "" {
+		return writeExports(os.Stderr, analyzer, prefix, format)
+	}
+	return writeReport(os.Stdout, analyzer, format)
+}
+
+// writeReport writes the analyzer's report to w in the given format, one
+// of those of Analyzer.Exporter.
+func writeReport(w io.Writer, analyzer *analysis.Analyzer, format string) error {
+	exporter, err := analyzer.Exporter(format)
+	if err != nil {
+		return err
+	}
+	return exporter.Write(w, analyzer.Report)
+}
+
+// writeExports writes the analyzer's report in each of the comma-separated
+// formats to a file named after prefix, and lists the files written to w.
+func writeExports(w io.Writer, analyzer *analysis.Analyzer, prefix, formats string) error {
+	exporters, err := analyzer.Exporters(formats)
+	if err != nil {
+		return err
+	}
+	files, err := analysis.WriteExports(prefix, analyzer.Report, exporters)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		fmt.Fprintf(w, "Wrote %s\n", file)
+	}
+	return nil
+}
+
+// writeBundle writes the bundle of report to the file at path.
+func writeBundle(path string, report types.AnalysisReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := analysis.WriteBundle(f, report); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeFileMetrics analyzes a single file and writes the summaries of its
+// functions to w as JSON, or the functions with every metric in the full
+// format.
+func writeFileMetrics(w io.Writer, analyzer *analysis.Analyzer, path, format string) error {
+	fa, err := analyzer.AnalyzeFile(path)
+	if err != nil {
+		return err
+	}
+	return writeFunctionMetrics(w, fa.Functions, format)
+}
+
+// writeSourceMetrics reads Go source from r and writes the metrics of its
+// functions to w like writeFileMetrics, reporting them under filename.
+func writeSourceMetrics(w io.Writer, analyzer *analysis.Analyzer, r io.Reader, filename, format string) error {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read source: %w", err)
+	}
+	if len(bytes.TrimSpace(src)) == 0 {
+		return errors.New("no Go source to analyze")
+	}
+	fa, err := analyzer.AnalyzeSource(filename, src)
+	if err != nil {
+		return err
+	}
+	return writeFunctionMetrics(w, fa.Functions, format)
+}
+
+// writeFunctionMetrics writes the summaries of functions to w as JSON, or
+// the functions with every metric in the full format.
+func writeFunctionMetrics(w io.Writer, functions []types.FunctionCall, format string) error {
+	var v any
+	switch format {
+	case "summary", "json":
+		summaries := make([]types.FunctionSummary, len(functions))
+		for i, fn := range functions {
+			summaries[i] = fn.ToFunctionSummary()
+		}
+		v = summaries
+	case "full":
+		v = functions
+	default:
+		return fmt.Errorf("--format %s is not supported for a single file", format)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// writePlan writes the number of records plan would store per table to w.
+func writePlan(w io.Writer, plan db.StorePlan) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TABLE\tRECORDS")
+	for _, row := range []struct {
+		table string
+		count int
+	}{
+		{"functions", plan.Functions},
+		{"structs", plan.Structs},
+		{"types", plan.Types},
+		{"interfaces", plan.Interfaces},
+		{"globals", plan.Globals},
+		{"imports", plan.Imports},
+		{"calls", plan.Calls},
+		{"methods", plan.Methods},
+		{"implements", plan.Implements},
+		{"references", plan.References},
+		{"dependencies", plan.Dependencies},
+		{"uses_symbol", plan.SymbolUses},
+	} {
+		fmt.Fprintf(tw, "%s\t%d\n", row.table, row.count)
+	}
+	return tw.Flush()
+}
+
+// writeTimings writes the time spent in each phase of an analysis to w,
+// with its share of the total.
+func writeTimings(w io.Writer, timings analysis.Timings) error {
+	total := timings.Total()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PHASE\tTIME\tSHARE")
+	for _, row := range []struct {
+		phase string
+		d     time.Duration
+	}{
+		{"scan", timings.Scan},
+		{"parse", timings.Parse},
+		{"type check", timings.TypeCheck},
+		{"metrics", timings.Metrics},
+		{"store", timings.Store},
+		{"total", total},
+	} {
+		share := 0.0
+		if total > 0 {
+			share = 100 * float64(row.d) / float64(total)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.1f%%\n", row.phase, row.d.Round(time.Microsecond), share)
+	}
+	return tw.Flush()
+}
+
+// writeTemplate writes the analyzer's report to w with the named template.
+func writeTemplate(w io.Writer, analyzer *analysis.Analyzer, name string) error {
+	tmpl, err := analysis.LoadTemplate(name, analyzer.Metrics.Thresholds)
+	if err != nil {
+		return err
+	}
+	summary := analyzer.Report.BuildSummary()
+	if analyzer.SeparateTests {
+		summary = analyzer.Report.BuildSeparateSummary()
+	}
+	return analysis.WriteTemplate(w, tmpl, analysis.TemplateData{
+		Report:  analyzer.Report,
+		Summary: summary,
+		Code:    analyzer.GenerateCodeSummary(analyzer.Report),
+	})
+}
+
+// configureAnalyzer applies the analysis options to analyzer, which logs to
+// logger.
+func configureAnalyzer(analyzer *analysis.Analyzer, opts docopt.Opts, logger *slog.Logger) {
+	analyzer.IncludeClosures, _ = opts.Bool("--include-closures")
+	analyzer.IncludeExternalCalls, _ = opts.Bool("--include-stdlib-calls")
+	analyzer.SkipNestedModules, _ = opts.Bool("--skip-nested-modules")
+	analyzer.Strict, _ = opts.Bool("--strict")
+	analyzer.Partial, _ = opts.Bool("--partial-on-timeout")
+	analyzer.Locals, _ = opts.Bool("--locals")
+	analyzer.SeparateTests, _ = opts.Bool("--separate-tests")
+	analyzer.Logger = logger
+	if path, _ := opts.String("--codeowners"); path != "" {
+		codeowners, err := analysis.LoadCodeowners(path)
+		if err != nil {
+			fatalf("Failed to read CODEOWNERS: %v", err)
+		}
+		analyzer.Codeowners = codeowners
+	}
+	if path, _ := opts.String("--rules"); path != "" {
+		rules, err := analysis.LoadRules(path)
+		if err != nil {
+			fatalf("Failed to read rules: %v", err)
+		}
+		analyzer.Rules = rules
+	}
+}
+
+// newLogger returns a logger writing to w at the level of --log-level, or
+// at least Info with --verbose.
+func newLogger(w io.Writer, opts docopt.Opts) (*slog.Logger, error) {
+	name, err := opts.String("--log-level")
+	if err != nil {
+		name = "warn"
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return nil, err
+	}
+	if verbose, _ := opts.Bool("--verbose"); verbose {
+		level = min(level, slog.LevelInfo)
+	}
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level})), nil
+}
+
+// configureHotspots applies the --top and --sort-by options to analyzer.
+func configureHotspots(analyzer *analysis.Analyzer, opts docopt.Opts) error {
+	top, err := opts.Int("--top")
+	if err != nil || top < 0 {
+		return fmt.Errorf("--top: want a count, got %v", opts["--top"])
+	}
+	order, _ := opts.String("--sort-by")
+	switch order {
+	case analysis.HotspotsByComplexity, analysis.HotspotsByMaintainability, analysis.HotspotsByCognitive, analysis.HotspotsByLines:
+	default:
+		return fmt.Errorf("--sort-by: unknown ranking %q", order)
+	}
+	analyzer.Metrics.TopHotspots = top
+	analyzer.Metrics.HotspotOrder = order
+	return nil
+}
+
+// dbConfig builds the SurrealDB configuration from the database options.
+func dbConfig(opts docopt.Opts) (db.Config, error) {
+	dbURL, _ := opts.String("--db")
+	namespace, _ := opts.String("--namespace")
+	database, _ := opts.String("--database")
+	dbUser, _ := opts.String("--db-user")
+	dbPass, _ := opts.String("--db-pass")
+	dbAuthLevel, _ := opts.String("--db-auth-level")
+	dbToken, _ := opts.String("--db-token")
+	dbScope, _ := opts.String("--db-scope")
+	if dbToken != "" {
+		// The default root credentials do not apply to token auth.
+		dbUser, dbPass = "", ""
+	}
+	replace, _ := opts.Bool("--replace")
+	timeout, _ := opts.String("--connect-timeout")
+	connectTimeout, err := time.ParseDuration(timeout)
+	if err != nil {
+		return db.Config{}, fmt.Errorf("--connect-timeout: %w", err)
+	}
+	maxRetries, err := opts.Int("--max-retries")
+	if err != nil {
+		return db.Config{}, fmt.Errorf("--max-retries: %w", err)
+	}
+	concurrency, err := opts.Int("--store-concurrency")
+	if err != nil {
+		return db.Config{}, fmt.Errorf("--store-concurrency: %w", err)
+	}
+	fields, _ := opts.String("--store")
+
+	return db.Config{
+		URL:       dbURL,
+		Namespace: namespace,
+		Database:  database,
+		Username:  dbUser,
+		Password:  dbPass,
+		Replace:   replace,
+
+		AuthLevel: dbAuthLevel,
+		Token:     dbToken,
+		Scope:     dbScope,
+
+		ConnectTimeout: connectTimeout,
+		MaxRetries:     maxRetries,
+		Concurrency:    concurrency,
+		Fields:         fields,
+	}, nil
+}
+
+// timeoutContext returns the context of an analysis run, canceled after
+// --timeout if it is set.
+func timeoutContext(opts docopt.Opts) (context.Context, context.CancelFunc, error) {
+	timeout, _ := opts.String("--timeout")
+	if timeout == "" {
+		ctx, cancel := context.WithCancel(context.Background())
+		return ctx, cancel, nil
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d <= 0 {
+		return nil, nil, fmt.Errorf("%s is not positive", timeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	return ctx, cancel, nil
+}
+
+// newAnalyzer creates an analyzer storing its results in the named backend.
+func newAnalyzer(backend, out string, config db.Config) (*analysis.Analyzer, error) {
+	switch backend {
+	case "surreal":
+		return analysis.NewAnalyzer(config)
+	case "json":
+		if out == "" {
+			out = "surrealcode.json"
+		}
+		return analysis.NewAnalyzerWithDB(db.NewJSONFileDB(out)), nil
+	case "sqlite":
+		if out == "" {
+			out = "surrealcode.db"
+		}
+		store, err := db.NewSQLiteDB(out)
+		if err != nil {
+			return nil, err
+		}
+		return analysis.NewAnalyzerWithDB(store), nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q", backend)
+	}
+}
+
+// parseGates builds the quality gates from the --fail-on-* options.
+func parseGates(opts docopt.Opts) (types.Gates, error) {
+	var gates types.Gates
+	if v, err := opts.String("--fail-on-complexity"); err == nil {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return gates, fmt.Errorf("--fail-on-complexity: %w", err)
+		}
+		gates.MaxComplexity = n
+	}
+	if v, err := opts.String("--fail-on-maintainability"); err == nil {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return gates, fmt.Errorf("--fail-on-maintainability: %w", err)
+		}
+		gates.MinMaintainability = n
+	}
+	gates.FailOnDuplicate, _ = opts.Bool("--fail-on-duplicate")
+	gates.FailOnDeadCode, _ = opts.Bool("--fail-on-dead-code")
+	return gates, nil
+}
+
+// runDiff prints the differences between two reports and reports whether all
+// functions stayed within the complexity increase threshold.
+func runDiff(basePath, headPath string, threshold int) bool {
+	base, err := loadReport(basePath)
+	if err != nil {
+		log.Fatalf("Failed to load base report: %v", err)
+	}
+	head, err := loadReport(headPath)
+	if err != nil {
+		log.Fatalf("Failed to load head report: %v", err)
+	}
+
+	diff := analysis.DiffReports(base, head)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tFUNCTION\tFILE\tCOMPLEXITY\tMAINTAINABILITY\tLOC\tCOGNITIVE")
+	for _, fn := range diff.Added {
+		fmt.Fprintf(tw, "added\t%s\t%s\t%d\t%.1f\t%d\t%d\n", fn.Caller, fn.File,
+			fn.Metrics.CyclomaticComplexity, fn.Metrics.Maintainability,
+			fn.Metrics.LinesOfCode, fn.Metrics.CognitiveComplexity.Score)
+	}
+	for _, fn := range diff.Removed {
+		fmt.Fprintf(tw, "removed\t%s\t%s\t\t\t\t\n", fn.Caller, fn.File)
+	}
+	for _, d := range diff.Changed {
+		fmt.Fprintf(tw, "changed\t%s\t%s\t%+d\t%+.1f\t%+d\t%+d\n", d.Caller, d.File,
+			d.Complexity, d.Maintainability, d.LinesOfCode, d.Cognitive)
+	}
+	tw.Flush()
+
+	violations := diff.ComplexityIncreases(threshold)
+	for _, d := range violations {
+		fmt.Fprintf(os.Stderr, "complexity of %s (%s) increased by %d (threshold %d)\n",
+			d.Caller, d.File, d.Complexity, threshold)
+	}
+	return len(violations) == 0
+}
+
+// loadReport reads a JSON-encoded analysis report from path.
+func loadReport(path string) (types.AnalysisReport, error) {
+	var report types.AnalysisReport
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return report, err
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return report, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return report, nil
+}
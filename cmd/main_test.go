@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/schema"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/docopt/docopt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileMetrics(t *testing.T) {
+	// A temporary directory is outside any module.
+	path := filepath.Join(t.TempDir(), "foo.go")
+	require.NoError(t, os.WriteFile(path, []byte(`package foo
+
+func Abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+`), 0644))
+
+	var buf bytes.Buffer
+	require.NoError(t, writeFileMetrics(&buf, analysis.NewAnalyzerWithoutDB(), path, "summary"))
+	var summaries []types.FunctionSummary
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &summaries))
+	require.Len(t, summaries, 1)
+	assert.Equal(t, "Abs", summaries[0].Name)
+	assert.Equal(t, path, summaries[0].File)
+	assert.Equal(t, 2, summaries[0].Complexity)
+
+	assert.Error(t, writeFileMetrics(&buf, analysis.NewAnalyzerWithoutDB(), path, "md"))
+	assert.Error(t, writeFileMetrics(&buf, analysis.NewAnalyzerWithoutDB(), filepath.Join(t.TempDir(), "missing.go"), "summary"))
+}
+
+func TestWriteVersion(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeVersion(&buf, &debug.BuildInfo{
+		GoVersion: "go1.24.2",
+		Settings: []debug.BuildSetting{
+			{Key: "vcs", Value: "git"},
+			{Key: "vcs.revision", Value: "1e14b14"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}))
+	assert.Equal(t, "version: "+version+"\n"+
+		"go:      go1.24.2\n"+
+		"os/arch: "+runtime.GOOS+"/"+runtime.GOARCH+"\n"+
+		"commit:  1e14b14\n"+
+		"dirty:   true\n"+
+		"schema:  "+strconv.Itoa(schema.Version)+"\n", buf.String())
+
+	// Without build info, as in binaries built without module support, the
+	// VCS state is unknown.
+	buf.Reset()
+	require.NoError(t, writeVersion(&buf, nil))
+	assert.Contains(t, buf.String(), "go:      "+runtime.Version()+"\n")
+	assert.Contains(t, buf.String(), "commit:  unknown\n")
+	assert.Contains(t, buf.String(), "dirty:   unknown\n")
+
+	opts, err := docopt.ParseArgs(usage, []string{"version"}, version)
+	require.NoError(t, err)
+	cmd, _ := opts.Bool("version")
+	assert.True(t, cmd)
+}
+
+func TestConfigureHotspots(t *testing.T) {
+	hotspot := func(name string, maintainability float64) types.FunctionCall {
+		return types.FunctionCall{
+			Caller: name,
+			File:   "main.go",
+			Metrics: types.FunctionMetrics{
+				CyclomaticComplexity: 20 - int(maintainability/10),
+				Maintainability:      maintainability,
+			},
+		}
+	}
+	report := types.AnalysisReport{Functions: []types.FunctionCall{
+		hotspot("a", 40), hotspot("b", 10), hotspot("c", 30), hotspot("d", 20), hotspot("e", 45),
+	}}
+
+	opts, err := docopt.ParseArgs(usage, []string{"analyze", "--top=3", "--sort-by=maintainability"}, version)
+	require.NoError(t, err)
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	require.NoError(t, configureHotspots(analyzer, opts))
+	var names []string
+	for _, h := range analyzer.GenerateCodeSummary(report).Hotspots {
+		names = append(names, h.Name)
+	}
+	assert.Equal(t, []string{"b", "d", "c"}, names)
+
+	// By default, the ten most complex hotspots are reported.
+	opts, err = docopt.ParseArgs(usage, []string{"analyze"}, version)
+	require.NoError(t, err)
+	require.NoError(t, configureHotspots(analyzer, opts))
+	names = nil
+	for _, h := range analyzer.GenerateCodeSummary(report).Hotspots {
+		names = append(names, h.Name)
+	}
+	assert.Equal(t, []string{"b", "d", "c", "a", "e"}, names)
+
+	for _, args := range [][]string{{"analyze", "--top=-1"}, {"analyze", "--sort-by=name"}} {
+		opts, err := docopt.ParseArgs(usage, args, version)
+		require.NoError(t, err)
+		assert.Error(t, configureHotspots(analyzer, opts), args)
+	}
+}
+
+func TestWriteOutput(t *testing.T) {
+	analyzer := analysis.NewAnalyzerWithoutDB()
+	analyzer.Report = types.AnalysisReport{Functions: []types.FunctionCall{
+		{Caller: "main", Package: "main", File: "main.go", Callees: []string{"run"}},
+		{Caller: "run", Package: "main", File: "main.go"},
+	}}
+
+	// Several formats, or one, go to the --out-prefix files, as a partial
+	// report does on --timeout.
+	for _, format := range []string{"dot,json", "dot"} {
+		prefix := filepath.Join(t.TempDir(), "r")
+		opts, err := docopt.ParseArgs(usage, []string{"analyze", "--format=" + format, "--out-prefix=" + prefix}, version)
+		require.NoError(t, err)
+		require.NoError(t, writeOutput(opts, analyzer), format)
+		dot, err := os.ReadFile(prefix + ".dot")
+		require.NoError(t, err)
+		assert.Contains(t, string(dot), `"main.main" -> "main.run";`)
+		_, err = os.Stat(prefix + ".json")
+		assert.Equal(t, strings.Contains(format, "json"), err == nil, format)
+	}
+}
+
+func TestWriteSourceMetrics(t *testing.T) {
+	src := strings.NewReader(`package foo
+
+func Sign(x int) int {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	}
+	return 0
+}
+`)
+	var buf bytes.Buffer
+	require.NoError(t, writeSourceMetrics(&buf, analysis.NewAnalyzerWithoutDB(), src, "sign.go", "summary"))
+	var summaries []types.FunctionSummary
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &summaries))
+	require.Len(t, summaries, 1)
+	assert.Equal(t, "Sign", summaries[0].Name)
+	assert.Equal(t, "sign.go", summaries[0].File)
+	assert.Equal(t, 3, summaries[0].Complexity)
+
+	err := writeSourceMetrics(&buf, analysis.NewAnalyzerWithoutDB(), strings.NewReader(" \n"), "stdin.go", "summary")
+	assert.ErrorContains(t, err, "no Go source")
+	assert.Error(t, writeSourceMetrics(&buf, analysis.NewAnalyzerWithoutDB(), strings.NewReader("func {"), "stdin.go", "summary"))
+}
+
+// fakeGraph is a call graph given as callee lists.
+type fakeGraph map[string][]string
+
+func (g fakeGraph) HasFunction(_ context.Context, function string) (bool, error) {
+	_, ok := g[function]
+	return ok, nil
+}
+
+func (g fakeGraph) Callees(_ context.Context, function string) ([]string, error) {
+	return g[function], nil
+}
+
+func (g fakeGraph) Callers(_ context.Context, function string) ([]string, error) {
+	var callers []string
+	for caller, callees := range g {
+		if slices.Contains(callees, function) {
+			callers = append(callers, caller)
+		}
+	}
+	slices.Sort(callers)
+	return callers, nil
+}
+
+func TestRunQuery(t *testing.T) {
+	graph := fakeGraph{
+		"main":   {"run", "helper"},
+		"run":    {"parse", "helper"},
+		"parse":  {"parse"},
+		"helper": nil,
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, runQuery(context.Background(), &buf, graph, false, "main", 3))
+	assert.Equal(t, `main
+├── run
+│   ├── parse
+│   │   └── parse (recursive)
+│   └── helper
+└── helper
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, runQuery(context.Background(), &buf, graph, true, "helper", 1))
+	assert.Equal(t, "helper\n├── main\n└── run\n", buf.String())
+
+	err := runQuery(context.Background(), &buf, graph, true, "missing", 1)
+	assert.ErrorContains(t, err, "no function named missing is stored")
+}
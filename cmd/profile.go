@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+)
+
+// stopProfiles stops the profiles started by startProfiles and writes them.
+// It is safe to call more than once.
+var stopProfiles = func() {}
+
+// startProfiles starts CPU profiling to cpuPath and arranges for a heap
+// profile to be written to memPath when stopProfiles is called. Empty paths
+// are skipped.
+func startProfiles(cpuPath, memPath string) error {
+	var cpuFile *os.File
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		cpuFile = f
+	}
+	var once sync.Once
+	stopProfiles = func() {
+		once.Do(func() {
+			if cpuFile != nil {
+				pprof.StopCPUProfile()
+				if err := cpuFile.Close(); err != nil {
+					log.Printf("Failed to write CPU profile: %v", err)
+				}
+			}
+			if memPath != "" {
+				if err := writeHeapProfile(memPath); err != nil {
+					log.Printf("Failed to write memory profile: %v", err)
+				}
+			}
+		})
+	}
+	return nil
+}
+
+// writeHeapProfile writes a heap profile, up to date as of the last garbage
+// collection, to path.
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// fatalf writes any profiles before logging and exiting like log.Fatalf,
+// which skips deferred calls.
+func fatalf(format string, args ...any) {
+	stopProfiles()
+	log.Fatalf(format, args...)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// printCallTree writes root and, up to depth levels below it, the functions
+// next returns for each function as a tree. Functions already on the path
+// from root are marked recursive rather than expanded again.
+func printCallTree(w io.Writer, root string, depth int, next func(function string) ([]string, error)) error {
+	fmt.Fprintln(w, root)
+	var walk func(function, prefix string, level int, path []string) error
+	walk = func(function, prefix string, level int, path []string) error {
+		if level > depth {
+			return nil
+		}
+		names, err := next(function)
+		if err != nil {
+			return err
+		}
+		for i, name := range names {
+			branch, indent := "├── ", "│   "
+			if i == len(names)-1 {
+				branch, indent = "└── ", "    "
+			}
+			if slices.Contains(path, name) {
+				fmt.Fprintf(w, "%s%s%s (recursive)\n", prefix, branch, name)
+				continue
+			}
+			fmt.Fprintf(w, "%s%s%s\n", prefix, branch, name)
+			if err := walk(name, prefix+indent, level+1, append(path, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(root, "", 1, []string{root})
+}
+
+// callGraph is the part of the SurrealDB backend queried by runQuery.
+type callGraph interface {
+	HasFunction(ctx context.Context, function string) (bool, error)
+	Callers(ctx context.Context, function string) ([]string, error)
+	Callees(ctx context.Context, function string) ([]string, error)
+}
+
+// runQuery prints the callers or callees of function up to depth levels.
+func runQuery(ctx context.Context, w io.Writer, graph callGraph, callers bool, function string, depth int) error {
+	found, err := graph.HasFunction(ctx, function)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("no function named %s is stored; analyze the code first", function)
+	}
+	next := graph.Callees
+	if callers {
+		next = graph.Callers
+	}
+	return printCallTree(w, function, depth, func(function string) ([]string, error) {
+		return next(ctx, function)
+	})
+}
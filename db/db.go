@@ -10,3 +10,15 @@ type DB interface {
 	Initialize(ctx context.Context) error
 	StoreAnalysis(ctx context.Context, report types.AnalysisReport) error
 }
+
+// Pruner is implemented by databases that can delete the stored analysis of
+// files that no longer exist. Only files under roots are pruned.
+type Pruner interface {
+	Prune(ctx context.Context, roots, liveFiles []string) error
+}
+
+// SnapshotStore is implemented by databases that can keep the metrics of
+// each run as history.
+type SnapshotStore interface {
+	StoreSnapshot(ctx context.Context, report types.AnalysisReport, meta SnapshotMeta) error
+}
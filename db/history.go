@@ -0,0 +1,178 @@
+//go:build go1.24
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TFMV/surrealcode/types"
+	surrealdb "github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+// SnapshotMeta identifies a run recorded in the history.
+type SnapshotMeta struct {
+	Commit string    `json:"commit"` // commit hash the code was analyzed at, if known
+	Time   time.Time `json:"time"`
+}
+
+// key returns the record key of the run: its commit, or else its time.
+// Storing a run again replaces its snapshot.
+func (m SnapshotMeta) key() string {
+	if m.Commit != "" {
+		return escapeKey(m.Commit)
+	}
+	return escapeKey(m.Time.UTC().Format(time.RFC3339Nano))
+}
+
+// Snapshot holds the metrics of a function in one recorded run.
+type Snapshot struct {
+	Commit   string                `json:"commit"`
+	Time     time.Time             `json:"time"`
+	Function string                `json:"function"`
+	Package  string                `json:"package"`
+	File     string                `json:"file"`
+	Metrics  types.FunctionMetrics `json:"metrics"`
+}
+
+// StoreSnapshot records the report's aggregate metrics and the metrics of
+// each of its functions as a run in the history. Unlike StoreAnalysis, it
+// keeps the records of earlier runs.
+func (s *SurrealDB) StoreSnapshot(ctx context.Context, report types.AnalysisReport, meta SnapshotMeta) error {
+	if meta.Time.IsZero() {
+		meta.Time = time.Now()
+	}
+	stamp := &models.CustomDateTime{Time: meta.Time.UTC()}
+	snapshotID := models.NewRecordID("snapshots", meta.key())
+
+	var lines, complexity int
+	var maintainability float64
+	for _, fn := range report.Functions {
+		lines += fn.Metrics.LinesOfCode
+		complexity += fn.Metrics.CyclomaticComplexity
+		maintainability += fn.Metrics.Maintainability
+	}
+	snapshot := map[string]interface{}{
+		"commit":              meta.Commit,
+		"time":                stamp,
+		"functions":           len(report.Functions),
+		"lines_of_code":       lines,
+		"avg_complexity":      0.0,
+		"avg_maintainability": 0.0,
+		"health_score":        report.HealthScore(),
+	}
+	if n := len(report.Functions); n > 0 {
+		snapshot["avg_complexity"] = float64(complexity) / float64(n)
+		snapshot["avg_maintainability"] = maintainability / float64(n)
+	}
+	if err := upsertRecord(s.db, snapshotID, snapshot); err != nil {
+		return fmt.Errorf("error storing snapshot %s: %v", meta.key(), err)
+	}
+
+	ops := make([]func() error, len(report.Functions))
+	for i, fn := range report.Functions {
+		id := models.NewRecordID("history", RecordKey(packageScope(fn.File, fn.Package), fn.Caller)+"__"+meta.key())
+		record := map[string]interface{}{
+			"snapshot": snapshotID,
+			"commit":   meta.Commit,
+			"time":     stamp,
+			"function": fn.Caller,
+			"package":  fn.Package,
+			"file":     fn.File,
+			"metrics":  fn.Metrics,
+		}
+		ops[i] = func() error {
+			if err := upsertRecord(s.db, id, record); err != nil {
+				return fmt.Errorf("error storing history of function %s: %v", fn.Caller, err)
+			}
+			return nil
+		}
+	}
+	return runConcurrently(ctx, s.config.Concurrency, ops)
+}
+
+// HistoryFor returns the recorded snapshots of function, given as
+// package.name or dir:package.name, oldest first. Without a directory, the
+// package must have been recorded in only one directory, as for
+// HasFunction.
+func (s *SurrealDB) HistoryFor(ctx context.Context, function string) ([]Snapshot, error) {
+	vars, err := functionVars(function)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := querySnapshots(s.db, "SELECT commit, time, function, package, file, metrics FROM history WHERE package = $package AND function = $name", vars)
+	if err != nil {
+		return nil, fmt.Errorf("error querying history of %s: %v", function, err)
+	}
+	dir := vars["dir"].(string)
+	var scopes []string
+	snapshots = slices.DeleteFunc(snapshots, func(snapshot Snapshot) bool {
+		if dir != "" && filepath.Dir(snapshot.File) != dir {
+			return true
+		}
+		if scope := packageScope(snapshot.File, snapshot.Package); !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+		return false
+	})
+	if len(scopes) > 1 {
+		slices.Sort(scopes)
+		for i, scope := range scopes {
+			scopes[i] = scope + "." + vars["name"].(string)
+		}
+		return nil, fmt.Errorf("function %s is recorded in several directories; qualify it as one of %s", function, strings.Join(scopes, ", "))
+	}
+	slices.SortStableFunc(snapshots, func(a, b Snapshot) int {
+		if c := a.Time.Compare(b.Time); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Commit, b.Commit)
+	})
+	return snapshots, nil
+}
+
+// querySnapshots runs a query selecting history records; tests replace it
+// with a fake.
+var querySnapshots = func(db *surrealdb.DB, query string, vars map[string]interface{}) ([]Snapshot, error) {
+	results, err := surrealdb.Query[[]historyRecord](db, query, vars)
+	if err != nil {
+		return nil, err
+	}
+	if err := queryError(results); err != nil {
+		return nil, err
+	}
+	var snapshots []Snapshot
+	for _, result := range *results {
+		for _, record := range result.Result {
+			snapshots = append(snapshots, record.snapshot())
+		}
+	}
+	return snapshots, nil
+}
+
+// historyRecord is a history record as SurrealDB returns it, with its time
+// as a datetime.
+type historyRecord struct {
+	Commit   string                `json:"commit"`
+	Time     models.CustomDateTime `json:"time"`
+	Function string                `json:"function"`
+	Package  string                `json:"package"`
+	File     string                `json:"file"`
+	Metrics  types.FunctionMetrics `json:"metrics"`
+}
+
+func (r historyRecord) snapshot() Snapshot {
+	return Snapshot{
+		Commit:   r.Commit,
+		Time:     r.Time.Time,
+		Function: r.Function,
+		Package:  r.Package,
+		File:     r.File,
+		Metrics:  r.Metrics,
+	}
+}
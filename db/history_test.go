@@ -0,0 +1,119 @@
+//go:build go1.24
+
+package db
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	surrealdb "github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+func TestSurrealDB_StoreSnapshot(t *testing.T) {
+	var mu sync.Mutex
+	records := map[models.RecordID]map[string]interface{}{}
+	origUpsert, origQuery := upsertRecord, querySnapshots
+	upsertRecord = func(_ *surrealdb.DB, id models.RecordID, data interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		records[id] = data.(map[string]interface{})
+		return nil
+	}
+	querySnapshots = func(_ *surrealdb.DB, _ string, vars map[string]interface{}) ([]Snapshot, error) {
+		var snapshots []Snapshot
+		for id, record := range records {
+			if id.Table == "history" && record["package"] == vars["package"] && record["function"] == vars["name"] {
+				snapshots = append(snapshots, Snapshot{
+					Commit:   record["commit"].(string),
+					Time:     record["time"].(*models.CustomDateTime).Time,
+					Function: record["function"].(string),
+					Package:  record["package"].(string),
+					File:     record["file"].(string),
+					Metrics:  record["metrics"].(types.FunctionMetrics),
+				})
+			}
+		}
+		return snapshots, nil
+	}
+	t.Cleanup(func() { upsertRecord, querySnapshots = origUpsert, origQuery })
+
+	sdb := &SurrealDB{}
+	report := func(complexity int) types.AnalysisReport {
+		return types.AnalysisReport{Functions: []types.FunctionCall{
+			{Caller: "parse", Package: "main", File: "parse.go", Metrics: types.FunctionMetrics{CyclomaticComplexity: complexity}},
+			{Caller: "main", Package: "main", File: "main.go", Metrics: types.FunctionMetrics{CyclomaticComplexity: 1}},
+			{Caller: "parse", Package: "config", File: "config/parse.go", Metrics: types.FunctionMetrics{CyclomaticComplexity: 20}},
+		}}
+	}
+	day := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	// Stored out of order to check that history is sorted by time.
+	require.NoError(t, sdb.StoreSnapshot(context.Background(), report(7), SnapshotMeta{Commit: "bbb222", Time: day.Add(24 * time.Hour)}))
+	require.NoError(t, sdb.StoreSnapshot(context.Background(), report(4), SnapshotMeta{Commit: "aaa111", Time: day}))
+
+	history, err := sdb.HistoryFor(context.Background(), "main.parse")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "aaa111", history[0].Commit)
+	assert.True(t, day.Equal(history[0].Time))
+	assert.Equal(t, 4, history[0].Metrics.CyclomaticComplexity)
+	assert.Equal(t, "bbb222", history[1].Commit)
+	assert.Equal(t, 7, history[1].Metrics.CyclomaticComplexity)
+
+	snapshot := records[models.NewRecordID("snapshots", "aaa111")]
+	require.NotNil(t, snapshot)
+	assert.Equal(t, 3, snapshot["functions"])
+	assert.InDelta(t, 25.0/3, snapshot["avg_complexity"], 1e-9)
+
+	// Recording a commit again replaces its snapshot.
+	require.NoError(t, sdb.StoreSnapshot(context.Background(), report(5), SnapshotMeta{Commit: "aaa111", Time: day}))
+	history, err = sdb.HistoryFor(context.Background(), "main.parse")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, 5, history[0].Metrics.CyclomaticComplexity)
+
+	// A main package in another directory makes main.parse ambiguous.
+	tool := types.AnalysisReport{Functions: []types.FunctionCall{
+		{Caller: "parse", Package: "main", File: "cmd/tool/parse.go", Metrics: types.FunctionMetrics{CyclomaticComplexity: 9}},
+	}}
+	require.NoError(t, sdb.StoreSnapshot(context.Background(), tool, SnapshotMeta{Commit: "ccc333", Time: day.Add(48 * time.Hour)}))
+	_, err = sdb.HistoryFor(context.Background(), "main.parse")
+	assert.EqualError(t, err, "function main.parse is recorded in several directories; qualify it as one of .:main.parse, cmd/tool:main.parse")
+	history, err = sdb.HistoryFor(context.Background(), "cmd/tool:main.parse")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, 9, history[0].Metrics.CyclomaticComplexity)
+	history, err = sdb.HistoryFor(context.Background(), ".:main.parse")
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+}
+
+func TestHistoryRecord_DecodesDatetime(t *testing.T) {
+	// SurrealDB returns datetimes as CBOR tag 12, which time.Time does not
+	// decode from.
+	stamp := time.Date(2026, 10, 1, 12, 30, 0, 500, time.UTC)
+	data, err := models.CborMarshaler{}.Marshal(map[string]interface{}{
+		"commit":   "aaa111",
+		"time":     &models.CustomDateTime{Time: stamp},
+		"function": "parse",
+		"package":  "main",
+		"file":     "parse.go",
+		"metrics":  map[string]interface{}{"cyclomatic_complexity": 4},
+	})
+	require.NoError(t, err)
+
+	var record historyRecord
+	require.NoError(t, models.CborUnmarshaler{}.Unmarshal(data, &record))
+	snapshot := record.snapshot()
+	assert.True(t, stamp.Equal(snapshot.Time), "time %v", snapshot.Time)
+	assert.Equal(t, "main", snapshot.Package)
+	assert.Equal(t, 4, snapshot.Metrics.CyclomaticComplexity)
+
+	var plain Snapshot
+	assert.Error(t, models.CborUnmarshaler{}.Unmarshal(data, &plain))
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/TFMV/surrealcode/types"
+)
+
+// JSONFileDB persists analysis reports as an indented JSON file.
+type JSONFileDB struct {
+	Path string
+}
+
+func NewJSONFileDB(path string) *JSONFileDB {
+	return &JSONFileDB{Path: path}
+}
+
+// Initialize makes sure the directory holding the report file exists.
+func (j *JSONFileDB) Initialize(ctx context.Context) error {
+	if err := os.MkdirAll(filepath.Dir(j.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", j.Path, err)
+	}
+	return nil
+}
+
+// StoreAnalysis writes the report to the file, replacing any previous report.
+func (j *JSONFileDB) StoreAnalysis(ctx context.Context, report types.AnalysisReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	if err := os.WriteFile(j.Path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write report to %s: %w", j.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package db_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/surrealcode/db"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONFileDB_StoreAnalysis(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "report.json")
+	store := db.NewJSONFileDB(path)
+	require.NoError(t, store.Initialize(context.Background()))
+
+	report := types.AnalysisReport{
+		Functions: []types.FunctionCall{{Caller: "main", Package: "main", Callees: []string{"helper"}}},
+		Structs:   []types.StructDefinition{{Name: "Person", Package: "main"}},
+	}
+	require.NoError(t, store.StoreAnalysis(context.Background(), report))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var got types.AnalysisReport
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, report.Functions[0].Callees, got.Functions[0].Callees)
+	assert.Equal(t, "Person", got.Structs[0].Name)
+}
@@ -2,13 +2,30 @@ package db
 
 import (
 	"context"
+	"sync"
 
 	"github.com/TFMV/surrealcode/types"
 )
 
+// MockDB is an in-memory DB for tests. It records every report passed to
+// StoreAnalysis before delegating to StoreAnalysisFunc, if set.
 type MockDB struct {
 	InitializeFunc    func(ctx context.Context) error
 	StoreAnalysisFunc func(ctx context.Context, report types.AnalysisReport) error
+	CloseFunc         func() error
+	PruneFunc         func(ctx context.Context, roots, liveFiles []string) error
+
+	// LastReport is the most recently stored report and Reports holds every
+	// stored report in order.
+	LastReport types.AnalysisReport
+	Reports    []types.AnalysisReport
+
+	// PrunedRoots and PrunedFiles are the roots and live file list of the
+	// most recent Prune call.
+	PrunedRoots []string
+	PrunedFiles []string
+
+	mu sync.Mutex
 }
 
 func NewMockDB() *MockDB {
@@ -24,8 +41,53 @@ func (m *MockDB) Initialize(ctx context.Context) error {
 }
 
 func (m *MockDB) StoreAnalysis(ctx context.Context, report types.AnalysisReport) error {
+	m.mu.Lock()
+	m.LastReport = report
+	m.Reports = append(m.Reports, report)
+	m.mu.Unlock()
+
 	if m.StoreAnalysisFunc != nil {
 		return m.StoreAnalysisFunc(ctx, report)
 	}
 	return nil
 }
+
+func (m *MockDB) Prune(ctx context.Context, roots, liveFiles []string) error {
+	m.mu.Lock()
+	m.PrunedRoots = roots
+	m.PrunedFiles = liveFiles
+	m.mu.Unlock()
+
+	if m.PruneFunc != nil {
+		return m.PruneFunc(ctx, roots, liveFiles)
+	}
+	return nil
+}
+
+func (m *MockDB) Close() error {
+	if m.CloseFunc != nil {
+		return m.CloseFunc()
+	}
+	return nil
+}
+
+// StoredFunctions returns the functions of the most recently stored report.
+func (m *MockDB) StoredFunctions() []types.FunctionCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.LastReport.Functions
+}
+
+// StoredStructs returns the structs of the most recently stored report.
+func (m *MockDB) StoredStructs() []types.StructDefinition {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.LastReport.Structs
+}
+
+// StoreCount returns how many reports have been stored.
+func (m *MockDB) StoreCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.Reports)
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/TFMV/surrealcode/schema"
+	"github.com/TFMV/surrealcode/types"
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteDB persists analysis reports into a SQLite database file.
+type SQLiteDB struct {
+	db *sql.DB
+}
+
+func NewSQLiteDB(path string) (*SQLiteDB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	return &SQLiteDB{db: db}, nil
+}
+
+// Initialize creates the functions, calls and structs tables.
+func (s *SQLiteDB) Initialize(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, schema.SQLiteSchema); err != nil {
+		return fmt.Errorf("failed to initialize sqlite schema: %w", err)
+	}
+	return nil
+}
+
+// StoreAnalysis replaces the stored analysis with report in a single
+// transaction.
+func (s *SQLiteDB) StoreAnalysis(ctx context.Context, report types.AnalysisReport) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Replace the previous analysis, so storing again does not duplicate rows.
+	for _, table := range []string{"functions", "calls", "structs"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+			return fmt.Errorf("error clearing %s: %v", table, err)
+		}
+	}
+
+	// Store functions
+	for _, fn := range report.Functions {
+		metrics, err := json.Marshal(fn.Metrics)
+		if err != nil {
+			return fmt.Errorf("error encoding metrics for %s: %v", fn.Caller, err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO functions (caller, file, package, is_method, struct, is_recursive,
+				cyclomatic_complexity, lines_of_code, maintainability, metrics)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			fn.Caller, fn.File, fn.Package, fn.IsMethod, fn.Struct, fn.IsRecursive,
+			fn.Metrics.CyclomaticComplexity, fn.Metrics.LinesOfCode, fn.Metrics.Maintainability,
+			string(metrics)); err != nil {
+			return fmt.Errorf("error storing function %s: %v", fn.Caller, err)
+		}
+	}
+
+	// Store function calls
+	for _, fn := range report.Functions {
+		for _, callee := range fn.Callees {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO calls (caller, callee, file, package) VALUES (?, ?, ?, ?)`,
+				fn.Caller, callee, fn.File, fn.Package); err != nil {
+				return fmt.Errorf("error storing call from %s to %s: %v", fn.Caller, callee, err)
+			}
+		}
+	}
+
+	// Store structs
+	for _, st := range report.Structs {
+		methods, err := json.Marshal(st.Methods)
+		if err != nil {
+			return fmt.Errorf("error encoding methods for struct %s: %v", st.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO structs (name, file, package, methods) VALUES (?, ?, ?, ?)`,
+			st.Name, st.File, st.Package, string(methods)); err != nil {
+			return fmt.Errorf("error storing struct %s: %v", st.Name, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Close closes the underlying database handle.
+func (s *SQLiteDB) Close() error {
+	return s.db.Close()
+}
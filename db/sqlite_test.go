@@ -0,0 +1,40 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/surrealcode/db"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteDB_StoreAnalysisTwice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.db")
+	store, err := db.NewSQLiteDB(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	require.NoError(t, store.Initialize(context.Background()))
+
+	report := types.AnalysisReport{
+		Functions: []types.FunctionCall{
+			{Caller: "main", Package: "main", Callees: []string{"helper"}},
+			{Caller: "helper", Package: "main"},
+		},
+		Structs: []types.StructDefinition{{Name: "Person", Package: "main"}},
+	}
+	require.NoError(t, store.StoreAnalysis(context.Background(), report))
+	require.NoError(t, store.StoreAnalysis(context.Background(), report))
+
+	conn, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer conn.Close()
+	for table, want := range map[string]int{"functions": 2, "calls": 1, "structs": 1} {
+		var got int
+		require.NoError(t, conn.QueryRow("SELECT COUNT(*) FROM "+table).Scan(&got))
+		assert.Equal(t, want, got, table)
+	}
+}
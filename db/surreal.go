@@ -3,13 +3,20 @@
 package db
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/TFMV/surrealcode/types"
 	surrealdb "github.com/surrealdb/surrealdb.go"
 	"github.com/surrealdb/surrealdb.go/pkg/models"
+	"golang.org/x/sync/errgroup"
 )
 
 type Config struct {
@@ -18,15 +25,69 @@ type Config struct {
 	Database  string
 	Username  string
 	Password  string
+	Replace   bool // delete all stored analysis before storing a new one
+
+	// Exactly one authentication mode must be configured: Username and
+	// Password for a system user signing in at AuthLevel ("root", the
+	// default, "namespace" or "database"), Token to authenticate with an
+	// existing token, or Scope to sign in as a record user of that scope.
+	AuthLevel string
+	Token     string
+	Scope     string
+
+	// ConnectTimeout bounds the total time spent retrying the connection
+	// and sign-in; zero means no bound. MaxRetries is the number of retries
+	// after the first attempt; zero disables retrying.
+	ConnectTimeout time.Duration
+	MaxRetries     int
+
+	// Concurrency is the number of records StoreAnalysis writes at once;
+	// zero or one writes them one at a time.
+	Concurrency int
+
+	// Fields selects what StoreAnalysis stores of functions and
+	// interfaces: StoreGraph, StoreMetrics or StoreFull, the default.
+	Fields string
 }
 
+// Field sets for Config.Fields.
+const (
+	StoreGraph   = "graph"   // nodes and edges, without metrics
+	StoreMetrics = "metrics" // also the metrics of functions
+	StoreFull    = "full"    // also docs, positions, call sites and body hashes
+)
+
+// dial connects to SurrealDB; tests replace it with a fake.
+var dial = surrealdb.New
+
+// Backoff between retries starts at retryBaseDelay and doubles up to
+// retryMaxDelay.
+var (
+	retryBaseDelay = 100 * time.Millisecond
+	retryMaxDelay  = 5 * time.Second
+)
+
 type SurrealDB struct {
 	db     *surrealdb.DB
 	config Config
+
+	cleanup   runtime.Cleanup
+	closeOnce sync.Once
+	closeErr  error
 }
 
 func NewSurrealDB(config Config) (*SurrealDB, error) {
-	db, err := surrealdb.New(config.URL)
+	switch config.Fields {
+	case "", StoreGraph, StoreMetrics, StoreFull:
+	default:
+		return nil, fmt.Errorf("unknown field set %q: must be graph, metrics or full", config.Fields)
+	}
+	var db *surrealdb.DB
+	err := retry(context.Background(), config, func() error {
+		var err error
+		db, err = dial(config.URL)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -36,78 +97,199 @@ func NewSurrealDB(config Config) (*SurrealDB, error) {
 		config: config,
 	}
 
-	// Add cleanup for database connection
-	runtime.AddCleanup(sdb, func(db *surrealdb.DB) {
+	// Add cleanup for database connection as a safety net; Close stops it.
+	sdb.cleanup = runtime.AddCleanup(sdb, func(db *surrealdb.DB) {
 		db.Close()
 	}, sdb.db)
 
 	return sdb, nil
 }
 
+// Close closes the underlying SurrealDB connection. It is safe to call more
+// than once; only the first call closes the connection.
+func (s *SurrealDB) Close() error {
+	s.closeOnce.Do(func() {
+		s.cleanup.Stop()
+		if s.db != nil {
+			s.closeErr = s.db.Close()
+		}
+	})
+	return s.closeErr
+}
+
+// Initialize signs in, retrying while the server is not ready, and selects
+// the configured namespace and database, creating them if the user may.
 func (s *SurrealDB) Initialize(ctx context.Context) error {
-	if err := s.db.Use(s.config.Namespace, s.config.Database); err != nil {
-		return fmt.Errorf("failed to set namespace/database: %w", err)
+	if err := s.config.validateAuth(); err != nil {
+		return err
 	}
 
-	authData := &surrealdb.Auth{
-		Username: s.config.Username,
-		Password: s.config.Password,
+	token := s.config.Token
+	if token == "" {
+		authData := &surrealdb.Auth{
+			Username: s.config.Username,
+			Password: s.config.Password,
+		}
+		switch {
+		case s.config.Scope != "":
+			authData.Namespace = s.config.Namespace
+			authData.Database = s.config.Database
+			authData.Scope = s.config.Scope
+		case s.config.AuthLevel == "namespace":
+			authData.Namespace = s.config.Namespace
+		case s.config.AuthLevel == "database":
+			authData.Namespace = s.config.Namespace
+			authData.Database = s.config.Database
+		}
+		err := retry(ctx, s.config, func() error {
+			var err error
+			token, err = s.db.SignIn(authData)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to sign in: %w", err)
+		}
 	}
-	token, err := s.db.SignIn(authData)
+
+	err := retry(ctx, s.config, func() error {
+		return s.db.Authenticate(token)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to sign in: %w", err)
+		return fmt.Errorf("failed to authenticate: %w", err)
 	}
 
-	if err := s.db.Authenticate(token); err != nil {
-		return fmt.Errorf("failed to authenticate: %w", err)
+	// Only system users may define namespaces and databases; everyone else
+	// relies on them existing.
+	var query string
+	if s.config.Token == "" && s.config.Scope == "" {
+		switch s.config.AuthLevel {
+		case "", "root":
+			query = fmt.Sprintf("DEFINE NAMESPACE IF NOT EXISTS %s; USE NS %s; DEFINE DATABASE IF NOT EXISTS %s;",
+				ident(s.config.Namespace), ident(s.config.Namespace), ident(s.config.Database))
+		case "namespace":
+			query = fmt.Sprintf("USE NS %s; DEFINE DATABASE IF NOT EXISTS %s;",
+				ident(s.config.Namespace), ident(s.config.Database))
+		}
+	}
+	if query != "" {
+		if err := execQuery(s.db, query, map[string]interface{}{}); err != nil {
+			return fmt.Errorf("failed to define namespace/database: %w", err)
+		}
 	}
 
+	if err := s.db.Use(s.config.Namespace, s.config.Database); err != nil {
+		return fmt.Errorf("failed to set namespace/database: %w", err)
+	}
+
+	return nil
+}
+
+// validateAuth checks that exactly one authentication mode is configured.
+func (c Config) validateAuth() error {
+	credentials := c.Username != "" || c.Password != ""
+	switch {
+	case c.Token != "" && (credentials || c.Scope != ""):
+		return fmt.Errorf("token authentication cannot be combined with a username, password or scope")
+	case c.Token == "" && !credentials:
+		return fmt.Errorf("no authentication configured: set a username and password, optionally with a scope, or a token")
+	}
+	switch c.AuthLevel {
+	case "", "root":
+	case "namespace", "database":
+		if c.Token != "" || c.Scope != "" {
+			return fmt.Errorf("auth level %s only applies to system users", c.AuthLevel)
+		}
+	default:
+		return fmt.Errorf("unknown auth level %q: must be root, namespace or database", c.AuthLevel)
+	}
 	return nil
 }
 
+// retry calls fn until it succeeds, config.MaxRetries retries have failed or
+// config.ConnectTimeout has elapsed, backing off exponentially in between.
+func retry(ctx context.Context, config Config, fn func() error) error {
+	if config.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.ConnectTimeout)
+		defer cancel()
+	}
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt > config.MaxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, retryMaxDelay)
+	}
+}
+
+// ident escapes name for use as a SurrealQL identifier.
+func ident(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "\\`") + "`"
+}
+
+// StoreAnalysis upserts the report's nodes under stable record ids, replaces
+// the edges of every re-analyzed node, and then reconciles the analyzed files
+// so nodes deleted from source do not linger. Config.Fields selects the
+// fields stored.
 func (s *SurrealDB) StoreAnalysis(ctx context.Context, report types.AnalysisReport) error {
-	// Store functions (nodes)
-	for _, fn := range report.Functions {
-		function := map[string]interface{}{
-			"caller":       fn.Caller,
-			"file":         fn.File,
-			"package":      fn.Package,
-			"params":       fn.Params,
-			"returns":      fn.Returns,
-			"is_method":    fn.IsMethod,
-			"struct":       fn.Struct,
-			"is_recursive": fn.IsRecursive,
-			"metrics":      fn.Metrics,
-			"is_duplicate": fn.IsDuplicate,
-			"is_interface": fn.IsInterface,
-			"is_struct":    fn.IsStruct,
-			"is_global":    fn.IsGlobal,
-		}
-		if _, err := surrealdb.Create[map[string]interface{}](s.db, "functions", function); err != nil {
-			return fmt.Errorf("error storing function %s: %v", fn.Caller, err)
-		}
-	}
-
-	// Store function calls (edges)
-	for _, fn := range report.Functions {
-		for _, callee := range fn.Callees {
-			call := map[string]interface{}{
-				"from":    fmt.Sprintf("functions:%s", fn.Caller),
-				"to":      fmt.Sprintf("functions:%s", callee),
-				"file":    fn.File,
-				"package": fn.Package,
+	if s.config.Replace {
+		if err := s.truncate(); err != nil {
+			return err
+		}
+	}
+
+	ids := make(map[string][]models.RecordID)
+	files := analyzedFiles(report)
+	fields := cmp.Or(s.config.Fields, StoreFull)
+
+	// Nodes are stored first so every edge can point at its nodes.
+	var nodes []func() error
+	upsert := func(id models.RecordID, data interface{}, what string) {
+		ids[id.Table] = append(ids[id.Table], id)
+		nodes = append(nodes, func() error {
+			if err := upsertRecord(s.db, id, data); err != nil {
+				return fmt.Errorf("error storing %s: %v", what, err)
 			}
-			if _, err := surrealdb.Create[map[string]interface{}](s.db, "calls", call); err != nil {
-				return fmt.Errorf("error storing call from %s to %s: %v", fn.Caller, callee, err)
+			return nil
+		})
+	}
+
+	// Store functions (nodes), and the external functions they call
+	external := make(map[models.RecordID]bool)
+	for _, fn := range report.Functions {
+		upsert(recordID("functions", packageScope(fn.File, fn.Package), fn.Caller), functionRecord(fn, fields), "function "+fn.Caller)
+		for _, call := range fn.ExternalCalls {
+			id := callTarget(call)
+			if call.Dir != "" || external[id] {
+				continue
 			}
+			external[id] = true
+			// External nodes are shared by every analysis, so their edges
+			// are not dropped with those of the re-analyzed nodes.
+			node := types.FunctionCall{Caller: call.Function, Package: call.Package, Params: []string{}, Returns: []string{}, IsExternal: true}
+			nodes = append(nodes, func() error {
+				if err := upsertRecord(s.db, id, functionRecord(node, StoreGraph)); err != nil {
+					return fmt.Errorf("error storing external function %s.%s: %v", call.Package, call.Function, err)
+				}
+				return nil
+			})
 		}
 	}
 
 	// Store structs
 	for _, st := range report.Structs {
-		if _, err := surrealdb.Create[types.StructDefinition](s.db, models.Table("structs"), st); err != nil {
-			return fmt.Errorf("error storing struct %s: %v", st.Name, err)
-		}
+		upsert(recordID("structs", packageScope(st.File, st.Package), st.Name), st, "struct "+st.Name)
+	}
+
+	// Store other named types
+	for _, typ := range report.Types {
+		upsert(recordID("types", packageScope(typ.File, typ.Package), typ.Name), typ, "type "+typ.Name)
 	}
 
 	// Store interfaces
@@ -118,59 +300,89 @@ func (s *SurrealDB) StoreAnalysis(ctx context.Context, report types.AnalysisRepo
 			"file":    iface.File,
 			"package": iface.Package,
 		}
-		if _, err := surrealdb.Create[map[string]interface{}](s.db, "interfaces", interfaceData); err != nil {
-			return fmt.Errorf("error storing interface %s: %v", iface.Name, err)
+		if iface.Doc != "" && fields == StoreFull {
+			interfaceData["doc"] = iface.Doc
 		}
+		upsert(recordID("interfaces", packageScope(iface.File, iface.Package), iface.Name), interfaceData, "interface "+iface.Name)
 	}
 
 	// Store globals
 	for _, global := range report.Globals {
-		if _, err := surrealdb.Create[types.GlobalVariable](s.db, models.Table("globals"), global); err != nil {
-			return fmt.Errorf("error storing global %s: %v", global.Name, err)
-		}
+		upsert(recordID("globals", packageScope(global.File, global.Package), global.Name), global, "global "+global.Name)
 	}
 
-	// Store imports
+	// Store imports, one per importing file
 	for _, imp := range report.Imports {
-		if _, err := surrealdb.Create[types.ImportDefinition](s.db, models.Table("imports"), imp); err != nil {
-			return fmt.Errorf("error storing import %s: %v", imp.Path, err)
-		}
+		upsert(recordID("imports", imp.File, imp.Path), imp, "import "+imp.Path)
 	}
 
-	// Store methods (struct-to-function edges)
+	if err := runConcurrently(ctx, s.config.Concurrency, nodes); err != nil {
+		return err
+	}
+
+	// Drop the edges of every re-analyzed node; they are recreated below.
+	if err := s.deleteEdges(ids); err != nil {
+		return err
+	}
+
+	var edges []func() error
+	create := func(table string, data map[string]interface{}, what string) {
+		edges = append(edges, func() error {
+			if err := createRecord(s.db, table, data); err != nil {
+				return fmt.Errorf("error storing %s: %v", what, err)
+			}
+			return nil
+		})
+	}
+
+	// Store function calls (edges). Callees are declared in the package of
+	// their caller.
 	for _, fn := range report.Functions {
-		if fn.IsMethod && fn.Struct != "" {
-			method := map[string]interface{}{
-				"struct":   fmt.Sprintf("structs:%s", fn.Struct),
-				"function": fmt.Sprintf("functions:%s", fn.Caller),
+		scope := packageScope(fn.File, fn.Package)
+		for _, callee := range fn.Callees {
+			call := map[string]interface{}{
+				"from":    recordID("functions", scope, fn.Caller),
+				"to":      recordID("functions", scope, callee),
+				"file":    fn.File,
+				"package": fn.Package,
 			}
-			if _, err := surrealdb.Create[map[string]interface{}](s.db, "methods", method); err != nil {
-				return fmt.Errorf("error storing method %s for struct %s: %v", fn.Caller, fn.Struct, err)
+			create("calls", call, fmt.Sprintf("call from %s to %s", fn.Caller, callee))
+		}
+		for _, ext := range fn.ExternalCalls {
+			call := map[string]interface{}{
+				"from":    recordID("functions", scope, fn.Caller),
+				"to":      callTarget(ext),
+				"file":    fn.File,
+				"package": fn.Package,
 			}
+			create("calls", call, fmt.Sprintf("call from %s to %s.%s", fn.Caller, ext.Package, ext.Function))
 		}
 	}
 
+	// Store methods (struct-to-function edges)
+	for _, method := range methodEdges(report) {
+		create("methods", method, fmt.Sprintf("method %v for struct %v", method["function"], method["struct"]))
+	}
+
 	// Store implements (struct-to-interface edges)
 	for _, impl := range report.Implements {
+		scope := packageScope(impl.File, impl.Package)
 		implData := map[string]interface{}{
-			"struct":    fmt.Sprintf("structs:%s", impl.Struct),
-			"interface": fmt.Sprintf("interfaces:%s", impl.Interface),
-		}
-		if _, err := surrealdb.Create[map[string]interface{}](s.db, "implements", implData); err != nil {
-			return fmt.Errorf("error storing implementation of %s by struct %s: %v", impl.Interface, impl.Struct, err)
+			"struct":    recordID("structs", scope, impl.Struct),
+			"interface": recordID("interfaces", scope, impl.Interface),
 		}
+		create("implements", implData, fmt.Sprintf("implementation of %s by struct %s", impl.Interface, impl.Struct))
 	}
 
 	// Store references (function-to-global edges)
 	for _, fn := range report.Functions {
+		scope := packageScope(fn.File, fn.Package)
 		for _, global := range fn.ReferencedGlobals {
 			reference := map[string]interface{}{
-				"function": fmt.Sprintf("functions:%s", fn.Caller),
-				"global":   fmt.Sprintf("globals:%s", global),
-			}
-			if _, err := surrealdb.Create[map[string]interface{}](s.db, "references", reference); err != nil {
-				return fmt.Errorf("error storing reference to global %s in function %s: %v", global, fn.Caller, err)
+				"function": recordID("functions", scope, fn.Caller),
+				"global":   recordID("globals", scope, global),
 			}
+			create("references", reference, fmt.Sprintf("reference to global %s in function %s", global, fn.Caller))
 		}
 	}
 
@@ -178,14 +390,673 @@ func (s *SurrealDB) StoreAnalysis(ctx context.Context, report types.AnalysisRepo
 	for _, fn := range report.Functions {
 		for _, imp := range fn.Dependencies {
 			dependency := map[string]interface{}{
-				"function": fmt.Sprintf("functions:%s", fn.Caller),
-				"import":   fmt.Sprintf("imports:%s", imp),
+				"function": recordID("functions", packageScope(fn.File, fn.Package), fn.Caller),
+				"import":   recordID("imports", fn.File, imp),
+			}
+			create("dependencies", dependency, fmt.Sprintf("dependency %s in function %s", imp, fn.Caller))
+		}
+	}
+
+	// Store symbol uses (function-to-import edges per called symbol)
+	for _, fn := range report.Functions {
+		for _, dep := range fn.PackageDependencies {
+			use := map[string]interface{}{
+				"function": recordID("functions", packageScope(fn.File, fn.Package), fn.Caller),
+				"import":   recordID("imports", fn.File, dep.ImportPath),
+				"symbol":   dep.Symbol,
+			}
+			create("uses_symbol", use, fmt.Sprintf("use of %s.%s in function %s", dep.ImportPath, dep.Symbol, fn.Caller))
+		}
+	}
+
+	if err := runConcurrently(ctx, s.config.Concurrency, edges); err != nil {
+		return err
+	}
+
+	return s.reconcile(report, files, ids)
+}
+
+// functionRecord returns the fields of fn stored on its node.
+func functionRecord(fn types.FunctionCall, fields string) map[string]interface{} {
+	function := map[string]interface{}{
+		"caller":               fn.Caller,
+		"file":                 fn.File,
+		"package":              fn.Package,
+		"params":               fn.Params,
+		"returns":              fn.Returns,
+		"param_count":          fn.ParamCount,
+		"return_count":         fn.ReturnCount,
+		"return_names":         fn.ReturnNames,
+		"is_variadic":          fn.IsVariadic,
+		"is_method":            fn.IsMethod,
+		"pointer_receiver":     fn.PointerReceiver,
+		"struct":               fn.Struct,
+		"is_recursive":         fn.IsRecursive,
+		"recursion_group_id":   fn.RecursionGroupID,
+		"is_duplicate":         fn.IsDuplicate,
+		"is_interface":         fn.IsInterface,
+		"is_struct":            fn.IsStruct,
+		"is_global":            fn.IsGlobal,
+		"is_closure":           fn.IsClosure,
+		"is_test":              fn.IsTest,
+		"has_naked_return":     fn.HasNakedReturn,
+		"ignores_errors":       fn.IgnoresErrors,
+		"is_empty":             fn.IsEmpty,
+		"uses_anonymous_types": fn.UsesAnonymousTypes,
+		"has_goroutines":       fn.HasGoroutines,
+		"captures":             fn.Captures,
+	}
+	if fields != StoreGraph {
+		function["metrics"] = fn.Metrics
+	}
+	if fields == StoreFull {
+		function["call_sites"] = fn.CallSites
+		if fn.Doc != "" {
+			function["doc"] = fn.Doc
+		}
+		if fn.Line > 0 {
+			function["line"] = fn.Line
+		}
+		if fn.BodyHash != "" {
+			function["body_hash"] = fn.BodyHash
+		}
+	}
+	if len(fn.UnguardedGlobalWrites) > 0 {
+		function["unguarded_global_writes"] = fn.UnguardedGlobalWrites
+	}
+	if len(fn.ErrorsIgnoredBy) > 0 {
+		function["errors_ignored_by"] = fn.ErrorsIgnoredBy
+	}
+	if len(fn.Owners) > 0 {
+		function["owners"] = fn.Owners
+	}
+	if len(fn.Labels) > 0 {
+		function["labels"] = fn.Labels
+	}
+	if fn.Ignored {
+		function["ignored"] = true
+		if len(fn.IgnoredChecks) > 0 {
+			function["ignored_checks"] = fn.IgnoredChecks
+		}
+	}
+	if fn.IsExternal {
+		function["is_external"] = true
+	}
+	return function
+}
+
+// StorePlan counts the records StoreAnalysis would store, per table.
+type StorePlan struct {
+	// Nodes
+	Functions  int
+	Structs    int
+	Types      int
+	Interfaces int
+	Globals    int
+	Imports    int
+
+	// Edges
+	Calls        int
+	Methods      int
+	Implements   int
+	References   int
+	Dependencies int
+	SymbolUses   int
+}
+
+// PlanStore returns what StoreAnalysis would store for report without
+// touching the database. Nodes sharing a record ID are counted once, as
+// they are upserted into the same record.
+func (s *SurrealDB) PlanStore(report types.AnalysisReport) StorePlan {
+	distinct := func(n int, id func(i int) models.RecordID) int {
+		seen := make(map[models.RecordID]bool, n)
+		for i := range n {
+			seen[id(i)] = true
+		}
+		return len(seen)
+	}
+	plan := StorePlan{
+		Functions: distinct(len(report.Functions), func(i int) models.RecordID {
+			fn := report.Functions[i]
+			return recordID("functions", packageScope(fn.File, fn.Package), fn.Caller)
+		}),
+		Structs: distinct(len(report.Structs), func(i int) models.RecordID {
+			st := report.Structs[i]
+			return recordID("structs", packageScope(st.File, st.Package), st.Name)
+		}),
+		Types: distinct(len(report.Types), func(i int) models.RecordID {
+			typ := report.Types[i]
+			return recordID("types", packageScope(typ.File, typ.Package), typ.Name)
+		}),
+		Interfaces: distinct(len(report.Interfaces), func(i int) models.RecordID {
+			iface := report.Interfaces[i]
+			return recordID("interfaces", packageScope(iface.File, iface.Package), iface.Name)
+		}),
+		Globals: distinct(len(report.Globals), func(i int) models.RecordID {
+			global := report.Globals[i]
+			return recordID("globals", packageScope(global.File, global.Package), global.Name)
+		}),
+		Imports: distinct(len(report.Imports), func(i int) models.RecordID {
+			return recordID("imports", report.Imports[i].File, report.Imports[i].Path)
+		}),
+		Methods:    len(methodEdges(report)),
+		Implements: len(report.Implements),
+	}
+	external := make(map[models.RecordID]bool)
+	for _, fn := range report.Functions {
+		plan.Calls += len(fn.Callees) + len(fn.ExternalCalls)
+		for _, call := range fn.ExternalCalls {
+			if call.Dir == "" {
+				external[callTarget(call)] = true
+			}
+		}
+		plan.References += len(fn.ReferencedGlobals)
+		plan.Dependencies += len(fn.Dependencies)
+		plan.SymbolUses += len(fn.PackageDependencies)
+	}
+	plan.Functions += len(external)
+	return plan
+}
+
+// upsertRecord and createRecord store a node or an edge; tests replace them
+// with fakes.
+var (
+	upsertRecord = func(db *surrealdb.DB, id models.RecordID, data interface{}) error {
+		_, err := surrealdb.Upsert[any](db, id, data)
+		return err
+	}
+	createRecord = func(db *surrealdb.DB, table string, data map[string]interface{}) error {
+		_, err := surrealdb.Create[any](db, table, data)
+		return err
+	}
+)
+
+// runConcurrently runs ops on up to n goroutines, or one if n is less than
+// one, and returns the first error. No op is started once one has failed or
+// ctx is done.
+func runConcurrently(ctx context.Context, n int, ops []func() error) error {
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(n, 1))
+	var err error
+	for _, op := range ops {
+		if err = gctx.Err(); err != nil {
+			break
+		}
+		g.Go(op)
+	}
+	if werr := g.Wait(); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// Implementors returns the names of the structs implementing the named
+// interface, according to the stored implements edges.
+func (s *SurrealDB) Implementors(ctx context.Context, iface string) ([]string, error) {
+	names, err := queryNames(s.db, "SELECT VALUE struct.name FROM implements WHERE interface.name = $name", map[string]interface{}{
+		"name": iface,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error querying implementors of %s: %v", iface, err)
+	}
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
+
+// ImplementedInterfaces returns the names of the interfaces the named struct
+// implements, according to the stored implements edges.
+func (s *SurrealDB) ImplementedInterfaces(ctx context.Context, structName string) ([]string, error) {
+	names, err := queryNames(s.db, "SELECT VALUE interface.name FROM implements WHERE struct.name = $name", map[string]interface{}{
+		"name": structName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error querying interfaces implemented by %s: %v", structName, err)
+	}
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
+
+// Callers returns the names of the functions calling function, given as
+// package.name or dir:package.name, according to the stored call edges.
+// Names are qualified like function; see functionNames.
+func (s *SurrealDB) Callers(ctx context.Context, function string) ([]string, error) {
+	id, err := s.resolveFunction(function)
+	if err != nil || id == nil {
+		return nil, err
+	}
+	functions, err := queryFunctions(s.db, "SELECT from.file AS file, from.package AS package, from.caller AS caller FROM calls WHERE to = $id", map[string]interface{}{"id": *id})
+	if err != nil {
+		return nil, fmt.Errorf("error querying callers of %s: %v", function, err)
+	}
+	return s.functionNames(functions)
+}
+
+// Callees returns the names of the stored functions function, given as
+// package.name or dir:package.name, calls, according to the stored call
+// edges. Names are qualified like function; see functionNames.
+func (s *SurrealDB) Callees(ctx context.Context, function string) ([]string, error) {
+	id, err := s.resolveFunction(function)
+	if err != nil || id == nil {
+		return nil, err
+	}
+	functions, err := queryFunctions(s.db, "SELECT to.file AS file, to.package AS package, to.caller AS caller FROM calls WHERE from = $id AND to.caller != NONE", map[string]interface{}{"id": *id})
+	if err != nil {
+		return nil, fmt.Errorf("error querying callees of %s: %v", function, err)
+	}
+	return s.functionNames(functions)
+}
+
+// HasFunction reports whether function, given as package.name or
+// dir:package.name, is stored. It is an error if function names functions
+// of same-named packages in several directories.
+func (s *SurrealDB) HasFunction(ctx context.Context, function string) (bool, error) {
+	id, err := s.resolveFunction(function)
+	return id != nil, err
+}
+
+// resolveFunction returns the record ID of function, given as package.name
+// or dir:package.name, or nil if it is not stored. Without a directory, the
+// package must be stored in only one directory; otherwise the error lists
+// the qualified names to choose from.
+func (s *SurrealDB) resolveFunction(function string) (*models.RecordID, error) {
+	vars, err := functionVars(function)
+	if err != nil {
+		return nil, err
+	}
+	functions, err := queryFunctions(s.db, "SELECT file, package, caller FROM functions WHERE package = $package AND caller = $name", vars)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up function %s: %v", function, err)
+	}
+	dir := vars["dir"].(string)
+	var scopes []string
+	for _, fn := range functions {
+		if dir != "" && (fn.File == "" || filepath.Dir(fn.File) != dir) {
+			continue
+		}
+		if !slices.Contains(scopes, fn.scope()) {
+			scopes = append(scopes, fn.scope())
+		}
+	}
+	switch len(scopes) {
+	case 0:
+		return nil, nil
+	case 1:
+		id := recordID("functions", scopes[0], vars["name"].(string))
+		return &id, nil
+	}
+	slices.Sort(scopes)
+	for i, scope := range scopes {
+		scopes[i] = scope + "." + vars["name"].(string)
+	}
+	return nil, fmt.Errorf("function %s is declared in several directories; qualify it as one of %s", function, strings.Join(scopes, ", "))
+}
+
+// functionNames returns the sorted, distinct names of functions qualified by
+// their package, as in main.run, and also by their directory, as in
+// cmd/tool:main.run, if their package name is stored in several
+// directories, so each name resolves to one function.
+func (s *SurrealDB) functionNames(functions []storedFunction) ([]string, error) {
+	var packages []string
+	for _, fn := range functions {
+		if fn.File != "" && !slices.Contains(packages, fn.Package) {
+			packages = append(packages, fn.Package)
+		}
+	}
+	dirs := make(map[string][]string)
+	if len(packages) > 0 {
+		stored, err := queryFunctions(s.db, "SELECT file, package FROM functions WHERE package INSIDE $packages AND file != \"\"", map[string]interface{}{"packages": packages})
+		if err != nil {
+			return nil, fmt.Errorf("error looking up packages: %v", err)
+		}
+		for _, fn := range stored {
+			if dir := filepath.Dir(fn.File); !slices.Contains(dirs[fn.Package], dir) {
+				dirs[fn.Package] = append(dirs[fn.Package], dir)
 			}
-			if _, err := surrealdb.Create[map[string]interface{}](s.db, "dependencies", dependency); err != nil {
-				return fmt.Errorf("error storing dependency %s in function %s: %v", imp, fn.Caller, err)
+		}
+	}
+	names := make([]string, 0, len(functions))
+	for _, fn := range functions {
+		name := fn.Package + "." + fn.Caller
+		if len(dirs[fn.Package]) > 1 {
+			name = fn.scope() + "." + fn.Caller
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
+
+// functionVars splits a function name qualified by its package, and
+// optionally by the directory of the package, as in main.run,
+// db.SurrealDB.Close or cmd/tool:main.run, into the dir, package and name
+// query variables. The dir is empty if not given.
+func functionVars(function string) (map[string]interface{}, error) {
+	var dir string
+	if i := strings.LastIndex(function, ":"); i >= 0 {
+		dir = filepath.Clean(filepath.FromSlash(function[:i]))
+		function = function[i+1:]
+	}
+	pkg, name, ok := strings.Cut(function, ".")
+	if !ok || pkg == "" || name == "" {
+		return nil, fmt.Errorf("function %s is not qualified by its package, as in main.run", function)
+	}
+	return map[string]interface{}{"dir": dir, "package": pkg, "name": name}, nil
+}
+
+// storedFunction is a function node as the call graph queries select it.
+type storedFunction struct {
+	File    string `json:"file"`
+	Package string `json:"package"`
+	Caller  string `json:"caller"`
+}
+
+// scope returns the package scope of the function's record ID; external
+// functions have no file and are scoped by their import path.
+func (fn storedFunction) scope() string {
+	if fn.File == "" {
+		return fn.Package
+	}
+	return packageScope(fn.File, fn.Package)
+}
+
+// queryFunctions runs a query selecting function nodes; tests replace it
+// with a fake.
+var queryFunctions = func(db *surrealdb.DB, query string, vars map[string]interface{}) ([]storedFunction, error) {
+	results, err := surrealdb.Query[[]storedFunction](db, query, vars)
+	if err != nil {
+		return nil, err
+	}
+	if err := queryError(results); err != nil {
+		return nil, err
+	}
+	var functions []storedFunction
+	for _, result := range *results {
+		functions = append(functions, result.Result...)
+	}
+	return functions, nil
+}
+
+// queryNames runs a query selecting a list of names; tests replace it with a
+// fake.
+var queryNames = func(db *surrealdb.DB, query string, vars map[string]interface{}) ([]string, error) {
+	results, err := surrealdb.Query[[]string](db, query, vars)
+	if err != nil {
+		return nil, err
+	}
+	if err := queryError(results); err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, result := range *results {
+		names = append(names, result.Result...)
+	}
+	return names, nil
+}
+
+// nodeEdges lists, for each node table, the edge table fields that link to it.
+var nodeEdges = []struct {
+	table string
+	edges []string
+}{
+	{"functions", []string{"calls.from", "calls.to", "methods.function", "references.function", "dependencies.function", "uses_symbol.function"}},
+	{"structs", []string{"methods.struct", "implements.struct"}},
+	{"types", []string{"methods.struct"}},
+	{"interfaces", []string{"implements.interface"}},
+	{"globals", []string{"references.global"}},
+	{"imports", []string{"dependencies.import", "uses_symbol.import"}},
+}
+
+// methodEdges returns the type-to-function edges of the report's methods.
+// Value and pointer methods of a type both link to the type's record: a
+// struct's, or that of another named type, such as type Celsius float64.
+func methodEdges(report types.AnalysisReport) []map[string]interface{} {
+	named := make(map[string]bool, len(report.Types))
+	for _, typ := range report.Types {
+		named[RecordKey(packageScope(typ.File, typ.Package), typ.Name)] = true
+	}
+	var edges []map[string]interface{}
+	for _, fn := range report.Functions {
+		if fn.IsMethod && fn.Struct != "" {
+			scope := packageScope(fn.File, fn.Package)
+			name, table := strings.TrimPrefix(fn.Struct, "*"), "structs"
+			if named[RecordKey(scope, name)] {
+				table = "types"
 			}
+			edges = append(edges, map[string]interface{}{
+				"struct":   recordID(table, scope, name),
+				"function": recordID("functions", scope, fn.Caller),
+			})
 		}
 	}
+	return edges
+}
+
+// recordID builds the stable record id of a node from its scope and name.
+// Nodes and the edges pointing at them must both use it.
+func recordID(table, scope, name string) models.RecordID {
+	return models.NewRecordID(table, RecordKey(scope, name))
+}
+
+// packageScope returns the scope of the nodes a package declares in file:
+// its directory and package name, as in "cmd/tool:main", so same-named
+// packages in different directories never share a record. External
+// functions are scoped by their import path instead, which holds no colon,
+// and imports by their importing file.
+func packageScope(file, pkg string) string {
+	return filepath.Dir(file) + ":" + pkg
+}
+
+// callTarget returns the record ID of the function an external call calls:
+// its node if its package was analyzed, or else its external node, keyed
+// by import path.
+func callTarget(call types.ExternalCall) models.RecordID {
+	if call.Dir != "" {
+		return recordID("functions", call.Dir+":"+call.Name, call.Function)
+	}
+	return recordID("functions", call.Package, call.Function)
+}
 
+// RecordKey returns the key of the record of the node called name in
+// scope, such as _2e_3amain__MathOps_2eAdd for the method MathOps.Add of
+// package main declared in the current directory, whose scope is ".:main".
+// Keys only hold ASCII letters, digits and underscores, so they are valid
+// unquoted SurrealQL record ids: every other byte of scope and name,
+// underscores included, is written as an underscore and two hex digits, and
+// a double underscore separates the two. Distinct nodes never share a key.
+func RecordKey(scope, name string) string {
+	return escapeKey(scope) + "__" + escapeKey(name)
+}
+
+// escapeKey escapes s for RecordKey.
+func escapeKey(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "_%02x", c)
+		}
+	}
+	return b.String()
+}
+
+// analyzedFiles returns every file that contributed a node to the report.
+func analyzedFiles(report types.AnalysisReport) []string {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(file string) {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	for _, fn := range report.Functions {
+		add(fn.File)
+	}
+	for _, st := range report.Structs {
+		add(st.File)
+	}
+	for _, iface := range report.Interfaces {
+		add(iface.File)
+	}
+	for _, typ := range report.Types {
+		add(typ.File)
+	}
+	for _, global := range report.Globals {
+		add(global.File)
+	}
+	for _, imp := range report.Imports {
+		add(imp.File)
+	}
+	return files
+}
+
+// truncate deletes every node and edge.
+func (s *SurrealDB) truncate() error {
+	query := "DELETE calls; DELETE methods; DELETE implements; DELETE references; DELETE dependencies; DELETE uses_symbol;"
+	for _, node := range nodeEdges {
+		query += fmt.Sprintf(" DELETE %s;", node.table)
+	}
+	if err := execQuery(s.db, query, map[string]interface{}{}); err != nil {
+		return fmt.Errorf("error truncating tables: %v", err)
+	}
+	return nil
+}
+
+// deleteEdges deletes every edge that starts from one of the given nodes.
+// Incoming call edges are kept since callers may not have been re-analyzed.
+func (s *SurrealDB) deleteEdges(ids map[string][]models.RecordID) error {
+	query := `DELETE calls WHERE from INSIDE $functions;
+DELETE methods WHERE function INSIDE $functions;
+DELETE references WHERE function INSIDE $functions;
+DELETE dependencies WHERE function INSIDE $functions;
+DELETE uses_symbol WHERE function INSIDE $functions;
+DELETE implements WHERE struct INSIDE $structs;`
+	vars := map[string]interface{}{
+		"functions": ids["functions"],
+		"structs":   ids["structs"],
+	}
+	if err := execQuery(s.db, query, vars); err != nil {
+		return fmt.Errorf("error deleting stale edges: %v", err)
+	}
+	return nil
+}
+
+// reconcile deletes nodes from the analyzed files that were not part of this
+// analysis, and the nodes of files under the report's roots that are gone,
+// together with the edges pointing at them.
+func (s *SurrealDB) reconcile(report types.AnalysisReport, files []string, ids map[string][]models.RecordID) error {
+	for _, node := range nodeEdges {
+		vars := map[string]interface{}{
+			"files": files,
+			"ids":   ids[node.table],
+		}
+		if err := s.deleteNodes(node.table, node.edges, "file INSIDE $files AND id NOTINSIDE $ids", vars); err != nil {
+			return fmt.Errorf("error reconciling %s: %v", node.table, err)
+		}
+	}
+	if len(report.Roots) == 0 {
+		return nil
+	}
+	// Files that failed to parse keep their earlier analysis.
+	live := slices.Clone(files)
+	for _, fe := range report.FileErrors {
+		live = append(live, fe.Path)
+	}
+	if err := s.deleteGoneFiles(report.Roots, live); err != nil {
+		return fmt.Errorf("error reconciling %v", err)
+	}
+	return nil
+}
+
+// deleteGoneFiles deletes the nodes of the stored files under roots that are
+// not among live, such as files deleted since they were analyzed, together
+// with the edges pointing at them. The nodes of other roots are kept, as are
+// external functions, which have no file.
+func (s *SurrealDB) deleteGoneFiles(roots, live []string) error {
+	var query string
+	for _, node := range nodeEdges {
+		query += fmt.Sprintf("SELECT VALUE file FROM %s WHERE file != \"\";", node.table)
+	}
+	stored, err := queryNames(s.db, query, map[string]interface{}{})
+	if err != nil {
+		return fmt.Errorf("stored files: %v", err)
+	}
+	gone := []string{}
+	for _, file := range stored {
+		if file != "" && !slices.Contains(live, file) && !slices.Contains(gone, file) && slices.ContainsFunc(roots, func(root string) bool {
+			return underRoot(file, root)
+		}) {
+			gone = append(gone, file)
+		}
+	}
+	if len(gone) == 0 {
+		return nil
+	}
+	slices.Sort(gone)
+	for _, node := range nodeEdges {
+		if err := s.deleteNodes(node.table, node.edges, "file INSIDE $gone", map[string]interface{}{"gone": gone}); err != nil {
+			return fmt.Errorf("%s: %v", node.table, err)
+		}
+	}
+	return nil
+}
+
+// underRoot reports whether file lies under root, comparing their paths as
+// the analyzer joins them.
+func underRoot(file, root string) bool {
+	rel, err := filepath.Rel(root, file)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Prune deletes every node of a file under roots that is not among
+// liveFiles, such as the nodes of deleted files, together with the edges
+// pointing at them. Nodes of files under other roots are kept.
+func (s *SurrealDB) Prune(ctx context.Context, roots, liveFiles []string) error {
+	if err := s.deleteGoneFiles(roots, liveFiles); err != nil {
+		return fmt.Errorf("error pruning %v", err)
+	}
+	return nil
+}
+
+// deleteNodes deletes the nodes of table matching where, deleting the edges
+// pointing at them first so no edge is left dangling.
+func (s *SurrealDB) deleteNodes(table string, edges []string, where string, vars map[string]interface{}) error {
+	query := fmt.Sprintf("LET $stale = (SELECT VALUE id FROM %s WHERE %s);", table, where)
+	for _, edge := range edges {
+		edgeTable, field, _ := strings.Cut(edge, ".")
+		query += fmt.Sprintf(" DELETE %s WHERE %s INSIDE $stale;", edgeTable, field)
+	}
+	query += fmt.Sprintf(" DELETE %s WHERE id INSIDE $stale;", table)
+	return execQuery(s.db, query, vars)
+}
+
+// execQuery runs a query whose results are not needed; tests replace it
+// with a fake.
+var execQuery = func(db *surrealdb.DB, query string, vars map[string]interface{}) error {
+	results, err := surrealdb.Query[any](db, query, vars)
+	if err != nil {
+		return err
+	}
+	return queryError(results)
+}
+
+// queryError returns an error for the first failed statement of a query.
+// surrealdb.Query only returns transport errors; a failed statement comes
+// back with a status other than OK and its message as the result.
+func queryError[T any](results *[]surrealdb.QueryResult[T]) error {
+	if results == nil {
+		return nil
+	}
+	for i, result := range *results {
+		if result.Status == "OK" {
+			continue
+		}
+		if message, ok := any(result.Result).(string); ok {
+			return fmt.Errorf("statement %d failed: %s", i+1, message)
+		}
+		return fmt.Errorf("statement %d failed with status %s", i+1, result.Status)
+	}
 	return nil
 }
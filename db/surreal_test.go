@@ -0,0 +1,824 @@
+//go:build go1.24
+
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	surrealdb "github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+func fakeDial(t *testing.T, failures int) *int {
+	t.Helper()
+	attempts := 0
+	origDial, origDelay := dial, retryBaseDelay
+	dial = func(url string) (*surrealdb.DB, error) {
+		attempts++
+		if attempts <= failures {
+			return nil, errors.New("connection refused")
+		}
+		return nil, nil
+	}
+	retryBaseDelay = time.Millisecond
+	t.Cleanup(func() { dial, retryBaseDelay = origDial, origDelay })
+	return &attempts
+}
+
+func TestNewSurrealDB_RetriesConnection(t *testing.T) {
+	attempts := fakeDial(t, 2)
+
+	sdb, err := NewSurrealDB(Config{URL: "ws://localhost:8000", MaxRetries: 3})
+	require.NoError(t, err)
+	defer sdb.Close()
+	assert.Equal(t, 3, *attempts)
+}
+
+func TestNewSurrealDB_GivesUpAfterMaxRetries(t *testing.T) {
+	attempts := fakeDial(t, 2)
+
+	_, err := NewSurrealDB(Config{URL: "ws://localhost:8000", MaxRetries: 1})
+	assert.ErrorContains(t, err, "connection refused")
+	assert.Equal(t, 2, *attempts)
+}
+
+func TestNewSurrealDB_ConnectTimeout(t *testing.T) {
+	attempts := fakeDial(t, 100)
+	retryBaseDelay = 20 * time.Millisecond
+
+	_, err := NewSurrealDB(Config{URL: "ws://localhost:8000", MaxRetries: 100, ConnectTimeout: 30 * time.Millisecond})
+	assert.ErrorContains(t, err, "giving up")
+	assert.Less(t, *attempts, 100)
+}
+
+func TestConfig_ValidateAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{name: "root user", config: Config{Username: "root", Password: "root"}},
+		{name: "namespace user", config: Config{Username: "ns", Password: "pw", AuthLevel: "namespace"}},
+		{name: "token", config: Config{Token: "eyJ"}},
+		{name: "scope", config: Config{Username: "user", Password: "pw", Scope: "account"}},
+		{name: "none", config: Config{}, wantErr: "no authentication configured"},
+		{name: "scope without credentials", config: Config{Scope: "account"}, wantErr: "no authentication configured"},
+		{name: "token and user", config: Config{Token: "eyJ", Username: "root", Password: "root"}, wantErr: "cannot be combined"},
+		{name: "token and scope", config: Config{Token: "eyJ", Scope: "account"}, wantErr: "cannot be combined"},
+		{name: "scope with auth level", config: Config{Username: "u", Password: "p", Scope: "account", AuthLevel: "database"}, wantErr: "only applies to system users"},
+		{name: "unknown auth level", config: Config{Username: "u", Password: "p", AuthLevel: "cluster"}, wantErr: "unknown auth level"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.validateAuth()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSurrealDB_ImplementsQueries(t *testing.T) {
+	edges := []struct{ structName, iface string }{
+		{"MathOps", "Calculator"},
+		{"FastMath", "Calculator"},
+		{"MathOps", "Stringer"},
+		{"MathOps", "Calculator"},
+	}
+	origQuery := queryNames
+	queryNames = func(db *surrealdb.DB, query string, vars map[string]interface{}) ([]string, error) {
+		var names []string
+		for _, edge := range edges {
+			switch {
+			case strings.Contains(query, "WHERE interface.name") && edge.iface == vars["name"]:
+				names = append(names, edge.structName)
+			case strings.Contains(query, "WHERE struct.name") && edge.structName == vars["name"]:
+				names = append(names, edge.iface)
+			}
+		}
+		return names, nil
+	}
+	t.Cleanup(func() { queryNames = origQuery })
+
+	sdb := &SurrealDB{}
+	implementors, err := sdb.Implementors(context.Background(), "Calculator")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"FastMath", "MathOps"}, implementors)
+
+	interfaces, err := sdb.ImplementedInterfaces(context.Background(), "MathOps")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Calculator", "Stringer"}, interfaces)
+
+	queryNames = func(*surrealdb.DB, string, map[string]interface{}) ([]string, error) {
+		return nil, errors.New("connection lost")
+	}
+	_, err = sdb.Implementors(context.Background(), "Calculator")
+	assert.ErrorContains(t, err, "connection lost")
+}
+
+func TestSurrealDB_CallersAndCallees(t *testing.T) {
+	// Two packages have a run and a helper, and two main packages in
+	// different directories have a run; lookups keep them apart.
+	functions := []storedFunction{
+		{File: "main.go", Package: "main", Caller: "main"},
+		{File: "main.go", Package: "main", Caller: "run"},
+		{File: "util.go", Package: "main", Caller: "helper"},
+		{File: "tool/tool.go", Package: "tool", Caller: "run"},
+		{File: "tool/tool.go", Package: "tool", Caller: "helper"},
+		{File: "cmd/b/main.go", Package: "main", Caller: "run"},
+		{Package: "fmt", Caller: "Println"},
+	}
+	calls := [][2]int{{0, 1}, {1, 2}, {0, 2}, {0, 1}, {3, 4}, {5, 6}}
+	id := func(fn storedFunction) models.RecordID {
+		return recordID("functions", fn.scope(), fn.Caller)
+	}
+	origQuery := queryFunctions
+	queryFunctions = func(db *surrealdb.DB, query string, vars map[string]interface{}) ([]storedFunction, error) {
+		var selected []storedFunction
+		switch {
+		case strings.Contains(query, "WHERE package = $package"):
+			for _, fn := range functions {
+				if fn.Package == vars["package"] && fn.Caller == vars["name"] {
+					selected = append(selected, fn)
+				}
+			}
+		case strings.Contains(query, "WHERE package INSIDE $packages"):
+			for _, fn := range functions {
+				if fn.File != "" && slices.Contains(vars["packages"].([]string), fn.Package) {
+					selected = append(selected, fn)
+				}
+			}
+		case strings.Contains(query, "WHERE to = $id"):
+			for _, call := range calls {
+				if id(functions[call[1]]) == vars["id"] {
+					selected = append(selected, functions[call[0]])
+				}
+			}
+		case strings.Contains(query, "WHERE from = $id"):
+			for _, call := range calls {
+				if id(functions[call[0]]) == vars["id"] {
+					selected = append(selected, functions[call[1]])
+				}
+			}
+		}
+		return selected, nil
+	}
+	t.Cleanup(func() { queryFunctions = origQuery })
+
+	sdb := &SurrealDB{}
+	callers, err := sdb.Callers(context.Background(), "main.helper")
+	require.NoError(t, err)
+	assert.Equal(t, []string{".:main.main", ".:main.run"}, callers)
+
+	callees, err := sdb.Callees(context.Background(), ".:main.main")
+	require.NoError(t, err)
+	assert.Equal(t, []string{".:main.helper", ".:main.run"}, callees)
+
+	callees, err = sdb.Callees(context.Background(), "tool.run")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tool.helper"}, callees)
+
+	callees, err = sdb.Callees(context.Background(), "cmd/b:main.run")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fmt.Println"}, callees)
+
+	// A function of same-named packages in several directories must be
+	// qualified by its directory.
+	_, err = sdb.Callers(context.Background(), "main.run")
+	assert.EqualError(t, err, "function main.run is declared in several directories; qualify it as one of .:main.run, cmd/b:main.run")
+	found, err := sdb.HasFunction(context.Background(), "cmd/b:main.run")
+	require.NoError(t, err)
+	assert.True(t, found)
+	found, err = sdb.HasFunction(context.Background(), "cmd/a:main.run")
+	require.NoError(t, err)
+	assert.False(t, found)
+	found, err = sdb.HasFunction(context.Background(), "main.missing")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	_, err = sdb.HasFunction(context.Background(), "run")
+	assert.ErrorContains(t, err, "not qualified by its package")
+
+	queryFunctions = func(*surrealdb.DB, string, map[string]interface{}) ([]storedFunction, error) {
+		return nil, errors.New("statement 1 failed: permission denied")
+	}
+	_, err = sdb.Callees(context.Background(), "tool.run")
+	assert.EqualError(t, err, "error looking up function tool.run: statement 1 failed: permission denied")
+}
+
+func TestSurrealDB_Prune(t *testing.T) {
+	var queries []string
+	origExec, origQuery := execQuery, queryNames
+	execQuery = func(db *surrealdb.DB, query string, vars map[string]interface{}) error {
+		assert.Equal(t, []string{"app/old.go"}, vars["gone"])
+		queries = append(queries, query)
+		return nil
+	}
+	// The stored files of two roots, and an external function without a file.
+	queryNames = func(*surrealdb.DB, string, map[string]interface{}) ([]string, error) {
+		return []string{"app/main.go", "app/util.go", "app/old.go", "lib/lib.go", ""}, nil
+	}
+	t.Cleanup(func() { execQuery, queryNames = origExec, origQuery })
+
+	sdb := &SurrealDB{}
+	require.NoError(t, sdb.Prune(context.Background(), []string{"app"}, []string{"app/main.go", "app/util.go"}))
+	require.Len(t, queries, len(nodeEdges))
+
+	for i, node := range nodeEdges {
+		statements := strings.Split(strings.TrimSuffix(queries[i], ";"), ";")
+		require.Len(t, statements, len(node.edges)+2, queries[i])
+		assert.Equal(t, "LET $stale = (SELECT VALUE id FROM "+node.table+" WHERE file INSIDE $gone)", statements[0])
+		// Edges pointing at the stale nodes go before the nodes themselves.
+		for j, edge := range node.edges {
+			table, field, _ := strings.Cut(edge, ".")
+			assert.Equal(t, " DELETE "+table+" WHERE "+field+" INSIDE $stale", statements[j+1])
+		}
+		assert.Equal(t, " DELETE "+node.table+" WHERE id INSIDE $stale", statements[len(statements)-1])
+	}
+
+	// Nothing is deleted when every stored file of the roots is live.
+	queries = nil
+	require.NoError(t, sdb.Prune(context.Background(), []string{"lib"}, []string{"lib/lib.go"}))
+	assert.Empty(t, queries)
+
+	// External functions have no file, so no root holds them.
+	require.NoError(t, sdb.Prune(context.Background(), []string{"."}, []string{"app/main.go", "app/util.go", "app/old.go", "lib/lib.go"}))
+	assert.Empty(t, queries)
+
+	execQuery = func(*surrealdb.DB, string, map[string]interface{}) error {
+		return errors.New("connection lost")
+	}
+	err := sdb.Prune(context.Background(), []string{"app"}, []string{"app/main.go", "app/util.go"})
+	assert.ErrorContains(t, err, "error pruning functions: connection lost")
+
+	// A failed statement fails the prune too.
+	failStatement("LET $stale")
+	err = sdb.Prune(context.Background(), []string{"app"}, []string{"app/main.go", "app/util.go"})
+	assert.EqualError(t, err, "error pruning functions: statement 1 failed: permission denied")
+}
+
+func TestMethodEdges(t *testing.T) {
+	report := types.AnalysisReport{Functions: []types.FunctionCall{
+		{Caller: "Person.Greet", Package: "main", IsMethod: true, Struct: "Person"},
+		{Caller: "*Person.Rename", Package: "main", IsMethod: true, Struct: "Person", PointerReceiver: true},
+		{Caller: "main", Package: "main"},
+	}}
+
+	edges := methodEdges(report)
+	require.Len(t, edges, 2)
+	for _, edge := range edges {
+		assert.Equal(t, models.NewRecordID("structs", "_2e_3amain__Person"), edge["struct"])
+	}
+	assert.Equal(t, models.NewRecordID("functions", "_2e_3amain___2aPerson_2eRename"), edges[1]["function"])
+
+	// Methods of named types other than structs link to their type record.
+	report.Types = []types.TypeDefinition{{Name: "Celsius", Package: "main"}}
+	report.Functions = append(report.Functions, types.FunctionCall{Caller: "Celsius.String", Package: "main", IsMethod: true, Struct: "Celsius"})
+	edges = methodEdges(report)
+	require.Len(t, edges, 3)
+	assert.Equal(t, models.NewRecordID("structs", "_2e_3amain__Person"), edges[0]["struct"])
+	assert.Equal(t, models.NewRecordID("types", "_2e_3amain__Celsius"), edges[2]["struct"])
+}
+
+// fakeStore replaces the record writes of StoreAnalysis and returns the
+// stored node ids and the number of edges per table.
+func fakeStore(t *testing.T) (nodes map[models.RecordID]bool, edges map[string]int) {
+	t.Helper()
+	var mu sync.Mutex
+	nodes, edges = make(map[models.RecordID]bool), make(map[string]int)
+	origUpsert, origCreate, origExec := upsertRecord, createRecord, execQuery
+	upsertRecord = func(_ *surrealdb.DB, id models.RecordID, _ interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(edges) > 0 {
+			t.Errorf("node %v stored after edges", id)
+		}
+		nodes[id] = true
+		return nil
+	}
+	createRecord = func(_ *surrealdb.DB, table string, _ map[string]interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		edges[table]++
+		return nil
+	}
+	execQuery = func(*surrealdb.DB, string, map[string]interface{}) error { return nil }
+	t.Cleanup(func() { upsertRecord, createRecord, execQuery = origUpsert, origCreate, origExec })
+	return nodes, edges
+}
+
+// syntheticReport returns a report of n functions, each calling the next.
+func syntheticReport(n int) types.AnalysisReport {
+	var report types.AnalysisReport
+	for i := range n {
+		report.Functions = append(report.Functions, types.FunctionCall{
+			Caller:  fmt.Sprintf("fn%d", i),
+			Package: "main",
+			File:    "main.go",
+			Callees: []string{fmt.Sprintf("fn%d", (i+1)%n)},
+		})
+	}
+	return report
+}
+
+func TestSurrealDB_StoreAnalysisConcurrently(t *testing.T) {
+	nodes, edges := fakeStore(t)
+	sdb := &SurrealDB{config: Config{Concurrency: 8}}
+	require.NoError(t, sdb.StoreAnalysis(context.Background(), syntheticReport(1000)))
+
+	assert.Len(t, nodes, 1000)
+	for i := range 1000 {
+		assert.True(t, nodes[recordID("functions", ".:main", fmt.Sprintf("fn%d", i))], i)
+	}
+	assert.Equal(t, map[string]int{"calls": 1000}, edges)
+
+	// Failures are reported and stop the store before any edge is created.
+	clear(edges)
+	upsertRecord = func(_ *surrealdb.DB, id models.RecordID, _ interface{}) error {
+		if id == recordID("functions", ".:main", "fn500") {
+			return errors.New("connection lost")
+		}
+		return nil
+	}
+	err := sdb.StoreAnalysis(context.Background(), syntheticReport(1000))
+	assert.ErrorContains(t, err, "error storing function fn500: connection lost")
+	assert.Empty(t, edges)
+}
+
+func TestQueryError(t *testing.T) {
+	assert.NoError(t, queryError[any](nil))
+	assert.NoError(t, queryError(&[]surrealdb.QueryResult[any]{{Status: "OK"}, {Status: "OK"}}))
+
+	err := queryError(&[]surrealdb.QueryResult[any]{{Status: "OK"}, {Status: "ERR", Result: "There was a problem with the database"}})
+	assert.EqualError(t, err, "statement 2 failed: There was a problem with the database")
+	err = queryError(&[]surrealdb.QueryResult[[]string]{{Status: "ERR"}})
+	assert.EqualError(t, err, "statement 1 failed with status ERR")
+}
+
+// failStatement makes execQuery fail, as a failed statement does, on queries
+// starting with prefix.
+func failStatement(prefix string) {
+	execQuery = func(_ *surrealdb.DB, query string, _ map[string]interface{}) error {
+		if !strings.HasPrefix(query, prefix) {
+			return nil
+		}
+		return queryError(&[]surrealdb.QueryResult[any]{{Status: "ERR", Result: "permission denied"}})
+	}
+}
+
+func TestSurrealDB_StoreAnalysisFailedStatement(t *testing.T) {
+	fakeStore(t)
+	for _, tc := range []struct {
+		prefix, want string
+	}{
+		{"DELETE calls;", "error truncating tables: statement 1 failed: permission denied"},
+		{"DELETE calls WHERE", "error deleting stale edges: statement 1 failed: permission denied"},
+		{"LET $stale", "error reconciling functions: statement 1 failed: permission denied"},
+	} {
+		failStatement(tc.prefix)
+		sdb := &SurrealDB{config: Config{Replace: true}}
+		err := sdb.StoreAnalysis(context.Background(), syntheticReport(10))
+		assert.EqualError(t, err, tc.want, tc.prefix)
+	}
+}
+
+func BenchmarkSurrealDB_StoreAnalysis(b *testing.B) {
+	origUpsert, origCreate, origExec := upsertRecord, createRecord, execQuery
+	b.Cleanup(func() { upsertRecord, createRecord, execQuery = origUpsert, origCreate, origExec })
+	upsertRecord = func(*surrealdb.DB, models.RecordID, interface{}) error {
+		time.Sleep(10 * time.Microsecond)
+		return nil
+	}
+	createRecord = func(*surrealdb.DB, string, map[string]interface{}) error {
+		time.Sleep(10 * time.Microsecond)
+		return nil
+	}
+	execQuery = func(*surrealdb.DB, string, map[string]interface{}) error { return nil }
+	report := syntheticReport(1000)
+	for _, concurrency := range []int{1, 8} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			sdb := &SurrealDB{config: Config{Concurrency: concurrency}}
+			for b.Loop() {
+				if err := sdb.StoreAnalysis(context.Background(), report); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordKey(t *testing.T) {
+	valid := regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	keys := map[string]bool{}
+	for _, node := range [][2]string{
+		{"main", "MathOps.Add"},
+		{"main", "*MathOps.Add"},
+		{"main", "MathOps_Add"},
+		{"main_x", "y"},
+		{"main", "x__y"},
+		{"github.com/a/b:pkg", "List[T].Push"},
+		{"main", "run$1"},
+	} {
+		key := RecordKey(node[0], node[1])
+		assert.Regexp(t, valid, key)
+		assert.False(t, keys[key], "duplicate key %s", key)
+		keys[key] = true
+	}
+	assert.Equal(t, "main__MathOps_2eAdd", RecordKey("main", "MathOps.Add"))
+}
+
+func TestSurrealDB_StoreAnalysisMethodCallEdge(t *testing.T) {
+	var mu sync.Mutex
+	nodes := map[models.RecordID]bool{}
+	var calls []map[string]interface{}
+	origUpsert, origCreate, origExec := upsertRecord, createRecord, execQuery
+	upsertRecord = func(_ *surrealdb.DB, id models.RecordID, _ interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		nodes[id] = true
+		return nil
+	}
+	createRecord = func(_ *surrealdb.DB, table string, data map[string]interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if table == "calls" {
+			calls = append(calls, data)
+		}
+		return nil
+	}
+	execQuery = func(*surrealdb.DB, string, map[string]interface{}) error { return nil }
+	t.Cleanup(func() { upsertRecord, createRecord, execQuery = origUpsert, origCreate, origExec })
+
+	report := types.AnalysisReport{Functions: []types.FunctionCall{
+		{Caller: "*Person.Rename", Package: "main", File: "main.go", IsMethod: true, Struct: "Person", PointerReceiver: true},
+		{Caller: "main", Package: "main", File: "main.go", Callees: []string{"*Person.Rename"}},
+	}}
+	require.NoError(t, (&SurrealDB{}).StoreAnalysis(context.Background(), report))
+
+	require.Len(t, calls, 1)
+	to := calls[0]["to"].(models.RecordID)
+	assert.True(t, nodes[to], "call edge points at %v, which was not stored", to)
+	assert.True(t, nodes[calls[0]["from"].(models.RecordID)])
+	assert.Regexp(t, `^[A-Za-z0-9_]+$`, to.ID)
+}
+
+func TestSurrealDB_StoreAnalysisPackageScopes(t *testing.T) {
+	var mu sync.Mutex
+	nodes := make(map[models.RecordID]interface{})
+	var calls, dependencies []map[string]interface{}
+	origUpsert, origCreate, origExec := upsertRecord, createRecord, execQuery
+	upsertRecord = func(_ *surrealdb.DB, id models.RecordID, data interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		nodes[id] = data
+		return nil
+	}
+	createRecord = func(_ *surrealdb.DB, table string, data map[string]interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		switch table {
+		case "calls":
+			calls = append(calls, data)
+		case "dependencies":
+			dependencies = append(dependencies, data)
+		}
+		return nil
+	}
+	execQuery = func(*surrealdb.DB, string, map[string]interface{}) error { return nil }
+	t.Cleanup(func() { upsertRecord, createRecord, execQuery = origUpsert, origCreate, origExec })
+
+	// Two commands, both package main, declare the same functions.
+	report := types.AnalysisReport{
+		Functions: []types.FunctionCall{
+			{Caller: "main", Package: "main", File: "cmd/a/main.go", Callees: []string{"run"}, Dependencies: []string{"fmt"}},
+			{Caller: "run", Package: "main", File: "cmd/a/main.go"},
+			{Caller: "main", Package: "main", File: "cmd/b/main.go", Callees: []string{"run"}},
+			{Caller: "run", Package: "main", File: "cmd/b/run.go"},
+		},
+		Imports: []types.ImportDefinition{
+			{Path: "fmt", File: "cmd/a/main.go", Package: "main"},
+			{Path: "fmt", File: "cmd/b/run.go", Package: "main", IsUnused: true},
+		},
+	}
+	require.NoError(t, new(SurrealDB).StoreAnalysis(context.Background(), report))
+
+	a, b := "cmd/a:main", "cmd/b:main"
+	for _, id := range []models.RecordID{
+		recordID("functions", a, "main"), recordID("functions", a, "run"),
+		recordID("functions", b, "main"), recordID("functions", b, "run"),
+	} {
+		assert.Contains(t, nodes, id)
+	}
+	// Each call stays within the command declaring its caller.
+	require.Len(t, calls, 2)
+	for _, call := range calls {
+		from, to := call["from"].(models.RecordID), call["to"].(models.RecordID)
+		assert.Equal(t, strings.TrimSuffix(from.ID.(string), "main")+"run", to.ID, "call from %v", from)
+	}
+	// Each file keeps its own import record.
+	aFmt, bFmt := recordID("imports", "cmd/a/main.go", "fmt"), recordID("imports", "cmd/b/run.go", "fmt")
+	require.Contains(t, nodes, aFmt)
+	require.Contains(t, nodes, bFmt)
+	assert.False(t, nodes[aFmt].(types.ImportDefinition).IsUnused)
+	assert.True(t, nodes[bFmt].(types.ImportDefinition).IsUnused)
+	require.Len(t, dependencies, 1)
+	assert.Equal(t, aFmt, dependencies[0]["import"])
+}
+
+func TestSurrealDB_StoreAnalysisDeletesGoneFiles(t *testing.T) {
+	var mu sync.Mutex
+	var gone []interface{}
+	origUpsert, origCreate, origExec, origQuery := upsertRecord, createRecord, execQuery, queryNames
+	upsertRecord = func(*surrealdb.DB, models.RecordID, interface{}) error { return nil }
+	createRecord = func(*surrealdb.DB, string, map[string]interface{}) error { return nil }
+	execQuery = func(_ *surrealdb.DB, query string, vars map[string]interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.Contains(query, "file INSIDE $gone") {
+			gone = append(gone, vars["gone"])
+		}
+		return nil
+	}
+	// The stored files of two roots, and an external function without a file.
+	queryNames = func(_ *surrealdb.DB, query string, _ map[string]interface{}) ([]string, error) {
+		assert.Contains(t, query, "SELECT VALUE file FROM functions")
+		return []string{"app/main.go", "app/old.go", "app/broken.go", "app/gone/util.go", "lib/lib.go", "", "app/main.go"}, nil
+	}
+	t.Cleanup(func() {
+		upsertRecord, createRecord, execQuery, queryNames = origUpsert, origCreate, origExec, origQuery
+	})
+
+	report := types.AnalysisReport{
+		Functions:  []types.FunctionCall{{Caller: "main", Package: "main", File: "app/main.go"}},
+		FileErrors: []types.FileError{{Path: "app/broken.go", Error: "syntax error"}},
+	}
+	// Without roots, such as for changed files, nothing else is deleted.
+	require.NoError(t, new(SurrealDB).StoreAnalysis(context.Background(), report))
+	assert.Empty(t, gone)
+
+	report.Roots = []string{"app"}
+	require.NoError(t, new(SurrealDB).StoreAnalysis(context.Background(), report))
+	require.Len(t, gone, len(nodeEdges))
+	for _, files := range gone {
+		assert.Equal(t, []string{"app/gone/util.go", "app/old.go"}, files)
+	}
+}
+
+func TestUnderRoot(t *testing.T) {
+	tests := []struct {
+		file, root string
+		want       bool
+	}{
+		{"main.go", ".", true},
+		{"cmd/tool/main.go", ".", true},
+		{"cmd/tool/main.go", "cmd", true},
+		{"cmd/tool/main.go", "cmd/tool/main.go", true},
+		{"cmdline/main.go", "cmd", false},
+		{"../other/main.go", ".", false},
+		{"/src/app/main.go", "/src/app", true},
+		{"/src/app/main.go", "app", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, underRoot(tt.file, tt.root), "%s under %s", tt.file, tt.root)
+	}
+}
+
+func TestSurrealDB_StoreAnalysisSymbolUses(t *testing.T) {
+	var mu sync.Mutex
+	var uses []map[string]interface{}
+	origUpsert, origCreate, origExec := upsertRecord, createRecord, execQuery
+	upsertRecord = func(*surrealdb.DB, models.RecordID, interface{}) error { return nil }
+	createRecord = func(_ *surrealdb.DB, table string, data map[string]interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if table == "uses_symbol" {
+			uses = append(uses, data)
+		}
+		return nil
+	}
+	execQuery = func(*surrealdb.DB, string, map[string]interface{}) error { return nil }
+	t.Cleanup(func() { upsertRecord, createRecord, execQuery = origUpsert, origCreate, origExec })
+
+	report := types.AnalysisReport{
+		Functions: []types.FunctionCall{{
+			Caller:              "main",
+			Package:             "main",
+			File:                "main.go",
+			Dependencies:        []string{"fmt"},
+			PackageDependencies: []types.PackageDependency{{Caller: "main", ImportPath: "fmt", Symbol: "Println"}},
+		}},
+		Imports: []types.ImportDefinition{{Path: "fmt", Name: "fmt", File: "main.go", Package: "main"}},
+	}
+	require.NoError(t, (&SurrealDB{}).StoreAnalysis(context.Background(), report))
+
+	require.Len(t, uses, 1)
+	assert.Equal(t, recordID("functions", ".:main", "main"), uses[0]["function"])
+	assert.Equal(t, recordID("imports", "main.go", "fmt"), uses[0]["import"])
+	assert.Equal(t, "Println", uses[0]["symbol"])
+}
+
+func TestSurrealDB_StoreAnalysisExternalCalls(t *testing.T) {
+	var mu sync.Mutex
+	nodes := make(map[models.RecordID]map[string]interface{})
+	var calls []map[string]interface{}
+	origUpsert, origCreate, origExec := upsertRecord, createRecord, execQuery
+	upsertRecord = func(_ *surrealdb.DB, id models.RecordID, data interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if m, ok := data.(map[string]interface{}); ok {
+			nodes[id] = m
+		}
+		return nil
+	}
+	createRecord = func(_ *surrealdb.DB, table string, data map[string]interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if table == "calls" {
+			calls = append(calls, data)
+		}
+		return nil
+	}
+	execQuery = func(*surrealdb.DB, string, map[string]interface{}) error { return nil }
+	t.Cleanup(func() { upsertRecord, createRecord, execQuery = origUpsert, origCreate, origExec })
+
+	run := types.FunctionCall{Caller: "run", Package: "main", File: "main.go"}
+	mainFn := types.FunctionCall{Caller: "main", Package: "main", File: "main.go", Callees: []string{"run"}}
+	greet := types.FunctionCall{Caller: "Greet", Package: "lib", File: "lib/lib.go"}
+	printlnID := recordID("functions", "fmt", "Println")
+	greetID := recordID("functions", "lib:lib", "Greet")
+
+	for _, includeExternal := range []bool{false, true} {
+		clear(nodes)
+		calls = nil
+		caller := mainFn
+		if includeExternal {
+			run.ExternalCalls = []types.ExternalCall{{Package: "fmt", Function: "Println"}}
+			// A call to an analyzed package links to its node.
+			caller.ExternalCalls = append(run.ExternalCalls, types.ExternalCall{Package: "example.com/app/lib", Function: "Greet", Dir: "lib", Name: "lib"})
+		}
+		report := types.AnalysisReport{Functions: []types.FunctionCall{caller, run, greet}}
+		require.NoError(t, new(SurrealDB).StoreAnalysis(context.Background(), report))
+
+		// Every call edge points at a stored node.
+		for _, call := range calls {
+			assert.Contains(t, nodes, call["to"])
+		}
+		if !includeExternal {
+			assert.Len(t, calls, 1)
+			assert.NotContains(t, nodes, printlnID)
+			continue
+		}
+		assert.Len(t, calls, 4)
+		require.Contains(t, nodes, printlnID)
+		assert.Equal(t, true, nodes[printlnID]["is_external"])
+		assert.Equal(t, "Println", nodes[printlnID]["caller"])
+		assert.Equal(t, "fmt", nodes[printlnID]["package"])
+		assert.Contains(t, calls, map[string]interface{}{"from": recordID("functions", ".:main", "main"), "to": greetID, "file": "main.go", "package": "main"})
+		assert.NotContains(t, nodes[greetID], "is_external")
+		assert.NotContains(t, nodes, recordID("functions", "example.com/app/lib", "Greet"))
+		assert.Len(t, nodes, 4)
+		assert.Equal(t, 4, new(SurrealDB).PlanStore(report).Functions)
+	}
+}
+
+func TestSurrealDB_StoreAnalysisFields(t *testing.T) {
+	var mu sync.Mutex
+	stored := make(map[models.RecordID]map[string]interface{})
+	origUpsert, origCreate, origExec := upsertRecord, createRecord, execQuery
+	upsertRecord = func(_ *surrealdb.DB, id models.RecordID, data interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if m, ok := data.(map[string]interface{}); ok {
+			stored[id] = m
+		}
+		return nil
+	}
+	createRecord = func(*surrealdb.DB, string, map[string]interface{}) error { return nil }
+	execQuery = func(*surrealdb.DB, string, map[string]interface{}) error { return nil }
+	t.Cleanup(func() { upsertRecord, createRecord, execQuery = origUpsert, origCreate, origExec })
+
+	report := types.AnalysisReport{
+		Functions: []types.FunctionCall{{
+			Caller:    "main",
+			Package:   "main",
+			File:      "main.go",
+			Line:      3,
+			Doc:       "main runs.\n",
+			CallSites: []types.CallSite{{Callee: "run", Line: 4, Col: 2}},
+			Callees:   []string{"run"},
+			Metrics:   types.FunctionMetrics{CyclomaticComplexity: 2},
+		}},
+		Interfaces: []types.InterfaceDefinition{{Name: "Runner", File: "main.go", Package: "main", Doc: "Runner runs.\n"}},
+	}
+	fn, iface := recordID("functions", ".:main", "main"), recordID("interfaces", ".:main", "Runner")
+
+	tests := []struct {
+		fields      string
+		wantMetrics bool
+		wantFull    bool
+	}{
+		{StoreGraph, false, false},
+		{StoreMetrics, true, false},
+		{StoreFull, true, true},
+		{"", true, true},
+	}
+	for _, tt := range tests {
+		clear(stored)
+		sdb := &SurrealDB{config: Config{Fields: tt.fields}}
+		require.NoError(t, sdb.StoreAnalysis(context.Background(), report))
+		require.Contains(t, stored, fn)
+		assert.Equal(t, "main", stored[fn]["caller"], tt.fields)
+		_, hasMetrics := stored[fn]["metrics"]
+		assert.Equal(t, tt.wantMetrics, hasMetrics, tt.fields)
+		for _, field := range []string{"doc", "line", "call_sites"} {
+			_, ok := stored[fn][field]
+			assert.Equal(t, tt.wantFull, ok, "%s: %s", tt.fields, field)
+		}
+		_, hasDoc := stored[iface]["doc"]
+		assert.Equal(t, tt.wantFull, hasDoc, tt.fields)
+	}
+
+	_, err := NewSurrealDB(Config{Fields: "everything"})
+	assert.ErrorContains(t, err, `unknown field set "everything"`)
+}
+
+func TestSurrealDB_PlanStore(t *testing.T) {
+	report := types.AnalysisReport{
+		Functions: []types.FunctionCall{
+			{Caller: "main", Package: "main", Callees: []string{"run", "MathOps.Add"}, Dependencies: []string{"fmt"},
+				PackageDependencies: []types.PackageDependency{{Caller: "main", ImportPath: "fmt", Symbol: "Println"}}},
+			{Caller: "run", Package: "main", ReferencedGlobals: []string{"config", "verbose"}},
+			{Caller: "MathOps.Add", Package: "main", IsMethod: true, Struct: "MathOps"},
+			{Caller: "*MathOps.Reset", Package: "main", IsMethod: true, Struct: "MathOps", PointerReceiver: true},
+		},
+		Structs:    []types.StructDefinition{{Name: "MathOps", Package: "main"}},
+		Interfaces: []types.InterfaceDefinition{{Name: "Calculator", Package: "main"}},
+		Globals:    []types.GlobalVariable{{Name: "config", Package: "main"}, {Name: "verbose", Package: "main"}},
+		// Each file importing a package has an import record of its own.
+		Imports: []types.ImportDefinition{
+			{Path: "fmt", File: "main.go", Package: "main"},
+			{Path: "fmt", File: "run.go", Package: "main"},
+		},
+		Implements: []types.InterfaceImplementation{{Struct: "MathOps", Interface: "Calculator", Package: "main"}},
+	}
+
+	assert.Equal(t, StorePlan{
+		Functions:    4,
+		Structs:      1,
+		Interfaces:   1,
+		Globals:      2,
+		Imports:      2,
+		Calls:        2,
+		Methods:      2,
+		Implements:   1,
+		References:   2,
+		Dependencies: 1,
+		SymbolUses:   1,
+	}, (&SurrealDB{}).PlanStore(report))
+
+	// The plan matches what is stored.
+	var mu sync.Mutex
+	nodes := map[models.RecordID]bool{}
+	edges := map[string]int{}
+	origUpsert, origCreate, origExec := upsertRecord, createRecord, execQuery
+	upsertRecord = func(_ *surrealdb.DB, id models.RecordID, _ interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		nodes[id] = true
+		return nil
+	}
+	createRecord = func(_ *surrealdb.DB, table string, _ map[string]interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		edges[table]++
+		return nil
+	}
+	execQuery = func(*surrealdb.DB, string, map[string]interface{}) error { return nil }
+	t.Cleanup(func() { upsertRecord, createRecord, execQuery = origUpsert, origCreate, origExec })
+	require.NoError(t, (&SurrealDB{}).StoreAnalysis(context.Background(), report))
+
+	plan := (&SurrealDB{}).PlanStore(report)
+	assert.Equal(t, plan.Functions+plan.Structs+plan.Interfaces+plan.Globals+plan.Imports, len(nodes))
+	assert.Equal(t, map[string]int{
+		"calls":        plan.Calls,
+		"methods":      plan.Methods,
+		"implements":   plan.Implements,
+		"references":   plan.References,
+		"dependencies": plan.Dependencies,
+		"uses_symbol":  plan.SymbolUses,
+	}, edges)
+}
@@ -3,17 +3,25 @@ package expr
 import (
 	"fmt"
 	"go/ast"
+	"go/types"
+	"hash"
+	"hash/fnv"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/golang/groupcache/lru"
 )
 
-// ExprCache caches the string representation of AST expressions.
+// ExprCache caches the string representation of AST expressions. Entries
+// are keyed by the structure of the expression rather than by node pointer,
+// so identical expressions from different files or parses share an entry.
 type ExprCache struct {
 	cache *lru.Cache
-	mu    sync.RWMutex // Mutex for thread safety
+	mu    sync.Mutex // lru.Cache reorders entries even on Get
+
+	hits, misses atomic.Uint64
 }
 
 // NewExprCache creates a new ExprCache of the given size and registers a cleanup function.
@@ -24,7 +32,6 @@ func NewExprCache(size int) *ExprCache {
 
 	// Register a cleanup function that clears the cache when ec is garbage collected.
 	runtime.AddCleanup(ec, func(c *lru.Cache) {
-		fmt.Println("Cleaning up ExprCache: clearing underlying cache")
 		c.Clear()
 	}, ec.cache)
 
@@ -33,42 +40,48 @@ func NewExprCache(size int) *ExprCache {
 
 // Get returns the cached string for the given expression, if available.
 func (c *ExprCache) Get(expr ast.Expr) (string, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	if val, ok := c.cache.Get(expr); ok {
+	return c.get(structuralKey(expr))
+}
+
+// Put adds the string representation for an expression into the cache.
+func (c *ExprCache) Put(expr ast.Expr, str string) {
+	c.put(structuralKey(expr), str)
+}
+
+// Stats returns how many ToString calls were served from the cache and how
+// many had to compute the string.
+func (c *ExprCache) Stats() (hits, misses uint64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+func (c *ExprCache) get(key uint64) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if val, ok := c.cache.Get(key); ok {
 		return val.(string), true
 	}
 	return "", false
 }
 
-// Put adds the string representation for an expression into the cache.
-func (c *ExprCache) Put(expr ast.Expr, str string) {
+func (c *ExprCache) put(key uint64, str string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.cache.Add(expr, str)
+	c.cache.Add(key, str)
 }
 
 // ToString returns the string representation for the given AST expression,
 // using the cache to avoid redundant computations.
 func (c *ExprCache) ToString(expr ast.Expr) string {
-	// Try reading with a read lock first.
-	c.mu.RLock()
-	if val, ok := c.cache.Get(expr); ok {
-		c.mu.RUnlock()
-		return val.(string)
+	key := structuralKey(expr)
+	if val, ok := c.get(key); ok {
+		c.hits.Add(1)
+		return val
 	}
-	c.mu.RUnlock()
+	c.misses.Add(1)
 
-	// If not found, acquire a write lock and compute the string.
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	// Double-check in case another goroutine computed it meanwhile.
-	if val, ok := c.cache.Get(expr); ok {
-		return val.(string)
-	}
-
-	// Compute string representation based on the expression type.
+	// Compute the string without holding the lock; children go through the
+	// cache themselves. Concurrent callers may compute the same string, which
+	// is harmless.
 	var result string
 	switch e := expr.(type) {
 	case *ast.Ident:
@@ -99,28 +112,67 @@ func (c *ExprCache) ToString(expr ast.Expr) string {
 		if len(results) > 0 {
 			result += " (" + strings.Join(results, ", ") + ")"
 		}
-	case *ast.InterfaceType:
-		methods := make([]string, 0, len(e.Methods.List))
-		for _, m := range e.Methods.List {
-			methods = append(methods, c.ToString(m.Type))
-		}
-		result = "interface{" + strings.Join(methods, "; ") + "}"
-	case *ast.StructType:
-		fields := make([]string, 0, len(e.Fields.List))
-		for _, f := range e.Fields.List {
-			fields = append(fields, c.ToString(f.Type))
-		}
-		result = "struct{" + strings.Join(fields, "; ") + "}"
+	case *ast.InterfaceType, *ast.StructType:
+		// Anonymous types are written with their field and method names, as
+		// go/types does, so that they read like the source.
+		result = types.ExprString(e)
 	case *ast.BasicLit:
 		result = e.Value
 	default:
 		result = fmt.Sprintf("<%T>", expr)
 	}
 
-	c.cache.Add(expr, result)
+	c.put(key, result)
 	return result
 }
 
+// structuralKey hashes the kind, names and children of expr, covering
+// everything ToString renders.
+func structuralKey(expr ast.Expr) uint64 {
+	h := fnv.New64a()
+	writeStructure(h, expr)
+	return h.Sum64()
+}
+
+func writeStructure(h hash.Hash64, expr ast.Expr) {
+	fmt.Fprintf(h, "%T(", expr)
+	switch e := expr.(type) {
+	case *ast.Ident:
+		h.Write([]byte(e.Name))
+	case *ast.StarExpr:
+		writeStructure(h, e.X)
+	case *ast.SelectorExpr:
+		writeStructure(h, e.X)
+		h.Write([]byte("." + e.Sel.Name))
+	case *ast.ArrayType:
+		writeStructure(h, e.Elt)
+	case *ast.MapType:
+		writeStructure(h, e.Key)
+		writeStructure(h, e.Value)
+	case *ast.ChanType:
+		writeStructure(h, e.Value)
+	case *ast.FuncType:
+		writeFields(h, e.Params)
+		h.Write([]byte("->"))
+		writeFields(h, e.Results)
+	case *ast.InterfaceType, *ast.StructType:
+		h.Write([]byte(types.ExprString(e)))
+	case *ast.BasicLit:
+		h.Write([]byte(e.Value))
+	}
+	h.Write([]byte(")"))
+}
+
+func writeFields(h hash.Hash64, fields *ast.FieldList) {
+	if fields == nil {
+		return
+	}
+	for _, f := range fields.List {
+		writeStructure(h, f.Type)
+		h.Write([]byte(","))
+	}
+}
+
 // Clear clears the cache.
 func (c *ExprCache) Clear() {
 	c.mu.Lock()
@@ -0,0 +1,94 @@
+package expr_test
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/TFMV/surrealcode/expr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// paramTypes parses src and returns the parameter types of its functions.
+func paramTypes(tb testing.TB, src string) []ast.Expr {
+	tb.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "src.go", src, 0)
+	require.NoError(tb, err)
+	var types []ast.Expr
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			for _, param := range fn.Type.Params.List {
+				types = append(types, param.Type)
+			}
+		}
+	}
+	return types
+}
+
+func TestExprCache_StructuralKeys(t *testing.T) {
+	cache := expr.NewExprCache(100)
+	src := `package p
+		func a(ctx context.Context, m map[string][]*int, f func(int) error) {}`
+
+	first := paramTypes(t, src)
+	second := paramTypes(t, src)
+	for i := range first {
+		assert.Equal(t, cache.ToString(first[i]), cache.ToString(second[i]))
+	}
+	assert.Equal(t, "context.Context", cache.ToString(first[0]))
+	assert.Equal(t, "map[string][]*int", cache.ToString(first[1]))
+	assert.Equal(t, "func(int) (error)", cache.ToString(first[2]))
+
+	// The second parse is served entirely from the cache.
+	hits, _ := cache.Stats()
+	assert.GreaterOrEqual(t, hits, uint64(len(second)))
+
+	// Structurally different expressions do not share an entry.
+	other := paramTypes(t, `package p
+		func b(ctx context.Context, m map[string][]int) {}`)
+	assert.Equal(t, "map[string][]int", cache.ToString(other[1]))
+}
+
+func TestExprCache_AnonymousTypes(t *testing.T) {
+	cache := expr.NewExprCache(100)
+	params := paramTypes(t, `package p
+		func a(p struct{ X, Y int }, q struct {
+			A int
+			B int
+		}, s interface{ String() string }) {}`)
+	assert.Equal(t, "struct{X, Y int}", cache.ToString(params[0]))
+	assert.Equal(t, "struct{A int; B int}", cache.ToString(params[1]))
+	assert.Equal(t, "interface{String() string}", cache.ToString(params[2]))
+}
+
+// BenchmarkExprCache_ContextParams simulates a repository where most
+// functions take a context.Context, each file being parsed separately.
+func BenchmarkExprCache_ContextParams(b *testing.B) {
+	var src strings.Builder
+	src.WriteString("package p\n")
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&src, "func f%d(ctx context.Context, id string, opts ...Option) error { return nil }\n", i)
+	}
+	files := make([][]ast.Expr, 20)
+	for i := range files {
+		files[i] = paramTypes(b, src.String())
+	}
+
+	cache := expr.NewExprCache(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, types := range files {
+			for _, typ := range types {
+				cache.ToString(typ)
+			}
+		}
+	}
+	b.StopTimer()
+
+	hits, misses := cache.Stats()
+	b.ReportMetric(float64(hits)/float64(hits+misses)*100, "hit%")
+}
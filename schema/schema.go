@@ -7,6 +7,10 @@ import (
 	surrealdb "github.com/surrealdb/surrealdb.go"
 )
 
+// Version is the version of Schema, raised whenever tables or fields change
+// in a way older data does not fit.
+const Version = 6
+
 // Schema contains all SurrealDB schema definitions
 const Schema = `
 -- Functions table (nodes)
@@ -16,15 +20,50 @@ DEFINE FIELD file ON functions TYPE string;
 DEFINE FIELD package ON functions TYPE string ASSERT $value != NONE;
 DEFINE FIELD params ON functions TYPE array;
 DEFINE FIELD returns ON functions TYPE array;
+DEFINE FIELD param_count ON functions TYPE int;
+DEFINE FIELD return_count ON functions TYPE int;
+DEFINE FIELD return_names ON functions TYPE option<array>;
+DEFINE FIELD is_variadic ON functions TYPE bool;
 DEFINE FIELD is_method ON functions TYPE bool;
+DEFINE FIELD pointer_receiver ON functions TYPE bool;
 DEFINE FIELD struct ON functions TYPE option<string>;
 DEFINE FIELD is_recursive ON functions TYPE bool;
-DEFINE FIELD metrics ON functions TYPE object {
+DEFINE FIELD recursion_group_id ON functions TYPE option<int>;
+DEFINE FIELD is_closure ON functions TYPE bool;
+DEFINE FIELD is_test ON functions TYPE bool;
+DEFINE FIELD captures ON functions TYPE option<array>;
+DEFINE FIELD call_sites ON functions TYPE option<array>;
+DEFINE FIELD is_duplicate ON functions TYPE bool;
+DEFINE FIELD is_interface ON functions TYPE bool;
+DEFINE FIELD is_struct ON functions TYPE bool;
+DEFINE FIELD is_global ON functions TYPE bool;
+DEFINE FIELD has_naked_return ON functions TYPE bool;
+DEFINE FIELD ignores_errors ON functions TYPE bool;
+DEFINE FIELD is_empty ON functions TYPE bool;
+DEFINE FIELD uses_anonymous_types ON functions TYPE bool;
+DEFINE FIELD has_goroutines ON functions TYPE bool;
+DEFINE FIELD unguarded_global_writes ON functions TYPE option<array<string>>;
+DEFINE FIELD errors_ignored_by ON functions TYPE option<array<string>>;
+DEFINE FIELD line ON functions TYPE option<int>;
+DEFINE FIELD body_hash ON functions TYPE option<string>;
+DEFINE FIELD doc ON functions TYPE option<string>;
+DEFINE FIELD is_external ON functions TYPE option<bool>;
+DEFINE FIELD owners ON functions TYPE option<array<string>>;
+DEFINE FIELD labels ON functions TYPE option<array<string>>;
+DEFINE FIELD ignored ON functions TYPE option<bool>;
+DEFINE FIELD ignored_checks ON functions TYPE option<array<string>>;
+DEFINE FIELD metrics ON functions TYPE option<object {
     cyclomatic_complexity: int,
     lines_of_code: int,
-    is_duplicate: bool,
+    sloc: int,
+    statement_count: int,
     is_unused: bool,
+    is_hotspot: bool,
     halstead_metrics: {
+        operators: int,
+        operands: int,
+        unique_operators: int,
+        unique_operands: int,
         volume: float,
         difficulty: float,
         effort: float
@@ -38,10 +77,15 @@ DEFINE FIELD metrics ON functions TYPE object {
     readability: {
         nesting_depth: int,
         comment_density: float,
-        branch_density: float
+        branch_density: float,
+        num_loops: int,
+        num_switches: int,
+        num_selects: int
     },
-    maintainability: float
-};
+    maintainability_index: float,
+    maintainability_raw: float,
+    centrality: float
+}>;
 DEFINE FIELD created_at ON functions TYPE datetime DEFAULT time::now();
 DEFINE FIELD updated_at ON functions TYPE datetime DEFAULT time::now();
 DEFINE INDEX function_name ON functions FIELDS package, caller;
@@ -59,11 +103,26 @@ DEFINE TABLE structs SCHEMAFULL;
 DEFINE FIELD name ON structs TYPE string ASSERT $value != NONE;
 DEFINE FIELD file ON structs TYPE string;
 DEFINE FIELD package ON structs TYPE string ASSERT $value != NONE;
+DEFINE FIELD methods ON structs TYPE array;
+DEFINE FIELD doc ON structs TYPE option<string>;
+DEFINE FIELD field_count ON structs TYPE int;
+DEFINE FIELD size_bytes ON structs TYPE option<int>;
 DEFINE INDEX struct_name ON structs FIELDS package, name;
 
--- Methods relation (edges: struct-to-function)
+-- Types table (named types other than structs and interfaces)
+DEFINE TABLE types SCHEMAFULL;
+DEFINE FIELD name ON types TYPE string ASSERT $value != NONE;
+DEFINE FIELD underlying ON types TYPE string;
+DEFINE FIELD is_alias ON types TYPE bool;
+DEFINE FIELD file ON types TYPE string;
+DEFINE FIELD package ON types TYPE string ASSERT $value != NONE;
+DEFINE FIELD methods ON types TYPE array;
+DEFINE INDEX type_name ON types FIELDS package, name;
+
+-- Methods relation (edges: struct-to-function, or type-to-function for
+-- methods of other named types)
 DEFINE TABLE methods SCHEMAFULL;
-DEFINE FIELD struct ON methods TYPE record<structs> ASSERT $value != NONE;
+DEFINE FIELD struct ON methods TYPE record<structs | types> ASSERT $value != NONE;
 DEFINE FIELD function ON methods TYPE record<functions> ASSERT $value != NONE;
 
 -- Interfaces table
@@ -72,6 +131,7 @@ DEFINE FIELD name ON interfaces TYPE string ASSERT $value != NONE;
 DEFINE FIELD methods ON interfaces TYPE array;
 DEFINE FIELD file ON interfaces TYPE string;
 DEFINE FIELD package ON interfaces TYPE string ASSERT $value != NONE;
+DEFINE FIELD doc ON interfaces TYPE option<string>;
 DEFINE INDEX interface_name ON interfaces FIELDS package, name;
 
 -- Interface implementations
@@ -86,6 +146,8 @@ DEFINE FIELD type ON globals TYPE string;
 DEFINE FIELD value ON globals TYPE option<string>;
 DEFINE FIELD file ON globals TYPE string;
 DEFINE FIELD package ON globals TYPE string ASSERT $value != NONE;
+DEFINE FIELD is_const ON globals TYPE bool;
+DEFINE FIELD is_unused ON globals TYPE bool;
 DEFINE INDEX global_name ON globals FIELDS package, name;
 
 -- References table (edges: function-to-global relationships)
@@ -96,13 +158,79 @@ DEFINE FIELD global ON references TYPE record<globals> ASSERT $value != NONE;
 -- Imports table
 DEFINE TABLE imports SCHEMAFULL;
 DEFINE FIELD path ON imports TYPE string ASSERT $value != NONE;
+DEFINE FIELD name ON imports TYPE string;
 DEFINE FIELD file ON imports TYPE string;
 DEFINE FIELD package ON imports TYPE string ASSERT $value != NONE;
+DEFINE FIELD import_kind ON imports TYPE string;
+DEFINE FIELD is_unused ON imports TYPE bool;
 
 -- Dependencies table (edges: function-to-import relationships)
 DEFINE TABLE dependencies SCHEMAFULL;
 DEFINE FIELD function ON dependencies TYPE record<functions> ASSERT $value != NONE;
 DEFINE FIELD import ON dependencies TYPE record<imports> ASSERT $value != NONE;
+
+-- Uses symbol table (edges: function-to-import calls of a package's symbols)
+DEFINE TABLE uses_symbol SCHEMAFULL;
+DEFINE FIELD function ON uses_symbol TYPE record<functions> ASSERT $value != NONE;
+DEFINE FIELD import ON uses_symbol TYPE record<imports> ASSERT $value != NONE;
+DEFINE FIELD symbol ON uses_symbol TYPE string ASSERT $value != NONE;
+
+-- Snapshots table (aggregate metrics of each recorded run)
+DEFINE TABLE snapshots SCHEMAFULL;
+DEFINE FIELD commit ON snapshots TYPE string;
+DEFINE FIELD time ON snapshots TYPE datetime;
+DEFINE FIELD functions ON snapshots TYPE int;
+DEFINE FIELD lines_of_code ON snapshots TYPE int;
+DEFINE FIELD avg_complexity ON snapshots TYPE float;
+DEFINE FIELD avg_maintainability ON snapshots TYPE float;
+DEFINE FIELD health_score ON snapshots TYPE float;
+
+-- History table (metrics of each function in each recorded run)
+DEFINE TABLE history SCHEMAFULL;
+DEFINE FIELD snapshot ON history TYPE record<snapshots> ASSERT $value != NONE;
+DEFINE FIELD commit ON history TYPE string;
+DEFINE FIELD time ON history TYPE datetime;
+DEFINE FIELD function ON history TYPE string ASSERT $value != NONE;
+DEFINE FIELD package ON history TYPE string;
+DEFINE FIELD file ON history TYPE string;
+DEFINE FIELD metrics ON history FLEXIBLE TYPE object;
+DEFINE INDEX history_function ON history FIELDS package, function, time;
+`
+
+// SQLiteSchema contains the table definitions used by the SQLite backend
+const SQLiteSchema = `
+CREATE TABLE IF NOT EXISTS functions (
+    id INTEGER PRIMARY KEY,
+    caller TEXT NOT NULL,
+    file TEXT,
+    package TEXT NOT NULL,
+    is_method BOOLEAN,
+    struct TEXT,
+    is_recursive BOOLEAN,
+    cyclomatic_complexity INTEGER,
+    lines_of_code INTEGER,
+    maintainability REAL,
+    metrics TEXT
+);
+CREATE INDEX IF NOT EXISTS function_name ON functions (package, caller);
+
+CREATE TABLE IF NOT EXISTS calls (
+    id INTEGER PRIMARY KEY,
+    caller TEXT NOT NULL,
+    callee TEXT NOT NULL,
+    file TEXT,
+    package TEXT
+);
+CREATE INDEX IF NOT EXISTS call_relation ON calls (caller, callee);
+
+CREATE TABLE IF NOT EXISTS structs (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    file TEXT,
+    package TEXT NOT NULL,
+    methods TEXT
+);
+CREATE INDEX IF NOT EXISTS struct_name ON structs (package, name);
 `
 
 // InitializeSchema sets up the database schema
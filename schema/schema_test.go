@@ -0,0 +1,118 @@
+package schema_test
+
+import (
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/TFMV/surrealcode/schema"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var defineField = regexp.MustCompile(`(?s)DEFINE FIELD (\w+) ON (\w+) TYPE (.*?);`)
+
+// schemaFields maps every table to its fields and their SurrealQL types.
+func schemaFields(t *testing.T) map[string]map[string]string {
+	t.Helper()
+	tables := make(map[string]map[string]string)
+	for _, m := range defineField.FindAllStringSubmatch(schema.Schema, -1) {
+		field, table, typ := m[1], m[2], m[3]
+		if tables[table] == nil {
+			tables[table] = make(map[string]string)
+		}
+		tables[table][field] = typ
+	}
+	require.NotEmpty(t, tables)
+	return tables
+}
+
+// jsonFields returns the JSON field names of struct type t.
+func jsonFields(t reflect.Type, skip ...string) []string {
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" && name != "id" && !slices.Contains(skip, name) {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// objectKeys parses the keys of a SurrealQL object type literal such as
+// `object { a: int, b: { c: float } }`, qualifying nested keys as b.c.
+func objectKeys(literal string) []string {
+	var keys, path []string
+	var key string
+	for _, tok := range regexp.MustCompile(`\w+\s*:|[{}]`).FindAllString(literal, -1) {
+		switch tok {
+		case "{":
+			if key != "" {
+				path = append(path, key)
+				key = ""
+			} else if len(path) == 0 {
+				path = append(path, "")
+			}
+		case "}":
+			path = path[:len(path)-1]
+		default:
+			key = strings.TrimSpace(strings.TrimSuffix(tok, ":"))
+			keys = append(keys, strings.TrimPrefix(strings.Join(append(path[1:], key), "."), "."))
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// nestedJSONFields returns the JSON field names of t, descending into struct
+// fields and qualifying their names as parent.child.
+func nestedJSONFields(t reflect.Type) []string {
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		fields = append(fields, name)
+		if t.Field(i).Type.Kind() == reflect.Struct {
+			for _, child := range nestedJSONFields(t.Field(i).Type) {
+				fields = append(fields, name+"."+child)
+			}
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// TestSchemaMatchesTypes asserts that every field stored for a node is
+// defined on its SCHEMAFULL table, so SurrealDB does not drop it.
+func TestSchemaMatchesTypes(t *testing.T) {
+	tables := schemaFields(t)
+
+	tests := []struct {
+		table string
+		typ   reflect.Type
+		skip  []string // fields stored as edges rather than on the node
+	}{
+		{"functions", reflect.TypeOf(types.FunctionCall{}), []string{"callees", "referenced_globals", "dependencies", "package_dependencies", "external_calls"}},
+		{"structs", reflect.TypeOf(types.StructDefinition{}), nil},
+		{"interfaces", reflect.TypeOf(types.InterfaceDefinition{}), nil},
+		{"types", reflect.TypeOf(types.TypeDefinition{}), nil},
+		{"globals", reflect.TypeOf(types.GlobalVariable{}), nil},
+		{"imports", reflect.TypeOf(types.ImportDefinition{}), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.table, func(t *testing.T) {
+			require.Contains(t, tables, tt.table)
+			for _, field := range jsonFields(tt.typ, tt.skip...) {
+				assert.Contains(t, tables[tt.table], field, "%s.%s is not defined in the schema", tt.table, field)
+			}
+		})
+	}
+
+	t.Run("functions.metrics", func(t *testing.T) {
+		assert.Equal(t, nestedJSONFields(reflect.TypeOf(types.FunctionMetrics{})), objectKeys(tables["functions"]["metrics"]))
+	})
+}
@@ -0,0 +1,145 @@
+// Package server exposes code analysis as a JSON API over HTTP.
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/types"
+)
+
+// Server serves the analysis of the most recently analyzed directory.
+type Server struct {
+	analyzer *analysis.Analyzer
+	mux      *http.ServeMux
+
+	// analyzeMu serializes analyses, which update the analyzer's report.
+	analyzeMu sync.Mutex
+	mu        sync.RWMutex
+	report    types.AnalysisReport
+	analyzed  bool
+}
+
+// AnalyzeRequest is the body of a POST /analyze request.
+type AnalyzeRequest struct {
+	Dir string `json:"dir"`
+}
+
+// FunctionResponse describes a single function together with its edges. The
+// outgoing edges are part of the function itself; Callers lists the incoming
+// call edges.
+type FunctionResponse struct {
+	Function types.FunctionCall `json:"function"`
+	Callers  []string           `json:"callers"`
+}
+
+// New creates a server backed by analyzer.
+func New(analyzer *analysis.Analyzer) *Server {
+	s := &Server{
+		analyzer: analyzer,
+		mux:      http.NewServeMux(),
+	}
+	s.mux.HandleFunc("POST /analyze", s.handleAnalyze)
+	s.mux.HandleFunc("GET /summary", s.handleSummary)
+	s.mux.HandleFunc("GET /function/{name}", s.handleFunction)
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	var req AnalyzeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if req.Dir == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("missing dir"))
+		return
+	}
+
+	s.analyzeMu.Lock()
+	report, err := s.analyzer.GetAnalysis(r.Context(), req.Dir)
+	s.analyzeMu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to analyze %s: %w", req.Dir, err))
+		return
+	}
+
+	s.mu.Lock()
+	s.report = report
+	s.analyzed = true
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) handleSummary(w http.ResponseWriter, r *http.Request) {
+	report, ok := s.currentReport(w)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.analyzer.GenerateCodeSummary(report))
+}
+
+func (s *Server) handleFunction(w http.ResponseWriter, r *http.Request) {
+	report, ok := s.currentReport(w)
+	if !ok {
+		return
+	}
+
+	name := r.PathValue("name")
+	idx := slices.IndexFunc(report.Functions, func(fn types.FunctionCall) bool {
+		return fn.Caller == name
+	})
+	if idx < 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("function %s not found", name))
+		return
+	}
+
+	resp := FunctionResponse{
+		Function: report.Functions[idx],
+		Callers:  []string{},
+	}
+	for _, fn := range report.Functions {
+		if slices.Contains(fn.Callees, name) {
+			resp.Callers = append(resp.Callers, fn.Caller)
+		}
+	}
+	slices.Sort(resp.Callers)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// currentReport returns the latest report, or writes an error if nothing has
+// been analyzed yet.
+func (s *Server) currentReport(w http.ResponseWriter) (types.AnalysisReport, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.analyzed {
+		writeError(w, http.StatusConflict, fmt.Errorf("no analysis available; POST /analyze first"))
+		return types.AnalysisReport{}, false
+	}
+	return s.report, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,67 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TFMV/surrealcode/analysis"
+	"github.com/TFMV/surrealcode/server"
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	src := `package main
+		func main() { helper() }
+		func helper() {}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(src), 0644))
+
+	srv := httptest.NewServer(server.New(analysis.NewAnalyzerWithoutDB()))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/summary")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	body, err := json.Marshal(server.AnalyzeRequest{Dir: dir})
+	require.NoError(t, err)
+	resp, err = http.Post(srv.URL+"/analyze", "application/json", strings.NewReader(string(body)))
+	require.NoError(t, err)
+	var report types.AnalysisReport
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, report.Functions, 2)
+
+	resp, err = http.Get(srv.URL + "/summary")
+	require.NoError(t, err)
+	var summary types.CodeSummary
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&summary))
+	resp.Body.Close()
+	assert.Equal(t, 2, summary.TotalFunctions)
+
+	resp, err = http.Get(srv.URL + "/function/helper")
+	require.NoError(t, err)
+	var fn server.FunctionResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&fn))
+	resp.Body.Close()
+	assert.Equal(t, "helper", fn.Function.Caller)
+
+	resp, err = http.Get(srv.URL + "/function/missing")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Post(srv.URL+"/analyze", "application/json", strings.NewReader("{}"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,173 @@
+package types
+
+import (
+	"cmp"
+	"maps"
+	"path/filepath"
+	"slices"
+)
+
+// CallGraph is a directed graph with an edge from each function to each
+// function it calls. Calls to functions outside the graph are left out.
+type CallGraph struct {
+	nodes        []string
+	successors   map[string][]string
+	predecessors map[string][]string
+}
+
+// NewCallGraph returns the call graph of functions, keyed by the names of
+// the nodes, with the Callees of each function as its successors.
+func NewCallGraph(functions map[string]FunctionCall) *CallGraph {
+	g := &CallGraph{
+		nodes:        slices.Sorted(maps.Keys(functions)),
+		successors:   make(map[string][]string, len(functions)),
+		predecessors: make(map[string][]string, len(functions)),
+	}
+	for _, name := range g.nodes {
+		for _, callee := range functions[name].Callees {
+			if _, ok := functions[callee]; !ok || slices.Contains(g.successors[name], callee) {
+				continue
+			}
+			g.successors[name] = append(g.successors[name], callee)
+			g.predecessors[callee] = append(g.predecessors[callee], name)
+		}
+	}
+	return g
+}
+
+// CallGraph returns the call graph of the report's functions, named
+// package.Caller like RecursionGroups. Callees are unqualified, so each
+// function calls functions of its own package directory only. Packages of
+// the same name in different directories, such as several main packages,
+// are told apart by their directory, as in cmd/tool:main.run.
+func (r AnalysisReport) CallGraph() *CallGraph {
+	declared := make(map[string]bool, len(r.Functions))
+	dirs := make(map[string][]string)
+	for _, fn := range r.Functions {
+		dir := filepath.Dir(fn.File)
+		declared[dir+":"+fn.Package+"."+fn.Caller] = true
+		if !slices.Contains(dirs[fn.Package], dir) {
+			dirs[fn.Package] = append(dirs[fn.Package], dir)
+		}
+	}
+	node := func(fn FunctionCall, name string) string {
+		if len(dirs[fn.Package]) > 1 {
+			return filepath.Dir(fn.File) + ":" + fn.Package + "." + name
+		}
+		return fn.Package + "." + name
+	}
+	functions := make(map[string]FunctionCall, len(r.Functions))
+	for _, fn := range r.Functions {
+		qualified := fn
+		qualified.Callees = nil
+		for _, callee := range fn.Callees {
+			if declared[filepath.Dir(fn.File)+":"+fn.Package+"."+callee] {
+				qualified.Callees = append(qualified.Callees, node(fn, callee))
+			}
+		}
+		functions[node(fn, fn.Caller)] = qualified
+	}
+	return NewCallGraph(functions)
+}
+
+// Nodes returns the names of the functions of the graph, sorted.
+func (g *CallGraph) Nodes() []string {
+	return slices.Clone(g.nodes)
+}
+
+// Edges returns every call of the graph as a caller and callee pair, sorted
+// by caller and then in call order.
+func (g *CallGraph) Edges() [][2]string {
+	var edges [][2]string
+	for _, name := range g.nodes {
+		for _, callee := range g.successors[name] {
+			edges = append(edges, [2]string{name, callee})
+		}
+	}
+	return edges
+}
+
+// Successors returns the functions n calls, in call order.
+func (g *CallGraph) Successors(n string) []string {
+	return slices.Clone(g.successors[n])
+}
+
+// Predecessors returns the functions calling n, sorted.
+func (g *CallGraph) Predecessors(n string) []string {
+	return slices.Clone(g.predecessors[n])
+}
+
+// SCCs returns the strongly connected components of the graph, found with
+// Tarjan's algorithm: the largest sets of functions that all reach each
+// other through calls. Every function is in exactly one component, of its
+// own if it is not part of a cycle. Components are sorted, and ordered by
+// their first function. The depth-first search keeps its own stack, so
+// call chains of any length are safe.
+func (g *CallGraph) SCCs() [][]string {
+	type node struct {
+		index, lowlink int
+		onStack        bool
+	}
+	// A frame is a function being searched and the index of its next
+	// successor to visit.
+	type frame struct {
+		name string
+		next int
+	}
+	var components [][]string
+	nodes := make(map[string]*node, len(g.nodes))
+	var stack []string
+	var search []frame
+	enter := func(name string) {
+		nodes[name] = &node{index: len(nodes), lowlink: len(nodes), onStack: true}
+		stack = append(stack, name)
+		search = append(search, frame{name: name})
+	}
+
+	for _, root := range g.nodes {
+		if _, visited := nodes[root]; visited {
+			continue
+		}
+		enter(root)
+		for len(search) > 0 {
+			top := &search[len(search)-1]
+			n := nodes[top.name]
+			if successors := g.successors[top.name]; top.next < len(successors) {
+				next := successors[top.next]
+				top.next++
+				if m, visited := nodes[next]; !visited {
+					enter(next)
+				} else if m.onStack {
+					n.lowlink = min(n.lowlink, m.index)
+				}
+				continue
+			}
+
+			name := top.name
+			search = search[:len(search)-1]
+			if len(search) > 0 {
+				parent := nodes[search[len(search)-1].name]
+				parent.lowlink = min(parent.lowlink, n.lowlink)
+			}
+			if n.lowlink != n.index {
+				continue
+			}
+			var component []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				nodes[top].onStack = false
+				component = append(component, top)
+				if top == name {
+					break
+				}
+			}
+			slices.Sort(component)
+			components = append(components, component)
+		}
+	}
+	slices.SortFunc(components, func(a, b []string) int {
+		return cmp.Compare(a[0], b[0])
+	})
+	return components
+}
@@ -0,0 +1,49 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallGraph(t *testing.T) {
+	graph := types.NewCallGraph(map[string]types.FunctionCall{
+		"main":  {Caller: "main", Callees: []string{"parse", "run", "parse", "fmt.Println"}},
+		"parse": {Caller: "parse", Callees: []string{"expr"}},
+		"expr":  {Caller: "expr", Callees: []string{"term"}},
+		"term":  {Caller: "term", Callees: []string{"expr", "term"}},
+		"run":   {Caller: "run", Callees: []string{"expr"}},
+	})
+
+	assert.Equal(t, []string{"expr", "main", "parse", "run", "term"}, graph.Nodes())
+	assert.Equal(t, []string{"parse", "run"}, graph.Successors("main"), "calls outside the graph are left out")
+	assert.Equal(t, []string{"parse", "run", "term"}, graph.Predecessors("expr"))
+	assert.Empty(t, graph.Predecessors("main"))
+	assert.Empty(t, graph.Successors("missing"))
+	assert.Equal(t, [][2]string{
+		{"expr", "term"},
+		{"main", "parse"}, {"main", "run"},
+		{"parse", "expr"},
+		{"run", "expr"},
+		{"term", "expr"}, {"term", "term"},
+	}, graph.Edges())
+	assert.Equal(t, [][]string{{"expr", "term"}, {"main"}, {"parse"}, {"run"}}, graph.SCCs())
+}
+
+func TestAnalysisReport_CallGraph(t *testing.T) {
+	report := types.AnalysisReport{Functions: []types.FunctionCall{
+		{Caller: "main", Package: "main", File: "cmd/a/main.go", Callees: []string{"run"}},
+		{Caller: "run", Package: "main", File: "cmd/a/run.go", Callees: []string{"main"}},
+		{Caller: "main", Package: "main", File: "cmd/b/main.go", Callees: []string{"helper"}},
+		{Caller: "helper", Package: "main", File: "cmd/b/main.go", Callees: []string{"main"}},
+		{Caller: "Greet", Package: "lib", File: "lib/lib.go", Callees: []string{"main"}},
+	}}
+	graph := report.CallGraph()
+
+	// Only the main packages share a name, so only they are named by
+	// directory.
+	assert.Equal(t, []string{"cmd/a:main.main", "cmd/a:main.run", "cmd/b:main.helper", "cmd/b:main.main", "lib.Greet"}, graph.Nodes())
+	assert.Equal(t, []string{"cmd/a:main.run"}, graph.Predecessors("cmd/a:main.main"), "calls stay within a directory")
+	assert.Equal(t, [][]string{{"cmd/a:main.main", "cmd/a:main.run"}, {"cmd/b:main.helper", "cmd/b:main.main"}, {"lib.Greet"}}, graph.SCCs())
+}
@@ -0,0 +1,77 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Gates configures the quality gates checked by CheckGates. Zero values
+// disable the corresponding gate. Gates with a Label only apply to the
+// functions carrying it, as in "fail if a critical function has complexity
+// above 8".
+type Gates struct {
+	Label              string  `yaml:"label"`               // only check functions with this label
+	MaxComplexity      int     `yaml:"max_complexity"`      // fail functions above this cyclomatic complexity
+	MinMaintainability float64 `yaml:"min_maintainability"` // fail functions below this maintainability index
+	FailOnDuplicate    bool    `yaml:"fail_on_duplicate"`   // fail duplicated functions
+	FailOnDeadCode     bool    `yaml:"fail_on_dead_code"`   // fail unused functions
+}
+
+// Violation is a function failing a quality gate.
+type Violation struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Gate     string `json:"gate"`
+	Message  string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s (%s): %s", v.Function, v.File, v.Message)
+}
+
+// CheckGates returns every violation of g in the report, ordered by file and
+// function.
+func (r AnalysisReport) CheckGates(g Gates) []Violation {
+	var violations []Violation
+	for _, fn := range r.Functions {
+		if g.Label != "" && !slices.Contains(fn.Labels, g.Label) {
+			continue
+		}
+		// Violations of gates scoped to a label name it, as its limits may
+		// differ from the unscoped gates.
+		labelSuffix := ""
+		if g.Label != "" {
+			labelSuffix = fmt.Sprintf(" (%s)", g.Label)
+		}
+		add := func(gate, format string, args ...interface{}) {
+			violations = append(violations, Violation{
+				Function: fn.Caller,
+				File:     fn.File,
+				Gate:     gate,
+				Message:  fmt.Sprintf(format, args...) + labelSuffix,
+			})
+		}
+		if g.MaxComplexity > 0 && fn.Metrics.CyclomaticComplexity > g.MaxComplexity && !fn.IgnoresCheck(CheckComplexity) {
+			add("complexity", "cyclomatic complexity %d exceeds %d",
+				fn.Metrics.CyclomaticComplexity, g.MaxComplexity)
+		}
+		if g.MinMaintainability > 0 && fn.Metrics.Maintainability < g.MinMaintainability && !fn.IgnoresCheck(CheckMaintainability) {
+			add("maintainability", "maintainability index %.1f is below %.1f",
+				fn.Metrics.Maintainability, g.MinMaintainability)
+		}
+		if g.FailOnDuplicate && fn.IsDuplicate && !fn.IgnoresCheck(CheckDuplicate) {
+			add("duplicate", "duplicates another function")
+		}
+		if g.FailOnDeadCode && fn.Metrics.IsUnused && !fn.IgnoresCheck(CheckDeadCode) {
+			add("dead-code", "is never called")
+		}
+	}
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].File != violations[j].File {
+			return violations[i].File < violations[j].File
+		}
+		return violations[i].Function < violations[j].Function
+	})
+	return violations
+}
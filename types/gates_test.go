@@ -0,0 +1,47 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckGates(t *testing.T) {
+	report := types.AnalysisReport{
+		Functions: []types.FunctionCall{
+			{Caller: "simple", File: "a.go", Labels: []string{"critical"}, Metrics: types.FunctionMetrics{CyclomaticComplexity: 2, Maintainability: 160}},
+			{Caller: "tangled", File: "a.go", Metrics: types.FunctionMetrics{CyclomaticComplexity: 12, Maintainability: 40}},
+			{Caller: "copy", File: "b.go", IsDuplicate: true, Metrics: types.FunctionMetrics{CyclomaticComplexity: 1, Maintainability: 171}},
+			{Caller: "orphan", File: "b.go", Metrics: types.FunctionMetrics{CyclomaticComplexity: 1, Maintainability: 171, IsUnused: true}},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		gates types.Gates
+		want  []string
+	}{
+		{name: "no gates", gates: types.Gates{}, want: nil},
+		{name: "complexity", gates: types.Gates{MaxComplexity: 10}, want: []string{"tangled:complexity"}},
+		{name: "maintainability", gates: types.Gates{MinMaintainability: 50}, want: []string{"tangled:maintainability"}},
+		{name: "duplicate", gates: types.Gates{FailOnDuplicate: true}, want: []string{"copy:duplicate"}},
+		{name: "dead code", gates: types.Gates{FailOnDeadCode: true}, want: []string{"orphan:dead-code"}},
+		{name: "label", gates: types.Gates{Label: "critical", MaxComplexity: 1}, want: []string{"simple:complexity"}},
+		{
+			name:  "all gates",
+			gates: types.Gates{MaxComplexity: 10, MinMaintainability: 50, FailOnDuplicate: true, FailOnDeadCode: true},
+			want:  []string{"tangled:complexity", "tangled:maintainability", "copy:duplicate", "orphan:dead-code"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range report.CheckGates(tt.gates) {
+				got = append(got, v.Function+":"+v.Gate)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package types
+
+// HealthWeights configures HealthScore. The score is the weighted average
+// of four components, each from 0 to 100:
+//
+//   - Maintainability: the average maintainability index of the functions.
+//   - Hotspots: the share of functions the analyzer did not flag as
+//     hotspots (FunctionMetrics.IsHotspot) under its complexity thresholds.
+//   - Duplication: the share of functions that are not duplicated.
+//   - DeadCode: the share of functions that are used.
+//
+// Weights are relative; they need not sum to one.
+type HealthWeights struct {
+	Maintainability float64
+	Hotspots        float64
+	Duplication     float64
+	DeadCode        float64
+}
+
+// DefaultHealthWeights returns the weights HealthScore uses.
+func DefaultHealthWeights() HealthWeights {
+	return HealthWeights{
+		Maintainability: 0.4,
+		Hotspots:        0.3,
+		Duplication:     0.15,
+		DeadCode:        0.15,
+	}
+}
+
+// HealthScore rates the codebase from 0 to 100 with the default weights.
+// A report without functions scores 100.
+func (r AnalysisReport) HealthScore() float64 {
+	return r.HealthScoreWeighted(DefaultHealthWeights())
+}
+
+// HealthScoreWeighted rates the codebase from 0 to 100 with the given
+// weights, or the default weights if they are all zero.
+func (r AnalysisReport) HealthScoreWeighted(w HealthWeights) float64 {
+	total := w.Maintainability + w.Hotspots + w.Duplication + w.DeadCode
+	if total <= 0 {
+		w = DefaultHealthWeights()
+		total = w.Maintainability + w.Hotspots + w.Duplication + w.DeadCode
+	}
+	if len(r.Functions) == 0 {
+		return 100
+	}
+
+	var maintainability float64
+	var hotspots, duplicates, unused int
+	for _, fn := range r.Functions {
+		maintainability += min(max(fn.Metrics.Maintainability, 0), 100)
+		if fn.Metrics.IsHotspot {
+			hotspots++
+		}
+		if fn.IsDuplicate {
+			duplicates++
+		}
+		if fn.Metrics.IsUnused {
+			unused++
+		}
+	}
+	n := float64(len(r.Functions))
+	healthy := func(count int) float64 { return 100 * (1 - float64(count)/n) }
+
+	score := w.Maintainability*maintainability/n +
+		w.Hotspots*healthy(hotspots) +
+		w.Duplication*healthy(duplicates) +
+		w.DeadCode*healthy(unused)
+	return score / total
+}
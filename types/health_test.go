@@ -0,0 +1,33 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthScore(t *testing.T) {
+	clean := types.AnalysisReport{
+		Functions: []types.FunctionCall{
+			{Caller: "main", Metrics: types.FunctionMetrics{CyclomaticComplexity: 2, Maintainability: 95}},
+			{Caller: "run", Metrics: types.FunctionMetrics{CyclomaticComplexity: 3, Maintainability: 90}},
+		},
+	}
+	assert.InDelta(t, 97, clean.HealthScore(), 0.01)
+
+	bad := types.AnalysisReport{
+		Functions: []types.FunctionCall{
+			{Caller: "tangled", Metrics: types.FunctionMetrics{CyclomaticComplexity: 30, Maintainability: 10, IsHotspot: true}},
+			{Caller: "copy", IsDuplicate: true, Metrics: types.FunctionMetrics{CyclomaticComplexity: 25, Maintainability: 20, IsUnused: true, IsHotspot: true}},
+			{Caller: "orphan", Metrics: types.FunctionMetrics{CyclomaticComplexity: 2, Maintainability: 30, IsUnused: true, IsHotspot: true}},
+		},
+	}
+	assert.Less(t, bad.HealthScore(), 25.0)
+
+	// Only dead code counts: two of three functions are unused.
+	assert.InDelta(t, 100.0/3, bad.HealthScoreWeighted(types.HealthWeights{DeadCode: 1}), 0.01)
+
+	assert.Equal(t, 100.0, types.AnalysisReport{}.HealthScore())
+	assert.Equal(t, clean.HealthScore(), clean.BuildSummary().HealthScore)
+}
@@ -0,0 +1,148 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jsonSchemaDraft is the JSON Schema dialect ReportJSONSchema declares.
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// ReportJSONSchema returns a JSON Schema (draft 2020-12) for the JSON
+// reports: an AnalysisReport, as written by FullReport, or a Summary, as
+// written by PrettyPrint. Both are defined under $defs, with the types they
+// use, so either can be referenced on its own.
+//
+// The schema is derived from the Go types and their json tags, so it
+// cannot drift from what is marshaled. Fields without omitempty are
+// required, slices and maps may be null, and no other properties are
+// allowed. Types from other packages, such as record IDs, are left
+// unconstrained.
+func ReportJSONSchema() []byte {
+	return slices.Clone(reportJSONSchema())
+}
+
+var reportJSONSchema = sync.OnceValue(func() []byte {
+	g := schemaGenerator{defs: make(map[string]any)}
+	schema := map[string]any{
+		"$schema": jsonSchemaDraft,
+		"title":   "surrealcode report",
+		"anyOf": []any{
+			g.schemaFor(reflect.TypeFor[AnalysisReport]()),
+			g.schemaFor(reflect.TypeFor[Summary]()),
+		},
+		"$defs": g.defs,
+	}
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		panic(err) // the schema holds only JSON values
+	}
+	return data
+})
+
+// schemaGenerator derives JSON Schemas from Go types, collecting the named
+// struct types it meets in defs.
+type schemaGenerator struct {
+	defs map[string]any
+}
+
+var (
+	timeType   = reflect.TypeFor[time.Time]()
+	ownPkgPath = reflect.TypeFor[AnalysisReport]().PkgPath()
+)
+
+// schemaFor returns the schema of values of type t.
+func (g schemaGenerator) schemaFor(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct && t.PkgPath() != ownPkgPath:
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Pointer:
+		return nullable(g.schemaFor(t.Elem()))
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return nullable(map[string]any{"type": "string", "contentEncoding": "base64"})
+		}
+		return nullable(map[string]any{"type": "array", "items": g.schemaFor(t.Elem())})
+	case reflect.Array:
+		return map[string]any{"type": "array", "items": g.schemaFor(t.Elem()), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Map:
+		return nullable(map[string]any{"type": "object", "additionalProperties": g.schemaFor(t.Elem())})
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.objectSchema(t)
+		}
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = nil // reserve the name for recursive types
+			g.defs[t.Name()] = g.objectSchema(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
+
+// objectSchema returns the schema of struct type t, following the rules of
+// encoding/json for field names, embedded structs and omitempty.
+func (g schemaGenerator) objectSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	required := []string{}
+	g.addFields(t, properties, &required)
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+func (g schemaGenerator) addFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			g.addFields(field.Type, properties, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schemaFor(field.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// nullable extends schema to also allow null, which nil pointers, slices
+// and maps marshal to.
+func nullable(schema map[string]any) map[string]any {
+	if typ, ok := schema["type"].(string); ok {
+		schema["type"] = []string{typ, "null"}
+		return schema
+	}
+	return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+}
@@ -0,0 +1,76 @@
+package types_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/TFMV/surrealcode/types"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportJSONSchema(t *testing.T) {
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal(types.ReportJSONSchema(), &schema))
+	assert.Equal(t, "https://json-schema.org/draft/2020-12/schema", schema["$schema"])
+
+	report := types.AnalysisReport{
+		Functions: []types.FunctionCall{{
+			Caller:  "main",
+			File:    "main.go",
+			Package: "main",
+			Callees: []string{"helper"},
+			Metrics: types.FunctionMetrics{CyclomaticComplexity: 2, LinesOfCode: 12, Maintainability: 71.5},
+		}, {
+			Caller:  "helper",
+			File:    "main.go",
+			Package: "main",
+		}},
+		Structs:    []types.StructDefinition{{Name: "Config", File: "main.go", Package: "main", FieldCount: 2, SizeBytes: 24}},
+		Globals:    []types.GlobalVariable{{Name: "version", Type: "string", Value: `"1.0"`, File: "main.go", Package: "main", IsConst: true}},
+		Imports:    []types.ImportDefinition{{Path: "fmt", File: "main.go"}},
+		FileErrors: []types.FileError{{Path: "broken.go", Error: "expected declaration"}},
+	}
+	full, err := report.FullReport()
+	require.NoError(t, err)
+	assert.NoError(t, validate("#/$defs/AnalysisReport", full))
+	assert.NoError(t, validate("#", full))
+
+	summary, err := report.PrettyPrint()
+	require.NoError(t, err)
+	assert.NoError(t, validate("#/$defs/Summary", summary))
+	separate, err := json.Marshal(report.BuildSeparateSummary())
+	require.NoError(t, err)
+	assert.NoError(t, validate("#", separate))
+
+	assert.Error(t, validate("#/$defs/AnalysisReport", summary))
+	assert.Error(t, validate("#", []byte(`{"functions": "main"}`)))
+	tampered := strings.Replace(string(full), `"caller": "main"`, `"caller": 1`, 1)
+	assert.Error(t, validate("#/$defs/AnalysisReport", []byte(tampered)))
+}
+
+// validate validates the JSON document data against the subschema of
+// ReportJSONSchema at the JSON pointer ref with a draft 2020-12 validator.
+func validate(ref string, data []byte) error {
+	schema, err := jsonschema.UnmarshalJSON(bytes.NewReader(types.ReportJSONSchema()))
+	if err != nil {
+		return err
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft2020)
+	if err := compiler.AddResource("report.schema.json", schema); err != nil {
+		return err
+	}
+	compiled, err := compiler.Compile("report.schema.json" + ref)
+	if err != nil {
+		return err
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return compiled.Validate(doc)
+}
@@ -3,7 +3,14 @@ package types
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
+	"math"
+	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/surrealdb/surrealdb.go/pkg/models"
 )
@@ -15,21 +22,130 @@ import (
 type FunctionCall struct {
 	ID                *models.RecordID `json:"id,omitempty"`
 	Caller            string           `json:"caller"`
-	Callees           []string         `json:"callees"`
+	Callees           []string         `json:"callees"` // distinct callees of CallSites, in order of first call
+	CallSites         []CallSite       `json:"call_sites,omitempty"`
 	File              string           `json:"file"`
+	Line              int              `json:"line,omitempty"` // line of the declaration
 	Package           string           `json:"package"`
 	Params            []string         `json:"params"`
 	Returns           []string         `json:"returns"`
+	ParamCount        int              `json:"param_count"`
+	ReturnCount       int              `json:"return_count"`
+	ReturnNames       []string         `json:"return_names,omitempty"` // names of named results, in order
+	IsVariadic        bool             `json:"is_variadic"`
 	IsMethod          bool             `json:"is_method"`
+	PointerReceiver   bool             `json:"pointer_receiver"`
 	IsRecursive       bool             `json:"is_recursive"`
+	RecursionGroupID  int              `json:"recursion_group_id,omitempty"` // 0 unless recursive
 	IsDuplicate       bool             `json:"is_duplicate"`
 	IsInterface       bool             `json:"is_interface"`
 	IsStruct          bool             `json:"is_struct"`
 	IsGlobal          bool             `json:"is_global"`
+	IsClosure         bool             `json:"is_closure"`
+	IsTest            bool             `json:"is_test"` // declared in a _test.go file
+	HasNakedReturn    bool             `json:"has_naked_return"`
+	IgnoresErrors     bool             `json:"ignores_errors"` // best-effort; needs type information
+	IsEmpty           bool             `json:"is_empty"`       // no body, or only a TODO panic
 	Struct            string           `json:"struct"`
 	Metrics           FunctionMetrics  `json:"metrics"`
 	ReferencedGlobals []string         `json:"referenced_globals"`
 	Dependencies      []string         `json:"dependencies"`
+	// PackageDependencies refines Dependencies with the symbols called.
+	PackageDependencies []PackageDependency `json:"package_dependencies,omitempty"`
+	Captures            []string            `json:"captures,omitempty"`
+	Doc                 string              `json:"doc,omitempty"`       // doc comment text
+	BodyHash            string              `json:"body_hash,omitempty"` // SHA-256 of the gofmt-printed body, without comments
+
+	// UsesAnonymousTypes is set if a parameter or result has an inline
+	// struct or non-empty interface type.
+	UsesAnonymousTypes bool `json:"uses_anonymous_types"`
+
+	// Ignored is set by a //surrealcode:ignore directive in the doc
+	// comment. The directive ignores every check, or only the checks
+	// listed in IgnoredChecks, as in //surrealcode:ignore=complexity,deadcode.
+	Ignored       bool     `json:"ignored,omitempty"`
+	IgnoredChecks []string `json:"ignored_checks,omitempty"`
+
+	// HasGoroutines is set if the function has a go statement.
+	// UnguardedGlobalWrites lists the package-level variables it assigns
+	// while no mutex is locked, as far as can be told from the statements
+	// around the assignment.
+	HasGoroutines         bool     `json:"has_goroutines"`
+	UnguardedGlobalWrites []string `json:"unguarded_global_writes,omitempty"`
+
+	// ErrorsIgnoredBy lists the callers in the package that discard the
+	// function's error result, by calling it as a statement or assigning
+	// the error to _. It is best-effort, as method calls are only known
+	// with type information.
+	ErrorsIgnoredBy []string `json:"errors_ignored_by,omitempty"`
+
+	// ExternalCalls lists the functions and methods of other packages, in
+	// the standard library or not, that the function calls, if the
+	// analyzer includes them. Calls to analyzed packages are stored as
+	// calls to their function nodes, and the others as calls to external
+	// function nodes, which have IsExternal set and no file.
+	ExternalCalls []ExternalCall `json:"external_calls,omitempty"`
+	IsExternal    bool           `json:"is_external,omitempty"`
+
+	// Owners lists the owners of the function's file in CODEOWNERS, if
+	// the analyzer was given one.
+	Owners []string `json:"owners,omitempty"`
+
+	// Labels lists the labels, such as "legacy" or "critical", of the
+	// rules matching the function, if the analyzer was given rules.
+	Labels []string `json:"labels,omitempty"`
+}
+
+// ExternalCall is a call to Function, such as Println or *Buffer.Write, of
+// the package with import path Package. If that package was analyzed too,
+// Dir and Name are its directory and name.
+type ExternalCall struct {
+	Package  string `json:"package"`
+	Function string `json:"function"`
+	Dir      string `json:"dir,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+// Checks a //surrealcode:ignore directive can name.
+const (
+	CheckComplexity      = "complexity"      // complexity and nesting hotspots and gate
+	CheckMaintainability = "maintainability" // maintainability hotspots and gate
+	CheckDuplicate       = "duplicate"       // duplicate gate
+	CheckDeadCode        = "deadcode"        // dead code detection and gate
+)
+
+// IgnoresCheck reports whether a directive excludes fn from check.
+func (fn FunctionCall) IgnoresCheck(check string) bool {
+	return fn.Ignored && (len(fn.IgnoredChecks) == 0 || slices.Contains(fn.IgnoredChecks, check))
+}
+
+// PackageDependency is a call from Caller to Symbol of the imported
+// package ImportPath, such as fmt.Println.
+type PackageDependency struct {
+	Caller     string `json:"caller"`
+	ImportPath string `json:"import_path"`
+	Symbol     string `json:"symbol"`
+}
+
+// UsersOf returns the calls into the imported package importPath, grouped
+// by calling function.
+func (r AnalysisReport) UsersOf(importPath string) []PackageDependency {
+	var users []PackageDependency
+	for _, fn := range r.Functions {
+		for _, dep := range fn.PackageDependencies {
+			if dep.ImportPath == importPath {
+				users = append(users, dep)
+			}
+		}
+	}
+	return users
+}
+
+// CallSite is a call to Callee at Line and Col of the caller's file.
+type CallSite struct {
+	Callee string `json:"callee"`
+	Line   int    `json:"line"`
+	Col    int    `json:"col"`
 }
 
 type StructDefinition struct {
@@ -37,6 +153,11 @@ type StructDefinition struct {
 	Name    string           `json:"name"`
 	File    string           `json:"file"`
 	Package string           `json:"package"`
+	Methods []string         `json:"methods"`
+	Doc     string           `json:"doc,omitempty"`
+
+	FieldCount int   `json:"field_count"`          // named fields, counting each embedded field once
+	SizeBytes  int64 `json:"size_bytes,omitempty"` // size on the host architecture; needs type information
 }
 
 type InterfaceDefinition struct {
@@ -45,36 +166,481 @@ type InterfaceDefinition struct {
 	File    string           `json:"file"`
 	Package string           `json:"package"`
 	Methods []string         `json:"methods"`
+	Doc     string           `json:"doc,omitempty"`
+}
+
+// TypeDefinition is a type declaration other than a struct or interface: a
+// defined type such as type Celsius float64, or an alias such as
+// type ID = string.
+type TypeDefinition struct {
+	ID         *models.RecordID `json:"id,omitempty"`
+	Name       string           `json:"name"`
+	Underlying string           `json:"underlying"` // the declared type, as written
+	IsAlias    bool             `json:"is_alias"`
+	File       string           `json:"file"`
+	Package    string           `json:"package"`
+	Methods    []string         `json:"methods"`
 }
 
 type GlobalVariable struct {
-	ID      *models.RecordID `json:"id,omitempty"`
-	Name    string           `json:"name"`
-	Type    string           `json:"type"`
-	Value   string           `json:"value,omitempty"`
-	File    string           `json:"file"`
-	Package string           `json:"package"`
+	ID       *models.RecordID `json:"id,omitempty"`
+	Name     string           `json:"name"`
+	Type     string           `json:"type"`
+	Value    string           `json:"value,omitempty"`
+	File     string           `json:"file"`
+	Package  string           `json:"package"`
+	IsConst  bool             `json:"is_const"`
+	IsUnused bool             `json:"is_unused"`
 }
 
 type ImportDefinition struct {
-	ID      *models.RecordID `json:"id,omitempty"`
-	Path    string           `json:"path"`
-	File    string           `json:"file"`
-	Package string           `json:"package"`
+	ID         *models.RecordID `json:"id,omitempty"`
+	Path       string           `json:"path"`
+	Name       string           `json:"name"` // local name: alias or declared package name
+	File       string           `json:"file"`
+	Package    string           `json:"package"`
+	ImportKind string           `json:"import_kind"` // ImportNormal, ImportDot, ImportBlank or ImportAlias
+	IsUnused   bool             `json:"is_unused"`
 }
 
+// Kinds of imports.
+const (
+	ImportNormal = "normal" // import "fmt"
+	ImportDot    = "dot"    // import . "fmt"
+	ImportBlank  = "blank"  // import _ "embed", for its side effects only
+	ImportAlias  = "alias"  // import f "fmt"
+)
+
 type InterfaceImplementation struct {
 	Struct    string `json:"struct"`
 	Interface string `json:"interface"`
+	Package   string `json:"package"`
+	File      string `json:"file,omitempty"` // file declaring the struct and the interface
 }
 
 type AnalysisReport struct {
-	Functions  []FunctionCall
-	Structs    []StructDefinition
-	Interfaces []InterfaceDefinition
-	Globals    []GlobalVariable
-	Imports    []ImportDefinition
-	Implements []InterfaceImplementation
+	Functions  []FunctionCall            `json:"functions"`
+	Structs    []StructDefinition        `json:"structs"`
+	Interfaces []InterfaceDefinition     `json:"interfaces"`
+	Globals    []GlobalVariable          `json:"globals"`
+	Imports    []ImportDefinition        `json:"imports"`
+	Implements []InterfaceImplementation `json:"implements"`
+
+	// Types lists the declared types that are neither structs nor
+	// interfaces, such as function types and aliases.
+	Types []TypeDefinition `json:"types,omitempty"`
+
+	// RecursionGroups lists the groups of functions, named package.Caller,
+	// that call each other recursively. A function's RecursionGroupID is
+	// its group's index here plus one.
+	RecursionGroups [][]string `json:"recursion_groups,omitempty"`
+
+	// FileErrors lists the files skipped because they could not be analyzed.
+	FileErrors []FileError `json:"file_errors,omitempty"`
+
+	// Roots lists the directories whose Go files were all analyzed. Stores
+	// drop the stored nodes of files under them that are gone. It is empty
+	// for partial reports, such as those of changed files.
+	Roots []string `json:"roots,omitempty"`
+
+	// Findings lists problems with local variables, if they were analyzed.
+	Findings []Finding `json:"findings,omitempty"`
+
+	// UnusedInterfaceMethods lists the interface methods, named
+	// package.Interface.Method, that are not called anywhere. Calls are
+	// matched by method name only.
+	UnusedInterfaceMethods []string `json:"unused_interface_methods,omitempty"`
+
+	// AnonymousTypes lists the inline struct and non-empty interface types
+	// of function signatures and struct fields, most used first. They are
+	// candidates for named types.
+	AnonymousTypes []AnonymousType `json:"anonymous_types,omitempty"`
+
+	// DataRaceSuspects lists the functions that may run concurrently and
+	// write globals without holding a lock.
+	DataRaceSuspects []DataRaceSuspect `json:"data_race_suspects,omitempty"`
+
+	// LeakSuspects lists the resources opened and never closed by the
+	// function opening them.
+	LeakSuspects []LeakSuspect `json:"leak_suspects,omitempty"`
+
+	// ReceiverInconsistencies lists the types whose methods mix value and
+	// pointer receivers.
+	ReceiverInconsistencies []ReceiverInconsistency `json:"receiver_inconsistencies,omitempty"`
+
+	// ImportCycles lists the cycles in the imports between the analyzed
+	// packages of a module, which the go command would reject.
+	ImportCycles []ImportCycle `json:"import_cycles,omitempty"`
+
+	// InitActions lists what runs when packages are initialized. Within a
+	// package, variable initializers calling functions come first, as they
+	// run before init functions, and each kind is in file and line order.
+	InitActions []InitAction `json:"init_actions,omitempty"`
+}
+
+// InitAction is code run when a package is initialized: an init function,
+// or the initializer of package-level variables calling a function.
+type InitAction struct {
+	Name    string `json:"name"` // the function's Caller, or the variables
+	Package string `json:"package"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Kind    string `json:"kind"` // InitFunction or InitVariable
+}
+
+// Kinds of init actions.
+const (
+	InitFunction = "init"
+	InitVariable = "var"
+)
+
+// ImportCycle is a strongly connected component of the import graph: the
+// packages, by import path, that import each other directly or not.
+type ImportCycle struct {
+	Packages []string `json:"packages"`
+}
+
+// DataRaceSuspect is a function writing package-level variables without
+// a lock while it may run in a goroutine: it starts goroutines itself, or
+// is called, directly or not, by a function of its package that does.
+// It is a heuristic, not a race detector.
+type DataRaceSuspect struct {
+	Function  string   `json:"function"`
+	Package   string   `json:"package"`
+	File      string   `json:"file"`
+	Globals   []string `json:"globals"`   // the globals written without a lock
+	Goroutine string   `json:"goroutine"` // the goroutine-starting function it runs under
+}
+
+// LeakSuspect is a call opening a resource, such as a file, whose result its
+// function never closes nor hands on. It is a heuristic: a close in a helper
+// the resource is passed to is not seen, so such a resource is reported.
+type LeakSuspect struct {
+	Func     string `json:"func"`
+	File     string `json:"file"`
+	Resource string `json:"resource"` // the opening function, such as os.Open
+	Line     int    `json:"line"`
+}
+
+// ReceiverInconsistency is a type with methods on both value and pointer
+// receivers. Its method set then differs between values and pointers, so
+// only pointers implement some interfaces, and copies of values share state
+// unexpectedly.
+type ReceiverInconsistency struct {
+	Struct         string   `json:"struct"`
+	Package        string   `json:"package"`
+	File           string   `json:"file"` // the type declaration, or its first method outside the analyzed files
+	ValueMethods   []string `json:"value_methods"`
+	PointerMethods []string `json:"pointer_methods"`
+}
+
+// AnonymousType is an inline struct or interface type and how often it is
+// used. Types are written as by go/types, so identical types compare equal
+// however they are formatted.
+type AnonymousType struct {
+	Type    string   `json:"type"`
+	Kind    string   `json:"kind"`              // struct or interface
+	Methods []string `json:"methods,omitempty"` // the methods an interface declares
+	Uses    int      `json:"uses"`
+}
+
+// Kinds of findings.
+const (
+	FindingUnused   = "unused"   // a local variable that is never used
+	FindingShadowed = "shadowed" // a local variable that shadows a variable of an enclosing scope
+)
+
+// Finding is a problem with a local variable of a function.
+type Finding struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Var  string `json:"var"`
+	Line int    `json:"line"`
+	Kind string `json:"kind"`
+}
+
+// FileError records why a file was left out of the analysis.
+type FileError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// MethodsOf returns the methods declared on the named struct. Methods with
+// pointer and value receivers are both included; use PointerReceiver to tell
+// them apart.
+func (r AnalysisReport) MethodsOf(structName string) []FunctionCall {
+	var methods []FunctionCall
+	for _, fn := range r.Functions {
+		if fn.IsMethod && strings.TrimPrefix(fn.Struct, "*") == structName {
+			methods = append(methods, fn)
+		}
+	}
+	return methods
+}
+
+// Implementors returns the structs implementing the named interface.
+func (r AnalysisReport) Implementors(iface string) []string {
+	var structs []string
+	for _, impl := range r.Implements {
+		if impl.Interface == iface && !slices.Contains(structs, impl.Struct) {
+			structs = append(structs, impl.Struct)
+		}
+	}
+	sort.Strings(structs)
+	return structs
+}
+
+// ImplementedInterfaces returns the interfaces the named struct implements.
+func (r AnalysisReport) ImplementedInterfaces(structName string) []string {
+	var ifaces []string
+	for _, impl := range r.Implements {
+		if impl.Struct == structName && !slices.Contains(ifaces, impl.Interface) {
+			ifaces = append(ifaces, impl.Interface)
+		}
+	}
+	sort.Strings(ifaces)
+	return ifaces
+}
+
+// FileMetrics aggregates the metrics of the functions declared in a file.
+type FileMetrics struct {
+	Path            string  `json:"path"`
+	TotalComplexity int     `json:"total_complexity"`
+	AvgComplexity   float64 `json:"avg_complexity"`
+	FunctionCount   int     `json:"function_count"`
+	TotalLOC        int     `json:"total_loc"`
+}
+
+// FileMetrics groups the report's functions by file, sorted by total
+// complexity descending so the worst files come first.
+func (r AnalysisReport) FileMetrics() []FileMetrics {
+	byPath := make(map[string]*FileMetrics)
+	var files []*FileMetrics
+	for _, fn := range r.Functions {
+		fm, ok := byPath[fn.File]
+		if !ok {
+			fm = &FileMetrics{Path: fn.File}
+			byPath[fn.File] = fm
+			files = append(files, fm)
+		}
+		fm.FunctionCount++
+		fm.TotalComplexity += fn.Metrics.CyclomaticComplexity
+		fm.TotalLOC += fn.Metrics.LinesOfCode
+	}
+
+	metrics := make([]FileMetrics, 0, len(files))
+	for _, fm := range files {
+		fm.AvgComplexity = float64(fm.TotalComplexity) / float64(fm.FunctionCount)
+		metrics = append(metrics, *fm)
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].TotalComplexity != metrics[j].TotalComplexity {
+			return metrics[i].TotalComplexity > metrics[j].TotalComplexity
+		}
+		return metrics[i].Path < metrics[j].Path
+	})
+	return metrics
+}
+
+// PackageSummary holds Robert Martin's coupling metrics of a package, i.e. a
+// directory of analyzed files.
+type PackageSummary struct {
+	Package     string  `json:"package"`
+	Dir         string  `json:"dir"`
+	Efferent    int     `json:"efferent"`    // Ce: distinct packages imported
+	Afferent    int     `json:"afferent"`    // Ca: analyzed packages importing this one
+	Instability float64 `json:"instability"` // Ce / (Ce + Ca), 0 without any coupling
+
+	// Abstractness is the share of interfaces among the package's structs
+	// and interfaces, 0 without any, and Distance how far the package is
+	// from the main sequence A + I = 1, balancing abstractness and
+	// stability: |A + I - 1|.
+	Abstractness float64 `json:"abstractness"`
+	Distance     float64 `json:"distance"`
+}
+
+// Packages computes the coupling metrics of every analyzed package, sorted
+// by directory. An import path refers to the analyzed package whose
+// directory ends with the longest run of the path's trailing elements, so
+// no module information is needed. Imports matching no analyzed package,
+// such as the standard library, only count toward Ce.
+func (r AnalysisReport) Packages() []PackageSummary {
+	byDir := r.packageDirs()
+	imported := make(map[string]map[string]bool)  // dir -> import paths
+	importers := make(map[string]map[string]bool) // dir -> importing dirs
+	for _, imp := range r.Imports {
+		dir := filepath.ToSlash(filepath.Dir(imp.File))
+		if imported[dir] == nil {
+			imported[dir] = make(map[string]bool)
+		}
+		imported[dir][imp.Path] = true
+		if target := importedDir(imp.Path, byDir); target != "" && target != dir {
+			if importers[target] == nil {
+				importers[target] = make(map[string]bool)
+			}
+			importers[target][dir] = true
+		}
+	}
+
+	abstract := make(map[string]int) // dir -> interfaces
+	concrete := make(map[string]int) // dir -> structs
+	for _, st := range r.Structs {
+		concrete[filepath.ToSlash(filepath.Dir(st.File))]++
+	}
+	for _, iface := range r.Interfaces {
+		abstract[filepath.ToSlash(filepath.Dir(iface.File))]++
+	}
+
+	packages := make([]PackageSummary, 0, len(byDir))
+	for dir, ps := range byDir {
+		ps.Efferent = len(imported[dir])
+		ps.Afferent = len(importers[dir])
+		if total := ps.Efferent + ps.Afferent; total > 0 {
+			ps.Instability = float64(ps.Efferent) / float64(total)
+		}
+		if total := abstract[dir] + concrete[dir]; total > 0 {
+			ps.Abstractness = float64(abstract[dir]) / float64(total)
+		}
+		ps.Distance = math.Abs(ps.Abstractness + ps.Instability - 1)
+		packages = append(packages, *ps)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Dir < packages[j].Dir
+	})
+	return packages
+}
+
+// packageDirs returns the analyzed packages by directory, with only their
+// names set.
+func (r AnalysisReport) packageDirs() map[string]*PackageSummary {
+	byDir := make(map[string]*PackageSummary)
+	add := func(file, pkg string) {
+		dir := filepath.ToSlash(filepath.Dir(file))
+		if _, ok := byDir[dir]; !ok {
+			byDir[dir] = &PackageSummary{Package: pkg, Dir: dir}
+		}
+	}
+	for _, fn := range r.Functions {
+		add(fn.File, fn.Package)
+	}
+	for _, st := range r.Structs {
+		add(st.File, st.Package)
+	}
+	for _, iface := range r.Interfaces {
+		add(iface.File, iface.Package)
+	}
+	for _, global := range r.Globals {
+		add(global.File, global.Package)
+	}
+	for _, imp := range r.Imports {
+		add(imp.File, imp.Package)
+	}
+	return byDir
+}
+
+// PackageCall is a call from a function of an analyzed package to a
+// function of another, such as lib.Parse, found through the import the
+// callee is referenced by.
+type PackageCall struct {
+	From   string `json:"from"` // directory of the calling package
+	To     string `json:"to"`   // directory of the called package
+	Caller string `json:"caller"`
+	Callee string `json:"callee"`
+}
+
+// PackageCalls returns the calls between analyzed packages, resolving
+// import paths to directories as Packages does, sorted by the calling
+// package and function and then the called package and function. Calls of
+// methods on values of other packages are not included.
+func (r AnalysisReport) PackageCalls() []PackageCall {
+	byDir := r.packageDirs()
+	var calls []PackageCall
+	for _, fn := range r.Functions {
+		from := filepath.ToSlash(filepath.Dir(fn.File))
+		for _, dep := range fn.PackageDependencies {
+			if to := importedDir(dep.ImportPath, byDir); to != "" && to != from {
+				calls = append(calls, PackageCall{From: from, To: to, Caller: fn.Caller, Callee: dep.Symbol})
+			}
+		}
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		a, b := calls[i], calls[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.Caller != b.Caller {
+			return a.Caller < b.Caller
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Callee < b.Callee
+	})
+	return calls
+}
+
+// SplitByPackage splits the report into one report per analyzed package,
+// keyed by its directory as in Packages. Each part is the report ForFiles
+// returns for the package's files, so records spanning packages are in
+// every part; PackageCalls lists the calls between them.
+func (r AnalysisReport) SplitByPackage() map[string]AnalysisReport {
+	files := make(map[string]map[string]bool) // dir -> files
+	add := func(file string) {
+		dir := filepath.ToSlash(filepath.Dir(file))
+		if files[dir] == nil {
+			files[dir] = make(map[string]bool)
+		}
+		files[dir][file] = true
+	}
+	for _, fn := range r.Functions {
+		add(fn.File)
+	}
+	for _, st := range r.Structs {
+		add(st.File)
+	}
+	for _, iface := range r.Interfaces {
+		add(iface.File)
+	}
+	for _, global := range r.Globals {
+		add(global.File)
+	}
+	for _, imp := range r.Imports {
+		add(imp.File)
+	}
+
+	parts := make(map[string]AnalysisReport, len(files))
+	for dir, set := range files {
+		parts[dir] = r.ForFiles(slices.Sorted(maps.Keys(set)))
+	}
+	return parts
+}
+
+// importedDir returns the directory in dirs that importPath refers to, or ""
+// if there is none or the best match is ambiguous. Single-element paths such
+// as "fmt" are taken to be standard library packages.
+func importedDir(importPath string, dirs map[string]*PackageSummary) string {
+	elems := strings.Split(importPath, "/")
+	if len(elems) < 2 {
+		return ""
+	}
+	best, bestLen, ambiguous := "", 0, false
+	for dir := range dirs {
+		dirElems := strings.Split(dir, "/")
+		n := 0
+		for n < len(elems) && n < len(dirElems) && elems[len(elems)-1-n] == dirElems[len(dirElems)-1-n] {
+			n++
+		}
+		switch {
+		case n > bestLen:
+			best, bestLen, ambiguous = dir, n, false
+		case n == bestLen && n > 0:
+			ambiguous = true
+		}
+	}
+	if ambiguous {
+		return ""
+	}
+	return best
 }
 
 // -----------------------------------------------------------------------------
@@ -84,17 +650,26 @@ type AnalysisReport struct {
 type FunctionMetrics struct {
 	CyclomaticComplexity int                        `json:"cyclomatic_complexity"`
 	LinesOfCode          int                        `json:"lines_of_code"`
+	SLOC                 int                        `json:"sloc"` // lines of code excluding blank and comment lines
+	StatementCount       int                        `json:"statement_count"`
 	HalsteadMetrics      HalsteadMetrics            `json:"halstead_metrics"`
 	CognitiveComplexity  CognitiveComplexityMetrics `json:"cognitive_complexity"`
 	Readability          ReadabilityMetrics         `json:"readability"`
-	Maintainability      float64                    `json:"maintainability_index"`
+	Maintainability      float64                    `json:"maintainability_index"` // normalized to [0, 100]
+	MaintainabilityRaw   float64                    `json:"maintainability_raw"`
 	IsUnused             bool                       `json:"is_unused"`
+	IsHotspot            bool                       `json:"is_hotspot"`
+	Centrality           float64                    `json:"centrality"` // PageRank in the call graph
 }
 
 type HalsteadMetrics struct {
-	Volume     float64 `json:"volume"`
-	Difficulty float64 `json:"difficulty"`
-	Effort     float64 `json:"effort"`
+	Operators       int     `json:"operators"`
+	Operands        int     `json:"operands"`
+	UniqueOperators int     `json:"unique_operators"`
+	UniqueOperands  int     `json:"unique_operands"`
+	Volume          float64 `json:"volume"`
+	Difficulty      float64 `json:"difficulty"`
+	Effort          float64 `json:"effort"`
 }
 
 type CognitiveComplexityMetrics struct {
@@ -108,6 +683,9 @@ type ReadabilityMetrics struct {
 	NestingDepth   int     `json:"nesting_depth"`
 	CommentDensity float64 `json:"comment_density"`
 	BranchDensity  float64 `json:"branch_density"`
+	NumLoops       int     `json:"num_loops"`    // for and range statements
+	NumSwitches    int     `json:"num_switches"` // expression and type switches
+	NumSelects     int     `json:"num_selects"`
 }
 
 // -----------------------------------------------------------------------------
@@ -120,6 +698,10 @@ type CodeSummary struct {
 	UnusedFunctions    int `json:"unused_functions"`
 	RecursiveFunctions int `json:"recursive_functions"`
 	DuplicateCode      int `json:"duplicate_code"`
+	StubFunctions      int `json:"stub_functions"`
+	UncheckedErrors    int `json:"unchecked_errors"` // functions whose error result a caller discards
+	MixedReceivers     int `json:"mixed_receivers"`  // types mixing value and pointer receivers
+	LeakSuspects       int `json:"leak_suspects"`    // resources opened and never closed
 
 	// Averages
 	AvgComplexity      float64 `json:"avg_complexity"`
@@ -129,16 +711,43 @@ type CodeSummary struct {
 	// Distribution
 	ComplexityDistribution map[string]int `json:"complexity_distribution"` // Low/Medium/High
 
+	// Longest call chain from an entry point, functions named package.Caller
+	MaxCallDepth    int      `json:"max_call_depth"`
+	DeepestCallPath []string `json:"deepest_call_path,omitempty"`
+
+	// Fan-in: the functions with the most call sites in the analyzed code,
+	// and the unexported functions, other than entry points, without any.
+	// Functions never called are candidates for removal, although they may
+	// be called through function values or interfaces.
+	MostCalled  []FunctionRef `json:"most_called,omitempty"`
+	NeverCalled []FunctionRef `json:"never_called,omitempty"`
+
 	// Hotspots (most complex/problematic functions)
 	Hotspots []HotspotFunction `json:"hotspots"`
+
+	// Files, most complex first
+	Files []FileMetrics `json:"files"`
+
+	// Tests summarizes test code when it is reported separately.
+	Tests *CodeSummary `json:"tests,omitempty"`
+}
+
+// FunctionRef identifies a function, with the number of call sites calling
+// it where they are counted.
+type FunctionRef struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Calls    int    `json:"calls,omitempty"`
 }
 
 type HotspotFunction struct {
-	Name            string   `json:"name"`
-	File            string   `json:"file"`
-	Complexity      int      `json:"complexity"`
-	Maintainability float64  `json:"maintainability"`
-	Issues          []string `json:"issues"` // e.g., "High complexity", "Deep nesting"
+	Name                string   `json:"name"`
+	File                string   `json:"file"`
+	Complexity          int      `json:"complexity"`
+	CognitiveComplexity int      `json:"cognitive_complexity"`
+	LinesOfCode         int      `json:"lines_of_code"`
+	Maintainability     float64  `json:"maintainability"`
+	Issues              []string `json:"issues"` // e.g., "High complexity", "Deep nesting"
 }
 
 type StructSummary struct {
@@ -191,6 +800,9 @@ type FunctionSummary struct {
 	IsInterface     bool    `json:"is_interface"`
 	IsStruct        bool    `json:"is_struct"`
 	IsGlobal        bool    `json:"is_global"`
+
+	Owners []string `json:"owners,omitempty"` // see FunctionCall.Owners
+	Labels []string `json:"labels,omitempty"` // see FunctionCall.Labels
 }
 
 // ToFunctionSummary converts a FunctionCall into its summary representation.
@@ -212,6 +824,8 @@ func (fn FunctionCall) ToFunctionSummary() FunctionSummary {
 		IsInterface: fn.IsInterface,
 		IsStruct:    fn.IsStruct,
 		IsGlobal:    fn.IsGlobal,
+		Owners:      fn.Owners,
+		Labels:      fn.Labels,
 	}
 }
 
@@ -276,6 +890,11 @@ type Summary struct {
 	Globals        []GlobalSummary         `json:"globals"`
 	Imports        []ImportSummary         `json:"imports"`
 	Implements     []ImplementationSummary `json:"implements"`
+	Files          []FileMetrics           `json:"files"`
+	Packages       []PackageSummary        `json:"packages"`
+	HealthScore    float64                 `json:"health_score"` // see AnalysisReport.HealthScore
+	MissingDoc     []string                `json:"missing_doc"`  // see AnalysisReport.MissingDoc
+	Tests          *Summary                `json:"tests,omitempty"`
 }
 
 // BuildSummary constructs the summary object from the AnalysisReport.
@@ -322,6 +941,10 @@ func (r AnalysisReport) BuildSummary() Summary {
 		Globals:        globalSummaries,
 		Imports:        importSummaries,
 		Implements:     implSummaries,
+		Files:          r.FileMetrics(),
+		Packages:       r.Packages(),
+		HealthScore:    r.HealthScore(),
+		MissingDoc:     r.MissingDoc(),
 	}
 
 	// Sort them as needed
@@ -350,13 +973,197 @@ func (r AnalysisReport) BuildSummary() Summary {
 	return summary
 }
 
+// MissingDoc returns the exported functions, methods of exported types,
+// structs and interfaces without a doc comment, named package.Name and
+// sorted. Closures and test functions are left out.
+func (r AnalysisReport) MissingDoc() []string {
+	var missing []string
+	for _, fn := range r.Functions {
+		if fn.Doc != "" || fn.IsClosure || fn.IsTest {
+			continue
+		}
+		name := fn.Caller
+		if fn.IsMethod {
+			_, name, _ = strings.Cut(name, ".")
+			if !isExportedName(fn.Struct) {
+				continue
+			}
+		}
+		if isExportedName(name) {
+			missing = append(missing, fn.Package+"."+fn.Caller)
+		}
+	}
+	for _, st := range r.Structs {
+		if st.Doc == "" && isExportedName(st.Name) {
+			missing = append(missing, st.Package+"."+st.Name)
+		}
+	}
+	for _, iface := range r.Interfaces {
+		if iface.Doc == "" && isExportedName(iface.Name) {
+			missing = append(missing, iface.Package+"."+iface.Name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// isExportedName reports whether name starts with an upper-case letter.
+func isExportedName(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
+}
+
+// SplitTests splits the report into its production code and the code of its
+// _test.go files.
+func (r AnalysisReport) SplitTests() (production, tests AnalysisReport) {
+	isTest := func(file string) bool { return strings.HasSuffix(file, "_test.go") }
+	production.RecursionGroups = r.RecursionGroups
+	production.FileErrors = r.FileErrors
+	production.UnusedInterfaceMethods = r.UnusedInterfaceMethods
+	production.AnonymousTypes = r.AnonymousTypes
+	production.ImportCycles = r.ImportCycles
+	production.Implements = r.Implements
+	for _, fn := range r.Functions {
+		if fn.IsTest {
+			tests.Functions = append(tests.Functions, fn)
+		} else {
+			production.Functions = append(production.Functions, fn)
+		}
+	}
+	for _, st := range r.Structs {
+		if isTest(st.File) {
+			tests.Structs = append(tests.Structs, st)
+		} else {
+			production.Structs = append(production.Structs, st)
+		}
+	}
+	for _, iface := range r.Interfaces {
+		if isTest(iface.File) {
+			tests.Interfaces = append(tests.Interfaces, iface)
+		} else {
+			production.Interfaces = append(production.Interfaces, iface)
+		}
+	}
+	for _, typ := range r.Types {
+		if isTest(typ.File) {
+			tests.Types = append(tests.Types, typ)
+		} else {
+			production.Types = append(production.Types, typ)
+		}
+	}
+	for _, global := range r.Globals {
+		if isTest(global.File) {
+			tests.Globals = append(tests.Globals, global)
+		} else {
+			production.Globals = append(production.Globals, global)
+		}
+	}
+	for _, imp := range r.Imports {
+		if isTest(imp.File) {
+			tests.Imports = append(tests.Imports, imp)
+		} else {
+			production.Imports = append(production.Imports, imp)
+		}
+	}
+	for _, f := range r.Findings {
+		if isTest(f.File) {
+			tests.Findings = append(tests.Findings, f)
+		} else {
+			production.Findings = append(production.Findings, f)
+		}
+	}
+	for _, action := range r.InitActions {
+		if isTest(action.File) {
+			tests.InitActions = append(tests.InitActions, action)
+		} else {
+			production.InitActions = append(production.InitActions, action)
+		}
+	}
+	for _, suspect := range r.DataRaceSuspects {
+		if isTest(suspect.File) {
+			tests.DataRaceSuspects = append(tests.DataRaceSuspects, suspect)
+		} else {
+			production.DataRaceSuspects = append(production.DataRaceSuspects, suspect)
+		}
+	}
+	for _, leak := range r.LeakSuspects {
+		if isTest(leak.File) {
+			tests.LeakSuspects = append(tests.LeakSuspects, leak)
+		} else {
+			production.LeakSuspects = append(production.LeakSuspects, leak)
+		}
+	}
+	for _, inconsistency := range r.ReceiverInconsistencies {
+		if isTest(inconsistency.File) {
+			tests.ReceiverInconsistencies = append(tests.ReceiverInconsistencies, inconsistency)
+		} else {
+			production.ReceiverInconsistencies = append(production.ReceiverInconsistencies, inconsistency)
+		}
+	}
+	return production, tests
+}
+
+// ForFiles returns the part of the report declared in the given files.
+// Records spanning packages, such as recursion groups, interface
+// implementations and import cycles, are kept whole.
+func (r AnalysisReport) ForFiles(files []string) AnalysisReport {
+	keep := func(file string) bool { return slices.Contains(files, file) }
+	filtered := r
+	filtered.Functions = filterByFile(r.Functions, func(fn FunctionCall) string { return fn.File }, keep)
+	filtered.Structs = filterByFile(r.Structs, func(st StructDefinition) string { return st.File }, keep)
+	filtered.Interfaces = filterByFile(r.Interfaces, func(iface InterfaceDefinition) string { return iface.File }, keep)
+	filtered.Types = filterByFile(r.Types, func(typ TypeDefinition) string { return typ.File }, keep)
+	filtered.Globals = filterByFile(r.Globals, func(global GlobalVariable) string { return global.File }, keep)
+	filtered.Imports = filterByFile(r.Imports, func(imp ImportDefinition) string { return imp.File }, keep)
+	filtered.FileErrors = filterByFile(r.FileErrors, func(fe FileError) string { return fe.Path }, keep)
+	filtered.Findings = filterByFile(r.Findings, func(f Finding) string { return f.File }, keep)
+	filtered.DataRaceSuspects = filterByFile(r.DataRaceSuspects, func(s DataRaceSuspect) string { return s.File }, keep)
+	filtered.LeakSuspects = filterByFile(r.LeakSuspects, func(leak LeakSuspect) string { return leak.File }, keep)
+	filtered.ReceiverInconsistencies = filterByFile(r.ReceiverInconsistencies, func(ri ReceiverInconsistency) string { return ri.File }, keep)
+	filtered.InitActions = filterByFile(r.InitActions, func(action InitAction) string { return action.File }, keep)
+	filtered.Implements = filterByFile(r.Implements, func(impl InterfaceImplementation) string { return impl.File }, keep)
+	filtered.Roots = nil
+	return filtered
+}
+
+// filterByFile returns the items whose file is kept.
+func filterByFile[T any](items []T, file func(T) string, keep func(string) bool) []T {
+	var kept []T
+	for _, item := range items {
+		if keep(file(item)) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// BuildSeparateSummary builds the summary of the report's production code,
+// with its test code summarized in the Tests section.
+func (r AnalysisReport) BuildSeparateSummary() Summary {
+	production, tests := r.SplitTests()
+	summary := production.BuildSummary()
+	testSummary := tests.BuildSummary()
+	summary.Tests = &testSummary
+	return summary
+}
+
 // PrettyPrint returns a JSON-formatted summary of the analysis.
-func (r AnalysisReport) PrettyPrint() string {
+func (r AnalysisReport) PrettyPrint() ([]byte, error) {
 	summary := r.BuildSummary()
 
 	jsonBytes, err := json.MarshalIndent(summary, "", "  ")
 	if err != nil {
-		return fmt.Sprintf("Error generating summary: %v", err)
+		return nil, fmt.Errorf("error generating summary: %w", err)
+	}
+	return jsonBytes, nil
+}
+
+// FullReport returns the complete report, with every metric and edge, as
+// indented JSON.
+func (r AnalysisReport) FullReport() ([]byte, error) {
+	jsonBytes, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error generating report: %w", err)
 	}
-	return string(jsonBytes)
+	return jsonBytes, nil
 }
@@ -0,0 +1,120 @@
+package types_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/TFMV/surrealcode/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalysisReport_FileMetrics(t *testing.T) {
+	fn := func(caller, file string, complexity, loc int) types.FunctionCall {
+		return types.FunctionCall{
+			Caller:  caller,
+			File:    file,
+			Metrics: types.FunctionMetrics{CyclomaticComplexity: complexity, LinesOfCode: loc},
+		}
+	}
+	report := types.AnalysisReport{
+		Functions: []types.FunctionCall{
+			fn("small", "a.go", 1, 3),
+			fn("parse", "b.go", 7, 40),
+			fn("render", "b.go", 2, 10),
+		},
+	}
+
+	assert.Equal(t, []types.FileMetrics{
+		{Path: "b.go", TotalComplexity: 9, AvgComplexity: 4.5, FunctionCount: 2, TotalLOC: 50},
+		{Path: "a.go", TotalComplexity: 1, AvgComplexity: 1, FunctionCount: 1, TotalLOC: 3},
+	}, report.FileMetrics())
+}
+
+func TestAnalysisReport_ImplementsQueries(t *testing.T) {
+	report := types.AnalysisReport{
+		Implements: []types.InterfaceImplementation{
+			{Struct: "MathOps", Interface: "Calculator"},
+			{Struct: "FastMath", Interface: "Calculator"},
+			{Struct: "MathOps", Interface: "Stringer"},
+		},
+	}
+
+	assert.Equal(t, []string{"FastMath", "MathOps"}, report.Implementors("Calculator"))
+	assert.Equal(t, []string{"Calculator", "Stringer"}, report.ImplementedInterfaces("MathOps"))
+	assert.Nil(t, report.Implementors("Reader"))
+}
+
+func TestAnalysisReport_SummaryAndFullReport(t *testing.T) {
+	report := types.AnalysisReport{
+		Functions: []types.FunctionCall{{
+			Caller:  "main",
+			File:    "main.go",
+			Package: "main",
+			Callees: []string{"run"},
+			Metrics: types.FunctionMetrics{CyclomaticComplexity: 3, SLOC: 12},
+		}},
+	}
+
+	data, err := report.PrettyPrint()
+	require.NoError(t, err)
+	var summary types.Summary
+	require.NoError(t, json.Unmarshal(data, &summary))
+	assert.Equal(t, 1, summary.TotalFunctions)
+	assert.Equal(t, 3, summary.Functions[0].Complexity)
+	assert.NotContains(t, string(data), "callees")
+
+	data, err = report.FullReport()
+	require.NoError(t, err)
+	var full types.AnalysisReport
+	require.NoError(t, json.Unmarshal(data, &full))
+	assert.Equal(t, report, full)
+}
+
+func TestAnalysisReport_Packages(t *testing.T) {
+	report := types.AnalysisReport{
+		Functions: []types.FunctionCall{
+			{Caller: "main", File: "/repo/cmd/app/main.go", Package: "main"},
+			{Caller: "Parse", File: "/repo/lib/parse.go", Package: "lib"},
+			{Caller: "Pad", File: "/repo/util/pad.go", Package: "util"},
+		},
+		Imports: []types.ImportDefinition{
+			{Path: "example.com/repo/lib", Name: "lib", File: "/repo/cmd/app/main.go", Package: "main"},
+			{Path: "example.com/repo/util", Name: "util", File: "/repo/cmd/app/main.go", Package: "main"},
+			{Path: "fmt", Name: "fmt", File: "/repo/cmd/app/main.go", Package: "main"},
+			{Path: "example.com/repo/util", Name: "util", File: "/repo/lib/parse.go", Package: "lib"},
+			{Path: "strings", Name: "strings", File: "/repo/lib/parse.go", Package: "lib"},
+			{Path: "strings", Name: "strings", File: "/repo/lib/format.go", Package: "lib"},
+		},
+	}
+
+	libInstability := 2.0 / 3
+	assert.Equal(t, []types.PackageSummary{
+		{Package: "main", Dir: "/repo/cmd/app", Efferent: 3, Afferent: 0, Instability: 1, Distance: 0},
+		{Package: "lib", Dir: "/repo/lib", Efferent: 2, Afferent: 1, Instability: libInstability, Distance: math.Abs(libInstability - 1)},
+		{Package: "util", Dir: "/repo/util", Efferent: 0, Afferent: 2, Instability: 0, Distance: 1},
+	}, report.Packages())
+}
+
+func TestAnalysisReport_PackagesAbstractness(t *testing.T) {
+	report := types.AnalysisReport{
+		Structs: []types.StructDefinition{
+			{Name: "File", File: "/repo/store/file.go", Package: "store"},
+			{Name: "Memory", File: "/repo/store/memory.go", Package: "store"},
+			{Name: "Options", File: "/repo/store/store.go", Package: "store"},
+		},
+		Interfaces: []types.InterfaceDefinition{
+			{Name: "Store", File: "/repo/store/store.go", Package: "store"},
+		},
+		Imports: []types.ImportDefinition{
+			{Path: "os", Name: "os", File: "/repo/store/file.go", Package: "store"},
+		},
+	}
+
+	packages := report.Packages()
+	require.Len(t, packages, 1)
+	assert.Equal(t, 0.25, packages[0].Abstractness)
+	assert.Equal(t, 1.0, packages[0].Instability)
+	assert.Equal(t, 0.25, packages[0].Distance)
+}